@@ -0,0 +1,283 @@
+package chain
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil/base58"
+	"github.com/btcsuite/btcd/btcutil/bech32"
+	"golang.org/x/crypto/sha3"
+)
+
+var ErrInvalidAddress = errors.New("invalid address")
+
+// AddressError tells why an address is not valid on a chain.
+type AddressError struct {
+	Chain  string
+	Reason string
+}
+
+func (e *AddressError) Error() string {
+	return fmt.Sprintf("invalid %s address: %s", e.Chain, e.Reason)
+}
+
+func (e *AddressError) Is(target error) bool {
+	return target == ErrInvalidAddress
+}
+
+const (
+	base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+	rippleAlphabet = "rpshnaf39wBUDNEGHJKLM4PQRST7VWXYZ2bcdeCg65jkm8oFqi1tuvAxyz"
+)
+
+// mainnet address formats, keyed by the chain of the assets
+var addressValidators = map[string]func(address string) error{
+	"bitcoin":   bitcoinLike("bc", 0x00, 0x05),
+	"litecoin":  bitcoinLike("ltc", 0x30, 0x32, 0x05),
+	"dogecoin":  bitcoinLike("", 0x1e, 0x16),
+	"ethereum":  evmAddress,
+	"bsc":       evmAddress,
+	"polygon":   evmAddress,
+	"avalanche": evmAddress, // C-Chain
+	"solana":    solanaAddress,
+	"tron":      tronAddress,
+	"xrp":       xrpAddress,
+	"cosmos":    cosmosAddress,
+	"aptos":     hexAddress(1),
+	"sui":       hexAddress(64),
+}
+
+// ValidateAddress checks the format and checksum of an address on chainName.
+// Addresses of chains without a validator are taken as they are.
+func ValidateAddress(chainName string, address string) error {
+	validate, ok := addressValidators[chainName]
+	if !ok {
+		return nil
+	}
+	if err := validate(address); err != nil {
+		return &AddressError{Chain: chainName, Reason: err.Error()}
+	}
+	return nil
+}
+
+// bitcoinLike takes legacy Base58Check addresses of the versions given and,
+// when hrp is set, segwit addresses with that prefix.
+func bitcoinLike(hrp string, versions ...byte) func(string) error {
+	return func(address string) error {
+		if hrp != "" && strings.HasPrefix(strings.ToLower(address), hrp+"1") {
+			return segwitAddress(hrp, address)
+		}
+		if prefix, _, _, err := bech32.DecodeGeneric(address); err == nil {
+			if hrp == "" {
+				return fmt.Errorf("segwit address with the %s prefix, expected a Base58Check address", prefix)
+			}
+			return fmt.Errorf("expected the %s prefix, got %s", hrp, prefix)
+		}
+		_, payload, err := base58CheckDecode(address, versions...)
+		if err != nil {
+			return err
+		}
+		if len(payload) != 20 {
+			return fmt.Errorf("expected a 20-byte hash, got %d bytes", len(payload))
+		}
+		return nil
+	}
+}
+
+func base58CheckDecode(address string, versions ...byte) (byte, []byte, error) {
+	if i := strings.IndexFunc(address, func(r rune) bool { return !strings.ContainsRune(base58Alphabet, r) }); i >= 0 {
+		return 0, nil, fmt.Errorf("character %q at position %d is not in the Base58 alphabet", address[i], i+1)
+	}
+	payload, version, err := base58.CheckDecode(address)
+	if errors.Is(err, base58.ErrChecksum) {
+		return 0, nil, errors.New("Base58Check checksum does not match")
+	}
+	if err != nil {
+		return 0, nil, errors.New("too short for a Base58Check address")
+	}
+	if !slices.Contains(versions, version) {
+		return 0, nil, fmt.Errorf("unexpected version byte 0x%02x", version)
+	}
+	return version, payload, nil
+}
+
+// segwitAddress follows BIP173 and BIP350: witness version 0 is encoded with
+// Bech32 and later versions with Bech32m.
+func segwitAddress(hrp string, address string) error {
+	prefix, data, encoding, err := bech32.DecodeGeneric(address)
+	if err != nil {
+		return bech32Error(err)
+	}
+	if prefix != hrp {
+		return fmt.Errorf("expected the %s prefix", hrp)
+	}
+	if len(data) == 0 {
+		return errors.New("missing witness version")
+	}
+	witnessVersion := data[0]
+	program, err := bech32.ConvertBits(data[1:], 5, 8, false)
+	if err != nil {
+		return errors.New("invalid witness program padding")
+	}
+
+	switch {
+	case witnessVersion > 16:
+		return fmt.Errorf("unknown witness version %d", witnessVersion)
+	case len(program) < 2 || len(program) > 40:
+		return fmt.Errorf("witness program of %d bytes, expected 2 to 40", len(program))
+	case witnessVersion == 0 && len(program) != 20 && len(program) != 32:
+		return fmt.Errorf("version 0 witness program of %d bytes, expected 20 or 32", len(program))
+	case witnessVersion == 0 && encoding != bech32.Version0:
+		return errors.New("version 0 addresses are encoded with Bech32, not Bech32m")
+	case witnessVersion != 0 && encoding != bech32.VersionM:
+		return fmt.Errorf("version %d addresses are encoded with Bech32m, not Bech32", witnessVersion)
+	}
+	return nil
+}
+
+// bech32Error leaves out the address the checksum would match, which is most
+// likely the typo itself.
+func bech32Error(err error) error {
+	var checksum bech32.ErrInvalidChecksum
+	var mixedCase bech32.ErrMixedCase
+	var character bech32.ErrInvalidCharacter
+	switch {
+	case errors.As(err, &checksum):
+		return errors.New("Bech32 checksum does not match")
+	case errors.As(err, &mixedCase):
+		return errors.New("mixes upper and lower case")
+	case errors.As(err, &character):
+		return fmt.Errorf("character %q is not in the Bech32 alphabet", rune(character))
+	}
+	return fmt.Errorf("not a Bech32 address: %v", err)
+}
+
+// evmAddress takes all lower or all upper case addresses, which carry no
+// checksum, and mixed case ones with a valid EIP-55 checksum.
+func evmAddress(address string) error {
+	digits, err := hexDigits(address)
+	if err != nil {
+		return err
+	}
+	if len(digits) != 40 {
+		return fmt.Errorf("expected 40 hex digits after 0x, got %d", len(digits))
+	}
+	lower := strings.ToLower(digits)
+	if digits == lower || digits == strings.ToUpper(digits) {
+		return nil
+	}
+
+	hash := sha3.NewLegacyKeccak256()
+	hash.Write([]byte(lower))
+	sum := hash.Sum(nil)
+	for i, c := range digits {
+		if c >= '0' && c <= '9' {
+			continue
+		}
+		nibble := sum[i/2] >> 4
+		if i%2 == 1 {
+			nibble = sum[i/2] & 0x0f
+		}
+		if (nibble >= 8) != (c >= 'A' && c <= 'F') {
+			return errors.New("EIP-55 checksum does not match")
+		}
+	}
+	return nil
+}
+
+// hexAddress takes 0x and up to 64 hex digits, at least minDigits of them.
+func hexAddress(minDigits int) func(string) error {
+	return func(address string) error {
+		digits, err := hexDigits(address)
+		if err != nil {
+			return err
+		}
+		if len(digits) < minDigits || len(digits) > 64 {
+			if minDigits == 64 {
+				return fmt.Errorf("expected 64 hex digits after 0x, got %d", len(digits))
+			}
+			return fmt.Errorf("expected %d to 64 hex digits after 0x, got %d", minDigits, len(digits))
+		}
+		return nil
+	}
+}
+
+func hexDigits(address string) (string, error) {
+	digits, ok := strings.CutPrefix(address, "0x")
+	if !ok {
+		return "", errors.New("must start with 0x")
+	}
+	if i := strings.IndexFunc(digits, func(r rune) bool { return !strings.ContainsRune("0123456789abcdefABCDEF", r) }); i >= 0 {
+		return "", fmt.Errorf("character %q at position %d is not a hex digit", digits[i], i+3)
+	}
+	return digits, nil
+}
+
+// solanaAddress is a Base58 public key, without a checksum.
+func solanaAddress(address string) error {
+	if i := strings.IndexFunc(address, func(r rune) bool { return !strings.ContainsRune(base58Alphabet, r) }); i >= 0 {
+		return fmt.Errorf("character %q at position %d is not in the Base58 alphabet", address[i], i+1)
+	}
+	if decoded := base58.Decode(address); len(decoded) != 32 {
+		return fmt.Errorf("expected a 32-byte public key, got %d bytes", len(decoded))
+	}
+	return nil
+}
+
+func tronAddress(address string) error {
+	_, payload, err := base58CheckDecode(address, 0x41)
+	if err != nil {
+		return err
+	}
+	if len(payload) != 20 {
+		return fmt.Errorf("expected a 20-byte hash, got %d bytes", len(payload))
+	}
+	return nil
+}
+
+// xrpAddress takes classic and X-addresses, Base58Check with the alphabet of
+// the XRP Ledger.
+func xrpAddress(address string) error {
+	if i := strings.IndexFunc(address, func(r rune) bool { return !strings.ContainsRune(rippleAlphabet, r) }); i >= 0 {
+		return fmt.Errorf("character %q at position %d is not in the XRP Ledger alphabet", address[i], i+1)
+	}
+	translated := strings.Map(func(r rune) rune { return rune(base58Alphabet[strings.IndexRune(rippleAlphabet, r)]) }, address)
+	version, payload, err := base58CheckDecode(translated, 0x00, 0x05)
+	if err != nil {
+		return err
+	}
+	// X-addresses start with 0x05 0x44 and carry a destination tag
+	if version == 0x05 {
+		if len(payload) != 30 || payload[0] != 0x44 {
+			return errors.New("malformed X-address")
+		}
+		return nil
+	}
+	if len(payload) != 20 {
+		return fmt.Errorf("expected a 20-byte account ID, got %d bytes", len(payload))
+	}
+	return nil
+}
+
+func cosmosAddress(address string) error {
+	prefix, data, encoding, err := bech32.DecodeGeneric(address)
+	if err != nil {
+		return bech32Error(err)
+	}
+	if prefix != "cosmos" {
+		return errors.New("expected the cosmos prefix")
+	}
+	if encoding != bech32.Version0 {
+		return errors.New("encoded with Bech32m, expected Bech32")
+	}
+	account, err := bech32.ConvertBits(data, 5, 8, false)
+	if err != nil {
+		return errors.New("invalid account padding")
+	}
+	if len(account) != 20 && len(account) != 32 {
+		return fmt.Errorf("expected a 20 or 32-byte account, got %d bytes", len(account))
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package chain
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+	"wallet-manager/upstream"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	satoshiExponent = -8
+	// Esplora lists 25 confirmed transactions per page
+	esploraPageSize = 25
+	esploraMaxPages = 40
+)
+
+// Esplora reads Bitcoin-like chains through an Esplora API, as served by
+// blockstream.info, mempool.space or a local electrs.
+type Esplora struct {
+	client  *upstream.Client
+	baseURL string
+}
+
+func NewEsplora(client *upstream.Client, baseURL string) *Esplora {
+	return &Esplora{client: client, baseURL: baseURL}
+}
+
+func (e *Esplora) Name() string {
+	return "esplora"
+}
+
+type esploraAddress struct {
+	ChainStats struct {
+		Funded int64 `json:"funded_txo_sum"`
+		Spent  int64 `json:"spent_txo_sum"`
+	} `json:"chain_stats"`
+}
+
+type esploraOutput struct {
+	Address string `json:"scriptpubkey_address"`
+	Value   int64  `json:"value"`
+}
+
+type esploraTx struct {
+	TxID   string `json:"txid"`
+	Status struct {
+		Confirmed bool  `json:"confirmed"`
+		BlockTime int64 `json:"block_time"`
+	} `json:"status"`
+	Vin []struct {
+		Prevout *esploraOutput `json:"prevout"`
+	} `json:"vin"`
+	Vout []esploraOutput `json:"vout"`
+}
+
+// Balance is the confirmed balance of address.
+func (e *Esplora) Balance(ctx context.Context, address string) (decimal.Decimal, error) {
+	var result esploraAddress
+	if err := getJSON(ctx, e.client, fmt.Sprintf("%s/address/%s", e.baseURL, url.PathEscape(address)), &result); err != nil {
+		return decimal.Zero, err
+	}
+	return decimal.New(result.ChainStats.Funded-result.ChainStats.Spent, satoshiExponent), nil
+}
+
+// Transfers nets the outputs paid to address against the inputs it spent in
+// each confirmed transaction, newest first. Addresses with more than 1000
+// transactions are cut at the oldest.
+func (e *Esplora) Transfers(ctx context.Context, address string) ([]Transfer, error) {
+	endpoint := fmt.Sprintf("%s/address/%s/txs/chain", e.baseURL, url.PathEscape(address))
+
+	var transfers []Transfer
+	for page, lastSeen := 0, ""; page < esploraMaxPages; page++ {
+		pageURL := endpoint
+		if lastSeen != "" {
+			pageURL += "/" + lastSeen
+		}
+		var txs []esploraTx
+		if err := getJSON(ctx, e.client, pageURL, &txs); err != nil {
+			return nil, err
+		}
+
+		for _, tx := range txs {
+			if !tx.Status.Confirmed {
+				continue
+			}
+			var net int64
+			for _, out := range tx.Vout {
+				if out.Address == address {
+					net += out.Value
+				}
+			}
+			for _, in := range tx.Vin {
+				if in.Prevout != nil && in.Prevout.Address == address {
+					net -= in.Prevout.Value
+				}
+			}
+			if net != 0 {
+				transfers = append(transfers, Transfer{
+					TxID:    tx.TxID,
+					Address: address,
+					Time:    time.Unix(tx.Status.BlockTime, 0).UTC(),
+					Amount:  decimal.New(net, satoshiExponent),
+				})
+			}
+		}
+
+		if len(txs) < esploraPageSize {
+			break
+		}
+		lastSeen = txs[len(txs)-1].TxID
+	}
+	return transfers, nil
+}
@@ -0,0 +1,244 @@
+package chain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+	"wallet-manager/upstream"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	weiExponent = -18
+	// blocks read per JSON-RPC batch
+	evmBatchSize = 100
+)
+
+// EVM reads an Ethereum-like chain through the standard JSON-RPC API of a node,
+// so it runs against a local stand-in such as anvil or hardhat as well.
+// Nodes do not index transactions by address, so Transfers scans the last
+// scanBlocks blocks; transfers made by contracts are not seen.
+type EVM struct {
+	client     *upstream.Client
+	url        string
+	scanBlocks int
+}
+
+func NewEVM(client *upstream.Client, url string, scanBlocks int) *EVM {
+	return &EVM{client: client, url: url, scanBlocks: scanBlocks}
+}
+
+func (e *EVM) Name() string {
+	return "evm"
+}
+
+type rpcRequest struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID      int    `json:"id"`
+	Method  string `json:"method"`
+	Params  []any  `json:"params"`
+}
+
+type rpcResponse struct {
+	ID     int             `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type evmBlock struct {
+	Timestamp    string `json:"timestamp"`
+	Transactions []struct {
+		Hash  string `json:"hash"`
+		From  string `json:"from"`
+		To    string `json:"to"`
+		Value string `json:"value"`
+	} `json:"transactions"`
+}
+
+type evmReceipt struct {
+	Status            string `json:"status"`
+	GasUsed           string `json:"gasUsed"`
+	EffectiveGasPrice string `json:"effectiveGasPrice"`
+}
+
+// call sends the requests as one batch and returns their results in order.
+func (e *EVM) call(ctx context.Context, requests []rpcRequest) ([]json.RawMessage, error) {
+	for i := range requests {
+		requests[i].JSONRPC, requests[i].ID = "2.0", i
+	}
+	body, err := json.Marshal(requests)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := e.client.Post(ctx, e.url, "application/json", body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: failed to get data: %s", e.client.Name(), resp.Status)
+	}
+
+	var responses []rpcResponse
+	if err := json.NewDecoder(resp.Body).Decode(&responses); err != nil {
+		return nil, err
+	}
+	results := make([]json.RawMessage, len(requests))
+	for _, response := range responses {
+		if response.ID < 0 || response.ID >= len(results) {
+			continue
+		}
+		if response.Error != nil {
+			return nil, fmt.Errorf("%s: %s: %s", e.client.Name(), requests[response.ID].Method, response.Error.Message)
+		}
+		results[response.ID] = response.Result
+	}
+	for i, result := range results {
+		if result == nil {
+			return nil, fmt.Errorf("%s: no result for %s", e.client.Name(), requests[i].Method)
+		}
+	}
+	return results, nil
+}
+
+func (e *EVM) callOne(ctx context.Context, method string, result any, params ...any) error {
+	results, err := e.call(ctx, []rpcRequest{{Method: method, Params: params}})
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(results[0], result)
+}
+
+func (e *EVM) Balance(ctx context.Context, address string) (decimal.Decimal, error) {
+	var balance string
+	if err := e.callOne(ctx, "eth_getBalance", &balance, address, "latest"); err != nil {
+		return decimal.Zero, err
+	}
+	wei, err := quantity(balance)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return decimal.NewFromBigInt(wei, weiExponent), nil
+}
+
+// Transfers are the value sent to or from address by the transactions of the
+// blocks scanned, oldest first. A failed transaction moves no value but still
+// costs its sender the fee.
+func (e *EVM) Transfers(ctx context.Context, address string) ([]Transfer, error) {
+	var head string
+	if err := e.callOne(ctx, "eth_blockNumber", &head); err != nil {
+		return nil, err
+	}
+	latest, err := quantity(head)
+	if err != nil {
+		return nil, err
+	}
+	first := max(latest.Int64()-int64(e.scanBlocks)+1, 0)
+
+	var transfers []Transfer
+	for start := first; start <= latest.Int64(); start += evmBatchSize {
+		end := min(start+evmBatchSize-1, latest.Int64())
+		requests := make([]rpcRequest, 0, end-start+1)
+		for number := start; number <= end; number++ {
+			requests = append(requests, rpcRequest{Method: "eth_getBlockByNumber", Params: []any{fmt.Sprintf("0x%x", number), true}})
+		}
+		results, err := e.call(ctx, requests)
+		if err != nil {
+			return nil, err
+		}
+
+		found, err := e.blockTransfers(ctx, address, results)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, found...)
+	}
+	return transfers, nil
+}
+
+func (e *EVM) blockTransfers(ctx context.Context, address string, blocks []json.RawMessage) ([]Transfer, error) {
+	var transfers []Transfer
+	var receipts []rpcRequest
+	var values []*big.Int
+	var sent []bool
+	for _, raw := range blocks {
+		var block evmBlock
+		if err := json.Unmarshal(raw, &block); err != nil {
+			return nil, err
+		}
+		timestamp, err := quantity(block.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+		for _, tx := range block.Transactions {
+			from, to := strings.EqualFold(tx.From, address), strings.EqualFold(tx.To, address)
+			if !from && !to {
+				continue
+			}
+			value, err := quantity(tx.Value)
+			if err != nil {
+				return nil, err
+			}
+			if from && to {
+				value = new(big.Int)
+			}
+			transfers = append(transfers, Transfer{TxID: tx.Hash, Address: address, Time: time.Unix(timestamp.Int64(), 0).UTC()})
+			receipts = append(receipts, rpcRequest{Method: "eth_getTransactionReceipt", Params: []any{tx.Hash}})
+			values = append(values, value)
+			sent = append(sent, from)
+		}
+	}
+	if len(receipts) == 0 {
+		return nil, nil
+	}
+
+	results, err := e.call(ctx, receipts)
+	if err != nil {
+		return nil, err
+	}
+	for i, raw := range results {
+		var receipt evmReceipt
+		if err := json.Unmarshal(raw, &receipt); err != nil {
+			return nil, err
+		}
+		amount := new(big.Int)
+		if receipt.Status == "0x1" {
+			amount.Set(values[i])
+		}
+		if sent[i] {
+			gasUsed, err := quantity(receipt.GasUsed)
+			if err != nil {
+				return nil, err
+			}
+			gasPrice, err := quantity(receipt.EffectiveGasPrice)
+			if err != nil {
+				return nil, err
+			}
+			amount.Neg(amount.Add(amount, gasUsed.Mul(gasUsed, gasPrice)))
+		}
+		transfers[i].Amount = decimal.NewFromBigInt(amount, weiExponent)
+	}
+	return transfers, nil
+}
+
+// quantity decodes a JSON-RPC hex quantity such as "0x1b4".
+func quantity(hex string) (*big.Int, error) {
+	digits := strings.TrimPrefix(hex, "0x")
+	if digits == "" {
+		return new(big.Int), nil
+	}
+	value, ok := new(big.Int).SetString(digits, 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid quantity %q", hex)
+	}
+	return value, nil
+}
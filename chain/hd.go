@@ -0,0 +1,140 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// Script types of the addresses derived from an extended key.
+const (
+	ScriptP2PKH      = "p2pkh"       // BIP44, xpub
+	ScriptP2SHP2WPKH = "p2sh-p2wpkh" // BIP49, ypub
+	ScriptP2WPKH     = "p2wpkh"      // BIP84, zpub
+)
+
+// Branches of an account: coins are received on the external one and change
+// comes back on the internal one.
+const (
+	BranchReceive uint32 = 0
+	BranchChange  uint32 = 1
+)
+
+var ErrInvalidExtendedKey = errors.New("invalid extended public key")
+
+// mainnet versions of the public keys and the script type they stand for
+var keyVersions = map[[4]byte]string{
+	{0x04, 0x88, 0xb2, 0x1e}: ScriptP2PKH,
+	{0x04, 0x9d, 0x7c, 0xb2}: ScriptP2SHP2WPKH,
+	{0x04, 0xb2, 0x47, 0x46}: ScriptP2WPKH,
+}
+
+// ExtendedKey is the public key of a Bitcoin account, which derives its
+// addresses without the private key, per BIP32.
+type ExtendedKey struct {
+	key        *hdkeychain.ExtendedKey
+	ScriptType string
+}
+
+// ParseExtendedKey reads an xpub, ypub or zpub. The script type is the one of
+// the prefix unless scriptType is given, for wallets that export an xpub
+// whatever the script.
+func ParseExtendedKey(encoded string, scriptType string) (*ExtendedKey, error) {
+	key, err := hdkeychain.NewKeyFromString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidExtendedKey, err)
+	}
+	if key.IsPrivate() {
+		return nil, fmt.Errorf("%w: this is a private key, give its public key instead", ErrInvalidExtendedKey)
+	}
+	prefixType, ok := keyVersions[[4]byte(key.Version())]
+	if !ok {
+		return nil, fmt.Errorf("%w: expected a mainnet xpub, ypub or zpub", ErrInvalidExtendedKey)
+	}
+
+	switch scriptType {
+	case "":
+		scriptType = prefixType
+	case ScriptP2PKH, ScriptP2SHP2WPKH, ScriptP2WPKH:
+	default:
+		return nil, fmt.Errorf("%w: unknown script type %q", ErrInvalidExtendedKey, scriptType)
+	}
+	return &ExtendedKey{key: key, ScriptType: scriptType}, nil
+}
+
+// Address derives the address at index of branch, the path branch/index below
+// the key.
+func (k *ExtendedKey) Address(branch uint32, index uint32) (string, error) {
+	if index >= hdkeychain.HardenedKeyStart {
+		return "", fmt.Errorf("address index %d out of range", index)
+	}
+	child, err := k.key.Derive(branch)
+	if err != nil {
+		return "", err
+	}
+	if child, err = child.Derive(index); err != nil {
+		return "", err
+	}
+	publicKey, err := child.ECPubKey()
+	if err != nil {
+		return "", err
+	}
+	hash := btcutil.Hash160(publicKey.SerializeCompressed())
+
+	var address btcutil.Address
+	switch k.ScriptType {
+	case ScriptP2PKH:
+		address, err = btcutil.NewAddressPubKeyHash(hash, &chaincfg.MainNetParams)
+	case ScriptP2SHP2WPKH:
+		// the witness program is nested in a pay-to-script-hash
+		redeemScript := append([]byte{0x00, 0x14}, hash...)
+		address, err = btcutil.NewAddressScriptHash(redeemScript, &chaincfg.MainNetParams)
+	default:
+		address, err = btcutil.NewAddressWitnessPubKeyHash(hash, &chaincfg.MainNetParams)
+	}
+	if err != nil {
+		return "", err
+	}
+	return address.EncodeAddress(), nil
+}
+
+// DerivedAddress is an address of an extended key.
+type DerivedAddress struct {
+	Address string
+	Branch  uint32
+	Index   uint32
+}
+
+// Path is where the address is below the key, as in 0/5.
+func (d DerivedAddress) Path() string {
+	return fmt.Sprintf("%d/%d", d.Branch, d.Index)
+}
+
+// Discover derives the addresses of branch from index from onwards and returns
+// the ones with transfers, stopping after gapLimit unused addresses in a row,
+// per BIP44. next is the index after the last address used, or from when none
+// was.
+func Discover(ctx context.Context, indexer Indexer, key *ExtendedKey, branch uint32, from uint32, gapLimit uint32) (used []DerivedAddress, next uint32, err error) {
+	next = from
+	for index, unused := from, uint32(0); unused < gapLimit; index++ {
+		address, err := key.Address(branch, index)
+		if err != nil {
+			return nil, 0, err
+		}
+		transfers, err := indexer.Transfers(ctx, address)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s: %w", address, err)
+		}
+		if len(transfers) == 0 {
+			unused++
+			continue
+		}
+		used = append(used, DerivedAddress{Address: address, Branch: branch, Index: index})
+		next, unused = index+1, 0
+	}
+	return used, next, nil
+}
@@ -0,0 +1,43 @@
+package chain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+	"wallet-manager/upstream"
+
+	"github.com/shopspring/decimal"
+)
+
+// Transfer is a confirmed movement of the native coin of a chain in or out of
+// an address. Amount is positive when the address received coins and
+// negative when it sent them, fees included.
+type Transfer struct {
+	TxID    string          `json:"txId"`
+	Address string          `json:"address"`
+	Time    time.Time       `json:"time"`
+	Amount  decimal.Decimal `json:"amount"`
+}
+
+// Indexer reads addresses of a chain. Amounts are in whole coins.
+type Indexer interface {
+	Name() string
+	Balance(ctx context.Context, address string) (decimal.Decimal, error)
+	Transfers(ctx context.Context, address string) ([]Transfer, error)
+}
+
+func getJSON(ctx context.Context, client *upstream.Client, url string, result any) error {
+	resp, err := client.Get(ctx, url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: failed to get data: %s", client.Name(), resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
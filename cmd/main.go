@@ -1,42 +1,248 @@
 package main
 
 import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+	"wallet-manager/chain"
 	"wallet-manager/config"
 	db "wallet-manager/database"
+	"wallet-manager/events"
 	"wallet-manager/handlers"
+	"wallet-manager/jobs"
+	"wallet-manager/middlewares"
+	"wallet-manager/models"
+	"wallet-manager/notifiers"
+	"wallet-manager/prices"
 	"wallet-manager/repositories"
 	"wallet-manager/services"
+	"wallet-manager/stream"
+	"wallet-manager/upstream"
+	"wallet-manager/validators"
+	"wallet-manager/webhooks"
 
 	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
 )
 
 func main() {
 	cfg := config.LoadConfig()
 	database := db.NewDB(&cfg.DB)
 
+	assetService := services.NewAssetService(repositories.NewAssetRepository(database))
+	assetHandler := handlers.NewAssetHandler(assetService)
+	if err := assetService.Seed(context.Background(), db.AssetSeed); err != nil {
+		log.Fatalf("Failed to seed assets: %v", err)
+	}
+
+	coinGeckoClient := upstream.NewClient("coingecko", &cfg.CoinGecko)
+	coinCapClient := upstream.NewClient("coincap", &cfg.CoinCap)
+	binanceClient := upstream.NewClient("binance", &cfg.Binance)
+	ptaxClient := upstream.NewClient("ptax", &cfg.PTAX)
+	esploraClient := upstream.NewClient("esplora", &cfg.Esplora)
+	evmClient := upstream.NewClient("evm-rpc", &cfg.EVMRPC)
+	coinGecko := prices.NewCoinGecko(coinGeckoClient, cfg.CoinGecko.BaseURL)
+	healthHandler := handlers.NewHealthHandler(coinGeckoClient, coinCapClient, binanceClient, ptaxClient, esploraClient, evmClient)
+
+	providers := map[string]prices.Provider{
+		"coingecko": coinGecko,
+		"coincap":   prices.NewCoinCap(coinCapClient, cfg.CoinCap.BaseURL),
+		"binance":   prices.NewBinance(binanceClient, cfg.Binance.BaseURL),
+		"file":      prices.NewFile(cfg.PriceFile),
+	}
+	var priceProviders []prices.Provider
+	for _, name := range cfg.PriceProviders {
+		provider, ok := providers[name]
+		if !ok {
+			log.Fatalf("Unknown price provider %q", name)
+		}
+		priceProviders = append(priceProviders, provider)
+	}
+	aggregator, err := prices.NewAggregator(cfg.PriceAggregation, decimal.NewFromFloat(cfg.PriceOutlierThreshold), assetService, priceProviders...)
+	if err != nil {
+		log.Fatalf("Failed to set up price providers: %v", err)
+	}
+
+	if err := validators.Register(assetService.IsKnown); err != nil {
+		log.Fatalf("Failed to register validators: %v", err)
+	}
+
+	transactor := repositories.NewTransactor(database)
+
+	auditRepo := repositories.NewAuditRepository(database)
+	auditService := services.NewAuditService(auditRepo)
+	auditHandler := handlers.NewAuditHandler(auditService)
+
+	outboxRepo := repositories.NewOutboxRepository(database)
+	bus := events.NewBus(outboxRepo, transactor)
+
+	webhookService := services.NewWebhookService(repositories.NewWebhookRepository(database), webhooks.NewSender(&http.Client{Timeout: 10 * time.Second}))
+	webhookHandler := handlers.NewWebhookHandler(webhookService)
+	bus.Subscribe("webhooks", webhookService.Enqueue, models.WebhookEventTypes...)
+	go jobs.Run(context.Background(), "webhook-delivery", cfg.WebhookDeliveryInterval, webhookService.Deliver)
+
 	cryptoRepo := repositories.NewCryptocurrencyRepository(database)
-	cryptoService := services.NewCryptocurrencyService(cryptoRepo)
+	transactionRepo := repositories.NewCryptoTransactionRepository(database)
+
+	cryptoService := services.NewCryptocurrencyService(cryptoRepo, transactionRepo, assetService, transactor, auditService, bus)
 	cryptoHandler := handlers.NewCryptocurrencyHandler(cryptoService)
 
-	transactionRepo := repositories.NewCryptoTransactionRepository(database)
-	transationService := services.NewCryptoTransactionService(transactionRepo, cryptoService)
+	transationService := services.NewCryptoTransactionService(transactionRepo, cryptoService, assetService, transactor, auditService, bus)
 	transactionHandler := handlers.NewCryptoTransactionHandler(transationService)
 
+	taxService := services.NewTaxService(cryptoRepo, transactionRepo, assetService, prices.NewPTAX(ptaxClient, cfg.PTAX.BaseURL))
+	reportHandler := handlers.NewReportHandler(taxService)
+
+	trashService := services.NewTrashService(cryptoRepo, transactionRepo, transactor, auditService)
+	trashHandler := handlers.NewTrashHandler(trashService)
+	go jobs.Run(context.Background(), "trash-purge", time.Hour, func(ctx context.Context) error {
+		return trashService.Purge(ctx, cfg.TrashRetention)
+	})
+
+	channels := map[string]notifiers.Notifier{
+		models.AlertChannelWebhook: notifiers.NewWebhookNotifier(&http.Client{Timeout: 10 * time.Second}),
+	}
+	if cfg.SMTP.Addr != "" {
+		channels[models.AlertChannelEmail] = notifiers.NewEmailNotifier(&cfg.SMTP)
+	}
+
+	priceRepo := repositories.NewCryptoPriceRepository(database)
+	priceService := services.NewPriceService(priceRepo, func(ctx context.Context, names []string) (map[string]prices.Price, error) {
+		return aggregator.Get(ctx, names, "usd")
+	}, transactor, bus)
+	alertService := services.NewAlertService(repositories.NewAlertRuleRepository(database), priceRepo, cryptoRepo, assetService, channels)
+	alertHandler := handlers.NewAlertHandler(alertService)
+	// a failing rule stays armed and is retried on the next refresh, so there is
+	// no point in redelivering the event
+	bus.Subscribe("alerts", func(ctx context.Context, _ events.Event) error {
+		if err := alertService.Evaluate(ctx); err != nil {
+			log.Printf("alert evaluation failed: %v", err)
+		}
+		return nil
+	}, models.EventPricesRefreshed, models.EventHoldingBalanceChanged)
+	go jobs.Run(context.Background(), "price-refresh", cfg.PriceRefreshInterval, priceService.Refresh)
+
+	dcaService := services.NewDCAService(repositories.NewDCAPlanRepository(database), cryptoRepo, transactionRepo, priceRepo, transationService, transactor)
+	dcaHandler := handlers.NewDCAHandler(dcaService)
+	go jobs.Run(context.Background(), "dca", cfg.DCAInterval, dcaService.Run)
+
+	portfolioService := services.NewPortfolioService(repositories.NewPortfolioTargetRepository(database), repositories.NewPortfolioSnapshotRepository(database), cryptoRepo, transactionRepo, priceRepo, assetService, taxService, coinGecko, transactor, cfg.RiskFreeRate)
+	portfolioHandler := handlers.NewPortfolioHandler(portfolioService)
+	go jobs.Run(context.Background(), "portfolio-snapshot", cfg.SnapshotInterval, portfolioService.Snapshot)
+
+	indexers := map[string]chain.Indexer{
+		"bitcoin":  chain.NewEsplora(esploraClient, cfg.Esplora.BaseURL),
+		"ethereum": chain.NewEVM(evmClient, cfg.EVMRPC.BaseURL, cfg.EVMScanBlocks),
+	}
+	addressService := services.NewAddressService(repositories.NewWatchedAddressRepository(database), repositories.NewExtendedKeyRepository(database), cryptoRepo, transactionRepo, priceRepo, assetService, indexers, transactor)
+	addressHandler := handlers.NewAddressHandler(addressService)
+	go jobs.Run(context.Background(), "extended-key-scan", cfg.KeyScanInterval, addressService.ScanAll)
+
+	reconciliationService := services.NewReconciliationService(repositories.NewReconciliationRepository(database), cryptoRepo, transactionRepo, cryptoService, addressService, transactor, auditService, bus)
+	reconciliationHandler := handlers.NewReconciliationHandler(reconciliationService)
+	go jobs.Run(context.Background(), "reconciliation", cfg.ReconcileInterval, reconciliationService.Run)
+
+	priceStores := []prices.Store{prices.NewMemoryStore()}
+	if cfg.PriceCacheBackend == "postgres" {
+		priceStores = append(priceStores, repositories.NewPriceCacheRepository(database))
+	}
+	priceCache := prices.NewCache(aggregator.GetQuotes, cfg.PriceCacheTTL, cfg.PriceCacheStaleWindow, priceStores...)
+	priceHandler := handlers.NewPriceHandler(priceCache)
+
+	hub := stream.NewHub(cfg.StreamBufferSize)
+	streamService := services.NewStreamService(hub, cryptoRepo, priceRepo)
+	streamHandler := handlers.NewStreamHandler(hub, streamService, cfg.StreamHeartbeat)
+	bus.Subscribe("stream", streamService.Handle, models.EventPriceUpdated, models.EventPricesRefreshed, models.EventHoldingBalanceChanged)
+
+	go jobs.Run(context.Background(), "outbox-dispatch", cfg.OutboxDispatchInterval, bus.Dispatch)
+	go jobs.Run(context.Background(), "outbox-cleanup", time.Hour, func(ctx context.Context) error {
+		_, err := outboxRepo.DeleteDispatched(ctx, time.Now().Add(-cfg.OutboxRetention).UTC())
+		return err
+	})
+
+	idempotencyRepo := repositories.NewIdempotencyKeyRepository(database)
+	go jobs.Run(context.Background(), "idempotency-key-cleanup", time.Hour, func(ctx context.Context) error {
+		_, err := idempotencyRepo.DeleteExpired(ctx)
+		return err
+	})
+
 	r := gin.Default()
+	r.Use(middlewares.Actor())
+	r.Use(middlewares.Idempotency(idempotencyRepo, cfg.IdempotencyTTL))
+
+	r.GET("/health", healthHandler.Get)
 
 	r.POST("/cryptocurrencies", cryptoHandler.Create)
 	r.GET("/cryptocurrencies", cryptoHandler.GetAll)
 	r.GET("/cryptocurrencies/:cryptoId", cryptoHandler.GetByID)
-	r.PUT("/cryptocurrencies", cryptoHandler.Update)
+	r.PUT("/cryptocurrencies/:cryptoId", cryptoHandler.Update)
+	r.PATCH("/cryptocurrencies/:cryptoId", cryptoHandler.Patch)
 	r.DELETE("/cryptocurrencies/:cryptoId", cryptoHandler.Delete)
+	r.POST("/cryptocurrencies/:cryptoId/restore", cryptoHandler.Restore)
+
+	r.POST("/cryptocurrencies/:cryptoId/addresses", addressHandler.Create)
+	r.GET("/cryptocurrencies/:cryptoId/addresses", addressHandler.GetAll)
+	r.DELETE("/cryptocurrencies/:cryptoId/addresses/:addressId", addressHandler.Delete)
+	r.GET("/cryptocurrencies/:cryptoId/onchain", addressHandler.OnChain)
+	r.POST("/cryptocurrencies/:cryptoId/keys", addressHandler.CreateKey)
+	r.GET("/cryptocurrencies/:cryptoId/keys", addressHandler.GetKeys)
+	r.DELETE("/cryptocurrencies/:cryptoId/keys/:keyId", addressHandler.DeleteKey)
+	r.POST("/cryptocurrencies/:cryptoId/keys/:keyId/scan", addressHandler.Scan)
+
+	r.GET("/prices", priceHandler.GetMultiplePrices)
+
+	r.GET("/stream", streamHandler.SSE)
+	r.GET("/ws", streamHandler.WebSocket)
 
-	r.GET("/prices", cryptoHandler.GetMultiplePrices)
+	r.GET("/assets", assetHandler.Search)
+	r.GET("/assets/:assetId", assetHandler.GetByID)
 
 	r.POST("/cryptocurrencies/:cryptoId/transactions", transactionHandler.Create)
 	r.GET("/cryptocurrencies/:cryptoId/transactions", transactionHandler.GetAll)
 	r.GET("/cryptocurrencies/:cryptoId/transactions/:transactionId", transactionHandler.GetByID)
 	r.PUT("/cryptocurrencies/:cryptoId/transactions/:transactionId", transactionHandler.Update)
+	r.PATCH("/cryptocurrencies/:cryptoId/transactions/:transactionId", transactionHandler.Patch)
 	r.DELETE("/cryptocurrencies/:cryptoId/transactions/:transactionId", transactionHandler.Delete)
+	r.POST("/cryptocurrencies/:cryptoId/transactions/:transactionId/restore", transactionHandler.Restore)
+
+	r.POST("/dca-plans", dcaHandler.Create)
+	r.GET("/dca-plans", dcaHandler.GetAll)
+	r.GET("/dca-plans/:planId", dcaHandler.GetByID)
+	r.PUT("/dca-plans/:planId", dcaHandler.Update)
+	r.DELETE("/dca-plans/:planId", dcaHandler.Delete)
+	r.GET("/dca-plans/:planId/executions", dcaHandler.Executions)
+	r.POST("/dca-plans/:planId/skip-next", dcaHandler.SkipNext)
+	r.POST("/dca-plans/:planId/executions/:transactionId/confirm", dcaHandler.Confirm)
+	r.POST("/dca-plans/:planId/executions/:transactionId/skip", dcaHandler.Skip)
+
+	r.GET("/portfolio/targets", portfolioHandler.GetTargets)
+	r.PUT("/portfolio/targets", portfolioHandler.SetTargets)
+	r.GET("/portfolio/rebalance", portfolioHandler.Rebalance)
+	r.GET("/portfolio/benchmark", portfolioHandler.Benchmark)
+	r.GET("/portfolio/risk", portfolioHandler.Risk)
+
+	r.GET("/reports/tax", reportHandler.Tax)
+	r.GET("/reports/tax/br", reportHandler.BrazilTax)
+
+	r.GET("/trash", trashHandler.GetAll)
+
+	r.GET("/reconciliation", reconciliationHandler.Get)
+	r.POST("/reconciliation/:cryptoId/adjust", reconciliationHandler.Adjust)
+
+	r.GET("/audit", auditHandler.Find)
+
+	r.POST("/alerts", alertHandler.Create)
+	r.GET("/alerts", alertHandler.GetAll)
+	r.GET("/alerts/:alertId", alertHandler.GetByID)
+	r.PUT("/alerts/:alertId", alertHandler.Update)
+	r.DELETE("/alerts/:alertId", alertHandler.Delete)
+
+	r.POST("/webhooks", webhookHandler.Create)
+	r.GET("/webhooks", webhookHandler.GetAll)
+	r.GET("/webhooks/:webhookId", webhookHandler.GetByID)
+	r.DELETE("/webhooks/:webhookId", webhookHandler.Delete)
+	r.GET("/webhooks/:webhookId/deliveries", webhookHandler.GetDeliveries)
 
 	r.Run(":" + cfg.Port)
 }
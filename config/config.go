@@ -3,13 +3,51 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	DB   DatabaseConfig
-	Port string
+	DB                    DatabaseConfig
+	SMTP                  SMTPConfig
+	CoinGecko             UpstreamConfig
+	CoinCap               UpstreamConfig
+	Binance               UpstreamConfig
+	PTAX                  UpstreamConfig
+	Esplora               UpstreamConfig
+	EVMRPC                UpstreamConfig
+	Port                  string
+	IdempotencyTTL        time.Duration
+	TrashRetention        time.Duration
+	PriceRefreshInterval  time.Duration
+	DCAInterval           time.Duration
+	SnapshotInterval      time.Duration
+	KeyScanInterval       time.Duration
+	ReconcileInterval     time.Duration
+	PriceCacheTTL         time.Duration
+	PriceCacheStaleWindow time.Duration
+	// PriceCacheBackend is "memory", or "postgres" to share the cache between instances.
+	PriceCacheBackend string
+	// PriceProviders are tried in this order: coingecko, coincap, binance, file.
+	PriceProviders []string
+	// PriceAggregation is "fallback" or "median".
+	PriceAggregation      string
+	PriceOutlierThreshold float64
+	// PriceFile feeds the file provider.
+	PriceFile               string
+	WebhookDeliveryInterval time.Duration
+	OutboxDispatchInterval  time.Duration
+	OutboxRetention         time.Duration
+	// StreamBufferSize is how many messages a streaming client may fall behind before it is dropped.
+	StreamBufferSize int
+	StreamHeartbeat  time.Duration
+	// RiskFreeRate is the annual rate the Sharpe and Sortino ratios measure returns against, 0.04 for 4%.
+	RiskFreeRate float64
+	// EVMScanBlocks is how many of the latest blocks are read for the transfers of an EVM address.
+	EVMScanBlocks int
 }
 
 type DatabaseConfig struct {
@@ -21,6 +59,26 @@ type DatabaseConfig struct {
 	SSLMode  string
 }
 
+// SMTPConfig is used by email alerts; they are disabled when Addr is empty.
+type SMTPConfig struct {
+	Addr     string
+	From     string
+	Username string
+	Password string
+}
+
+// UpstreamConfig tunes the shared client of a third-party API. The rate limit
+// should match the provider quota.
+type UpstreamConfig struct {
+	BaseURL          string
+	Timeout          time.Duration
+	MaxRetries       int
+	RatePerMinute    int
+	Burst            int
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
 func LoadConfig() *Config {
 	err := godotenv.Load()
 	if err != nil {
@@ -36,7 +94,40 @@ func LoadConfig() *Config {
 			DBName:   getEnv("DB_NAME", "mydatabase"),
 			SSLMode:  getEnv("DB_SSLMODE", "disable"),
 		},
-		Port: getEnv("PORT", "8080"),
+		SMTP: SMTPConfig{
+			Addr:     getEnv("SMTP_ADDR", ""),
+			From:     getEnv("SMTP_FROM", "wallet-manager@localhost"),
+			Username: getEnv("SMTP_USERNAME", ""),
+			Password: getEnv("SMTP_PASSWORD", ""),
+		},
+		CoinGecko:               loadUpstreamConfig("COINGECKO", "https://api.coingecko.com/api/v3", 30),
+		CoinCap:                 loadUpstreamConfig("COINCAP", "https://api.coincap.io/v2", 200),
+		Binance:                 loadUpstreamConfig("BINANCE", "https://api.binance.com/api/v3", 300),
+		PTAX:                    loadUpstreamConfig("PTAX", "https://olinda.bcb.gov.br/olinda/servico/PTAX/versao/v1/odata", 60),
+		Esplora:                 loadUpstreamConfig("ESPLORA", "https://blockstream.info/api", 60),
+		EVMRPC:                  loadUpstreamConfig("EVM_RPC", "http://localhost:8545", 600),
+		Port:                    getEnv("PORT", "8080"),
+		IdempotencyTTL:          getEnvDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+		TrashRetention:          getEnvDuration("TRASH_RETENTION", 30*24*time.Hour),
+		PriceRefreshInterval:    getEnvDuration("PRICE_REFRESH_INTERVAL", 5*time.Minute),
+		DCAInterval:             getEnvDuration("DCA_INTERVAL", time.Minute),
+		SnapshotInterval:        getEnvDuration("PORTFOLIO_SNAPSHOT_INTERVAL", time.Hour),
+		KeyScanInterval:         getEnvDuration("EXTENDED_KEY_SCAN_INTERVAL", 15*time.Minute),
+		ReconcileInterval:       getEnvDuration("RECONCILIATION_INTERVAL", time.Hour),
+		PriceCacheTTL:           getEnvDuration("PRICE_CACHE_TTL", time.Minute),
+		PriceCacheStaleWindow:   getEnvDuration("PRICE_CACHE_STALE_WINDOW", 10*time.Minute),
+		PriceCacheBackend:       getEnv("PRICE_CACHE_BACKEND", "memory"),
+		PriceProviders:          strings.Split(getEnv("PRICE_PROVIDERS", "coingecko,coincap,binance"), ","),
+		PriceAggregation:        getEnv("PRICE_AGGREGATION", "fallback"),
+		PriceOutlierThreshold:   getEnvFloat("PRICE_OUTLIER_THRESHOLD", 0.05),
+		PriceFile:               getEnv("PRICE_FILE", "prices.json"),
+		WebhookDeliveryInterval: getEnvDuration("WEBHOOK_DELIVERY_INTERVAL", 10*time.Second),
+		OutboxDispatchInterval:  getEnvDuration("OUTBOX_DISPATCH_INTERVAL", 2*time.Second),
+		OutboxRetention:         getEnvDuration("OUTBOX_RETENTION", 7*24*time.Hour),
+		StreamBufferSize:        getEnvInt("STREAM_BUFFER_SIZE", 64),
+		StreamHeartbeat:         getEnvDuration("STREAM_HEARTBEAT", 15*time.Second),
+		RiskFreeRate:            getEnvFloat("RISK_FREE_RATE", 0),
+		EVMScanBlocks:           getEnvInt("EVM_SCAN_BLOCKS", 1000),
 	}
 }
 
@@ -55,7 +146,40 @@ func LoadConfigTest() *Config {
 			DBName:   getEnv("DB_NAME_TEST", "mydatabase"),
 			SSLMode:  getEnv("DB_SSLMODE_TEST", "disable"),
 		},
-		Port: getEnv("PORT", "8080"),
+		SMTP: SMTPConfig{
+			Addr:     getEnv("SMTP_ADDR", ""),
+			From:     getEnv("SMTP_FROM", "wallet-manager@localhost"),
+			Username: getEnv("SMTP_USERNAME", ""),
+			Password: getEnv("SMTP_PASSWORD", ""),
+		},
+		CoinGecko:               loadUpstreamConfig("COINGECKO", "https://api.coingecko.com/api/v3", 30),
+		CoinCap:                 loadUpstreamConfig("COINCAP", "https://api.coincap.io/v2", 200),
+		Binance:                 loadUpstreamConfig("BINANCE", "https://api.binance.com/api/v3", 300),
+		PTAX:                    loadUpstreamConfig("PTAX", "https://olinda.bcb.gov.br/olinda/servico/PTAX/versao/v1/odata", 60),
+		Esplora:                 loadUpstreamConfig("ESPLORA", "https://blockstream.info/api", 60),
+		EVMRPC:                  loadUpstreamConfig("EVM_RPC", "http://localhost:8545", 600),
+		Port:                    getEnv("PORT", "8080"),
+		IdempotencyTTL:          getEnvDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+		TrashRetention:          getEnvDuration("TRASH_RETENTION", 30*24*time.Hour),
+		PriceRefreshInterval:    getEnvDuration("PRICE_REFRESH_INTERVAL", 5*time.Minute),
+		DCAInterval:             getEnvDuration("DCA_INTERVAL", time.Minute),
+		SnapshotInterval:        getEnvDuration("PORTFOLIO_SNAPSHOT_INTERVAL", time.Hour),
+		KeyScanInterval:         getEnvDuration("EXTENDED_KEY_SCAN_INTERVAL", 15*time.Minute),
+		ReconcileInterval:       getEnvDuration("RECONCILIATION_INTERVAL", time.Hour),
+		PriceCacheTTL:           getEnvDuration("PRICE_CACHE_TTL", time.Minute),
+		PriceCacheStaleWindow:   getEnvDuration("PRICE_CACHE_STALE_WINDOW", 10*time.Minute),
+		PriceCacheBackend:       getEnv("PRICE_CACHE_BACKEND", "memory"),
+		PriceProviders:          strings.Split(getEnv("PRICE_PROVIDERS", "coingecko,coincap,binance"), ","),
+		PriceAggregation:        getEnv("PRICE_AGGREGATION", "fallback"),
+		PriceOutlierThreshold:   getEnvFloat("PRICE_OUTLIER_THRESHOLD", 0.05),
+		PriceFile:               getEnv("PRICE_FILE", "prices.json"),
+		WebhookDeliveryInterval: getEnvDuration("WEBHOOK_DELIVERY_INTERVAL", 10*time.Second),
+		OutboxDispatchInterval:  getEnvDuration("OUTBOX_DISPATCH_INTERVAL", 2*time.Second),
+		OutboxRetention:         getEnvDuration("OUTBOX_RETENTION", 7*24*time.Hour),
+		StreamBufferSize:        getEnvInt("STREAM_BUFFER_SIZE", 64),
+		StreamHeartbeat:         getEnvDuration("STREAM_HEARTBEAT", 15*time.Second),
+		RiskFreeRate:            getEnvFloat("RISK_FREE_RATE", 0),
+		EVMScanBlocks:           getEnvInt("EVM_SCAN_BLOCKS", 1000),
 	}
 }
 
@@ -65,3 +189,51 @@ func getEnv(key string, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func loadUpstreamConfig(prefix string, baseURL string, ratePerMinute int) UpstreamConfig {
+	return UpstreamConfig{
+		BaseURL:          getEnv(prefix+"_URL", baseURL),
+		Timeout:          getEnvDuration(prefix+"_TIMEOUT", 10*time.Second),
+		MaxRetries:       getEnvInt(prefix+"_MAX_RETRIES", 3),
+		RatePerMinute:    getEnvInt(prefix+"_RATE_LIMIT", ratePerMinute),
+		Burst:            getEnvInt(prefix+"_BURST", 5),
+		BreakerThreshold: getEnvInt(prefix+"_BREAKER_THRESHOLD", 5),
+		BreakerCooldown:  getEnvDuration(prefix+"_BREAKER_COOLDOWN", time.Minute),
+	}
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("Invalid number for %s: %v", key, err)
+	}
+	return number
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Fatalf("Invalid number for %s: %v", key, err)
+	}
+	return number
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("Invalid duration for %s: %v", key, err)
+	}
+	return duration
+}
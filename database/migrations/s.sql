@@ -1,18 +1,199 @@
+-- asset registry, seeded from database/seeds/assets.json on startup
+CREATE TABLE asset (
+    asset_id VARCHAR(100) PRIMARY KEY,
+    symbol VARCHAR(20) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    decimals SMALLINT NOT NULL,
+    -- network of the native coin, which indexers are picked by; empty for tokens
+    chain VARCHAR(30) NOT NULL DEFAULT '',
+    -- id of the asset at each price provider, e.g. {"coingecko": "bitcoin", "binance": "BTC"}
+    provider_ids JSONB NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX asset_symbol_idx ON asset (LOWER(symbol));
+
 CREATE TABLE cryptocurrency (
     cryptocurrency_id SERIAL PRIMARY KEY,
+    asset_id VARCHAR(100) NOT NULL REFERENCES asset (asset_id),
     name VARCHAR(100) NOT NULL,
 	balance DECIMAL(30, 18),
 	fiat_balance NUMERIC(14,2),
-    created_date DATE NOT NULL DEFAULT CURRENT_DATE
+    created_date DATE NOT NULL DEFAULT CURRENT_DATE,
+    version INT NOT NULL DEFAULT 1,
+    deleted_at TIMESTAMP
+);
+
+-- single owner for now, so holding names are unique per table; an asset may back several holdings
+-- (e.g. an exchange account and a cold wallet) and trashed holdings do not count
+CREATE UNIQUE INDEX cryptocurrency_name_key ON cryptocurrency (name) WHERE deleted_at IS NULL;
+
+-- recurring purchases; the scheduler adds a planned transaction at each run
+CREATE TABLE dca_plan (
+    plan_id SERIAL PRIMARY KEY,
+    cryptocurrency_id INT NOT NULL,
+    fiat_amount NUMERIC(14,2) NOT NULL,
+    schedule VARCHAR(100) NOT NULL, -- cron expression, evaluated in UTC
+    start_date TIMESTAMP NOT NULL,
+    end_date TIMESTAMP,
+    next_run TIMESTAMP, -- NULL once the plan is over
+    created_date TIMESTAMP NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (cryptocurrency_id) REFERENCES cryptocurrency (cryptocurrency_id) ON DELETE CASCADE
 );
 
+CREATE INDEX dca_plan_next_run_idx ON dca_plan (next_run);
+
 CREATE TABLE crypto_transaction (
     transaction_id SERIAL PRIMARY KEY,
     cryptocurrency_id INT NOT NULL,
+    type VARCHAR(20) NOT NULL DEFAULT 'buy' CHECK (type IN ('buy', 'sell', 'adjustment', 'opening_balance')),
+    -- only confirmed transactions count towards the balance
+    status VARCHAR(10) NOT NULL DEFAULT 'confirmed' CHECK (status IN ('planned', 'confirmed', 'skipped')),
+    plan_id INT REFERENCES dca_plan (plan_id) ON DELETE SET NULL,
+    external_id VARCHAR(255), -- on-chain transaction recorded, see watched_address
+    address VARCHAR(255), -- where a sell was sent or a buy came from
 	cryptocurrency_amount NUMERIC(30, 18),
 	fiat_amount NUMERIC(14,2), -- only dollar at the moment
 	purchase_date TIMESTAMP NOT NULL DEFAULT CURRENT_DATE,
 	created_date TIMESTAMP NOT NULL DEFAULT CURRENT_DATE,
+    version INT NOT NULL DEFAULT 1,
+    deleted_at TIMESTAMP,
+    FOREIGN KEY (cryptocurrency_id) REFERENCES cryptocurrency (cryptocurrency_id) ON DELETE CASCADE
+);
+
+-- an on-chain movement is recorded once per holding
+CREATE UNIQUE INDEX crypto_transaction_external_id_key ON crypto_transaction (cryptocurrency_id, external_id) WHERE external_id IS NOT NULL AND deleted_at IS NULL;
+
+-- the balances of a holding are derived from its transactions: the confirmed
+-- ones outside the trash add up to them, sells taking their amount and
+-- proceeds out; see balanceEffect
+CREATE OR REPLACE FUNCTION derive_balances(holdings INT[])
+returns void
+language sql
+as
+$$
+   UPDATE cryptocurrency c
+   SET balance = l.balance, fiat_balance = l.fiat_balance, version = c.version + 1
+   FROM (
+      SELECT h.id,
+      COALESCE(SUM(CASE WHEN t.type = 'sell' THEN -t.cryptocurrency_amount ELSE t.cryptocurrency_amount END), 0) AS balance,
+      COALESCE(SUM(CASE WHEN t.type = 'sell' THEN -t.fiat_amount ELSE t.fiat_amount END), 0) AS fiat_balance
+      FROM UNNEST(holdings) AS h(id)
+      LEFT JOIN crypto_transaction t ON t.cryptocurrency_id = h.id AND t.status = 'confirmed' AND t.deleted_at IS NULL
+      GROUP BY h.id
+   ) l
+   WHERE c.cryptocurrency_id = l.id AND (c.balance, c.fiat_balance) IS DISTINCT FROM (l.balance, l.fiat_balance);
+$$;
+
+CREATE OR REPLACE FUNCTION derive_transaction_balances()
+returns trigger
+language plpgsql
+as
+$$
+begin
+   IF TG_OP = 'INSERT' THEN
+      PERFORM derive_balances(ARRAY(SELECT DISTINCT cryptocurrency_id FROM new_rows));
+   ELSIF TG_OP = 'UPDATE' THEN
+      PERFORM derive_balances(ARRAY(SELECT cryptocurrency_id FROM old_rows UNION SELECT cryptocurrency_id FROM new_rows));
+   ELSE
+      PERFORM derive_balances(ARRAY(SELECT DISTINCT cryptocurrency_id FROM old_rows));
+   END IF;
+   RETURN NULL;
+end;
+$$;
+
+-- once per statement, so a holding changes version once however many of its
+-- transactions the statement touched
+CREATE TRIGGER crypto_transaction_insert_balances
+AFTER INSERT ON crypto_transaction
+REFERENCING NEW TABLE AS new_rows
+FOR EACH STATEMENT EXECUTE FUNCTION derive_transaction_balances();
+
+CREATE TRIGGER crypto_transaction_update_balances
+AFTER UPDATE ON crypto_transaction
+REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+FOR EACH STATEMENT EXECUTE FUNCTION derive_transaction_balances();
+
+CREATE TRIGGER crypto_transaction_delete_balances
+AFTER DELETE ON crypto_transaction
+REFERENCING OLD TABLE AS old_rows
+FOR EACH STATEMENT EXECUTE FUNCTION derive_transaction_balances();
+
+-- the balances a holding is created with are recorded as its opening balance
+CREATE OR REPLACE FUNCTION open_holding_balance()
+returns trigger
+language plpgsql
+as
+$$
+begin
+   IF COALESCE(NEW.balance, 0) <> 0 OR COALESCE(NEW.fiat_balance, 0) <> 0 THEN
+      INSERT INTO crypto_transaction (cryptocurrency_id, type, cryptocurrency_amount, fiat_amount, purchase_date, created_date)
+      VALUES (NEW.cryptocurrency_id, 'opening_balance', COALESCE(NEW.balance, 0), COALESCE(NEW.fiat_balance, 0), NEW.created_date, NOW());
+   END IF;
+   RETURN NULL;
+end;
+$$;
+
+CREATE TRIGGER cryptocurrency_opening_balance
+AFTER INSERT ON cryptocurrency
+FOR EACH ROW EXECUTE FUNCTION open_holding_balance();
+
+-- account public key of a wallet; receive_index and change_index are the first
+-- unused address of each branch as of the last scan
+CREATE TABLE extended_key (
+    key_id SERIAL PRIMARY KEY,
+    cryptocurrency_id INT NOT NULL,
+    extended_key VARCHAR(120) NOT NULL,
+    script_type VARCHAR(20) NOT NULL,
+    gap_limit INT NOT NULL DEFAULT 20,
+    label VARCHAR(100) NOT NULL DEFAULT '',
+    receive_index INT NOT NULL DEFAULT 0,
+    change_index INT NOT NULL DEFAULT 0,
+    scanned_at TIMESTAMP,
+    created_date TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (cryptocurrency_id, extended_key),
+    FOREIGN KEY (cryptocurrency_id) REFERENCES cryptocurrency (cryptocurrency_id) ON DELETE CASCADE
+);
+
+-- watch-only addresses of a holding, read through the indexer of its asset
+-- chain; key_id and derivation_path are set on the ones derived from a key
+CREATE TABLE watched_address (
+    address_id SERIAL PRIMARY KEY,
+    cryptocurrency_id INT NOT NULL,
+    address VARCHAR(255) NOT NULL,
+    label VARCHAR(100) NOT NULL DEFAULT '',
+    key_id INT,
+    derivation_path VARCHAR(30),
+    created_date TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (cryptocurrency_id, address),
+    FOREIGN KEY (cryptocurrency_id) REFERENCES cryptocurrency (cryptocurrency_id) ON DELETE CASCADE,
+    FOREIGN KEY (key_id) REFERENCES extended_key (key_id) ON DELETE CASCADE
+);
+
+-- target allocation of the portfolio in percent of its value; 'others' covers
+-- every asset without a target of its own
+CREATE TABLE portfolio_target (
+    asset_id VARCHAR(100) PRIMARY KEY,
+    percentage NUMERIC(5, 2) NOT NULL CHECK (percentage > 0 AND percentage <= 100)
+);
+
+-- value of the portfolio once a day, the last snapshot of the day kept
+CREATE TABLE portfolio_snapshot (
+    snapshot_date DATE PRIMARY KEY,
+    value NUMERIC(20, 2) NOT NULL,
+    net_invested NUMERIC(20, 2) NOT NULL, -- fiat put in less fiat taken out
+    recorded_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- latest check of each holding against its transactions, and against the
+-- chain when its addresses are watched
+CREATE TABLE reconciliation (
+    cryptocurrency_id INT PRIMARY KEY,
+    balance NUMERIC(30, 18) NOT NULL,
+    ledger_balance NUMERIC(30, 18) NOT NULL,
+    fiat_balance NUMERIC(14,2) NOT NULL,
+    ledger_fiat_balance NUMERIC(14,2) NOT NULL,
+    onchain_balance NUMERIC(30, 18),
+    checked_at TIMESTAMP NOT NULL DEFAULT NOW(),
     FOREIGN KEY (cryptocurrency_id) REFERENCES cryptocurrency (cryptocurrency_id) ON DELETE CASCADE
 );
 
@@ -20,9 +201,133 @@ CREATE TABLE crypto_price (
     id SERIAL PRIMARY KEY,
     name VARCHAR(100) NOT NULL,
     price_usd NUMERIC(14,2) NOT NULL,
-	updated_date DATE NOT NULL DEFAULT CURRENT_DATE
+	updated_date TIMESTAMP NOT NULL DEFAULT NOW(),
+    -- provider that produced the price, or median(...) of several
+    source VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+CREATE UNIQUE INDEX crypto_price_name_key ON crypto_price (LOWER(name));
+
+-- every refreshed price, used by rules that look at the change over a window
+CREATE TABLE crypto_price_history (
+    history_id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    price_usd NUMERIC(14,2) NOT NULL,
+    recorded_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    source VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+CREATE INDEX crypto_price_history_name_idx ON crypto_price_history (name, recorded_at);
+
+-- shared cache of GET /prices, used when PRICE_CACHE_BACKEND=postgres
+CREATE TABLE price_cache (
+    asset VARCHAR(100) NOT NULL,
+    currency VARCHAR(10) NOT NULL,
+    price NUMERIC(30, 8) NOT NULL,
+    fetched_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (asset, currency)
+);
+
+CREATE TABLE alert_rule (
+    alert_rule_id SERIAL PRIMARY KEY,
+    type VARCHAR(30) NOT NULL,
+    asset VARCHAR(100) NOT NULL DEFAULT '', -- empty for holding rules
+    cryptocurrency_id INT,
+    threshold NUMERIC(30, 8) NOT NULL,
+    window_minutes INT NOT NULL DEFAULT 0,
+    cooldown_minutes INT NOT NULL DEFAULT 0,
+    channel VARCHAR(20) NOT NULL,
+    target TEXT NOT NULL,
+    paused BOOLEAN NOT NULL DEFAULT FALSE,
+    triggered BOOLEAN NOT NULL DEFAULT FALSE, -- the condition held on the last evaluation
+    last_notified_at TIMESTAMP,
+    created_date TIMESTAMP NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (cryptocurrency_id) REFERENCES cryptocurrency (cryptocurrency_id) ON DELETE CASCADE
+);
+
+CREATE TABLE idempotency_key (
+    idempotency_key VARCHAR(255) NOT NULL,
+    method VARCHAR(10) NOT NULL,
+    path TEXT NOT NULL,
+    request_hash CHAR(64) NOT NULL,
+    status_code INT, -- null while the original request is still being processed
+    response_headers JSONB,
+    response_body BYTEA,
+    created_date TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (idempotency_key, method, path)
 );
 
+CREATE TABLE audit_log (
+    audit_id BIGSERIAL PRIMARY KEY,
+    actor VARCHAR(100) NOT NULL,
+    action VARCHAR(30) NOT NULL,
+    entity VARCHAR(50) NOT NULL,
+    entity_id INT NOT NULL,
+    before JSONB,
+    after JSONB,
+    created_date TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX audit_log_entity_idx ON audit_log (entity, entity_id, created_date);
+
+CREATE OR REPLACE FUNCTION reject_audit_log_change()
+returns trigger
+language plpgsql
+as
+$$
+begin
+   RAISE EXCEPTION 'audit_log is append-only';
+end;
+$$;
+
+CREATE TRIGGER audit_log_append_only
+BEFORE UPDATE OR DELETE ON audit_log
+FOR EACH ROW EXECUTE FUNCTION reject_audit_log_change();
+
+-- domain events written in the transaction of the change that raised them and
+-- relayed to the subscribers by the dispatcher job
+CREATE TABLE outbox_event (
+    event_id BIGSERIAL PRIMARY KEY,
+    event_type VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL,
+    handled_by TEXT[] NOT NULL DEFAULT '{}', -- subscribers that already handled the event
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_error TEXT,
+    created_date TIMESTAMP NOT NULL DEFAULT NOW(),
+    dispatched_at TIMESTAMP
+);
+
+CREATE INDEX outbox_event_pending_idx ON outbox_event (next_attempt_at, event_id) WHERE dispatched_at IS NULL;
+
+CREATE TABLE webhook_subscription (
+    webhook_subscription_id SERIAL PRIMARY KEY,
+    url TEXT NOT NULL,
+    secret VARCHAR(255) NOT NULL,
+    event_types TEXT[] NOT NULL,
+    created_date TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- one row per event and subscriber, written in the transaction of the change
+-- and sent by the delivery job
+CREATE TABLE webhook_delivery (
+    delivery_id BIGSERIAL PRIMARY KEY,
+    webhook_subscription_id INT NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_status_code INT,
+    last_error TEXT,
+    created_date TIMESTAMP NOT NULL DEFAULT NOW(),
+    delivered_at TIMESTAMP,
+    FOREIGN KEY (webhook_subscription_id) REFERENCES webhook_subscription (webhook_subscription_id) ON DELETE CASCADE
+);
+
+CREATE INDEX webhook_delivery_pending_idx ON webhook_delivery (next_attempt_at) WHERE status = 'pending';
+
 CREATE OR REPLACE FUNCTION get_percentage_profit(crypto_balance numeric, price_usd numeric, fiat_balance numeric)
 returns NUMERIC
 language plpgsql
@@ -0,0 +1,8 @@
+package db
+
+import _ "embed"
+
+// AssetSeed is the bundled asset registry, loaded into the asset table on startup.
+//
+//go:embed seeds/assets.json
+var AssetSeed []byte
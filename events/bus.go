@@ -0,0 +1,150 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+	"wallet-manager/models"
+	"wallet-manager/repositories"
+)
+
+const (
+	dispatchBatchSize = 100
+	dispatchLease     = 5 * time.Minute
+	retryBase         = 5 * time.Second
+	retryMax          = 10 * time.Minute
+)
+
+// Publisher is what the services raise their events through.
+type Publisher interface {
+	// Publish stores event in the outbox. Call it with the context of the
+	// transaction making the change, so the event exists if and only if the
+	// change is committed.
+	Publish(ctx context.Context, event Event) error
+}
+
+type Handler func(ctx context.Context, event Event) error
+
+type subscriber struct {
+	name    string
+	handler Handler
+}
+
+// Bus relays the events of the outbox to the in-process subscribers. Delivery is
+// at least once: a subscriber that fails gets the event again later, while the
+// others are not called twice for it.
+type Bus struct {
+	repo        repositories.OutboxRepository
+	transactor  repositories.Transactor
+	mu          sync.RWMutex
+	subscribers map[string][]subscriber
+}
+
+func NewBus(repo repositories.OutboxRepository, transactor repositories.Transactor) *Bus {
+	return &Bus{repo: repo, transactor: transactor, subscribers: map[string][]subscriber{}}
+}
+
+// Subscribe registers handler for the given event types. name identifies the
+// subscriber in the outbox, so it must be unique and stable across restarts.
+func (b *Bus) Subscribe(name string, handler Handler, eventTypes ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, eventType := range eventTypes {
+		b.subscribers[eventType] = append(b.subscribers[eventType], subscriber{name: name, handler: handler})
+	}
+}
+
+func (b *Bus) Publish(ctx context.Context, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return b.repo.Create(ctx, &models.OutboxEvent{EventType: event.EventType(), Payload: payload})
+}
+
+// Dispatch hands the due events of the outbox to their subscribers, oldest first.
+// Each subscriber runs in its own transaction, committed together with the mark
+// that it handled the event.
+func (b *Bus) Dispatch(ctx context.Context) error {
+	pending, err := b.repo.ClaimDue(ctx, dispatchBatchSize, time.Now().Add(dispatchLease).UTC())
+	if err != nil {
+		return err
+	}
+
+	for i := range pending {
+		if err := b.dispatch(ctx, &pending[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *Bus) dispatch(ctx context.Context, outboxEvent *models.OutboxEvent) error {
+	now := time.Now().UTC()
+	event, err := Decode(outboxEvent.EventType, outboxEvent.Payload)
+	if err != nil {
+		// retrying cannot fix the payload, keep the row for inspection and move on
+		log.Printf("dropping outbox event %d: %v", outboxEvent.ID, err)
+		message := err.Error()
+		outboxEvent.LastError = &message
+		outboxEvent.DispatchedAt = &now
+		return b.repo.Update(ctx, outboxEvent)
+	}
+
+	var errs []error
+	for _, sub := range b.subscribersOf(outboxEvent.EventType) {
+		if handled(outboxEvent, sub.name) {
+			continue
+		}
+		err := b.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+			if err := sub.handler(ctx, event); err != nil {
+				return err
+			}
+			return b.repo.MarkHandled(ctx, outboxEvent.ID, sub.name)
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sub.name, err))
+		}
+	}
+
+	outboxEvent.Attempts++
+	if err := errors.Join(errs...); err != nil {
+		message := err.Error()
+		outboxEvent.LastError = &message
+		outboxEvent.NextAttemptAt = now.Add(backoff(outboxEvent.Attempts))
+	} else {
+		outboxEvent.LastError = nil
+		outboxEvent.DispatchedAt = &now
+	}
+	return b.repo.Update(ctx, outboxEvent)
+}
+
+func (b *Bus) subscribersOf(eventType string) []subscriber {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.subscribers[eventType]
+}
+
+func handled(event *models.OutboxEvent, name string) bool {
+	for _, handledBy := range event.HandledBy {
+		if handledBy == name {
+			return true
+		}
+	}
+	return false
+}
+
+func backoff(attempts int) time.Duration {
+	delay := retryBase
+	for i := 1; i < attempts && delay < retryMax; i++ {
+		delay *= 2
+	}
+	if delay > retryMax {
+		return retryMax
+	}
+	return delay
+}
@@ -0,0 +1,84 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"wallet-manager/models"
+
+	"github.com/shopspring/decimal"
+)
+
+// Event is a domain event. Its JSON encoding is what gets stored in the outbox
+// and sent to webhooks.
+type Event interface {
+	EventType() string
+}
+
+type TransactionCreated struct{ models.CryptoTransaction }
+type TransactionUpdated struct{ models.CryptoTransaction }
+type TransactionDeleted struct{ models.CryptoTransaction }
+type TransactionRestored struct{ models.CryptoTransaction }
+
+type HoldingCreated struct{ models.Cryptocurrency }
+type HoldingUpdated struct{ models.Cryptocurrency }
+type HoldingDeleted struct{ models.Cryptocurrency }
+type HoldingRestored struct{ models.Cryptocurrency }
+
+type HoldingBalanceChanged struct {
+	CryptocurrencyId    uint32          `json:"cryptocurrencyId"`
+	Balance             decimal.Decimal `json:"balance"`
+	FiatBalance         decimal.Decimal `json:"fiatBalance"`
+	PreviousBalance     decimal.Decimal `json:"previousBalance"`
+	PreviousFiatBalance decimal.Decimal `json:"previousFiatBalance"`
+}
+
+type PriceUpdated struct {
+	Name     string          `json:"name"`
+	PriceUSD decimal.Decimal `json:"priceUsd"`
+	Source   string          `json:"source"`
+}
+
+// PricesRefreshed is raised once per refresh, after every PriceUpdated of it.
+type PricesRefreshed struct {
+	Names []string `json:"names"`
+}
+
+func (TransactionCreated) EventType() string    { return models.EventTransactionCreated }
+func (TransactionUpdated) EventType() string    { return models.EventTransactionUpdated }
+func (TransactionDeleted) EventType() string    { return models.EventTransactionDeleted }
+func (TransactionRestored) EventType() string   { return models.EventTransactionRestored }
+func (HoldingCreated) EventType() string        { return models.EventHoldingCreated }
+func (HoldingUpdated) EventType() string        { return models.EventHoldingUpdated }
+func (HoldingDeleted) EventType() string        { return models.EventHoldingDeleted }
+func (HoldingRestored) EventType() string       { return models.EventHoldingRestored }
+func (HoldingBalanceChanged) EventType() string { return models.EventHoldingBalanceChanged }
+func (PriceUpdated) EventType() string          { return models.EventPriceUpdated }
+func (PricesRefreshed) EventType() string       { return models.EventPricesRefreshed }
+
+var registry = map[string]func() Event{
+	models.EventTransactionCreated:    func() Event { return &TransactionCreated{} },
+	models.EventTransactionUpdated:    func() Event { return &TransactionUpdated{} },
+	models.EventTransactionDeleted:    func() Event { return &TransactionDeleted{} },
+	models.EventTransactionRestored:   func() Event { return &TransactionRestored{} },
+	models.EventHoldingCreated:        func() Event { return &HoldingCreated{} },
+	models.EventHoldingUpdated:        func() Event { return &HoldingUpdated{} },
+	models.EventHoldingDeleted:        func() Event { return &HoldingDeleted{} },
+	models.EventHoldingRestored:       func() Event { return &HoldingRestored{} },
+	models.EventHoldingBalanceChanged: func() Event { return &HoldingBalanceChanged{} },
+	models.EventPriceUpdated:          func() Event { return &PriceUpdated{} },
+	models.EventPricesRefreshed:       func() Event { return &PricesRefreshed{} },
+}
+
+// Decode turns a stored payload back into its typed event. Subscribers receive
+// pointers, e.g. *TransactionCreated.
+func Decode(eventType string, payload []byte) (Event, error) {
+	newEvent, ok := registry[eventType]
+	if !ok {
+		return nil, fmt.Errorf("unknown event type %q", eventType)
+	}
+	event := newEvent()
+	if err := json.Unmarshal(payload, event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"wallet-manager/chain"
+	"wallet-manager/models"
+	"wallet-manager/repositories"
+	"wallet-manager/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AddressHandler struct {
+	service services.AddressService
+}
+
+func NewAddressHandler(service services.AddressService) *AddressHandler {
+	return &AddressHandler{service: service}
+}
+
+func (h *AddressHandler) Create(c *gin.Context) {
+	cryptoId, ok := cryptoParam(c)
+	if !ok {
+		return
+	}
+
+	var address models.WatchedAddress
+	if err := c.ShouldBindJSON(&address); err != nil {
+		c.JSON(http.StatusBadRequest, bindingError(err))
+		return
+	}
+
+	address.CryptocurrencyId = cryptoId
+	if err := h.service.Create(c.Request.Context(), &address); err != nil {
+		writeAddressError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, address)
+}
+
+func (h *AddressHandler) GetAll(c *gin.Context) {
+	cryptoId, ok := cryptoParam(c)
+	if !ok {
+		return
+	}
+
+	addresses, err := h.service.GetAll(c.Request.Context(), cryptoId)
+	if err != nil {
+		writeAddressError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, addresses)
+}
+
+func (h *AddressHandler) Delete(c *gin.Context) {
+	cryptoId, ok := cryptoParam(c)
+	if !ok {
+		return
+	}
+	id, err := uintParam(c, "addressId")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
+		return
+	}
+
+	if err := h.service.Delete(c.Request.Context(), cryptoId, id); err != nil {
+		writeAddressError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// OnChain compares the ledger balance of the holding to its watched addresses
+// and proposes the transactions missing from the ledger.
+func (h *AddressHandler) OnChain(c *gin.Context) {
+	cryptoId, ok := cryptoParam(c)
+	if !ok {
+		return
+	}
+
+	status, err := h.service.Status(c.Request.Context(), cryptoId)
+	if err != nil {
+		writeAddressError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// CreateKey registers an extended public key; the addresses it used are
+// watched after its first scan.
+func (h *AddressHandler) CreateKey(c *gin.Context) {
+	cryptoId, ok := cryptoParam(c)
+	if !ok {
+		return
+	}
+
+	var key models.ExtendedKey
+	if err := c.ShouldBindJSON(&key); err != nil {
+		c.JSON(http.StatusBadRequest, bindingError(err))
+		return
+	}
+
+	key.CryptocurrencyId = cryptoId
+	if err := h.service.CreateKey(c.Request.Context(), &key); err != nil {
+		writeAddressError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, key)
+}
+
+func (h *AddressHandler) GetKeys(c *gin.Context) {
+	cryptoId, ok := cryptoParam(c)
+	if !ok {
+		return
+	}
+
+	keys, err := h.service.GetKeys(c.Request.Context(), cryptoId)
+	if err != nil {
+		writeAddressError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+func (h *AddressHandler) DeleteKey(c *gin.Context) {
+	cryptoId, id, ok := keyParams(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteKey(c.Request.Context(), cryptoId, id); err != nil {
+		writeAddressError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Scan looks for the addresses the wallet used since the last scan now rather
+// than on the next scheduled one.
+func (h *AddressHandler) Scan(c *gin.Context) {
+	cryptoId, id, ok := keyParams(c)
+	if !ok {
+		return
+	}
+
+	key, err := h.service.Scan(c.Request.Context(), cryptoId, id)
+	if err != nil {
+		writeAddressError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, key)
+}
+
+func keyParams(c *gin.Context) (uint32, uint32, bool) {
+	cryptoId, ok := cryptoParam(c)
+	if !ok {
+		return 0, 0, false
+	}
+	id, err := uintParam(c, "keyId")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
+		return 0, 0, false
+	}
+	return cryptoId, id, true
+}
+
+func cryptoParam(c *gin.Context) (uint32, bool) {
+	id, err := uintParam(c, "cryptoId")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cryptoId"})
+		return 0, false
+	}
+	return id, true
+}
+
+func writeAddressError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, chain.ErrInvalidExtendedKey):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, chain.ErrInvalidAddress):
+		c.JSON(http.StatusBadRequest, addressError(err))
+	case errors.Is(err, services.ErrCryptocurrencyNotFound), errors.Is(err, services.ErrAddressNotFound), errors.Is(err, services.ErrExtendedKeyNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, repositories.ErrAddressTaken), errors.Is(err, repositories.ErrExtendedKeyTaken), errors.Is(err, services.ErrAddressDerived):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrUnsupportedChain):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"wallet-manager/models"
+	"wallet-manager/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AlertHandler struct {
+	service services.AlertService
+}
+
+func NewAlertHandler(service services.AlertService) *AlertHandler {
+	return &AlertHandler{service: service}
+}
+
+func (h *AlertHandler) Create(c *gin.Context) {
+	var rule models.AlertRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, bindingError(err))
+		return
+	}
+
+	if err := h.service.Create(c.Request.Context(), &rule); err != nil {
+		writeAlertError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, rule)
+}
+
+func (h *AlertHandler) GetAll(c *gin.Context) {
+	rules, err := h.service.GetAll(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+func (h *AlertHandler) GetByID(c *gin.Context) {
+	id, err := uintParam(c, "alertId")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
+		return
+	}
+
+	rule, err := h.service.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if rule == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrAlertRuleNotFound.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+func (h *AlertHandler) Update(c *gin.Context) {
+	id, err := uintParam(c, "alertId")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
+		return
+	}
+
+	var rule models.AlertRule
+	if err := c.ShouldBindJSON(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, bindingError(err))
+		return
+	}
+
+	rule.ID = id
+	if err := h.service.Update(c.Request.Context(), &rule); err != nil {
+		writeAlertError(c, err)
+		return
+	}
+
+	updated, err := h.service.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+func (h *AlertHandler) Delete(c *gin.Context) {
+	id, err := uintParam(c, "alertId")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
+		return
+	}
+
+	if err := h.service.Delete(c.Request.Context(), id); err != nil {
+		writeAlertError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func writeAlertError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidAlertRule):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrAlertRuleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
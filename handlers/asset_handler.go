@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"wallet-manager/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AssetHandler struct {
+	service services.AssetService
+}
+
+func NewAssetHandler(service services.AssetService) *AssetHandler {
+	return &AssetHandler{service: service}
+}
+
+// Search matches q against asset ids, symbols and display names.
+func (h *AssetHandler) Search(c *gin.Context) {
+	limit := 0
+	if limitParam := c.Query("limit"); limitParam != "" {
+		var err error
+		if limit, err = strconv.Atoi(limitParam); err != nil || limit < 1 || limit > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit, expected 1 to 100"})
+			return
+		}
+	}
+
+	assets, err := h.service.Search(c.Request.Context(), c.Query("q"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, assets)
+}
+
+func (h *AssetHandler) GetByID(c *gin.Context) {
+	asset, err := h.service.GetByID(c.Request.Context(), c.Param("assetId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if asset == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "asset not found"})
+		return
+	}
+	c.JSON(http.StatusOK, asset)
+}
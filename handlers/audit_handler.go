@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	"wallet-manager/models"
+	"wallet-manager/services"
+	"wallet-manager/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuditHandler struct {
+	service services.AuditService
+}
+
+func NewAuditHandler(service services.AuditService) *AuditHandler {
+	return &AuditHandler{service: service}
+}
+
+func (h *AuditHandler) Find(c *gin.Context) {
+	filter := models.AuditFilter{Entity: c.Query("entity")}
+
+	if idParam := c.Query("id"); idParam != "" {
+		id, err := strconv.ParseUint(idParam, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+			return
+		}
+		entityID := uint32(id)
+		filter.EntityID = &entityID
+	}
+
+	var err error
+	if filter.From, err = timeQuery(c, "from"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from, expected RFC3339"})
+		return
+	}
+	if filter.To, err = timeQuery(c, "to"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to, expected RFC3339"})
+		return
+	}
+
+	entries, err := h.service.Find(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+// timeQuery parses an optional RFC3339 query parameter as UTC, matching how timestamps are stored.
+func timeQuery(c *gin.Context, name string) (*time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := time.Parse(utils.TimeFormat, value)
+	if err != nil {
+		return nil, err
+	}
+	parsed = parsed.UTC()
+	return &parsed, nil
+}
@@ -1,13 +1,17 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"wallet-manager/chain"
 	"wallet-manager/models"
+	"wallet-manager/repositories"
 	"wallet-manager/services"
 	"wallet-manager/utils"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
 type CryptoTransactionHandler struct {
@@ -27,17 +31,19 @@ func (h *CryptoTransactionHandler) Create(c *gin.Context) {
 
 	var crypto models.CryptoTransaction
 	if err := c.ShouldBindJSON(&crypto); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, bindingError(err))
 		return
 	}
 
 	crypto.CryptocurrencyId = uint32(cryptoId)
+	crypto.Status, crypto.PlanID = models.TransactionStatusConfirmed, nil
 	crypto.CreatedDate = utils.NowFormatted()
-	if err := h.service.Create(&crypto); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.service.Create(c.Request.Context(), &crypto); err != nil {
+		writeTransactionError(c, err)
 		return
 	}
 
+	setETag(c, crypto.Version)
 	c.JSON(http.StatusCreated, crypto)
 }
 
@@ -48,7 +54,7 @@ func (h *CryptoTransactionHandler) GetAll(c *gin.Context) {
 		return
 	}
 
-	cryptos, err := h.service.GetAll(uint32(cryptoId))
+	cryptos, err := h.service.GetAll(c.Request.Context(), uint32(cryptoId))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -57,13 +63,12 @@ func (h *CryptoTransactionHandler) GetAll(c *gin.Context) {
 }
 
 func (h *CryptoTransactionHandler) GetByID(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("transactionId"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
+	cryptoId, id, ok := transactionParams(c)
+	if !ok {
 		return
 	}
 
-	crypto, err := h.service.GetByID(uint32(id))
+	crypto, err := h.service.GetByID(c.Request.Context(), cryptoId, id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -74,35 +79,165 @@ func (h *CryptoTransactionHandler) GetByID(c *gin.Context) {
 		return
 	}
 
+	setETag(c, crypto.Version)
 	c.JSON(http.StatusOK, crypto)
 }
 
 func (h *CryptoTransactionHandler) Update(c *gin.Context) {
+	cryptoId, id, ok := transactionParams(c)
+	if !ok {
+		return
+	}
+
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+
 	var transaction models.CryptoTransaction
 	if err := c.ShouldBindJSON(&transaction); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, bindingError(err))
+		return
+	}
+
+	transaction.ID = id
+	transaction.CryptocurrencyId = cryptoId
+	transaction.Version = version
+	h.update(c, &transaction)
+}
+
+func (h *CryptoTransactionHandler) Patch(c *gin.Context) {
+	cryptoId, id, ok := transactionParams(c)
+	if !ok {
+		return
+	}
+
+	version, ok := ifMatchVersion(c)
+	if !ok {
 		return
 	}
 
-	if err := h.service.Update(&transaction); err != nil {
+	current, err := h.service.GetByID(c.Request.Context(), cryptoId, id)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if current == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "cryptoTransaction not found"})
+		return
+	}
+	if current.Version != version {
+		setETag(c, current.Version)
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": repositories.ErrVersionMismatch.Error()})
+		return
+	}
+
+	var transaction models.CryptoTransaction
+	if err := applyMergePatch(c, current, &transaction); err != nil {
+		if errors.Is(err, errUnsupportedPatchType) {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	transaction.ID = id
+	transaction.CryptocurrencyId = cryptoId
+	transaction.Version = version
+	if err := binding.Validator.ValidateStruct(&transaction); err != nil {
+		c.JSON(http.StatusBadRequest, bindingError(err))
+		return
+	}
+
+	h.update(c, &transaction)
+}
+
+func (h *CryptoTransactionHandler) update(c *gin.Context, transaction *models.CryptoTransaction) {
+	if err := h.service.Update(c.Request.Context(), transaction); err != nil {
+		writeTransactionError(c, err)
+		return
+	}
 
+	setETag(c, transaction.Version)
 	c.JSON(http.StatusOK, transaction)
 }
 
 func (h *CryptoTransactionHandler) Delete(c *gin.Context) {
-	id, err := strconv.Atoi(c.Param("transactionId"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
+	cryptoId, id, ok := transactionParams(c)
+	if !ok {
 		return
 	}
 
-	if err := h.service.Delete(uint32(id)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Delete(c.Request.Context(), cryptoId, id, version); err != nil {
+		writeTransactionError(c, err)
 		return
 	}
 
 	c.Status(http.StatusNoContent)
 }
+
+func (h *CryptoTransactionHandler) Restore(c *gin.Context) {
+	cryptoId, id, ok := transactionParams(c)
+	if !ok {
+		return
+	}
+
+	transaction, err := h.service.Restore(c.Request.Context(), cryptoId, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrTransactionNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "cryptoTransaction not found in the trash"})
+		case errors.Is(err, services.ErrCryptocurrencyNotFound):
+			c.JSON(http.StatusConflict, gin.H{"error": "cryptocurrency is in the trash, restore it first"})
+		case errors.Is(err, services.ErrInsufficientBalance):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		case errors.Is(err, repositories.ErrExternalIDTaken):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	setETag(c, transaction.Version)
+	c.JSON(http.StatusOK, transaction)
+}
+
+func transactionParams(c *gin.Context) (uint32, uint32, bool) {
+	cryptoId, err := uintParam(c, "cryptoId")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cryptoId"})
+		return 0, 0, false
+	}
+
+	id, err := uintParam(c, "transactionId")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
+		return 0, 0, false
+	}
+
+	return cryptoId, id, true
+}
+
+func writeTransactionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrTransactionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, repositories.ErrVersionMismatch):
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInsufficientBalance):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, repositories.ErrExternalIDTaken), errors.Is(err, services.ErrTransactionReadOnly):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, chain.ErrInvalidAddress):
+		c.JSON(http.StatusBadRequest, addressError(err))
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -1,14 +1,16 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
-	"strings"
 	"wallet-manager/models"
+	"wallet-manager/repositories"
 	"wallet-manager/services"
 	"wallet-manager/utils"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
 type CryptocurrencyHandler struct {
@@ -22,21 +24,29 @@ func NewCryptocurrencyHandler(service services.CryptocurrencyService) *Cryptocur
 func (h *CryptocurrencyHandler) Create(c *gin.Context) {
 	var crypto models.Cryptocurrency
 	if err := c.ShouldBindJSON(&crypto); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, bindingError(err))
 		return
 	}
 
 	crypto.CreatedDate = utils.NowFormatted()
-	if err := h.service.Create(&crypto); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.service.Create(c.Request.Context(), &crypto); err != nil {
+		switch {
+		case errors.Is(err, services.ErrUnknownAsset):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, repositories.ErrCryptocurrencyNameTaken):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
 
+	setETag(c, crypto.Version)
 	c.JSON(http.StatusCreated, crypto)
 }
 
 func (h *CryptocurrencyHandler) GetAll(c *gin.Context) {
-	cryptos, err := h.service.GetAll()
+	cryptos, err := h.service.GetAll(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -51,7 +61,7 @@ func (h *CryptocurrencyHandler) GetByID(c *gin.Context) {
 		return
 	}
 
-	crypto, err := h.service.GetByID(uint32(id))
+	crypto, err := h.service.GetByID(c.Request.Context(), uint32(id))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -62,21 +72,98 @@ func (h *CryptocurrencyHandler) GetByID(c *gin.Context) {
 		return
 	}
 
+	setETag(c, crypto.Version)
 	c.JSON(http.StatusOK, crypto)
 }
 
 func (h *CryptocurrencyHandler) Update(c *gin.Context) {
+	id, err := uintParam(c, "cryptoId")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
+		return
+	}
+
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+
 	var crypto models.Cryptocurrency
 	if err := c.ShouldBindJSON(&crypto); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, bindingError(err))
+		return
+	}
+
+	crypto.ID = id
+	crypto.Version = version
+	h.update(c, &crypto)
+}
+
+func (h *CryptocurrencyHandler) Patch(c *gin.Context) {
+	id, err := uintParam(c, "cryptoId")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
 		return
 	}
 
-	if err := h.service.Update(&crypto); err != nil {
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+
+	current, err := h.service.GetByID(c.Request.Context(), id)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	if current == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "cryptocurrency not found"})
+		return
+	}
+	if current.Version != version {
+		setETag(c, current.Version)
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": repositories.ErrVersionMismatch.Error()})
+		return
+	}
+
+	var crypto models.Cryptocurrency
+	if err := applyMergePatch(c, current, &crypto); err != nil {
+		if errors.Is(err, errUnsupportedPatchType) {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	crypto.ID = id
+	crypto.Version = version
+	if err := binding.Validator.ValidateStruct(&crypto); err != nil {
+		c.JSON(http.StatusBadRequest, bindingError(err))
+		return
+	}
+
+	h.update(c, &crypto)
+}
+
+func (h *CryptocurrencyHandler) update(c *gin.Context, crypto *models.Cryptocurrency) {
+	if err := h.service.Update(c.Request.Context(), crypto); err != nil {
+		switch {
+		case errors.Is(err, services.ErrCryptocurrencyNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrUnknownAsset):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, repositories.ErrCryptocurrencyNameTaken):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, repositories.ErrVersionMismatch):
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
 
+	setETag(c, crypto.Version)
 	c.JSON(http.StatusOK, crypto)
 }
 
@@ -87,27 +174,46 @@ func (h *CryptocurrencyHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.Delete(uint32(id)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	version, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Delete(c.Request.Context(), uint32(id), version); err != nil {
+		switch {
+		case errors.Is(err, services.ErrCryptocurrencyNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, repositories.ErrVersionMismatch):
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
 
 	c.Status(http.StatusNoContent)
 }
 
-func (h *CryptocurrencyHandler) GetMultiplePrices(c *gin.Context) {
-	namesParam := c.Query("names")
-	if namesParam == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "no names provided"})
+func (h *CryptocurrencyHandler) Restore(c *gin.Context) {
+	id, err := uintParam(c, "cryptoId")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
 		return
 	}
 
-	names := strings.Split(namesParam, ",")
-	prices, err := utils.GetCryptoPrices(names)
+	crypto, err := h.service.Restore(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		switch {
+		case errors.Is(err, services.ErrCryptocurrencyNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "cryptocurrency not found in the trash"})
+		case errors.Is(err, repositories.ErrCryptocurrencyNameTaken):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
 
-	c.JSON(http.StatusOK, prices)
+	setETag(c, crypto.Version)
+	c.JSON(http.StatusOK, crypto)
 }
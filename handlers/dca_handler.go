@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"wallet-manager/models"
+	"wallet-manager/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DCAHandler struct {
+	service services.DCAService
+}
+
+func NewDCAHandler(service services.DCAService) *DCAHandler {
+	return &DCAHandler{service: service}
+}
+
+func (h *DCAHandler) Create(c *gin.Context) {
+	var plan models.DCAPlan
+	if err := c.ShouldBindJSON(&plan); err != nil {
+		c.JSON(http.StatusBadRequest, bindingError(err))
+		return
+	}
+
+	if err := h.service.Create(c.Request.Context(), &plan); err != nil {
+		writeDCAError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, plan)
+}
+
+func (h *DCAHandler) GetAll(c *gin.Context) {
+	plans, err := h.service.GetAll(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, plans)
+}
+
+func (h *DCAHandler) GetByID(c *gin.Context) {
+	id, ok := planParam(c)
+	if !ok {
+		return
+	}
+
+	plan, err := h.service.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if plan == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrDCAPlanNotFound.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, plan)
+}
+
+func (h *DCAHandler) Update(c *gin.Context) {
+	id, ok := planParam(c)
+	if !ok {
+		return
+	}
+
+	var plan models.DCAPlan
+	if err := c.ShouldBindJSON(&plan); err != nil {
+		c.JSON(http.StatusBadRequest, bindingError(err))
+		return
+	}
+
+	plan.ID = id
+	if err := h.service.Update(c.Request.Context(), &plan); err != nil {
+		writeDCAError(c, err)
+		return
+	}
+
+	updated, err := h.service.GetByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+func (h *DCAHandler) Delete(c *gin.Context) {
+	id, ok := planParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.Delete(c.Request.Context(), id); err != nil {
+		writeDCAError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Executions lists the runs awaiting confirmation and the next limit (5 by
+// default) to come.
+func (h *DCAHandler) Executions(c *gin.Context) {
+	id, ok := planParam(c)
+	if !ok {
+		return
+	}
+
+	limit := 0
+	if limitParam := c.Query("limit"); limitParam != "" {
+		var err error
+		if limit, err = strconv.Atoi(limitParam); err != nil || limit < 1 || limit > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit, expected 1 to 100"})
+			return
+		}
+	}
+
+	executions, err := h.service.Executions(c.Request.Context(), id, limit)
+	if err != nil {
+		writeDCAError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, executions)
+}
+
+func (h *DCAHandler) SkipNext(c *gin.Context) {
+	id, ok := planParam(c)
+	if !ok {
+		return
+	}
+
+	plan, err := h.service.SkipNext(c.Request.Context(), id)
+	if err != nil {
+		writeDCAError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, plan)
+}
+
+func (h *DCAHandler) Confirm(c *gin.Context) {
+	h.settle(c, h.service.Confirm)
+}
+
+func (h *DCAHandler) Skip(c *gin.Context) {
+	h.settle(c, h.service.Skip)
+}
+
+func (h *DCAHandler) settle(c *gin.Context, settle func(ctx context.Context, id uint32, transactionId uint32) (*models.CryptoTransaction, error)) {
+	id, ok := planParam(c)
+	if !ok {
+		return
+	}
+	transactionId, err := uintParam(c, "transactionId")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid transactionId"})
+		return
+	}
+
+	transaction, err := settle(c.Request.Context(), id, transactionId)
+	if err != nil {
+		writeDCAError(c, err)
+		return
+	}
+	setETag(c, transaction.Version)
+	c.JSON(http.StatusOK, transaction)
+}
+
+func planParam(c *gin.Context) (uint32, bool) {
+	id, err := uintParam(c, "planId")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+func writeDCAError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidDCAPlan):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDCAPlanNotFound), errors.Is(err, services.ErrTransactionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTransactionNotPlanned):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
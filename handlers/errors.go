@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"errors"
+	"wallet-manager/chain"
+	"wallet-manager/validators"
+
+	"github.com/gin-gonic/gin"
+)
+
+func bindingError(err error) gin.H {
+	if fields := validators.FieldErrors(err); fields != nil {
+		return gin.H{"error": "invalid request body", "fields": fields}
+	}
+	return gin.H{"error": err.Error()}
+}
+
+// addressError reports an address that is not valid on its chain like a
+// binding error of the address field.
+func addressError(err error) gin.H {
+	var invalid *chain.AddressError
+	if errors.As(err, &invalid) {
+		return gin.H{"error": "invalid request body", "fields": gin.H{"address": invalid.Error()}}
+	}
+	return gin.H{"error": err.Error()}
+}
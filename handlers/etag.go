@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func setETag(c *gin.Context, version uint32) {
+	c.Header("ETag", fmt.Sprintf("%q", strconv.FormatUint(uint64(version), 10)))
+}
+
+// ifMatchVersion reads the version a client expects from the If-Match header.
+// It writes the error response itself when the header is missing or unusable.
+func ifMatchVersion(c *gin.Context) (uint32, bool) {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": "If-Match header is required"})
+		return 0, false
+	}
+
+	tag := strings.TrimPrefix(strings.TrimSpace(header), "W/")
+	version, err := strconv.ParseUint(strings.Trim(tag, `"`), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "If-Match does not match the current version"})
+		return 0, false
+	}
+
+	return uint32(version), true
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"net/http"
+	"wallet-manager/upstream"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthHandler struct {
+	upstreams []*upstream.Client
+}
+
+func NewHealthHandler(upstreams ...*upstream.Client) *HealthHandler {
+	return &HealthHandler{upstreams: upstreams}
+}
+
+// Get reports the circuit breaker of each upstream. An open breaker makes the
+// service "degraded" rather than down, since cached prices are still served.
+func (h *HealthHandler) Get(c *gin.Context) {
+	status := "ok"
+	upstreams := make([]upstream.Health, len(h.upstreams))
+	for i, client := range h.upstreams {
+		upstreams[i] = client.Health()
+		if upstreams[i].State != upstream.StateClosed {
+			status = "degraded"
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": status, "upstreams": upstreams})
+}
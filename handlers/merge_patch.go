@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"wallet-manager/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+const mergePatchContentType = "application/merge-patch+json"
+
+var errUnsupportedPatchType = errors.New("PATCH requires Content-Type " + mergePatchContentType)
+
+// applyMergePatch merges the request body into current and decodes the result into target,
+// so fields absent from the patch keep their stored values.
+func applyMergePatch(c *gin.Context, current interface{}, target interface{}) error {
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if mediaType != mergePatchContentType && mediaType != binding.MIMEJSON {
+		return errUnsupportedPatchType
+	}
+
+	patch, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return err
+	}
+
+	doc, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+
+	merged, err := utils.MergePatch(doc, patch)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(merged, target)
+}
@@ -0,0 +1,12 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func uintParam(c *gin.Context, name string) (uint32, error) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 32)
+	return uint32(id), err
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"wallet-manager/models"
+	"wallet-manager/portfolio"
+	"wallet-manager/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+// defaultRebalanceTolerance is the drift, in percentage points, tolerated
+// before a rebalance is suggested.
+var defaultRebalanceTolerance = decimal.NewFromInt(5)
+
+type PortfolioHandler struct {
+	service services.PortfolioService
+}
+
+func NewPortfolioHandler(service services.PortfolioService) *PortfolioHandler {
+	return &PortfolioHandler{service: service}
+}
+
+func (h *PortfolioHandler) GetTargets(c *gin.Context) {
+	targets, err := h.service.GetTargets(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, targets)
+}
+
+func (h *PortfolioHandler) SetTargets(c *gin.Context) {
+	var targets models.PortfolioTargets
+	if err := c.ShouldBindJSON(&targets); err != nil {
+		c.JSON(http.StatusBadRequest, bindingError(err))
+		return
+	}
+
+	if err := h.service.SetTargets(c.Request.Context(), &targets); err != nil {
+		writePortfolioError(c, err)
+		return
+	}
+
+	updated, err := h.service.GetTargets(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+// Rebalance suggests the trades back to the targets. tolerance is the drift in
+// percentage points accepted before rebalancing, 5 unless given, and
+// minimizeTaxes sells the lots that cost the most first.
+func (h *PortfolioHandler) Rebalance(c *gin.Context) {
+	tolerance := defaultRebalanceTolerance
+	if toleranceParam := c.Query("tolerance"); toleranceParam != "" {
+		var err error
+		tolerance, err = decimal.NewFromString(toleranceParam)
+		if err != nil || tolerance.IsNegative() || tolerance.GreaterThan(decimal.NewFromInt(100)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tolerance, expected 0 to 100"})
+			return
+		}
+	}
+
+	minimizeTaxes, err := strconv.ParseBool(c.DefaultQuery("minimizeTaxes", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid minimizeTaxes, expected true or false"})
+		return
+	}
+
+	rebalance, err := h.service.Rebalance(c.Request.Context(), tolerance, minimizeTaxes)
+	if err != nil {
+		writePortfolioError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, rebalance)
+}
+
+// Benchmark compares the portfolio to having put its cash flows into against,
+// an asset or a basket such as "bitcoin:60,ethereum:40", bitcoin unless given.
+// interval spaces the value series: day, week (the default) or month.
+func (h *PortfolioHandler) Benchmark(c *gin.Context) {
+	against, err := portfolio.ParseBasket(c.DefaultQuery("against", "bitcoin"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	benchmark, err := h.service.Benchmark(c.Request.Context(), against, c.DefaultQuery("interval", portfolio.IntervalWeek))
+	if err != nil {
+		writePortfolioError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, benchmark)
+}
+
+// Risk measures volatility, drawdown, Sharpe and Sortino ratios and the
+// correlation of the assets held over window, 90d unless given.
+func (h *PortfolioHandler) Risk(c *gin.Context) {
+	window, err := portfolio.ParseWindow(c.DefaultQuery("window", "90d"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	risk, err := h.service.Risk(c.Request.Context(), window)
+	if err != nil {
+		writePortfolioError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, risk)
+}
+
+func writePortfolioError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidPortfolioTargets), errors.Is(err, portfolio.ErrInvalidBasket), errors.Is(err, portfolio.ErrUnknownInterval):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrNoPortfolioTargets), errors.Is(err, portfolio.ErrNoPriceHistory):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"wallet-manager/prices"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PriceHandler struct {
+	cache *prices.Cache
+}
+
+func NewPriceHandler(cache *prices.Cache) *PriceHandler {
+	return &PriceHandler{cache: cache}
+}
+
+// GetMultiplePrices answers from the price cache. X-Price-Age tells, in
+// seconds, how old the oldest returned price is.
+func (h *PriceHandler) GetMultiplePrices(c *gin.Context) {
+	namesParam := c.Query("names")
+	if namesParam == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no names provided"})
+		return
+	}
+
+	names := strings.Split(namesParam, ",")
+	result, err := h.cache.Get(c.Request.Context(), names, c.DefaultQuery("currency", "usd"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("X-Price-Age", strconv.Itoa(int(result.Age.Seconds())))
+	c.JSON(http.StatusOK, result.Prices)
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"wallet-manager/models"
+	"wallet-manager/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReconciliationHandler struct {
+	service services.ReconciliationService
+}
+
+func NewReconciliationHandler(service services.ReconciliationService) *ReconciliationHandler {
+	return &ReconciliationHandler{service: service}
+}
+
+// Get lists the discrepancies found by the last reconciliation, or by a new
+// one when refresh is set.
+func (h *ReconciliationHandler) Get(c *gin.Context) {
+	refresh, err := strconv.ParseBool(c.DefaultQuery("refresh", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid refresh, expected true or false"})
+		return
+	}
+
+	report, err := h.service.Report(c.Request.Context(), refresh)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// Adjust posts the transaction that reconciles a holding with target: its
+// stored balance (the default), or onchain for the balance of its watched
+// addresses.
+func (h *ReconciliationHandler) Adjust(c *gin.Context) {
+	id, ok := cryptoParam(c)
+	if !ok {
+		return
+	}
+	target := c.DefaultQuery("target", models.ReconcileTargetBalance)
+	if target != models.ReconcileTargetBalance && target != models.ReconcileTargetOnChain {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid target, expected balance or onchain"})
+		return
+	}
+
+	adjustment, err := h.service.Adjust(c.Request.Context(), id, target)
+	if err != nil {
+		writeReconciliationError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, adjustment)
+}
+
+func writeReconciliationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrCryptocurrencyNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrAlreadyReconciled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrNoOnChainBalance), errors.Is(err, services.ErrInsufficientBalance):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
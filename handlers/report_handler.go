@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"wallet-manager/services"
+	"wallet-manager/tax"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReportHandler struct {
+	tax services.TaxService
+}
+
+func NewReportHandler(tax services.TaxService) *ReportHandler {
+	return &ReportHandler{tax: tax}
+}
+
+// Tax reports the capital gains of a year. format picks between json (the
+// default), csv and form8949.
+func (h *ReportHandler) Tax(c *gin.Context) {
+	year, ok := yearQuery(c)
+	if !ok {
+		return
+	}
+
+	method, err := tax.ParseMethod(c.DefaultQuery("method", string(tax.MethodFIFO)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	format := c.DefaultQuery("format", "json")
+	var write func(w io.Writer, report *tax.Report) error
+	switch format {
+	case "json":
+	case "csv":
+		write = tax.WriteCSV
+	case "form8949":
+		write = tax.WriteForm8949
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid format, expected json, csv or form8949"})
+		return
+	}
+
+	report, err := h.tax.Report(c.Request.Context(), year, method)
+	if err != nil {
+		writeReportError(c, err)
+		return
+	}
+
+	if write == nil {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("tax-%d-%s-%s.csv", year, method, format)))
+	c.Status(http.StatusOK)
+	if err := write(c.Writer, report); err != nil {
+		c.Error(err)
+	}
+}
+
+// BrazilTax reports the monthly GCAP summary and the Bens e Direitos position
+// of a year in BRL. rules picks the rule set, gcap unless given.
+func (h *ReportHandler) BrazilTax(c *gin.Context) {
+	year, ok := yearQuery(c)
+	if !ok {
+		return
+	}
+
+	rules, err := tax.ParseRuleSet(c.DefaultQuery("rules", "gcap"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := h.tax.Brazil(c.Request.Context(), year, rules)
+	if err != nil {
+		writeReportError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+func yearQuery(c *gin.Context) (int, bool) {
+	year, err := strconv.Atoi(c.Query("year"))
+	if err != nil || year < 1970 || year > 9999 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid year"})
+		return 0, false
+	}
+	return year, true
+}
+
+func writeReportError(c *gin.Context, err error) {
+	if errors.Is(err, tax.ErrInsufficientLots) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
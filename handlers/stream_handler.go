@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"wallet-manager/services"
+	"wallet-manager/stream"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	streamWriteWait = 10 * time.Second
+	streamReadLimit = 4096
+)
+
+// the API has no authentication yet, so there is nothing for a cross-origin
+// page to steal
+var upgrader = websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+
+type StreamHandler struct {
+	hub       *stream.Hub
+	service   services.StreamService
+	heartbeat time.Duration
+}
+
+func NewStreamHandler(hub *stream.Hub, service services.StreamService, heartbeat time.Duration) *StreamHandler {
+	return &StreamHandler{hub: hub, service: service, heartbeat: heartbeat}
+}
+
+// subscription is sent by WebSocket clients to replace their filter.
+type subscription struct {
+	Action string   `json:"action"`
+	Assets []string `json:"assets"`
+	Types  []string `json:"types"`
+}
+
+// SSE streams price ticks and holdings as Server-Sent Events, filtered by the
+// comma separated assets and types query parameters.
+func (h *StreamHandler) SSE(c *gin.Context) {
+	filter := streamFilter(c)
+	client := h.hub.Subscribe(filter)
+	defer h.hub.Unsubscribe(client)
+
+	snapshot, err := h.service.Snapshot(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	for _, message := range snapshot {
+		c.SSEvent(message.Type, message)
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-client.Dropped():
+			c.SSEvent("error", gin.H{"error": "client too slow, reconnect"})
+			c.Writer.Flush()
+			return
+		case message := <-client.Messages():
+			c.SSEvent(message.Type, message)
+			c.Writer.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(c.Writer, ": ping\n\n")
+			c.Writer.Flush()
+		}
+	}
+}
+
+// WebSocket streams the same messages as SSE. Clients change what they receive
+// by sending {"action": "subscribe", "assets": [...], "types": [...]}, which
+// is answered with a snapshot for the new filter.
+func (h *StreamHandler) WebSocket(c *gin.Context) {
+	filter := streamFilter(c)
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	client := h.hub.Subscribe(filter)
+	defer h.hub.Unsubscribe(client)
+
+	// gorilla allows one reader and one writer, so the reader only hands new
+	// filters over to the loop below, which does all the writing
+	filters := make(chan stream.Filter)
+	closed := make(chan struct{})
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() {
+		defer close(closed)
+		conn.SetReadLimit(streamReadLimit)
+		conn.SetReadDeadline(time.Now().Add(2 * h.heartbeat))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(2 * h.heartbeat))
+		})
+		for {
+			var sub subscription
+			if err := conn.ReadJSON(&sub); err != nil {
+				return
+			}
+			if sub.Action == "subscribe" {
+				select {
+				case filters <- stream.NewFilter(sub.Assets, sub.Types):
+				case <-stopped:
+					return
+				}
+			}
+		}
+	}()
+
+	if !h.sendSnapshot(c, conn, filter) {
+		return
+	}
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case filter := <-filters:
+			client.SetFilter(filter)
+			if !h.sendSnapshot(c, conn, filter) {
+				return
+			}
+		case <-client.Dropped():
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "client too slow"), time.Now().Add(streamWriteWait))
+			return
+		case message := <-client.Messages():
+			conn.SetWriteDeadline(time.Now().Add(streamWriteWait))
+			if err := conn.WriteJSON(message); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteWait)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (h *StreamHandler) sendSnapshot(c *gin.Context, conn *websocket.Conn, filter stream.Filter) bool {
+	snapshot, err := h.service.Snapshot(c.Request.Context(), filter)
+	if err != nil {
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseInternalServerErr, err.Error()), time.Now().Add(streamWriteWait))
+		return false
+	}
+	for _, message := range snapshot {
+		conn.SetWriteDeadline(time.Now().Add(streamWriteWait))
+		if err := conn.WriteJSON(message); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func streamFilter(c *gin.Context) stream.Filter {
+	return stream.NewFilter(splitQuery(c, "assets"), splitQuery(c, "types"))
+}
+
+func splitQuery(c *gin.Context, name string) []string {
+	if value := c.Query(name); value != "" {
+		return strings.Split(value, ",")
+	}
+	return nil
+}
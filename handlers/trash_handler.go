@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"net/http"
+	"wallet-manager/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TrashHandler struct {
+	service services.TrashService
+}
+
+func NewTrashHandler(service services.TrashService) *TrashHandler {
+	return &TrashHandler{service: service}
+}
+
+func (h *TrashHandler) GetAll(c *gin.Context) {
+	trash, err := h.service.GetAll(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, trash)
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"wallet-manager/models"
+	"wallet-manager/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WebhookHandler struct {
+	service services.WebhookService
+}
+
+func NewWebhookHandler(service services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{service: service}
+}
+
+func (h *WebhookHandler) Create(c *gin.Context) {
+	var subscription models.WebhookSubscription
+	if err := c.ShouldBindJSON(&subscription); err != nil {
+		c.JSON(http.StatusBadRequest, bindingError(err))
+		return
+	}
+
+	if err := h.service.CreateSubscription(c.Request.Context(), &subscription); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, subscription)
+}
+
+func (h *WebhookHandler) GetAll(c *gin.Context) {
+	subscriptions, err := h.service.GetSubscriptions(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, subscriptions)
+}
+
+func (h *WebhookHandler) GetByID(c *gin.Context) {
+	id, err := uintParam(c, "webhookId")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
+		return
+	}
+
+	subscription, err := h.service.GetSubscriptionByID(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if subscription == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrWebhookNotFound.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, subscription)
+}
+
+func (h *WebhookHandler) Delete(c *gin.Context) {
+	id, err := uintParam(c, "webhookId")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
+		return
+	}
+
+	if err := h.service.DeleteSubscription(c.Request.Context(), id); err != nil {
+		writeWebhookError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *WebhookHandler) GetDeliveries(c *gin.Context) {
+	id, err := uintParam(c, "webhookId")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
+		return
+	}
+
+	deliveries, err := h.service.GetDeliveries(c.Request.Context(), id)
+	if err != nil {
+		writeWebhookError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+func writeWebhookError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrWebhookNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,19 @@
+package middlewares
+
+import (
+	"wallet-manager/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const ActorHeader = "X-Actor"
+
+// Actor stores who is making the request in the request context, for the audit log.
+func Actor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if actor := c.GetHeader(ActorHeader); actor != "" {
+			c.Request = c.Request.WithContext(services.WithActor(c.Request.Context(), actor))
+		}
+		c.Next()
+	}
+}
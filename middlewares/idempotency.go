@@ -0,0 +1,145 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+	"wallet-manager/models"
+	"wallet-manager/repositories"
+
+	"github.com/gin-gonic/gin"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// headers worth replaying alongside the stored body
+var replayedHeaders = []string{"Content-Type", "ETag", "Location"}
+
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *bodyRecorder) WriteString(data string) (int, error) {
+	w.body.WriteString(data)
+	return w.ResponseWriter.WriteString(data)
+}
+
+// Idempotency makes POST requests carrying an Idempotency-Key header safe to retry:
+// the first response is stored for ttl and replayed for later requests with the same key.
+func Idempotency(repo repositories.IdempotencyKeyRepository, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" || c.Request.Method != http.MethodPost {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		record := models.IdempotencyKey{
+			Key:         key,
+			Method:      c.Request.Method,
+			Path:        c.Request.URL.Path,
+			RequestHash: requestHash(body),
+			ExpiresAt:   time.Now().Add(ttl),
+		}
+
+		reserved, err := repo.Reserve(c.Request.Context(), &record)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !reserved {
+			replay(c, repo, &record)
+			return
+		}
+
+		release := func() {
+			if err := repo.Delete(c.Request.Context(), record.Key, record.Method, record.Path); err != nil {
+				log.Printf("failed to release idempotency key %q: %v", key, err)
+			}
+		}
+		// a panicking handler must not leave the key reserved until it expires
+		defer func() {
+			if r := recover(); r != nil {
+				release()
+				panic(r)
+			}
+		}()
+
+		recorder := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// server errors are not stored so the client can retry them
+		if recorder.Status() >= http.StatusInternalServerError {
+			release()
+			return
+		}
+
+		status := recorder.Status()
+		record.StatusCode = &status
+		record.Headers, _ = json.Marshal(responseHeaders(recorder.Header()))
+		record.ResponseBody = recorder.body.Bytes()
+		if err := repo.SaveResponse(c.Request.Context(), &record); err != nil {
+			log.Printf("failed to store response for idempotency key %q: %v", key, err)
+			release()
+		}
+	}
+}
+
+func replay(c *gin.Context, repo repositories.IdempotencyKeyRepository, request *models.IdempotencyKey) {
+	stored, err := repo.Get(c.Request.Context(), request.Key, request.Method, request.Path)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	switch {
+	case stored != nil && stored.RequestHash != request.RequestHash:
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used with a different request"})
+	case stored == nil || stored.StatusCode == nil:
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "a request with this Idempotency-Key is still being processed"})
+	default:
+		var headers map[string]string
+		_ = stored.Headers.Unmarshal(&headers)
+		for name, value := range headers {
+			c.Header(name, value)
+		}
+		c.Header("Idempotent-Replayed", "true")
+		c.Status(*stored.StatusCode)
+		c.Writer.Write(stored.ResponseBody)
+		c.Abort()
+	}
+}
+
+func requestHash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+func responseHeaders(header http.Header) map[string]string {
+	headers := make(map[string]string, len(replayedHeaders))
+	for _, name := range replayedHeaders {
+		if value := header.Get(name); value != "" {
+			headers[name] = value
+		}
+	}
+	return headers
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	AlertRulePriceAbove    = "price_above"
+	AlertRulePriceBelow    = "price_below"
+	AlertRulePercentChange = "percent_change"
+	AlertRuleProfitBelow   = "profit_below"
+
+	AlertChannelWebhook = "webhook"
+	AlertChannelEmail   = "email"
+)
+
+// AlertRule watches an asset or a holding. Threshold is a price in USD, or a
+// percentage for percent_change and profit_below, where -10 is a loss of 10%.
+type AlertRule struct {
+	ID               uint32          `json:"id" db:"alert_rule_id"`
+	Type             string          `json:"type" db:"type" binding:"required,oneof=price_above price_below percent_change profit_below"`
+	Asset            string          `json:"asset,omitempty" db:"asset" binding:"required_unless=Type profit_below,max=100"`
+	CryptocurrencyId *uint32         `json:"cryptocurrencyId,omitempty" db:"cryptocurrency_id" binding:"required_if=Type profit_below"`
+	Threshold        decimal.Decimal `json:"threshold" db:"threshold"`
+	WindowMinutes    uint32          `json:"windowMinutes,omitempty" db:"window_minutes" binding:"required_if=Type percent_change"`
+	CooldownMinutes  uint32          `json:"cooldownMinutes" db:"cooldown_minutes"`
+	Channel          string          `json:"channel" db:"channel" binding:"required,oneof=webhook email"`
+	Target           string          `json:"target" db:"target" binding:"required,max=2048"`
+	Paused           bool            `json:"paused" db:"paused"`
+	Triggered        bool            `json:"triggered" db:"triggered"`
+	LastNotifiedAt   *time.Time      `json:"lastNotifiedAt,omitempty" db:"last_notified_at"`
+	CreatedDate      string          `json:"createdDate" db:"created_date"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+)
+
+type Asset struct {
+	ID       string `json:"id" db:"asset_id"`
+	Symbol   string `json:"symbol" db:"symbol"`
+	Name     string `json:"name" db:"name"`
+	Decimals int    `json:"decimals" db:"decimals"`
+	// Chain is the network of the native coin, empty for tokens.
+	Chain       string      `json:"chain,omitempty" db:"chain"`
+	ProviderIDs ProviderIDs `json:"providerIds" db:"provider_ids"`
+}
+
+// ProviderIDs maps a price provider name to the id it knows the asset by.
+type ProviderIDs map[string]string
+
+func (p ProviderIDs) Value() (driver.Value, error) {
+	if p == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(p)
+}
+
+func (p *ProviderIDs) Scan(src any) error {
+	data, ok := src.([]byte)
+	if !ok {
+		return errors.New("provider ids must be JSON")
+	}
+	return json.Unmarshal(data, p)
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	AuditActionCreate        = "create"
+	AuditActionUpdate        = "update"
+	AuditActionUpdateBalance = "update_balance"
+	AuditActionDelete        = "delete"
+	AuditActionRestore       = "restore"
+	AuditActionPurge         = "purge"
+
+	AuditEntityCryptocurrency    = "cryptocurrency"
+	AuditEntityCryptoTransaction = "crypto_transaction"
+)
+
+// AuditEntry is one change to an entity. Before is nil for creations and After
+// for deletions and purges; both are stored as NULL.
+type AuditEntry struct {
+	ID          uint64           `json:"id" db:"audit_id"`
+	Actor       string           `json:"actor" db:"actor"`
+	Action      string           `json:"action" db:"action"`
+	Entity      string           `json:"entity" db:"entity"`
+	EntityID    uint32           `json:"entityId" db:"entity_id"`
+	Before      *json.RawMessage `json:"before" db:"before"`
+	After       *json.RawMessage `json:"after" db:"after"`
+	CreatedDate string           `json:"createdDate" db:"created_date"`
+}
+
+type AuditFilter struct {
+	Entity   string
+	EntityID *uint32
+	From     *time.Time
+	To       *time.Time
+}
@@ -0,0 +1,11 @@
+package models
+
+import "github.com/shopspring/decimal"
+
+type CryptoPrice struct {
+	ID          uint64          `json:"id" db:"id"`
+	Name        string          `json:"name" db:"name"`
+	PriceUSD    decimal.Decimal `json:"priceUsd" db:"price_usd"`
+	UpdatedDate string          `json:"updatedDate" db:"updated_date"`
+	Source      string          `json:"source" db:"source"`
+}
@@ -2,11 +2,39 @@ package models
 
 import "github.com/shopspring/decimal"
 
+const (
+	TransactionTypeBuy  = "buy"
+	TransactionTypeSell = "sell"
+	// TransactionTypeAdjustment is posted by reconciliation, see Reconciliation.
+	TransactionTypeAdjustment = "adjustment"
+	// TransactionTypeOpeningBalance records the balances a holding was created with.
+	TransactionTypeOpeningBalance = "opening_balance"
+
+	TransactionStatusPlanned   = "planned"
+	TransactionStatusConfirmed = "confirmed"
+	TransactionStatusSkipped   = "skipped"
+)
+
 type CryptoTransaction struct {
-	ID                   uint32          `json:"id" db:"transaction_id"`
-	CryptocurrencyId     uint32          `json:"cryptocurrency_id" db:"cryptocurrency_id"`
-	CryptocurrencyAmount decimal.Decimal `json:"cryptocurrencyAmount" db:"cryptocurrency_amount"`
-	FiatAmount           decimal.Decimal `json:"fiatAmount" db:"fiat_amount"`
-	PurchaseDate         string          `json:"purchaseDate" db:"purchase_date"`
+	ID               uint32 `json:"id" db:"transaction_id"`
+	CryptocurrencyId uint32 `json:"cryptocurrency_id" db:"cryptocurrency_id"`
+	// Type is buy unless given. Amounts are positive either way; for a sell
+	// FiatAmount holds the proceeds. The amounts of an adjustment are signed
+	// and added to the balances as they are. Adjustments and opening balances
+	// are never posted by clients.
+	Type string `json:"type" db:"type" binding:"omitempty,oneof=buy sell"`
+	// Status and PlanID are set by DCA plans, see DCAPlan.
+	Status string  `json:"status" db:"status"`
+	PlanID *uint32 `json:"planId,omitempty" db:"plan_id"`
+	// ExternalID is the on-chain transaction recorded, see WatchedAddress.
+	ExternalID *string `json:"externalId,omitempty" db:"external_id" binding:"omitempty,max=255"`
+	// Address is where a sell was sent or a buy came from, checked against the
+	// chain of the asset.
+	Address              *string         `json:"address,omitempty" db:"address" binding:"omitempty,max=255"`
+	CryptocurrencyAmount decimal.Decimal `json:"cryptocurrencyAmount" db:"cryptocurrency_amount" binding:"decimal_gt0"`
+	FiatAmount           decimal.Decimal `json:"fiatAmount" db:"fiat_amount" binding:"decimal_gt0"`
+	PurchaseDate         string          `json:"purchaseDate" db:"purchase_date" binding:"required,rfc3339"`
 	CreatedDate          string          `json:"createdDate" db:"created_date"`
+	Version              uint32          `json:"version" db:"version"`
+	DeletedAt            *string         `json:"deletedAt,omitempty" db:"deleted_at"`
 }
@@ -2,12 +2,19 @@ package models
 
 import "github.com/shopspring/decimal"
 
+// Cryptocurrency is a holding. Its Balance and CostInFiat are derived from its
+// transactions: given on creation they become its opening balance, and updates
+// ignore them.
 type Cryptocurrency struct {
-	ID               uint32          `json:"id" db:"cryptocurrency_id"`
-	Name             string          `json:"name" db:"name"`
-	Balance          decimal.Decimal `json:"balance" db:"balance"`
-	CostInFiat       decimal.Decimal `json:"fiatBalance" db:"fiat_balance"`
+	ID      uint32 `json:"id" db:"cryptocurrency_id"`
+	AssetID string `json:"assetId" db:"asset_id" binding:"required,max=100,known_asset"`
+	// Name is a label for the holding, the asset display name unless given.
+	Name             string          `json:"name" db:"name" binding:"max=100"`
+	Balance          decimal.Decimal `json:"balance" db:"balance" binding:"decimal_gte0"`
+	CostInFiat       decimal.Decimal `json:"fiatBalance" db:"fiat_balance" binding:"decimal_gte0"`
 	CreatedDate      string          `json:"createdDate" db:"created_date"`
 	ProfitPercentage float32         `db:"profit_percentage"`
 	ProfitUSD        float32         `db:"usd_profit"`
+	Version          uint32          `json:"version" db:"version"`
+	DeletedAt        *string         `json:"deletedAt,omitempty" db:"deleted_at"`
 }
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	DCAExecutionScheduled = "scheduled"
+	DCAExecutionPlanned   = "planned"
+)
+
+// DCAPlan buys FiatAmount of a holding at each time of Schedule, a standard
+// five field cron expression in UTC, from StartDate until EndDate.
+type DCAPlan struct {
+	ID               uint32          `json:"id" db:"plan_id"`
+	CryptocurrencyId uint32          `json:"cryptocurrencyId" db:"cryptocurrency_id" binding:"required"`
+	FiatAmount       decimal.Decimal `json:"fiatAmount" db:"fiat_amount" binding:"decimal_gt0"`
+	Schedule         string          `json:"schedule" db:"schedule" binding:"required,max=100"`
+	StartDate        time.Time       `json:"startDate" db:"start_date" binding:"required"`
+	EndDate          *time.Time      `json:"endDate,omitempty" db:"end_date"`
+	NextRun          *time.Time      `json:"nextRun,omitempty" db:"next_run"`
+	CreatedDate      string          `json:"createdDate" db:"created_date"`
+}
+
+// DCAExecution is a run of a plan: scheduled when it is still to come, planned
+// when its transaction awaits confirmation.
+type DCAExecution struct {
+	ScheduledAt time.Time          `json:"scheduledAt"`
+	Status      string             `json:"status"`
+	Transaction *CryptoTransaction `json:"transaction,omitempty"`
+}
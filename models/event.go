@@ -0,0 +1,32 @@
+package models
+
+const (
+	EventTransactionCreated  = "transaction.created"
+	EventTransactionUpdated  = "transaction.updated"
+	EventTransactionDeleted  = "transaction.deleted"
+	EventTransactionRestored = "transaction.restored"
+
+	EventHoldingCreated        = "holding.created"
+	EventHoldingUpdated        = "holding.updated"
+	EventHoldingDeleted        = "holding.deleted"
+	EventHoldingRestored       = "holding.restored"
+	EventHoldingBalanceChanged = "holding.balance_changed"
+
+	EventPriceUpdated    = "price.updated"
+	EventPricesRefreshed = "prices.refreshed"
+)
+
+// WebhookEventTypes are the events webhook subscriptions can ask for; keep the
+// oneof tag of WebhookSubscription.EventTypes in sync.
+var WebhookEventTypes = []string{
+	EventTransactionCreated,
+	EventTransactionUpdated,
+	EventTransactionDeleted,
+	EventTransactionRestored,
+	EventHoldingCreated,
+	EventHoldingUpdated,
+	EventHoldingDeleted,
+	EventHoldingRestored,
+	EventHoldingBalanceChanged,
+	EventPriceUpdated,
+}
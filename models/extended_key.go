@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// ExtendedKey is the account public key (xpub, ypub or zpub) of a wallet that
+// rotates addresses. Its addresses are derived and watched as they get used;
+// a scan stops after GapLimit unused addresses in a row on each branch.
+type ExtendedKey struct {
+	ID               uint32 `json:"id" db:"key_id"`
+	CryptocurrencyId uint32 `json:"cryptocurrencyId" db:"cryptocurrency_id"`
+	Key              string `json:"key" db:"extended_key" binding:"required,max=120"`
+	// ScriptType is the one of the key prefix unless given.
+	ScriptType string `json:"scriptType" db:"script_type" binding:"omitempty,oneof=p2pkh p2sh-p2wpkh p2wpkh"`
+	// GapLimit is 20 unless given.
+	GapLimit uint32 `json:"gapLimit" db:"gap_limit" binding:"omitempty,max=1000"`
+	Label    string `json:"label" db:"label" binding:"max=100"`
+	// ReceiveIndex and ChangeIndex are the first unused address of each branch.
+	ReceiveIndex uint32     `json:"receiveIndex" db:"receive_index"`
+	ChangeIndex  uint32     `json:"changeIndex" db:"change_index"`
+	ScannedAt    *time.Time `json:"scannedAt,omitempty" db:"scanned_at"`
+	CreatedDate  string     `json:"createdDate" db:"created_date"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx/types"
+)
+
+type IdempotencyKey struct {
+	Key          string         `db:"idempotency_key"`
+	Method       string         `db:"method"`
+	Path         string         `db:"path"`
+	RequestHash  string         `db:"request_hash"`
+	StatusCode   *int           `db:"status_code"`
+	Headers      types.JSONText `db:"response_headers"`
+	ResponseBody []byte         `db:"response_body"`
+	CreatedDate  time.Time      `db:"created_date"`
+	ExpiresAt    time.Time      `db:"expires_at"`
+}
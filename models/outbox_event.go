@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx/types"
+	"github.com/lib/pq"
+)
+
+type OutboxEvent struct {
+	ID            uint64         `json:"id" db:"event_id"`
+	EventType     string         `json:"eventType" db:"event_type"`
+	Payload       types.JSONText `json:"payload" db:"payload"`
+	HandledBy     pq.StringArray `json:"handledBy" db:"handled_by"`
+	Attempts      int            `json:"attempts" db:"attempts"`
+	NextAttemptAt time.Time      `json:"nextAttemptAt" db:"next_attempt_at"`
+	LastError     *string        `json:"lastError,omitempty" db:"last_error"`
+	CreatedDate   time.Time      `json:"createdDate" db:"created_date"`
+	DispatchedAt  *time.Time     `json:"dispatchedAt,omitempty" db:"dispatched_at"`
+}
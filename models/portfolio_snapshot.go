@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// PortfolioSnapshot is the value of the holdings at the prices of the day,
+// along with the fiat put in them: the sum of their fiatBalance.
+type PortfolioSnapshot struct {
+	Date        time.Time       `json:"date" db:"snapshot_date"`
+	Value       decimal.Decimal `json:"value" db:"value"`
+	NetInvested decimal.Decimal `json:"netInvested" db:"net_invested"`
+	RecordedAt  time.Time       `json:"recordedAt" db:"recorded_at"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "github.com/shopspring/decimal"
+
+// PortfolioTarget is the share of the portfolio value, in percent, an asset
+// should have. AssetID "others" covers every asset without a target.
+type PortfolioTarget struct {
+	AssetID    string          `json:"assetId" db:"asset_id" binding:"required,max=100"`
+	Percentage decimal.Decimal `json:"percentage" db:"percentage" binding:"decimal_gt0"`
+}
+
+// PortfolioTargets is the whole target allocation, replaced at once so the
+// percentages always add up to 100.
+type PortfolioTargets struct {
+	Targets []PortfolioTarget `json:"targets" binding:"required,min=1,dive"`
+}
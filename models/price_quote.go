@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// PriceQuote is a cached upstream price of an asset in a quote currency.
+type PriceQuote struct {
+	Asset     string          `json:"asset" db:"asset"`
+	Currency  string          `json:"currency" db:"currency"`
+	Price     decimal.Decimal `json:"price" db:"price"`
+	FetchedAt time.Time       `json:"fetchedAt" db:"fetched_at"`
+}
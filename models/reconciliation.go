@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// what an adjustment brings the balance of a holding to
+const (
+	ReconcileTargetBalance = "balance"
+	ReconcileTargetOnChain = "onchain"
+)
+
+// Reconciliation compares the stored totals of a holding to what its
+// confirmed transactions add up to, and its balance to the one of its watched
+// addresses when there are any.
+type Reconciliation struct {
+	CryptocurrencyID  uint32           `json:"cryptocurrencyId" db:"cryptocurrency_id"`
+	AssetID           string           `json:"assetId" db:"asset_id"`
+	Balance           decimal.Decimal  `json:"balance" db:"balance"`
+	LedgerBalance     decimal.Decimal  `json:"ledgerBalance" db:"ledger_balance"`
+	FiatBalance       decimal.Decimal  `json:"fiatBalance" db:"fiat_balance"`
+	LedgerFiatBalance decimal.Decimal  `json:"ledgerFiatBalance" db:"ledger_fiat_balance"`
+	OnChainBalance    *decimal.Decimal `json:"onChainBalance,omitempty" db:"onchain_balance"`
+	// BalanceDifference and FiatDifference are the stored totals less the
+	// ledger ones, OnChainDifference the on-chain balance less the stored one.
+	BalanceDifference decimal.Decimal  `json:"balanceDifference" db:"-"`
+	FiatDifference    decimal.Decimal  `json:"fiatDifference" db:"-"`
+	OnChainDifference *decimal.Decimal `json:"onChainDifference,omitempty" db:"-"`
+	CheckedAt         time.Time        `json:"checkedAt" db:"checked_at"`
+}
+
+type ReconciliationReport struct {
+	Holdings      int              `json:"holdings"`
+	Discrepancies []Reconciliation `json:"discrepancies"`
+}
+
+// Adjustment is the transaction posted to reconcile a holding, along with the
+// reconciliation that follows.
+type Adjustment struct {
+	Transaction    CryptoTransaction `json:"transaction"`
+	Reconciliation Reconciliation    `json:"reconciliation"`
+}
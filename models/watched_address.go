@@ -0,0 +1,36 @@
+package models
+
+import "github.com/shopspring/decimal"
+
+// WatchedAddress is a blockchain address of a holding, read through the
+// indexer of the chain of its asset.
+type WatchedAddress struct {
+	ID               uint32 `json:"id" db:"address_id"`
+	CryptocurrencyId uint32 `json:"cryptocurrencyId" db:"cryptocurrency_id"`
+	Address          string `json:"address" db:"address" binding:"required,max=255"`
+	Label            string `json:"label" db:"label" binding:"max=100"`
+	// KeyID and DerivationPath, as in 0/5, are set on the addresses derived
+	// from an ExtendedKey.
+	KeyID          *uint32 `json:"keyId,omitempty" db:"key_id"`
+	DerivationPath *string `json:"derivationPath,omitempty" db:"derivation_path"`
+	CreatedDate    string  `json:"createdDate" db:"created_date"`
+}
+
+type AddressBalance struct {
+	Address string          `json:"address"`
+	Label   string          `json:"label,omitempty"`
+	Balance decimal.Decimal `json:"balance"`
+}
+
+// OnChainStatus compares the balance of a holding in the ledger to the one of
+// its watched addresses. Proposals are the transactions that would record the
+// on-chain movements missing from the ledger.
+type OnChainStatus struct {
+	CryptocurrencyID uint32          `json:"cryptocurrencyId"`
+	LedgerBalance    decimal.Decimal `json:"ledgerBalance"`
+	OnChainBalance   decimal.Decimal `json:"onChainBalance"`
+	// Difference is the on-chain balance less the ledger one.
+	Difference decimal.Decimal     `json:"difference"`
+	Addresses  []AddressBalance    `json:"addresses"`
+	Proposals  []CryptoTransaction `json:"proposals"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx/types"
+	"github.com/lib/pq"
+)
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed    = "failed"
+)
+
+type WebhookSubscription struct {
+	ID          uint32         `json:"id" db:"webhook_subscription_id"`
+	URL         string         `json:"url" db:"url" binding:"required,url,max=2048"`
+	Secret      string         `json:"secret,omitempty" db:"secret" binding:"max=255"`
+	EventTypes  pq.StringArray `json:"eventTypes" db:"event_types" binding:"required,min=1,dive,oneof=transaction.created transaction.updated transaction.deleted transaction.restored holding.created holding.updated holding.deleted holding.restored holding.balance_changed price.updated"`
+	CreatedDate string         `json:"createdDate" db:"created_date"`
+}
+
+type WebhookDelivery struct {
+	ID             uint64         `json:"id" db:"delivery_id"`
+	SubscriptionID uint32         `json:"subscriptionId" db:"webhook_subscription_id"`
+	EventType      string         `json:"eventType" db:"event_type"`
+	Payload        types.JSONText `json:"payload" db:"payload"`
+	Status         string         `json:"status" db:"status"`
+	Attempts       int            `json:"attempts" db:"attempts"`
+	NextAttemptAt  time.Time      `json:"nextAttemptAt" db:"next_attempt_at"`
+	LastStatusCode *int           `json:"lastStatusCode,omitempty" db:"last_status_code"`
+	LastError      *string        `json:"lastError,omitempty" db:"last_error"`
+	CreatedDate    string         `json:"createdDate" db:"created_date"`
+	DeliveredAt    *time.Time     `json:"deliveredAt,omitempty" db:"delivered_at"`
+}
@@ -0,0 +1,46 @@
+package notifiers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"wallet-manager/config"
+)
+
+type emailNotifier struct {
+	cfg *config.SMTPConfig
+}
+
+func NewEmailNotifier(cfg *config.SMTPConfig) Notifier {
+	return &emailNotifier{cfg: cfg}
+}
+
+func (n *emailNotifier) Notify(ctx context.Context, target string, notification Notification) error {
+	var auth smtp.Auth
+	if n.cfg.Username != "" {
+		host, _, err := net.SplitHostPort(n.cfg.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", n.cfg.Username, n.cfg.Password, host)
+	}
+
+	return smtp.SendMail(n.cfg.Addr, auth, n.cfg.From, []string{target}, emailMessage(n.cfg.From, target, notification))
+}
+
+func emailMessage(from string, to string, notification Notification) []byte {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: Wallet alert: %s\r\n", notification.Message)
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("\r\n")
+	fmt.Fprintf(&msg, "%s\r\n\r\n", notification.Message)
+	fmt.Fprintf(&msg, "Rule: %d (%s)\r\n", notification.RuleID, notification.Type)
+	fmt.Fprintf(&msg, "Threshold: %s\r\n", notification.Threshold)
+	fmt.Fprintf(&msg, "Value: %s\r\n", notification.Value)
+	fmt.Fprintf(&msg, "Triggered at: %s\r\n", notification.TriggeredAt)
+	return []byte(msg.String())
+}
@@ -0,0 +1,24 @@
+package notifiers
+
+import (
+	"context"
+
+	"github.com/shopspring/decimal"
+)
+
+// Notification is what gets sent when an alert rule is triggered.
+type Notification struct {
+	RuleID      uint32          `json:"ruleId"`
+	Type        string          `json:"type"`
+	Asset       string          `json:"asset,omitempty"`
+	Threshold   decimal.Decimal `json:"threshold"`
+	Value       decimal.Decimal `json:"value"`
+	Message     string          `json:"message"`
+	TriggeredAt string          `json:"triggeredAt"`
+}
+
+// Notifier delivers a notification to target, whose format depends on the
+// channel: a URL for webhooks, an address for email.
+type Notifier interface {
+	Notify(ctx context.Context, target string, notification Notification) error
+}
@@ -0,0 +1,42 @@
+package notifiers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+type webhookNotifier struct {
+	client *http.Client
+}
+
+func NewWebhookNotifier(client *http.Client) Notifier {
+	return &webhookNotifier{client: client}
+}
+
+// Notify posts the notification as JSON; any non-2xx response is an error.
+func (n *webhookNotifier) Notify(ctx context.Context, target string, notification Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package portfolio
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"wallet-manager/prices"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	IntervalDay   = "day"
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+)
+
+var (
+	ErrUnknownInterval = errors.New("unknown interval")
+	ErrInvalidBasket   = errors.New("invalid benchmark basket")
+	ErrNoPriceHistory  = errors.New("no price history")
+)
+
+// ParseBasket reads what to benchmark against: an asset, or a basket of
+// asset:percentage pairs separated by commas, as in "bitcoin:60,ethereum:40".
+// Assets listed without percentages get equal weights.
+func ParseBasket(against string) ([]Target, error) {
+	var basket []Target
+	weighted := 0
+	for _, entry := range strings.Split(against, ",") {
+		asset, percentage, hasPercentage := strings.Cut(strings.TrimSpace(entry), ":")
+		if asset == "" {
+			return nil, fmt.Errorf("%w: empty asset in %q", ErrInvalidBasket, against)
+		}
+		target := Target{AssetID: asset}
+		if hasPercentage {
+			var err error
+			if target.Percentage, err = decimal.NewFromString(percentage); err != nil || !target.Percentage.IsPositive() {
+				return nil, fmt.Errorf("%w: invalid percentage %q", ErrInvalidBasket, percentage)
+			}
+			weighted++
+		}
+		basket = append(basket, target)
+	}
+
+	switch weighted {
+	case 0:
+		for i := range basket {
+			basket[i].Percentage = hundred.Div(decimal.NewFromInt(int64(len(basket))))
+		}
+	case len(basket):
+		total := decimal.Zero
+		for _, target := range basket {
+			total = total.Add(target.Percentage)
+		}
+		if !total.Equal(hundred) {
+			return nil, fmt.Errorf("%w: percentages add up to %s, expected 100", ErrInvalidBasket, total)
+		}
+	default:
+		return nil, fmt.Errorf("%w: give a percentage for every asset or none", ErrInvalidBasket)
+	}
+	return basket, nil
+}
+
+// CashFlow is a confirmed transaction: a buy puts fiat into an asset and a
+// sell takes its proceeds out, so both Amount and Fiat are negative for sells.
+type CashFlow struct {
+	Date    time.Time
+	AssetID string
+	Amount  decimal.Decimal
+	Fiat    decimal.Decimal
+}
+
+type BenchmarkPoint struct {
+	Date        time.Time       `json:"date"`
+	NetInvested decimal.Decimal `json:"netInvested"`
+	Portfolio   decimal.Decimal `json:"portfolio"`
+	Benchmark   decimal.Decimal `json:"benchmark"`
+}
+
+// Performance is a value with its gain over the fiat put in and taken out, and
+// the gain in percent of the fiat put in.
+type Performance struct {
+	Value  decimal.Decimal `json:"value"`
+	Gain   decimal.Decimal `json:"gain"`
+	Return decimal.Decimal `json:"return"`
+}
+
+type Benchmark struct {
+	Against   []Target        `json:"against"`
+	Interval  string          `json:"interval"`
+	Deposited decimal.Decimal `json:"deposited"`
+	Withdrawn decimal.Decimal `json:"withdrawn"`
+	Portfolio Performance     `json:"portfolio"`
+	Benchmark Performance     `json:"benchmark"`
+	// RelativePerformance is the return of the portfolio less the return of
+	// the benchmark, in percentage points.
+	RelativePerformance decimal.Decimal  `json:"relativePerformance"`
+	Series              []BenchmarkPoint `json:"series"`
+}
+
+// Compare replays the cash flows into the benchmark: each buy puts its fiat
+// into the benchmark assets by their percentages, at their prices of the day,
+// and each sell takes its proceeds out of the benchmark in proportion to what
+// it holds. Both are valued every interval from the first cash flow until now.
+func Compare(flows []CashFlow, history map[string]prices.Series, against []Target, interval string, now time.Time) (*Benchmark, error) {
+	step, err := intervalStep(interval)
+	if err != nil {
+		return nil, err
+	}
+
+	flows = append([]CashFlow(nil), flows...)
+	sort.SliceStable(flows, func(i, j int) bool { return flows[i].Date.Before(flows[j].Date) })
+
+	benchmark := &Benchmark{Against: against, Interval: interval, Series: []BenchmarkPoint{}}
+	if len(flows) == 0 {
+		return benchmark, nil
+	}
+
+	portfolioUnits := map[string]decimal.Decimal{}
+	benchmarkUnits := map[string]decimal.Decimal{}
+	deposited, withdrawn := decimal.Zero, decimal.Zero
+	next := 0
+	var portfolioValue, benchmarkValue decimal.Decimal
+	for _, date := range sampleDates(flows[0].Date, now, step) {
+		for ; next < len(flows) && !flows[next].Date.After(date); next++ {
+			flow := flows[next]
+			portfolioUnits[flow.AssetID] = portfolioUnits[flow.AssetID].Add(flow.Amount)
+			if flow.Fiat.IsPositive() {
+				deposited = deposited.Add(flow.Fiat)
+			} else {
+				withdrawn = withdrawn.Sub(flow.Fiat)
+			}
+			if err := invest(benchmarkUnits, flow, history, against); err != nil {
+				return nil, err
+			}
+		}
+
+		if portfolioValue, err = value(portfolioUnits, history, date); err != nil {
+			return nil, err
+		}
+		if benchmarkValue, err = value(benchmarkUnits, history, date); err != nil {
+			return nil, err
+		}
+		benchmark.Series = append(benchmark.Series, BenchmarkPoint{
+			Date:        date,
+			NetInvested: deposited.Sub(withdrawn).Round(2),
+			Portfolio:   portfolioValue.Round(2),
+			Benchmark:   benchmarkValue.Round(2),
+		})
+	}
+
+	benchmark.Deposited = deposited.Round(2)
+	benchmark.Withdrawn = withdrawn.Round(2)
+	benchmark.Portfolio = performance(portfolioValue, deposited, withdrawn)
+	benchmark.Benchmark = performance(benchmarkValue, deposited, withdrawn)
+	benchmark.RelativePerformance = benchmark.Portfolio.Return.Sub(benchmark.Benchmark.Return)
+	return benchmark, nil
+}
+
+// invest applies a cash flow of the portfolio to the benchmark.
+func invest(units map[string]decimal.Decimal, flow CashFlow, history map[string]prices.Series, against []Target) error {
+	if flow.Fiat.IsPositive() {
+		for _, target := range against {
+			price, err := priceAt(history, target.AssetID, flow.Date)
+			if err != nil {
+				return err
+			}
+			bought := flow.Fiat.Mul(target.Percentage).Div(hundred).Div(price)
+			units[target.AssetID] = units[target.AssetID].Add(bought)
+		}
+		return nil
+	}
+
+	held, err := value(units, history, flow.Date)
+	if err != nil || !held.IsPositive() {
+		return err
+	}
+	kept := decimal.Max(decimal.Zero, decimal.NewFromInt(1).Sub(flow.Fiat.Neg().Div(held)))
+	for asset, amount := range units {
+		units[asset] = amount.Mul(kept)
+	}
+	return nil
+}
+
+func value(units map[string]decimal.Decimal, history map[string]prices.Series, date time.Time) (decimal.Decimal, error) {
+	total := decimal.Zero
+	for asset, amount := range units {
+		if amount.IsZero() {
+			continue
+		}
+		price, err := priceAt(history, asset, date)
+		if err != nil {
+			return decimal.Zero, err
+		}
+		total = total.Add(amount.Mul(price))
+	}
+	return total, nil
+}
+
+func priceAt(history map[string]prices.Series, asset string, date time.Time) (decimal.Decimal, error) {
+	price, ok := history[asset].At(date)
+	if !ok || !price.IsPositive() {
+		return decimal.Zero, fmt.Errorf("%w: %s", ErrNoPriceHistory, asset)
+	}
+	return price, nil
+}
+
+func performance(value, deposited, withdrawn decimal.Decimal) Performance {
+	gain := value.Add(withdrawn).Sub(deposited)
+	performance := Performance{Value: value.Round(2), Gain: gain.Round(2), Return: decimal.Zero}
+	if deposited.IsPositive() {
+		performance.Return = gain.Mul(hundred).Div(deposited).Round(2)
+	}
+	return performance
+}
+
+func intervalStep(interval string) (func(time.Time) time.Time, error) {
+	switch interval {
+	case IntervalDay:
+		return func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }, nil
+	case IntervalWeek:
+		return func(t time.Time) time.Time { return t.AddDate(0, 0, 7) }, nil
+	case IntervalMonth:
+		return func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownInterval, interval)
+}
+
+// sampleDates steps from start up to now, which always ends the series.
+func sampleDates(start, now time.Time, step func(time.Time) time.Time) []time.Time {
+	var dates []time.Time
+	for date := start; date.Before(now); date = step(date) {
+		dates = append(dates, date)
+	}
+	return append(dates, now)
+}
@@ -0,0 +1,234 @@
+package portfolio
+
+import (
+	"sort"
+
+	"github.com/shopspring/decimal"
+)
+
+// Others is the target of every asset without one of its own.
+const Others = "others"
+
+const (
+	ActionBuy  = "buy"
+	ActionSell = "sell"
+)
+
+var (
+	hundred = decimal.NewFromInt(100)
+	// trades worth less than a cent are left out
+	minTradeValue = decimal.New(1, -2)
+)
+
+// Target is the share of the portfolio value, in percent, an asset should have.
+type Target struct {
+	AssetID    string
+	Percentage decimal.Decimal
+}
+
+// Position is a holding valued at the current price. Assets with a target but
+// not held yet come with a zero amount, and a zero price when none is known.
+type Position struct {
+	AssetID          string
+	CryptocurrencyID uint32
+	Amount           decimal.Decimal
+	Price            decimal.Decimal
+	// Decimals the quantities to trade are rounded to.
+	Decimals int32
+}
+
+func (p Position) value() decimal.Decimal {
+	return p.Amount.Mul(p.Price)
+}
+
+// Allocation compares the weight of a target, in percent of the portfolio
+// value, to the target.
+type Allocation struct {
+	AssetID   string          `json:"assetId"`
+	Value     decimal.Decimal `json:"value"`
+	Weight    decimal.Decimal `json:"weight"`
+	Target    decimal.Decimal `json:"target"`
+	Drift     decimal.Decimal `json:"drift"`
+	OutOfBand bool            `json:"outOfBand"`
+}
+
+// Trade is a buy or a sell that brings an asset back to its target. Quantity
+// is missing when the asset has no price, or for a buy of others while no
+// other asset is held.
+type Trade struct {
+	AssetID          string           `json:"assetId"`
+	CryptocurrencyID uint32           `json:"cryptocurrencyId,omitempty"`
+	Action           string           `json:"action"`
+	Quantity         *decimal.Decimal `json:"quantity,omitempty"`
+	Value            decimal.Decimal  `json:"value"`
+	// EstimatedGain is the taxable gain of a sell, when the lots cover it.
+	EstimatedGain *decimal.Decimal `json:"estimatedGain,omitempty"`
+}
+
+type Rebalance struct {
+	TotalValue  decimal.Decimal `json:"totalValue"`
+	Tolerance   decimal.Decimal `json:"tolerance"`
+	Balanced    bool            `json:"balanced"`
+	Allocations []Allocation    `json:"allocations"`
+	Trades      []Trade         `json:"trades"`
+}
+
+type bucket struct {
+	target    decimal.Decimal
+	positions []Position
+}
+
+func (b *bucket) value() decimal.Decimal {
+	value := decimal.Zero
+	for _, position := range b.positions {
+		value = value.Add(position.value())
+	}
+	return value
+}
+
+// Plan compares the weights of the positions to the targets. Assets without a
+// target count towards others, with a target of zero when there is none for
+// them. The portfolio is balanced while every drift stays within tolerance
+// percentage points; past it, every target is brought back to its weight,
+// the sells paying for the buys.
+func Plan(positions []Position, targets []Target, tolerance decimal.Decimal) *Rebalance {
+	buckets := map[string]*bucket{}
+	for _, target := range targets {
+		buckets[target.AssetID] = &bucket{target: target.Percentage}
+	}
+	for _, position := range positions {
+		b, ok := buckets[position.AssetID]
+		if !ok {
+			if !position.Amount.IsPositive() {
+				continue
+			}
+			if b, ok = buckets[Others]; !ok {
+				b = &bucket{target: decimal.Zero}
+				buckets[Others] = b
+			}
+		}
+		b.positions = append(b.positions, position)
+	}
+
+	ids := make([]string, 0, len(buckets))
+	total := decimal.Zero
+	for id, b := range buckets {
+		ids = append(ids, id)
+		total = total.Add(b.value())
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if (ids[i] == Others) != (ids[j] == Others) {
+			return ids[j] == Others
+		}
+		return ids[i] < ids[j]
+	})
+
+	rebalance := &Rebalance{
+		TotalValue:  total.Round(2),
+		Tolerance:   tolerance,
+		Balanced:    true,
+		Allocations: make([]Allocation, 0, len(ids)),
+		Trades:      []Trade{},
+	}
+	for _, id := range ids {
+		b := buckets[id]
+		value := b.value()
+		weight := decimal.Zero
+		if total.IsPositive() {
+			weight = value.Mul(hundred).Div(total)
+		}
+		drift := weight.Sub(b.target)
+		outOfBand := total.IsPositive() && drift.Abs().GreaterThan(tolerance)
+		rebalance.Balanced = rebalance.Balanced && !outOfBand
+		rebalance.Allocations = append(rebalance.Allocations, Allocation{
+			AssetID:   id,
+			Value:     value.Round(2),
+			Weight:    weight.Round(2),
+			Target:    b.target,
+			Drift:     drift.Round(2),
+			OutOfBand: outOfBand,
+		})
+	}
+	if rebalance.Balanced {
+		return rebalance
+	}
+
+	var sells, buys []Trade
+	for _, id := range ids {
+		b := buckets[id]
+		difference := total.Mul(b.target).Div(hundred).Sub(b.value())
+		for _, trade := range b.trades(id, difference) {
+			if trade.Action == ActionSell {
+				sells = append(sells, trade)
+			} else {
+				buys = append(buys, trade)
+			}
+		}
+	}
+	rebalance.Trades = append(append(rebalance.Trades, sells...), buys...)
+	return rebalance
+}
+
+// trades split the difference to the target value across the positions of
+// the bucket in proportion to their value.
+func (b *bucket) trades(id string, difference decimal.Decimal) []Trade {
+	if difference.Abs().LessThan(minTradeValue) {
+		return nil
+	}
+
+	value := b.value()
+	if !value.IsPositive() {
+		// nothing held yet: the asset of the target is bought, while a buy of
+		// others is left to pick
+		trade := Trade{AssetID: id, Action: ActionBuy, Value: difference.Round(2)}
+		if len(b.positions) > 0 {
+			trade = b.positions[0].trade(difference)
+		}
+		return []Trade{trade}
+	}
+
+	var trades []Trade
+	remaining := difference
+	held := 0
+	for _, position := range b.positions {
+		if position.value().IsPositive() {
+			held++
+		}
+	}
+	for _, position := range b.positions {
+		if !position.value().IsPositive() {
+			continue
+		}
+		held--
+		share := remaining
+		if held > 0 {
+			share = difference.Mul(position.value()).Div(value).Round(2)
+		}
+		remaining = remaining.Sub(share)
+		if share.Abs().LessThan(minTradeValue) {
+			continue
+		}
+		trades = append(trades, position.trade(share))
+	}
+	return trades
+}
+
+func (p Position) trade(value decimal.Decimal) Trade {
+	trade := Trade{
+		AssetID:          p.AssetID,
+		CryptocurrencyID: p.CryptocurrencyID,
+		Action:           ActionBuy,
+		Value:            value.Abs().Round(2),
+	}
+	if value.IsNegative() {
+		trade.Action = ActionSell
+	}
+	if p.Price.IsPositive() {
+		quantity := value.Abs().DivRound(p.Price, p.Decimals)
+		if trade.Action == ActionSell {
+			quantity = decimal.Min(quantity, p.Amount)
+		}
+		trade.Quantity = &quantity
+	}
+	return trade
+}
@@ -0,0 +1,346 @@
+package portfolio
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"wallet-manager/prices"
+
+	"github.com/shopspring/decimal"
+)
+
+// crypto trades every day of the year
+const periodsPerYear = 365
+
+var ErrInvalidWindow = errors.New("invalid window, expected a number of days, weeks, months or years such as 90d, 12w, 6m or 1y")
+
+// Window is how far back risk is measured.
+type Window struct {
+	text   string
+	amount int
+	unit   byte
+}
+
+func ParseWindow(window string) (Window, error) {
+	if len(window) < 2 {
+		return Window{}, ErrInvalidWindow
+	}
+	amount, err := strconv.Atoi(window[:len(window)-1])
+	unit := window[len(window)-1]
+	if err != nil || amount < 1 || amount > 3650 || strings.IndexByte("dwmy", unit) < 0 {
+		return Window{}, ErrInvalidWindow
+	}
+	return Window{text: window, amount: amount, unit: unit}, nil
+}
+
+func (w Window) String() string {
+	return w.text
+}
+
+// Start is the day the window starts, counting back from now.
+func (w Window) Start(now time.Time) time.Time {
+	today := day(now)
+	switch w.unit {
+	case 'w':
+		return today.AddDate(0, 0, -7*w.amount)
+	case 'm':
+		return today.AddDate(0, -w.amount, 0)
+	case 'y':
+		return today.AddDate(-w.amount, 0, 0)
+	}
+	return today.AddDate(0, 0, -w.amount)
+}
+
+// Days are the starts of the days from start until now, which ends them.
+func Days(start, now time.Time) []time.Time {
+	return sampleDates(start, now, func(t time.Time) time.Time { return t.AddDate(0, 0, 1) })
+}
+
+func day(t time.Time) time.Time {
+	year, month, d := t.UTC().Date()
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+// ValuePoint is the value of the portfolio at a date along with the fiat put
+// in up to then, which tells deposits apart from gains.
+type ValuePoint struct {
+	Date        time.Time
+	Value       decimal.Decimal
+	NetInvested decimal.Decimal
+}
+
+// Values replays the cash flows to value the portfolio at each date.
+func Values(flows []CashFlow, history map[string]prices.Series, dates []time.Time) ([]ValuePoint, error) {
+	flows = append([]CashFlow(nil), flows...)
+	sort.SliceStable(flows, func(i, j int) bool { return flows[i].Date.Before(flows[j].Date) })
+
+	units := map[string]decimal.Decimal{}
+	invested := decimal.Zero
+	next := 0
+	points := make([]ValuePoint, 0, len(dates))
+	for _, date := range dates {
+		for ; next < len(flows) && !flows[next].Date.After(date); next++ {
+			units[flows[next].AssetID] = units[flows[next].AssetID].Add(flows[next].Amount)
+			invested = invested.Add(flows[next].Fiat)
+		}
+		total, err := value(units, history, date)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, ValuePoint{Date: date, Value: total, NetInvested: invested})
+	}
+	return points, nil
+}
+
+type Drawdown struct {
+	// Percent is the fall from the peak to the trough, in percent of the peak.
+	Percent float64   `json:"percent"`
+	Peak    time.Time `json:"peak"`
+	Trough  time.Time `json:"trough"`
+	// Recovery is when the peak was reached again, if it was.
+	Recovery *time.Time `json:"recovery,omitempty"`
+}
+
+// Metrics are left out when there are too few returns to compute them.
+// Volatility is annualized and in percent.
+type Metrics struct {
+	Observations int       `json:"observations"`
+	Volatility   *float64  `json:"volatility,omitempty"`
+	MaxDrawdown  *Drawdown `json:"maxDrawdown,omitempty"`
+	Sharpe       *float64  `json:"sharpe,omitempty"`
+	Sortino      *float64  `json:"sortino,omitempty"`
+}
+
+type AssetRisk struct {
+	AssetID string `json:"assetId"`
+	Metrics
+}
+
+// Correlation holds the correlations of the daily returns of each pair of
+// Assets, in their order; a pair is missing when an asset did not move.
+type Correlation struct {
+	Assets []string     `json:"assets"`
+	Matrix [][]*float64 `json:"matrix"`
+}
+
+type Risk struct {
+	Window       string      `json:"window"`
+	From         time.Time   `json:"from"`
+	To           time.Time   `json:"to"`
+	RiskFreeRate float64     `json:"riskFreeRate"`
+	Portfolio    Metrics     `json:"portfolio"`
+	Assets       []AssetRisk `json:"assets"`
+	Correlation  Correlation `json:"correlation"`
+}
+
+// Analyze measures the risk of the portfolio from its values and of each asset
+// of history from its prices, all on the same dates. Returns of the portfolio
+// leave out what was put in or taken out between two dates.
+func Analyze(points []ValuePoint, history map[string]prices.Series, dates []time.Time, riskFreeRate float64) *Risk {
+	risk := &Risk{RiskFreeRate: riskFreeRate, Assets: []AssetRisk{}, Correlation: Correlation{Assets: []string{}, Matrix: [][]*float64{}}}
+	if len(dates) > 0 {
+		risk.From, risk.To = dates[0], dates[len(dates)-1]
+	}
+	risk.Portfolio = metrics(portfolioReturns(points), riskFreeRate)
+
+	assets := make([]string, 0, len(history))
+	for asset := range history {
+		assets = append(assets, asset)
+	}
+	sort.Strings(assets)
+
+	returns := make(map[string]dated, len(assets))
+	for _, asset := range assets {
+		series := priceReturns(history[asset], dates)
+		returns[asset] = series
+		risk.Assets = append(risk.Assets, AssetRisk{AssetID: asset, Metrics: metrics(series, riskFreeRate)})
+	}
+	risk.Correlation = correlate(assets, returns)
+	return risk
+}
+
+// dated returns end at their dates; start is the date the first one starts.
+type dated struct {
+	start   time.Time
+	dates   []time.Time
+	returns []float64
+}
+
+func portfolioReturns(points []ValuePoint) dated {
+	var series dated
+	for i := 1; i < len(points); i++ {
+		previous, current := points[i-1], points[i]
+		if !previous.Value.IsPositive() {
+			continue
+		}
+		if len(series.returns) == 0 {
+			series.start = previous.Date
+		}
+		flow := current.NetInvested.Sub(previous.NetInvested)
+		r, _ := current.Value.Sub(flow).Div(previous.Value).Float64()
+		series.dates = append(series.dates, current.Date)
+		series.returns = append(series.returns, r-1)
+	}
+	return series
+}
+
+func priceReturns(history prices.Series, dates []time.Time) dated {
+	var series dated
+	for i := 1; i < len(dates); i++ {
+		previous, ok := history.At(dates[i-1])
+		current, _ := history.At(dates[i])
+		if !ok || !previous.IsPositive() {
+			continue
+		}
+		if len(series.returns) == 0 {
+			series.start = dates[i-1]
+		}
+		r, _ := current.Div(previous).Float64()
+		series.dates = append(series.dates, dates[i])
+		series.returns = append(series.returns, r-1)
+	}
+	return series
+}
+
+func metrics(series dated, riskFreeRate float64) Metrics {
+	m := Metrics{Observations: len(series.returns)}
+	if len(series.returns) == 0 {
+		return m
+	}
+	m.MaxDrawdown = maxDrawdown(series)
+	if len(series.returns) < 2 {
+		return m
+	}
+
+	annualize := math.Sqrt(periodsPerYear)
+	excess := mean(series.returns) - riskFreeRate/periodsPerYear
+	deviation := stdev(series.returns)
+	m.Volatility = round(deviation * annualize * 100)
+	if deviation > 0 {
+		m.Sharpe = round(excess / deviation * annualize)
+	}
+
+	// downside deviation below the risk free rate, over every period
+	var downside float64
+	for _, r := range series.returns {
+		if below := r - riskFreeRate/periodsPerYear; below < 0 {
+			downside += below * below
+		}
+	}
+	if downside > 0 {
+		m.Sortino = round(excess / math.Sqrt(downside/float64(len(series.returns))) * annualize)
+	}
+	return m
+}
+
+// maxDrawdown follows an index of the returns, so deposits and withdrawals
+// do not count as rises or falls.
+func maxDrawdown(series dated) *Drawdown {
+	index := make([]float64, len(series.returns))
+	value := 1.0
+	for i, r := range series.returns {
+		value *= 1 + r
+		index[i] = value
+	}
+
+	drawdown := &Drawdown{Peak: series.start, Trough: series.start}
+	peak, peakDate, peakValue := 1.0, series.start, 1.0
+	trough := -1
+	for i, value := range index {
+		if value >= peak {
+			peak, peakDate = value, series.dates[i]
+			continue
+		}
+		if fall := (peak - value) / peak * 100; fall > drawdown.Percent {
+			drawdown.Percent, drawdown.Peak, drawdown.Trough = fall, peakDate, series.dates[i]
+			peakValue, trough = peak, i
+		}
+	}
+
+	if trough >= 0 {
+		for i := trough + 1; i < len(index); i++ {
+			if index[i] >= peakValue {
+				recovery := series.dates[i]
+				drawdown.Recovery = &recovery
+				break
+			}
+		}
+	}
+	drawdown.Percent = *round(drawdown.Percent)
+	return drawdown
+}
+
+func correlate(assets []string, returns map[string]dated) Correlation {
+	correlation := Correlation{Assets: assets, Matrix: make([][]*float64, len(assets))}
+	for i, a := range assets {
+		correlation.Matrix[i] = make([]*float64, len(assets))
+		for j, b := range assets {
+			correlation.Matrix[i][j] = pearson(returns[a], returns[b])
+		}
+	}
+	return correlation
+}
+
+// pearson correlates the returns of both series that end on the same dates.
+func pearson(x, y dated) *float64 {
+	a, b := paired(x, y)
+	if len(a) < 2 {
+		return nil
+	}
+	meanA, meanB := mean(a), mean(b)
+	var covariance, varianceA, varianceB float64
+	for i := range a {
+		da, db := a[i]-meanA, b[i]-meanB
+		covariance += da * db
+		varianceA += da * da
+		varianceB += db * db
+	}
+	if varianceA == 0 || varianceB == 0 {
+		return nil
+	}
+	return round(covariance / math.Sqrt(varianceA*varianceB))
+}
+
+// paired joins the returns of x and y on their dates, which both list in order.
+func paired(x, y dated) ([]float64, []float64) {
+	var a, b []float64
+	for i, j := 0, 0; i < len(x.dates) && j < len(y.dates); {
+		switch {
+		case x.dates[i].Before(y.dates[j]):
+			i++
+		case y.dates[j].Before(x.dates[i]):
+			j++
+		default:
+			a, b = append(a, x.returns[i]), append(b, y.returns[j])
+			i++
+			j++
+		}
+	}
+	return a, b
+}
+
+func mean(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// stdev is the sample standard deviation.
+func stdev(values []float64) float64 {
+	m := mean(values)
+	var sum float64
+	for _, v := range values {
+		sum += (v - m) * (v - m)
+	}
+	return math.Sqrt(sum / float64(len(values)-1))
+}
+
+func round(value float64) *float64 {
+	rounded := math.Round(value*10000) / 10000
+	return &rounded
+}
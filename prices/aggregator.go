@@ -0,0 +1,238 @@
+package prices
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// AggregationFallback asks the providers in order, each one only for the
+	// names the previous ones could not price.
+	AggregationFallback = "fallback"
+	// AggregationMedian asks every provider and takes the median.
+	AggregationMedian = "median"
+)
+
+// Price is an aggregated price along with where it came from.
+type Price struct {
+	Value decimal.Decimal
+	// Source names the provider, or median(a,b,...) of the providers used.
+	Source string
+	// Outliers are the providers left out of the median for being too far from it.
+	Outliers []string
+}
+
+type Aggregator struct {
+	mode      string
+	threshold decimal.Decimal
+	registry  Registry
+	providers []Provider
+}
+
+// NewAggregator combines providers with mode. In median mode a provider whose
+// price deviates from the median by more than threshold (0.05 is 5%) is
+// flagged as an outlier and left out. Without a registry, providers are asked
+// for the asset ids as they are.
+func NewAggregator(mode string, threshold decimal.Decimal, registry Registry, providers ...Provider) (*Aggregator, error) {
+	if mode != AggregationFallback && mode != AggregationMedian {
+		return nil, fmt.Errorf("unknown price aggregation %q", mode)
+	}
+	if len(providers) == 0 {
+		return nil, errors.New("no price providers")
+	}
+	return &Aggregator{mode: mode, threshold: threshold, registry: registry, providers: providers}, nil
+}
+
+// GetQuotes is a Fetcher, for the price cache.
+func (a *Aggregator) GetQuotes(ctx context.Context, names []string, currency string) (map[string]decimal.Decimal, error) {
+	prices, err := a.Get(ctx, names, currency)
+	if err != nil {
+		return nil, err
+	}
+	quotes := make(map[string]decimal.Decimal, len(prices))
+	for name, price := range prices {
+		quotes[name] = price.Value
+	}
+	return quotes, nil
+}
+
+// Get fails only when no provider could be reached; names nobody knows are
+// just missing from the result.
+func (a *Aggregator) Get(ctx context.Context, names []string, currency string) (map[string]Price, error) {
+	if a.mode == AggregationMedian {
+		return a.median(ctx, names, currency)
+	}
+	return a.fallback(ctx, names, currency)
+}
+
+func (a *Aggregator) fallback(ctx context.Context, names []string, currency string) (map[string]Price, error) {
+	prices := map[string]Price{}
+	var errs []error
+	missing := names
+	for _, provider := range a.providers {
+		quotes, err := a.quote(ctx, provider, missing, currency)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		var stillMissing []string
+		for _, name := range missing {
+			if price, ok := quotes[name]; ok {
+				prices[name] = Price{Value: price, Source: provider.Name()}
+			} else {
+				stillMissing = append(stillMissing, name)
+			}
+		}
+		if missing = stillMissing; len(missing) == 0 {
+			break
+		}
+	}
+
+	if len(errs) == len(a.providers) {
+		return nil, errors.Join(errs...)
+	}
+	return prices, nil
+}
+
+// quote asks provider for the price of assets, translated to its own ids.
+func (a *Aggregator) quote(ctx context.Context, provider Provider, assets []string, currency string) (map[string]decimal.Decimal, error) {
+	if a.registry == nil {
+		return provider.GetQuotes(ctx, assets, currency)
+	}
+
+	ids, err := a.registry.ProviderIDs(ctx, provider.Name(), assets)
+	if err != nil || len(ids) == 0 {
+		return map[string]decimal.Decimal{}, err
+	}
+	names := make([]string, 0, len(ids))
+	for _, id := range ids {
+		names = append(names, id)
+	}
+
+	quotes, err := provider.GetQuotes(ctx, names, currency)
+	if err != nil {
+		return nil, err
+	}
+	prices := make(map[string]decimal.Decimal, len(quotes))
+	for asset, id := range ids {
+		if price, ok := quotes[id]; ok {
+			prices[asset] = price
+		}
+	}
+	return prices, nil
+}
+
+type providerQuotes struct {
+	provider string
+	quotes   map[string]decimal.Decimal
+	err      error
+}
+
+func (a *Aggregator) median(ctx context.Context, names []string, currency string) (map[string]Price, error) {
+	results := make([]providerQuotes, len(a.providers))
+	var wg sync.WaitGroup
+	for i, provider := range a.providers {
+		wg.Add(1)
+		go func(i int, provider Provider) {
+			defer wg.Done()
+			quotes, err := a.quote(ctx, provider, names, currency)
+			results[i] = providerQuotes{provider: provider.Name(), quotes: quotes, err: err}
+		}(i, provider)
+	}
+	wg.Wait()
+
+	var errs []error
+	for _, result := range results {
+		if result.err != nil {
+			errs = append(errs, result.err)
+		}
+	}
+	if len(errs) == len(a.providers) {
+		return nil, errors.Join(errs...)
+	}
+
+	prices := map[string]Price{}
+	for _, name := range names {
+		var quotes []sourcedQuote
+		for _, result := range results {
+			if price, ok := result.quotes[name]; ok {
+				quotes = append(quotes, sourcedQuote{provider: result.provider, price: price})
+			}
+		}
+		if len(quotes) == 0 {
+			continue
+		}
+
+		price := a.aggregate(quotes)
+		if len(price.Outliers) > 0 {
+			log.Printf("price of %s from %v is off the median of %s", name, price.Outliers, price.Value)
+		}
+		prices[name] = price
+	}
+	return prices, nil
+}
+
+type sourcedQuote struct {
+	provider string
+	price    decimal.Decimal
+}
+
+func (a *Aggregator) aggregate(quotes []sourcedQuote) Price {
+	median := medianOf(quotes)
+
+	// with two quotes there is no telling which one is wrong
+	var kept []sourcedQuote
+	var outliers []string
+	for _, quote := range quotes {
+		if len(quotes) > 2 && a.threshold.IsPositive() && deviation(quote.price, median).GreaterThan(a.threshold) {
+			outliers = append(outliers, quote.provider)
+		} else {
+			kept = append(kept, quote)
+		}
+	}
+	if len(outliers) > 0 && len(kept) > 0 {
+		median = medianOf(kept)
+	} else {
+		kept = quotes
+		outliers = nil
+	}
+
+	sources := make([]string, len(kept))
+	for i, quote := range kept {
+		sources[i] = quote.provider
+	}
+	source := sources[0]
+	if len(sources) > 1 {
+		source = "median(" + strings.Join(sources, ",") + ")"
+	}
+	return Price{Value: median, Source: source, Outliers: outliers}
+}
+
+func medianOf(quotes []sourcedQuote) decimal.Decimal {
+	values := make([]decimal.Decimal, len(quotes))
+	for i, quote := range quotes {
+		values[i] = quote.price
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].LessThan(values[j]) })
+
+	middle := len(values) / 2
+	if len(values)%2 == 1 {
+		return values[middle]
+	}
+	return values[middle-1].Add(values[middle]).Div(decimal.NewFromInt(2))
+}
+
+func deviation(price decimal.Decimal, median decimal.Decimal) decimal.Decimal {
+	if median.IsZero() {
+		return decimal.Zero
+	}
+	return price.Sub(median).Div(median).Abs()
+}
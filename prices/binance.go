@@ -0,0 +1,60 @@
+package prices
+
+import (
+	"context"
+	"strings"
+	"wallet-manager/upstream"
+
+	"github.com/shopspring/decimal"
+)
+
+// Binance reads the public spot ticker, with names being base symbols ("BTC").
+// USD is quoted against USDT, other currencies against their own fiat pair
+// when Binance lists one.
+type Binance struct {
+	client  *upstream.Client
+	baseURL string
+}
+
+func NewBinance(client *upstream.Client, baseURL string) *Binance {
+	return &Binance{client: client, baseURL: baseURL}
+}
+
+func (p *Binance) Name() string {
+	return "binance"
+}
+
+type binanceTicker struct {
+	Symbol string          `json:"symbol"`
+	Price  decimal.Decimal `json:"price"`
+}
+
+func (p *Binance) GetQuotes(ctx context.Context, names []string, currency string) (map[string]decimal.Decimal, error) {
+	quote := strings.ToUpper(currency)
+	if quote == "USD" {
+		quote = "USDT"
+	}
+
+	wanted := map[string]string{}
+	for _, name := range names {
+		wanted[strings.ToUpper(name)+quote] = name
+	}
+	if len(wanted) == 0 {
+		return map[string]decimal.Decimal{}, nil
+	}
+
+	// a single call for every ticker is cheaper in request weight than asking
+	// for symbols that may not be listed, which fails the whole request
+	var tickers []binanceTicker
+	if err := getJSON(ctx, p.client, p.baseURL+"/ticker/price", &tickers); err != nil {
+		return nil, err
+	}
+
+	prices := map[string]decimal.Decimal{}
+	for _, ticker := range tickers {
+		if name, ok := wanted[ticker.Symbol]; ok {
+			prices[name] = ticker.Price
+		}
+	}
+	return prices, nil
+}
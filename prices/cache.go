@@ -0,0 +1,217 @@
+package prices
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"wallet-manager/models"
+
+	"github.com/shopspring/decimal"
+)
+
+var ErrNoPrices = errors.New("no prices available")
+
+// Fetcher returns the price of each known name in currency, like Provider.GetQuotes.
+type Fetcher func(ctx context.Context, names []string, currency string) (map[string]decimal.Decimal, error)
+
+type Result struct {
+	Prices map[string]decimal.Decimal
+	// Age is how old the oldest of Prices is.
+	Age time.Duration
+}
+
+// Cache serves prices from its stores and only asks the upstream for what is
+// missing or expired. A quote is fresh for ttl, after which it is still served
+// for staleWindow while it is refreshed in the background. Older quotes are
+// refreshed before answering, and only served when the upstream fails.
+type Cache struct {
+	fetch       Fetcher
+	ttl         time.Duration
+	staleWindow time.Duration
+	stores      []Store
+	flights     flightGroup
+}
+
+// NewCache reads stores in order and writes to all of them, so a memory store
+// can be put in front of a shared Postgres one.
+func NewCache(fetch Fetcher, ttl time.Duration, staleWindow time.Duration, stores ...Store) *Cache {
+	return &Cache{fetch: fetch, ttl: ttl, staleWindow: staleWindow, stores: stores, flights: flightGroup{calls: map[string]*flight{}}}
+}
+
+func (c *Cache) Get(ctx context.Context, names []string, currency string) (*Result, error) {
+	currency = strings.ToLower(currency)
+	quotes := map[string]*models.PriceQuote{}
+	var expired, stale []string
+
+	now := time.Now()
+	for _, name := range normalize(names) {
+		quote := c.lookup(ctx, name, currency)
+		if quote != nil {
+			quotes[name] = quote
+		}
+		switch {
+		case quote == nil || now.Sub(quote.FetchedAt) >= c.ttl+c.staleWindow:
+			expired = append(expired, name)
+		case now.Sub(quote.FetchedAt) >= c.ttl:
+			stale = append(stale, name)
+		}
+	}
+
+	if len(stale) > 0 {
+		go func() {
+			if _, err := c.refresh(context.Background(), stale, currency); err != nil {
+				log.Printf("could not revalidate prices of %v: %v", stale, err)
+			}
+		}()
+	}
+
+	if len(expired) > 0 {
+		fetched, err := c.refresh(ctx, expired, currency)
+		if err != nil {
+			// names unknown upstream are simply left out, but an upstream
+			// failure with nothing cached leaves nothing to answer with
+			if len(quotes) == 0 {
+				return nil, ErrNoPrices
+			}
+			log.Printf("could not fetch prices of %v, serving cached ones: %v", expired, err)
+		}
+		for name, quote := range fetched {
+			quotes[name] = quote
+		}
+	}
+	return result(quotes, now), nil
+}
+
+func (c *Cache) lookup(ctx context.Context, name string, currency string) *models.PriceQuote {
+	for i, store := range c.stores {
+		quote, err := store.Get(ctx, name, currency)
+		if err != nil {
+			log.Printf("price cache lookup failed: %v", err)
+			continue
+		}
+		if quote != nil {
+			// warm the faster stores in front of this one
+			for _, front := range c.stores[:i] {
+				front.Save(ctx, quote)
+			}
+			return quote
+		}
+	}
+	return nil
+}
+
+// refresh fetches names from the upstream and stores the result. Concurrent
+// refreshes of the same names share a single upstream call.
+func (c *Cache) refresh(ctx context.Context, names []string, currency string) (map[string]*models.PriceQuote, error) {
+	key := currency + ":" + strings.Join(names, ",")
+	return c.flights.do(ctx, key, func(ctx context.Context) (map[string]*models.PriceQuote, error) {
+		prices, err := c.fetch(ctx, names, currency)
+		if err != nil {
+			return nil, err
+		}
+
+		fetchedAt := time.Now().UTC()
+		quotes := make(map[string]*models.PriceQuote, len(prices))
+		for name, price := range prices {
+			quote := &models.PriceQuote{Asset: name, Currency: currency, Price: price, FetchedAt: fetchedAt}
+			for _, store := range c.stores {
+				if err := store.Save(ctx, quote); err != nil {
+					log.Printf("price cache save failed: %v", err)
+				}
+			}
+			quotes[name] = quote
+		}
+		return quotes, nil
+	})
+}
+
+func result(quotes map[string]*models.PriceQuote, now time.Time) *Result {
+	res := &Result{Prices: make(map[string]decimal.Decimal, len(quotes))}
+	for name, quote := range quotes {
+		res.Prices[name] = quote.Price
+		if age := now.Sub(quote.FetchedAt); age > res.Age {
+			res.Age = age
+		}
+	}
+	return res
+}
+
+// normalize lowercases, de-duplicates and sorts names so the same set always
+// maps to the same upstream call.
+func normalize(names []string) []string {
+	seen := map[string]bool{}
+	var normalized []string
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		normalized = append(normalized, name)
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+type flight struct {
+	done    chan struct{}
+	waiters int
+	cancel  context.CancelFunc
+	quotes  map[string]*models.PriceQuote
+	err     error
+}
+
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flight
+}
+
+// do runs fn once for all concurrent callers of key. The call is only
+// cancelled once every caller waiting on it has given up.
+func (g *flightGroup) do(ctx context.Context, key string, fn func(ctx context.Context) (map[string]*models.PriceQuote, error)) (map[string]*models.PriceQuote, error) {
+	g.mu.Lock()
+	call, ok := g.calls[key]
+	if !ok {
+		flightCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &flight{done: make(chan struct{}), cancel: cancel}
+		g.calls[key] = call
+		go func() {
+			call.quotes, call.err = fn(flightCtx)
+			g.forget(key, call)
+			cancel()
+			close(call.done)
+		}()
+	}
+	call.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.quotes, call.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		call.waiters--
+		abandoned := call.waiters == 0
+		if abandoned && g.calls[key] == call {
+			// later callers start a new call instead of joining a cancelled one
+			delete(g.calls, key)
+		}
+		g.mu.Unlock()
+		if abandoned {
+			call.cancel()
+		}
+		return nil, ctx.Err()
+	}
+}
+
+func (g *flightGroup) forget(key string, call *flight) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.calls[key] == call {
+		delete(g.calls, key)
+	}
+}
@@ -0,0 +1,53 @@
+package prices
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"wallet-manager/upstream"
+
+	"github.com/shopspring/decimal"
+)
+
+// CoinCap shares its asset ids with CoinGecko for most coins, but only quotes
+// in USD.
+type CoinCap struct {
+	client  *upstream.Client
+	baseURL string
+}
+
+func NewCoinCap(client *upstream.Client, baseURL string) *CoinCap {
+	return &CoinCap{client: client, baseURL: baseURL}
+}
+
+func (p *CoinCap) Name() string {
+	return "coincap"
+}
+
+type coinCapAssets struct {
+	Data []struct {
+		ID       string           `json:"id"`
+		PriceUSD *decimal.Decimal `json:"priceUsd"`
+	} `json:"data"`
+}
+
+func (p *CoinCap) GetQuotes(ctx context.Context, names []string, currency string) (map[string]decimal.Decimal, error) {
+	if currency != "usd" {
+		return map[string]decimal.Decimal{}, nil
+	}
+
+	var result coinCapAssets
+	endpoint := fmt.Sprintf("%s/assets?ids=%s", p.baseURL, url.QueryEscape(strings.Join(names, ",")))
+	if err := getJSON(ctx, p.client, endpoint, &result); err != nil {
+		return nil, err
+	}
+
+	prices := make(map[string]decimal.Decimal, len(result.Data))
+	for _, asset := range result.Data {
+		if asset.PriceUSD != nil {
+			prices[asset.ID] = *asset.PriceUSD
+		}
+	}
+	return prices, nil
+}
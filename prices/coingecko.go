@@ -0,0 +1,70 @@
+package prices
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+	"wallet-manager/upstream"
+
+	"github.com/shopspring/decimal"
+)
+
+type CoinGecko struct {
+	client  *upstream.Client
+	baseURL string
+}
+
+func NewCoinGecko(client *upstream.Client, baseURL string) *CoinGecko {
+	return &CoinGecko{client: client, baseURL: baseURL}
+}
+
+func (g *CoinGecko) Name() string {
+	return "coingecko"
+}
+
+func (g *CoinGecko) GetQuotes(ctx context.Context, names []string, currency string) (map[string]decimal.Decimal, error) {
+	ids := url.QueryEscape(strings.Join(names, ","))
+	endpoint := fmt.Sprintf("%s/simple/price?ids=%s&vs_currencies=%s", g.baseURL, ids, url.QueryEscape(currency))
+
+	var result map[string]map[string]decimal.Decimal
+	if err := getJSON(ctx, g.client, endpoint, &result); err != nil {
+		return nil, err
+	}
+
+	prices := make(map[string]decimal.Decimal, len(result))
+	for name, data := range result {
+		if price, ok := data[currency]; ok {
+			prices[name] = price
+		}
+	}
+
+	return prices, nil
+}
+
+type coinGeckoMarketChart struct {
+	// pairs of a time in milliseconds and a price
+	Prices [][2]decimal.Decimal `json:"prices"`
+}
+
+// GetHistory reads the market chart of name between from and to, hourly for
+// ranges up to 90 days and daily past that.
+func (g *CoinGecko) GetHistory(ctx context.Context, name string, currency string, from, to time.Time) (Series, error) {
+	query := url.Values{}
+	query.Set("vs_currency", currency)
+	query.Set("from", fmt.Sprint(from.Unix()))
+	query.Set("to", fmt.Sprint(to.Unix()))
+	endpoint := fmt.Sprintf("%s/coins/%s/market_chart/range?%s", g.baseURL, url.PathEscape(name), query.Encode())
+
+	var result coinGeckoMarketChart
+	if err := getJSON(ctx, g.client, endpoint, &result); err != nil {
+		return nil, err
+	}
+
+	series := make(Series, 0, len(result.Prices))
+	for _, point := range result.Prices {
+		series = append(series, Point{Time: time.UnixMilli(point[0].IntPart()).UTC(), Price: point[1]})
+	}
+	return series, nil
+}
@@ -0,0 +1,38 @@
+package prices
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Point is the price of an asset at a time.
+type Point struct {
+	Time  time.Time       `json:"time"`
+	Price decimal.Decimal `json:"price"`
+}
+
+// HistoryProvider is a source of past prices. Like Provider, names are the
+// ids the provider knows assets by.
+type HistoryProvider interface {
+	Name() string
+	GetHistory(ctx context.Context, name string, currency string, from, to time.Time) (Series, error)
+}
+
+// Series are the prices of an asset in time order.
+type Series []Point
+
+// At is the last price at or before t. Before the series starts, it is the
+// first price, as assets listed later have no earlier price to go by.
+func (s Series) At(t time.Time) (decimal.Decimal, bool) {
+	if len(s) == 0 {
+		return decimal.Zero, false
+	}
+	i := sort.Search(len(s), func(i int) bool { return s[i].Time.After(t) })
+	if i == 0 {
+		return s[0].Price, true
+	}
+	return s[i-1].Price, true
+}
@@ -0,0 +1,39 @@
+package prices
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"wallet-manager/upstream"
+
+	"github.com/shopspring/decimal"
+)
+
+// Provider is a source of prices. Names are the ids the provider knows assets
+// by, see Registry, and currency a lowercase code ("usd"). Names or currencies a provider does
+// not know are left out of the result rather than failing the call.
+type Provider interface {
+	Name() string
+	GetQuotes(ctx context.Context, names []string, currency string) (map[string]decimal.Decimal, error)
+}
+
+// Registry maps asset ids to the ids each provider knows them by, leaving out
+// assets it does not know.
+type Registry interface {
+	ProviderIDs(ctx context.Context, provider string, ids []string) (map[string]string, error)
+}
+
+func getJSON(ctx context.Context, client *upstream.Client, url string, result any) error {
+	resp, err := client.Get(ctx, url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: failed to get data: %s", client.Name(), resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
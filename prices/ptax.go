@@ -0,0 +1,85 @@
+package prices
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+	"wallet-manager/upstream"
+
+	"github.com/shopspring/decimal"
+)
+
+const ptaxDateFormat = "01-02-2006"
+
+// ExchangeRate is the PTAX of a day in BRL per USD: Buy is the bid
+// (cotação de compra) and Sell the ask (cotação de venda).
+type ExchangeRate struct {
+	Date time.Time
+	Buy  decimal.Decimal
+	Sell decimal.Decimal
+}
+
+// PTAX reads the USD/BRL closing rates published by Banco Central do Brasil.
+// Rates of past days never change, so they are kept once read.
+type PTAX struct {
+	client  *upstream.Client
+	baseURL string
+
+	mu    sync.Mutex
+	rates map[string]ExchangeRate
+}
+
+func NewPTAX(client *upstream.Client, baseURL string) *PTAX {
+	return &PTAX{client: client, baseURL: baseURL, rates: map[string]ExchangeRate{}}
+}
+
+type ptaxPeriod struct {
+	Value []struct {
+		Buy  decimal.Decimal `json:"cotacaoCompra"`
+		Sell decimal.Decimal `json:"cotacaoVenda"`
+		Date string          `json:"dataHoraCotacao"`
+	} `json:"value"`
+}
+
+// USDBRL returns the rate of date, or of the last business day before it when
+// none was published that day.
+func (p *PTAX) USDBRL(ctx context.Context, date time.Time) (ExchangeRate, error) {
+	key := date.Format(time.DateOnly)
+	p.mu.Lock()
+	rate, ok := p.rates[key]
+	p.mu.Unlock()
+	if ok {
+		return rate, nil
+	}
+
+	query := url.Values{}
+	query.Set("@dataInicial", "'"+date.AddDate(0, 0, -7).Format(ptaxDateFormat)+"'")
+	query.Set("@dataFinalCotacao", "'"+date.Format(ptaxDateFormat)+"'")
+	query.Set("$format", "json")
+	endpoint := fmt.Sprintf("%s/CotacaoDolarPeriodo(dataInicial=@dataInicial,dataFinalCotacao=@dataFinalCotacao)?%s", p.baseURL, query.Encode())
+
+	var result ptaxPeriod
+	if err := getJSON(ctx, p.client, endpoint, &result); err != nil {
+		return ExchangeRate{}, err
+	}
+	if len(result.Value) == 0 {
+		return ExchangeRate{}, fmt.Errorf("%s: no USD/BRL rate up to %s", p.client.Name(), key)
+	}
+
+	last := result.Value[len(result.Value)-1]
+	published, err := time.Parse(time.DateOnly, last.Date[:min(len(last.Date), len(time.DateOnly))])
+	if err != nil {
+		return ExchangeRate{}, err
+	}
+	rate = ExchangeRate{Date: published, Buy: last.Buy, Sell: last.Sell}
+
+	// today's rate may not be out yet
+	if key < time.Now().UTC().Format(time.DateOnly) {
+		p.mu.Lock()
+		p.rates[key] = rate
+		p.mu.Unlock()
+	}
+	return rate, nil
+}
@@ -0,0 +1,44 @@
+package prices
+
+import (
+	"context"
+	"sync"
+	"wallet-manager/models"
+)
+
+// Store keeps the last quote of each asset and quote currency.
+// repositories.PriceCacheRepository is the Postgres implementation.
+type Store interface {
+	Get(ctx context.Context, asset string, currency string) (*models.PriceQuote, error)
+	Save(ctx context.Context, quote *models.PriceQuote) error
+}
+
+type memoryStore struct {
+	mu     sync.RWMutex
+	quotes map[string]models.PriceQuote
+}
+
+func NewMemoryStore() Store {
+	return &memoryStore{quotes: map[string]models.PriceQuote{}}
+}
+
+func (s *memoryStore) Get(ctx context.Context, asset string, currency string) (*models.PriceQuote, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	quote, ok := s.quotes[currency+":"+asset]
+	if !ok {
+		return nil, nil
+	}
+	return &quote, nil
+}
+
+func (s *memoryStore) Save(ctx context.Context, quote *models.PriceQuote) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := quote.Currency + ":" + quote.Asset
+	if current, ok := s.quotes[key]; ok && current.FetchedAt.After(quote.FetchedAt) {
+		return nil
+	}
+	s.quotes[key] = *quote
+	return nil
+}
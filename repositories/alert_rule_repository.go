@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"wallet-manager/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	insertAlertRuleQuery = `
+		INSERT INTO alert_rule (type, asset, cryptocurrency_id, threshold, window_minutes, cooldown_minutes, channel, target, paused) 
+		VALUES (:type, LOWER(:asset), :cryptocurrency_id, :threshold, :window_minutes, :cooldown_minutes, :channel, :target, :paused) 
+		RETURNING alert_rule_id, created_date;
+	`
+	getAllAlertRulesQuery    = `SELECT * FROM alert_rule ORDER BY alert_rule_id;`
+	getActiveAlertRulesQuery = `SELECT * FROM alert_rule WHERE paused = FALSE ORDER BY alert_rule_id;`
+	getAlertRuleByIDQuery    = `SELECT * FROM alert_rule WHERE alert_rule_id=$1;`
+	updateAlertRuleQuery     = `
+		UPDATE alert_rule 
+		SET type=:type, asset=LOWER(:asset), cryptocurrency_id=:cryptocurrency_id, threshold=:threshold, window_minutes=:window_minutes, 
+		cooldown_minutes=:cooldown_minutes, channel=:channel, target=:target, paused=:paused, triggered=FALSE 
+		WHERE alert_rule_id=:alert_rule_id;
+	`
+	updateAlertRuleStateQuery = `
+		UPDATE alert_rule 
+		SET triggered=:triggered, last_notified_at=:last_notified_at 
+		WHERE alert_rule_id=:alert_rule_id;
+	`
+	deleteAlertRuleQuery = `DELETE FROM alert_rule WHERE alert_rule_id=$1;`
+)
+
+type AlertRuleRepository interface {
+	Create(ctx context.Context, rule *models.AlertRule) error
+	GetAll(ctx context.Context) ([]models.AlertRule, error)
+	GetActive(ctx context.Context) ([]models.AlertRule, error)
+	GetByID(ctx context.Context, id uint32) (*models.AlertRule, error)
+	Update(ctx context.Context, rule *models.AlertRule) error
+	UpdateState(ctx context.Context, rule *models.AlertRule) error
+	Delete(ctx context.Context, id uint32) error
+}
+
+type alertRuleRepository struct {
+	db *sqlx.DB
+}
+
+func NewAlertRuleRepository(db *sqlx.DB) AlertRuleRepository {
+	return &alertRuleRepository{db: db}
+}
+
+func (r *alertRuleRepository) Create(ctx context.Context, rule *models.AlertRule) error {
+	stmt, err := conn(ctx, r.db).PrepareNamedContext(ctx, insertAlertRuleQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	return stmt.QueryRowxContext(ctx, rule).Scan(&rule.ID, &rule.CreatedDate)
+}
+
+func (r *alertRuleRepository) GetAll(ctx context.Context) ([]models.AlertRule, error) {
+	rules := []models.AlertRule{}
+	err := conn(ctx, r.db).SelectContext(ctx, &rules, getAllAlertRulesQuery)
+	return rules, err
+}
+
+func (r *alertRuleRepository) GetActive(ctx context.Context) ([]models.AlertRule, error) {
+	var rules []models.AlertRule
+	err := conn(ctx, r.db).SelectContext(ctx, &rules, getActiveAlertRulesQuery)
+	return rules, err
+}
+
+func (r *alertRuleRepository) GetByID(ctx context.Context, id uint32) (*models.AlertRule, error) {
+	var rule models.AlertRule
+	err := conn(ctx, r.db).GetContext(ctx, &rule, getAlertRuleByIDQuery, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return &rule, err
+}
+
+// Update replaces the rule definition and re-arms it, so the new condition is
+// notified the next time it holds.
+func (r *alertRuleRepository) Update(ctx context.Context, rule *models.AlertRule) error {
+	result, err := conn(ctx, r.db).NamedExecContext(ctx, updateAlertRuleQuery, rule)
+	if err != nil {
+		return err
+	}
+	return expectFound(result)
+}
+
+func (r *alertRuleRepository) UpdateState(ctx context.Context, rule *models.AlertRule) error {
+	_, err := conn(ctx, r.db).NamedExecContext(ctx, updateAlertRuleStateQuery, rule)
+	return err
+}
+
+func (r *alertRuleRepository) Delete(ctx context.Context, id uint32) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx, deleteAlertRuleQuery, id)
+	if err != nil {
+		return err
+	}
+	return expectFound(result)
+}
@@ -0,0 +1,103 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"wallet-manager/models"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+const (
+	upsertAssetQuery = `
+		INSERT INTO asset (asset_id, symbol, name, decimals, chain, provider_ids) 
+		VALUES (:asset_id, :symbol, :name, :decimals, :chain, :provider_ids) 
+		ON CONFLICT (asset_id) DO UPDATE 
+		SET symbol = EXCLUDED.symbol, name = EXCLUDED.name, decimals = EXCLUDED.decimals, chain = EXCLUDED.chain, provider_ids = EXCLUDED.provider_ids;
+	`
+	getAssetByIDQuery = `SELECT * FROM asset WHERE asset_id = $1;`
+	// an exact id wins over a symbol, and a symbol over a display name
+	resolveAssetQuery = `
+		SELECT * FROM asset 
+		WHERE asset_id = LOWER($1) OR LOWER(symbol) = LOWER($1) OR LOWER(name) = LOWER($1) 
+		ORDER BY asset_id = LOWER($1) DESC, LOWER(symbol) = LOWER($1) DESC, asset_id 
+		LIMIT 1;
+	`
+	searchAssetsQuery = `
+		SELECT * FROM asset 
+		WHERE asset_id ILIKE '%' || $1 || '%' OR symbol ILIKE '%' || $1 || '%' OR name ILIKE '%' || $1 || '%' 
+		ORDER BY LOWER(symbol) = LOWER($1) DESC, LOWER(name) = LOWER($1) DESC, name 
+		LIMIT $2;
+	`
+	getProviderIDsQuery = `
+		SELECT asset_id, COALESCE(provider_ids->>$1, asset_id) AS provider_id 
+		FROM asset 
+		WHERE asset_id = ANY($2);
+	`
+)
+
+type AssetRepository interface {
+	Upsert(ctx context.Context, asset *models.Asset) error
+	GetByID(ctx context.Context, id string) (*models.Asset, error)
+	Resolve(ctx context.Context, ref string) (*models.Asset, error)
+	Search(ctx context.Context, query string, limit int) ([]models.Asset, error)
+	GetProviderIDs(ctx context.Context, provider string, ids []string) (map[string]string, error)
+}
+
+type assetRepository struct {
+	db *sqlx.DB
+}
+
+func NewAssetRepository(db *sqlx.DB) AssetRepository {
+	return &assetRepository{db: db}
+}
+
+func (r *assetRepository) Upsert(ctx context.Context, asset *models.Asset) error {
+	_, err := conn(ctx, r.db).NamedExecContext(ctx, upsertAssetQuery, asset)
+	return err
+}
+
+func (r *assetRepository) GetByID(ctx context.Context, id string) (*models.Asset, error) {
+	var asset models.Asset
+	err := conn(ctx, r.db).GetContext(ctx, &asset, getAssetByIDQuery, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return &asset, err
+}
+
+// Resolve finds the asset referred to by id, symbol or display name.
+func (r *assetRepository) Resolve(ctx context.Context, ref string) (*models.Asset, error) {
+	var asset models.Asset
+	err := conn(ctx, r.db).GetContext(ctx, &asset, resolveAssetQuery, ref)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return &asset, err
+}
+
+func (r *assetRepository) Search(ctx context.Context, query string, limit int) ([]models.Asset, error) {
+	assets := []models.Asset{}
+	err := conn(ctx, r.db).SelectContext(ctx, &assets, searchAssetsQuery, query, limit)
+	return assets, err
+}
+
+// GetProviderIDs maps each of ids to the id provider knows it by. Assets with
+// no id for provider keep their own, unknown assets are left out.
+func (r *assetRepository) GetProviderIDs(ctx context.Context, provider string, ids []string) (map[string]string, error) {
+	var rows []struct {
+		AssetID    string `db:"asset_id"`
+		ProviderID string `db:"provider_id"`
+	}
+	if err := conn(ctx, r.db).SelectContext(ctx, &rows, getProviderIDsQuery, provider, pq.Array(ids)); err != nil {
+		return nil, err
+	}
+
+	providerIDs := make(map[string]string, len(rows))
+	for _, row := range rows {
+		providerIDs[row.AssetID] = row.ProviderID
+	}
+	return providerIDs, nil
+}
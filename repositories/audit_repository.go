@@ -0,0 +1,70 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"wallet-manager/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const insertAuditEntryQuery = `
+	INSERT INTO audit_log (actor, action, entity, entity_id, before, after)
+	VALUES (:actor, :action, :entity, :entity_id, :before, :after)
+	RETURNING audit_id, created_date;
+`
+
+type AuditRepository interface {
+	Create(ctx context.Context, entry *models.AuditEntry) error
+	Find(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error)
+}
+
+type auditRepository struct {
+	db *sqlx.DB
+}
+
+func NewAuditRepository(db *sqlx.DB) AuditRepository {
+	return &auditRepository{db: db}
+}
+
+func (r *auditRepository) Create(ctx context.Context, entry *models.AuditEntry) error {
+	stmt, err := conn(ctx, r.db).PrepareNamedContext(ctx, insertAuditEntryQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	return stmt.QueryRowxContext(ctx, entry).Scan(&entry.ID, &entry.CreatedDate)
+}
+
+func (r *auditRepository) Find(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error) {
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Entity != "" {
+		addCondition("entity = $%d", filter.Entity)
+	}
+	if filter.EntityID != nil {
+		addCondition("entity_id = $%d", *filter.EntityID)
+	}
+	if filter.From != nil {
+		addCondition("created_date >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		addCondition("created_date <= $%d", *filter.To)
+	}
+
+	query := "SELECT * FROM audit_log"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY created_date, audit_id"
+
+	entries := []models.AuditEntry{}
+	err := conn(ctx, r.db).SelectContext(ctx, &entries, query, args...)
+	return entries, err
+}
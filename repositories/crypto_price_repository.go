@@ -0,0 +1,93 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+	"wallet-manager/models"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	upsertCryptoPriceQuery = `
+		INSERT INTO crypto_price (name, price_usd, updated_date, source) 
+		VALUES (LOWER($1), $2, NOW(), $3) 
+		ON CONFLICT ((LOWER(name))) DO UPDATE 
+		SET price_usd = EXCLUDED.price_usd, updated_date = EXCLUDED.updated_date, source = EXCLUDED.source;
+	`
+	insertCryptoPriceHistoryQuery = `INSERT INTO crypto_price_history (name, price_usd, source) VALUES (LOWER($1), $2, $3);`
+	getCryptoPriceByNameQuery     = `SELECT * FROM crypto_price WHERE LOWER(name) = LOWER($1);`
+	getAllCryptoPricesQuery       = `SELECT * FROM crypto_price ORDER BY name;`
+	getCryptoPriceAtQuery         = `
+		SELECT history_id AS id, name, price_usd, recorded_at AS updated_date, source 
+		FROM crypto_price_history 
+		WHERE name = LOWER($1) AND recorded_at <= $2 
+		ORDER BY recorded_at DESC 
+		LIMIT 1;
+	`
+	getTrackedCryptoNamesQuery = `
+		SELECT asset_id FROM cryptocurrency WHERE deleted_at IS NULL 
+		UNION 
+		SELECT LOWER(asset) FROM alert_rule WHERE asset <> '';
+	`
+)
+
+type CryptoPriceRepository interface {
+	Save(ctx context.Context, name string, price decimal.Decimal, source string) error
+	GetByName(ctx context.Context, name string) (*models.CryptoPrice, error)
+	GetAll(ctx context.Context) ([]models.CryptoPrice, error)
+	GetAt(ctx context.Context, name string, at time.Time) (*models.CryptoPrice, error)
+	GetTrackedNames(ctx context.Context) ([]string, error)
+}
+
+type cryptoPriceRepository struct {
+	db *sqlx.DB
+}
+
+func NewCryptoPriceRepository(db *sqlx.DB) CryptoPriceRepository {
+	return &cryptoPriceRepository{db: db}
+}
+
+// Save replaces the current price of name and appends it to its history.
+func (r *cryptoPriceRepository) Save(ctx context.Context, name string, price decimal.Decimal, source string) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, upsertCryptoPriceQuery, name, price, source); err != nil {
+		return err
+	}
+	_, err := conn(ctx, r.db).ExecContext(ctx, insertCryptoPriceHistoryQuery, name, price, source)
+	return err
+}
+
+func (r *cryptoPriceRepository) GetByName(ctx context.Context, name string) (*models.CryptoPrice, error) {
+	var price models.CryptoPrice
+	err := conn(ctx, r.db).GetContext(ctx, &price, getCryptoPriceByNameQuery, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return &price, err
+}
+
+func (r *cryptoPriceRepository) GetAll(ctx context.Context) ([]models.CryptoPrice, error) {
+	prices := []models.CryptoPrice{}
+	err := conn(ctx, r.db).SelectContext(ctx, &prices, getAllCryptoPricesQuery)
+	return prices, err
+}
+
+// GetAt returns the last price of name recorded at or before at.
+func (r *cryptoPriceRepository) GetAt(ctx context.Context, name string, at time.Time) (*models.CryptoPrice, error) {
+	var price models.CryptoPrice
+	err := conn(ctx, r.db).GetContext(ctx, &price, getCryptoPriceAtQuery, name, at)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return &price, err
+}
+
+// GetTrackedNames lists the assets whose price is needed, by holdings or by alert rules.
+func (r *cryptoPriceRepository) GetTrackedNames(ctx context.Context) ([]string, error) {
+	var names []string
+	err := conn(ctx, r.db).SelectContext(ctx, &names, getTrackedCryptoNamesQuery)
+	return names, err
+}
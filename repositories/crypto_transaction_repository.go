@@ -1,17 +1,30 @@
 package repositories
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
 	"wallet-manager/models"
 
 	"github.com/jmoiron/sqlx"
 )
 
 type CryptoTransactionRepository interface {
-	Create(transaction *models.CryptoTransaction) error
-	GetAll(cryptoId uint32) ([]models.CryptoTransaction, error)
-	GetByID(id uint32) (*models.CryptoTransaction, error)
-	Update(crypto *models.CryptoTransaction) error
-	Delete(id uint32) error
+	Create(ctx context.Context, transaction *models.CryptoTransaction) error
+	GetAll(ctx context.Context, cryptoId uint32) ([]models.CryptoTransaction, error)
+	GetAllActive(ctx context.Context) ([]models.CryptoTransaction, error)
+	GetPlanned(ctx context.Context, planId uint32) ([]models.CryptoTransaction, error)
+	GetByID(ctx context.Context, id uint32) (*models.CryptoTransaction, error)
+	Update(ctx context.Context, crypto *models.CryptoTransaction) error
+	SetStatus(ctx context.Context, id uint32, status string) error
+	Delete(ctx context.Context, id uint32, version uint32) error
+	DeleteByCryptocurrency(ctx context.Context, cryptoId uint32) ([]models.CryptoTransaction, error)
+	GetDeleted(ctx context.Context) ([]models.CryptoTransaction, error)
+	GetDeletedByID(ctx context.Context, id uint32) (*models.CryptoTransaction, error)
+	Restore(ctx context.Context, id uint32) error
+	RestoreByCryptocurrency(ctx context.Context, cryptoId uint32, deletedAt string) ([]models.CryptoTransaction, error)
+	Purge(ctx context.Context, deletedBefore time.Time) ([]models.CryptoTransaction, error)
 }
 
 type cryptoTransactionRepository struct {
@@ -22,36 +35,130 @@ func NewCryptoTransactionRepository(db *sqlx.DB) CryptoTransactionRepository {
 	return &cryptoTransactionRepository{db: db}
 }
 
-func (r *cryptoTransactionRepository) Create(transaction *models.CryptoTransaction) error {
-	query := `INSERT INTO crypto_transaction (cryptocurrency_id, cryptocurrency_amount, fiat_amount, purchase_date, created_date) 
-			  VALUES (:cryptocurrency_id, :cryptocurrency_amount, :fiat_amount, :purchase_date, :created_date) RETURNING transaction_id`
-	stmt, err := r.db.PrepareNamed(query)
+func (r *cryptoTransactionRepository) Create(ctx context.Context, transaction *models.CryptoTransaction) error {
+	query := `INSERT INTO crypto_transaction (cryptocurrency_id, type, status, plan_id, external_id, address, cryptocurrency_amount, fiat_amount, purchase_date, created_date) 
+			  VALUES (:cryptocurrency_id, :type, :status, :plan_id, :external_id, :address, :cryptocurrency_amount, :fiat_amount, :purchase_date, :created_date) RETURNING transaction_id, version`
+	stmt, err := conn(ctx, r.db).PrepareNamedContext(ctx, query)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
-	return stmt.Get(&transaction.ID, transaction)
+	err = stmt.QueryRowxContext(ctx, transaction).Scan(&transaction.ID, &transaction.Version)
+	if isUniqueViolation(err) {
+		return ErrExternalIDTaken
+	}
+	return err
 }
 
-func (r *cryptoTransactionRepository) GetAll(cryptoId uint32) ([]models.CryptoTransaction, error) {
+func (r *cryptoTransactionRepository) GetAll(ctx context.Context, cryptoId uint32) ([]models.CryptoTransaction, error) {
 	var cryptos []models.CryptoTransaction
-	err := r.db.Select(&cryptos, "SELECT * FROM crypto_transaction WHERE cryptocurrency_id=$1", cryptoId)
+	err := conn(ctx, r.db).SelectContext(ctx, &cryptos, "SELECT * FROM crypto_transaction WHERE cryptocurrency_id=$1 AND deleted_at IS NULL", cryptoId)
+	return cryptos, err
+}
+
+// GetAllActive returns the confirmed transactions of every holding outside the trash, oldest first.
+func (r *cryptoTransactionRepository) GetAllActive(ctx context.Context) ([]models.CryptoTransaction, error) {
+	cryptos := []models.CryptoTransaction{}
+	err := conn(ctx, r.db).SelectContext(ctx, &cryptos, `SELECT t.* FROM crypto_transaction t 
+			  JOIN cryptocurrency c ON c.cryptocurrency_id = t.cryptocurrency_id 
+			  WHERE t.status = 'confirmed' AND t.deleted_at IS NULL AND c.deleted_at IS NULL ORDER BY t.purchase_date, t.transaction_id`)
 	return cryptos, err
 }
 
-func (r *cryptoTransactionRepository) GetByID(id uint32) (*models.CryptoTransaction, error) {
+// GetPlanned returns the transactions of a DCA plan awaiting confirmation, oldest first.
+func (r *cryptoTransactionRepository) GetPlanned(ctx context.Context, planId uint32) ([]models.CryptoTransaction, error) {
+	cryptos := []models.CryptoTransaction{}
+	err := conn(ctx, r.db).SelectContext(ctx, &cryptos, `SELECT * FROM crypto_transaction 
+			  WHERE plan_id=$1 AND status = 'planned' AND deleted_at IS NULL ORDER BY purchase_date`, planId)
+	return cryptos, err
+}
+
+func (r *cryptoTransactionRepository) GetByID(ctx context.Context, id uint32) (*models.CryptoTransaction, error) {
 	var crypto models.CryptoTransaction
-	err := r.db.Get(&crypto, "SELECT * FROM crypto_transaction WHERE transaction_id=$1", id)
+	err := conn(ctx, r.db).GetContext(ctx, &crypto, "SELECT * FROM crypto_transaction WHERE transaction_id=$1 AND deleted_at IS NULL", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
 	return &crypto, err
 }
 
-func (r *cryptoTransactionRepository) Update(crypto *models.CryptoTransaction) error {
-	query := `UPDATE crypto_transaction SET cryptocurrency_amount=:cryptocurrency_amount, fiat_amount=:fiat_amount, purchase_date=:purchase_date WHERE transaction_id=:transaction_id`
-	_, err := r.db.NamedExec(query, crypto)
+func (r *cryptoTransactionRepository) Update(ctx context.Context, crypto *models.CryptoTransaction) error {
+	query := `UPDATE crypto_transaction SET type=:type, address=:address, cryptocurrency_amount=:cryptocurrency_amount, fiat_amount=:fiat_amount, purchase_date=:purchase_date, version = version + 1 
+			  WHERE transaction_id=:transaction_id AND version=:version AND deleted_at IS NULL RETURNING version`
+	stmt, err := conn(ctx, r.db).PrepareNamedContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	err = stmt.GetContext(ctx, &crypto.Version, crypto)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrVersionMismatch
+	}
 	return err
 }
 
-func (r *cryptoTransactionRepository) Delete(id uint32) error {
-	_, err := r.db.Exec("DELETE FROM crypto_transaction WHERE transaction_id=$1", id)
+// SetStatus settles a planned transaction as confirmed or skipped.
+func (r *cryptoTransactionRepository) SetStatus(ctx context.Context, id uint32, status string) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx, `UPDATE crypto_transaction SET status=$2, version = version + 1 
+			  WHERE transaction_id=$1 AND status = 'planned' AND deleted_at IS NULL`, id, status)
+	if err != nil {
+		return err
+	}
+	return expectFound(result)
+}
+
+// Delete moves the transaction to the trash; Purge removes it for good.
+func (r *cryptoTransactionRepository) Delete(ctx context.Context, id uint32, version uint32) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx, `UPDATE crypto_transaction SET deleted_at = NOW(), version = version + 1 
+			  WHERE transaction_id=$1 AND version=$2 AND deleted_at IS NULL`, id, version)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+// DeleteByCryptocurrency trashes the remaining transactions of a cryptocurrency being deleted.
+// They share its deleted_at, which is how RestoreByCryptocurrency finds them again.
+func (r *cryptoTransactionRepository) DeleteByCryptocurrency(ctx context.Context, cryptoId uint32) ([]models.CryptoTransaction, error) {
+	cryptos := []models.CryptoTransaction{}
+	err := conn(ctx, r.db).SelectContext(ctx, &cryptos, `UPDATE crypto_transaction SET deleted_at = NOW(), version = version + 1 
+			  WHERE cryptocurrency_id=$1 AND deleted_at IS NULL RETURNING *`, cryptoId)
+	return cryptos, err
+}
+
+func (r *cryptoTransactionRepository) GetDeleted(ctx context.Context) ([]models.CryptoTransaction, error) {
+	cryptos := []models.CryptoTransaction{}
+	err := conn(ctx, r.db).SelectContext(ctx, &cryptos, "SELECT * FROM crypto_transaction WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC")
+	return cryptos, err
+}
+
+func (r *cryptoTransactionRepository) GetDeletedByID(ctx context.Context, id uint32) (*models.CryptoTransaction, error) {
+	var crypto models.CryptoTransaction
+	err := conn(ctx, r.db).GetContext(ctx, &crypto, "SELECT * FROM crypto_transaction WHERE transaction_id=$1 AND deleted_at IS NOT NULL", id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return &crypto, err
+}
+
+func (r *cryptoTransactionRepository) Restore(ctx context.Context, id uint32) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, `UPDATE crypto_transaction SET deleted_at = NULL, version = version + 1 
+			  WHERE transaction_id=$1 AND deleted_at IS NOT NULL`, id)
+	if isUniqueViolation(err) {
+		return ErrExternalIDTaken
+	}
 	return err
 }
+
+func (r *cryptoTransactionRepository) RestoreByCryptocurrency(ctx context.Context, cryptoId uint32, deletedAt string) ([]models.CryptoTransaction, error) {
+	cryptos := []models.CryptoTransaction{}
+	err := conn(ctx, r.db).SelectContext(ctx, &cryptos, `UPDATE crypto_transaction SET deleted_at = NULL, version = version + 1 
+			  WHERE cryptocurrency_id=$1 AND deleted_at=$2 RETURNING *`, cryptoId, deletedAt)
+	return cryptos, err
+}
+
+func (r *cryptoTransactionRepository) Purge(ctx context.Context, deletedBefore time.Time) ([]models.CryptoTransaction, error) {
+	cryptos := []models.CryptoTransaction{}
+	err := conn(ctx, r.db).SelectContext(ctx, &cryptos, "DELETE FROM crypto_transaction WHERE deleted_at < $1 RETURNING *", deletedBefore)
+	return cryptos, err
+}
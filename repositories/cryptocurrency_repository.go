@@ -1,6 +1,10 @@
 package repositories
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
 	"wallet-manager/models"
 
 	"github.com/jmoiron/sqlx"
@@ -8,46 +12,58 @@ import (
 
 const (
 	insertCryptocurrencyQuery = `
-		INSERT INTO cryptocurrency (name, balance, fiat_balance, created_date) 
-		VALUES (LOWER(:name), :balance, :fiat_balance, :created_date) 
-		RETURNING cryptocurrency_id;
+		INSERT INTO cryptocurrency (asset_id, name, balance, fiat_balance, created_date) 
+		VALUES (:asset_id, :name, :balance, :fiat_balance, :created_date) 
+		RETURNING cryptocurrency_id, version;
 	`
 	getByIDQuery = `
 		SELECT c.*,
 		get_percentage_profit(c.balance, cp.price_usd, c.fiat_balance) AS profit_percentage,
 		get_usd_profit(c.balance, cp.price_usd, c.fiat_balance) AS usd_profit
 		FROM cryptocurrency c
-		LEFT JOIN crypto_price cp ON LOWER(c.name) = LOWER(cp.name)
-		WHERE c.cryptocurrency_id=$1;
+		LEFT JOIN crypto_price cp ON c.asset_id = LOWER(cp.name)
+		WHERE c.cryptocurrency_id=$1 AND c.deleted_at IS NULL;
 	`
 	updateCryptocurrencyQuery = `
 		UPDATE cryptocurrency 
-		SET name=LOWER(:name), balance=:balance, fiat_balance=:fiat_balance, created_date=:created_date 
-		WHERE cryptocurrency_id=:cryptocurrency_id;
+		SET asset_id=:asset_id, name=:name, version = version + 1 
+		WHERE cryptocurrency_id=:cryptocurrency_id AND version=:version AND deleted_at IS NULL 
+		RETURNING version;
 	`
-	updateCryptocurrencyBalanceQuery = `
+	deleteCryptocurrencyQuery = `
 		UPDATE cryptocurrency 
-		SET balance = :balance + balance, fiat_balance = :fiat_balance + fiat_balance 
-		WHERE cryptocurrency_id=:cryptocurrency_id;
+		SET deleted_at = NOW(), version = version + 1 
+		WHERE cryptocurrency_id=$1 AND version=$2 AND deleted_at IS NULL;
 	`
-	deleteCryptocurrencyQuery = `DELETE FROM cryptocurrency WHERE cryptocurrency_id=$1;`
 	getAllCryptocurrencyQuery = `
 		SELECT c.*,
 		get_percentage_profit(c.balance, cp.price_usd, c.fiat_balance) AS profit_percentage,
 		get_usd_profit(c.balance, cp.price_usd, c.fiat_balance) AS usd_profit
 		FROM cryptocurrency c
-		LEFT JOIN crypto_price cp ON LOWER(c.name) = LOWER(cp.name)
+		LEFT JOIN crypto_price cp ON c.asset_id = LOWER(cp.name)
+		WHERE c.deleted_at IS NULL
 		ORDER BY profit_percentage DESC;
 	`
+	getDeletedCryptocurrenciesQuery = `SELECT * FROM cryptocurrency WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC;`
+	getDeletedCryptocurrencyQuery   = `SELECT * FROM cryptocurrency WHERE cryptocurrency_id=$1 AND deleted_at IS NOT NULL;`
+	restoreCryptocurrencyQuery      = `
+		UPDATE cryptocurrency 
+		SET deleted_at = NULL, version = version + 1 
+		WHERE cryptocurrency_id=$1 AND deleted_at IS NOT NULL;
+	`
+	purgeCryptocurrenciesQuery = `DELETE FROM cryptocurrency WHERE deleted_at < $1 RETURNING *;`
 )
 
 type CryptocurrencyRepository interface {
-	Create(crypto *models.Cryptocurrency) error
-	GetAll() ([]models.Cryptocurrency, error)
-	GetByID(id uint32) (*models.Cryptocurrency, error)
-	Update(crypto *models.Cryptocurrency) error
-	UpdateBalance(crypto *models.Cryptocurrency) error
-	Delete(id uint32) error
+	Create(ctx context.Context, crypto *models.Cryptocurrency) error
+	GetAll(ctx context.Context) ([]models.Cryptocurrency, error)
+	GetByID(ctx context.Context, id uint32) (*models.Cryptocurrency, error)
+	Update(ctx context.Context, crypto *models.Cryptocurrency) error
+	Delete(ctx context.Context, id uint32, version uint32) error
+	GetDeleted(ctx context.Context) ([]models.Cryptocurrency, error)
+	GetDeletedByID(ctx context.Context, id uint32) (*models.Cryptocurrency, error)
+	Restore(ctx context.Context, id uint32) error
+	Purge(ctx context.Context, deletedBefore time.Time) ([]models.Cryptocurrency, error)
 }
 
 type cryptocurrencyRepository struct {
@@ -58,38 +74,87 @@ func NewCryptocurrencyRepository(db *sqlx.DB) CryptocurrencyRepository {
 	return &cryptocurrencyRepository{db: db}
 }
 
-func (r *cryptocurrencyRepository) Create(crypto *models.Cryptocurrency) error {
-	stmt, err := r.db.PrepareNamed(insertCryptocurrencyQuery)
+// Create stores the holding; the database records the balances it comes with
+// as its opening balance transaction.
+func (r *cryptocurrencyRepository) Create(ctx context.Context, crypto *models.Cryptocurrency) error {
+	stmt, err := conn(ctx, r.db).PrepareNamedContext(ctx, insertCryptocurrencyQuery)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
-	return stmt.Get(&crypto.ID, crypto)
+	err = stmt.QueryRowxContext(ctx, crypto).Scan(&crypto.ID, &crypto.Version)
+	if isUniqueViolation(err) {
+		return ErrCryptocurrencyNameTaken
+	}
+	return err
 }
 
-func (r *cryptocurrencyRepository) GetAll() ([]models.Cryptocurrency, error) {
+func (r *cryptocurrencyRepository) GetAll(ctx context.Context) ([]models.Cryptocurrency, error) {
 	var cryptos []models.Cryptocurrency
-	err := r.db.Select(&cryptos, getAllCryptocurrencyQuery)
+	err := conn(ctx, r.db).SelectContext(ctx, &cryptos, getAllCryptocurrencyQuery)
 	return cryptos, err
 }
 
-func (r *cryptocurrencyRepository) GetByID(id uint32) (*models.Cryptocurrency, error) {
+func (r *cryptocurrencyRepository) GetByID(ctx context.Context, id uint32) (*models.Cryptocurrency, error) {
 	var crypto models.Cryptocurrency
-	err := r.db.Get(&crypto, getByIDQuery, id)
+	err := conn(ctx, r.db).GetContext(ctx, &crypto, getByIDQuery, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
 	return &crypto, err
 }
 
-func (r *cryptocurrencyRepository) Update(crypto *models.Cryptocurrency) error {
-	_, err := r.db.NamedExec(updateCryptocurrencyQuery, crypto)
+// Update leaves the balances alone, the database derives them from the transactions.
+func (r *cryptocurrencyRepository) Update(ctx context.Context, crypto *models.Cryptocurrency) error {
+	stmt, err := conn(ctx, r.db).PrepareNamedContext(ctx, updateCryptocurrencyQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	err = stmt.GetContext(ctx, &crypto.Version, crypto)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrVersionMismatch
+	}
+	if isUniqueViolation(err) {
+		return ErrCryptocurrencyNameTaken
+	}
 	return err
 }
 
-func (r *cryptocurrencyRepository) UpdateBalance(crypto *models.Cryptocurrency) error {
-	_, err := r.db.NamedExec(updateCryptocurrencyBalanceQuery, crypto)
-	return err
+// Delete moves the cryptocurrency to the trash; Purge removes it for good.
+func (r *cryptocurrencyRepository) Delete(ctx context.Context, id uint32, version uint32) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx, deleteCryptocurrencyQuery, id, version)
+	if err != nil {
+		return err
+	}
+	return expectAffected(result)
+}
+
+func (r *cryptocurrencyRepository) GetDeleted(ctx context.Context) ([]models.Cryptocurrency, error) {
+	cryptos := []models.Cryptocurrency{}
+	err := conn(ctx, r.db).SelectContext(ctx, &cryptos, getDeletedCryptocurrenciesQuery)
+	return cryptos, err
 }
 
-func (r *cryptocurrencyRepository) Delete(id uint32) error {
-	_, err := r.db.Exec(deleteCryptocurrencyQuery, id)
+func (r *cryptocurrencyRepository) GetDeletedByID(ctx context.Context, id uint32) (*models.Cryptocurrency, error) {
+	var crypto models.Cryptocurrency
+	err := conn(ctx, r.db).GetContext(ctx, &crypto, getDeletedCryptocurrencyQuery, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return &crypto, err
+}
+
+func (r *cryptocurrencyRepository) Restore(ctx context.Context, id uint32) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, restoreCryptocurrencyQuery, id)
+	if isUniqueViolation(err) {
+		return ErrCryptocurrencyNameTaken
+	}
 	return err
 }
+
+func (r *cryptocurrencyRepository) Purge(ctx context.Context, deletedBefore time.Time) ([]models.Cryptocurrency, error) {
+	cryptos := []models.Cryptocurrency{}
+	err := conn(ctx, r.db).SelectContext(ctx, &cryptos, purgeCryptocurrenciesQuery, deletedBefore)
+	return cryptos, err
+}
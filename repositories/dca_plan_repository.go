@@ -0,0 +1,126 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+	"wallet-manager/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	insertDCAPlanQuery = `
+		INSERT INTO dca_plan (cryptocurrency_id, fiat_amount, schedule, start_date, end_date, next_run)
+		VALUES (:cryptocurrency_id, :fiat_amount, :schedule, :start_date, :end_date, :next_run)
+		RETURNING plan_id, created_date;
+	`
+	getAllDCAPlansQuery = `SELECT * FROM dca_plan ORDER BY plan_id;`
+	getDCAPlanByIDQuery = `SELECT * FROM dca_plan WHERE plan_id=$1;`
+	// plans of holdings in the trash wait until they are restored
+	getDueDCAPlansQuery = `
+		SELECT p.* FROM dca_plan p
+		JOIN cryptocurrency c ON c.cryptocurrency_id = p.cryptocurrency_id
+		WHERE p.next_run <= $1 AND c.deleted_at IS NULL
+		ORDER BY p.next_run;
+	`
+	lockDueDCAPlanQuery = `
+		SELECT * FROM dca_plan 
+		WHERE plan_id=$1 AND next_run <= $2 
+		FOR UPDATE SKIP LOCKED;
+	`
+	updateDCAPlanQuery = `
+		UPDATE dca_plan
+		SET cryptocurrency_id=:cryptocurrency_id, fiat_amount=:fiat_amount, schedule=:schedule,
+		start_date=:start_date, end_date=:end_date, next_run=:next_run
+		WHERE plan_id=:plan_id;
+	`
+	updateDCAPlanNextRunQuery = `UPDATE dca_plan SET next_run=$2 WHERE plan_id=$1;`
+	deleteDCAPlanQuery        = `DELETE FROM dca_plan WHERE plan_id=$1;`
+)
+
+type DCAPlanRepository interface {
+	Create(ctx context.Context, plan *models.DCAPlan) error
+	GetAll(ctx context.Context) ([]models.DCAPlan, error)
+	GetByID(ctx context.Context, id uint32) (*models.DCAPlan, error)
+	GetDue(ctx context.Context, now time.Time) ([]models.DCAPlan, error)
+	LockDue(ctx context.Context, id uint32, now time.Time) (*models.DCAPlan, error)
+	Update(ctx context.Context, plan *models.DCAPlan) error
+	SetNextRun(ctx context.Context, id uint32, nextRun *time.Time) error
+	Delete(ctx context.Context, id uint32) error
+}
+
+type dcaPlanRepository struct {
+	db *sqlx.DB
+}
+
+func NewDCAPlanRepository(db *sqlx.DB) DCAPlanRepository {
+	return &dcaPlanRepository{db: db}
+}
+
+func (r *dcaPlanRepository) Create(ctx context.Context, plan *models.DCAPlan) error {
+	stmt, err := conn(ctx, r.db).PrepareNamedContext(ctx, insertDCAPlanQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	return stmt.QueryRowxContext(ctx, plan).Scan(&plan.ID, &plan.CreatedDate)
+}
+
+func (r *dcaPlanRepository) GetAll(ctx context.Context) ([]models.DCAPlan, error) {
+	plans := []models.DCAPlan{}
+	err := conn(ctx, r.db).SelectContext(ctx, &plans, getAllDCAPlansQuery)
+	return plans, err
+}
+
+func (r *dcaPlanRepository) GetByID(ctx context.Context, id uint32) (*models.DCAPlan, error) {
+	var plan models.DCAPlan
+	err := conn(ctx, r.db).GetContext(ctx, &plan, getDCAPlanByIDQuery, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return &plan, err
+}
+
+// GetDue returns the plans with a run at or before now.
+func (r *dcaPlanRepository) GetDue(ctx context.Context, now time.Time) ([]models.DCAPlan, error) {
+	var plans []models.DCAPlan
+	err := conn(ctx, r.db).SelectContext(ctx, &plans, getDueDCAPlansQuery, now)
+	return plans, err
+}
+
+// LockDue locks the plan for the rest of the transaction while it is still
+// due. It returns nil when it is not, or another scheduler is running it.
+func (r *dcaPlanRepository) LockDue(ctx context.Context, id uint32, now time.Time) (*models.DCAPlan, error) {
+	var plan models.DCAPlan
+	err := conn(ctx, r.db).GetContext(ctx, &plan, lockDueDCAPlanQuery, id, now)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return &plan, err
+}
+
+func (r *dcaPlanRepository) Update(ctx context.Context, plan *models.DCAPlan) error {
+	result, err := conn(ctx, r.db).NamedExecContext(ctx, updateDCAPlanQuery, plan)
+	if err != nil {
+		return err
+	}
+	return expectFound(result)
+}
+
+func (r *dcaPlanRepository) SetNextRun(ctx context.Context, id uint32, nextRun *time.Time) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx, updateDCAPlanNextRunQuery, id, nextRun)
+	if err != nil {
+		return err
+	}
+	return expectFound(result)
+}
+
+func (r *dcaPlanRepository) Delete(ctx context.Context, id uint32) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx, deleteDCAPlanQuery, id)
+	if err != nil {
+		return err
+	}
+	return expectFound(result)
+}
@@ -0,0 +1,45 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrCryptocurrencyNameTaken = errors.New("a cryptocurrency with this name already exists")
+	ErrExternalIDTaken         = errors.New("the on-chain transaction is already recorded")
+	ErrAddressTaken            = errors.New("the address is already watched for this cryptocurrency")
+	ErrExtendedKeyTaken        = errors.New("the extended key is already registered for this cryptocurrency")
+	ErrVersionMismatch         = errors.New("resource was modified by another request")
+	ErrNotFound                = errors.New("resource not found")
+)
+
+const uniqueViolation = "23505"
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
+}
+
+// expectAffected reports a version mismatch when a versioned write matched no row.
+func expectAffected(result sql.Result) error {
+	return expectRows(result, ErrVersionMismatch)
+}
+
+// expectFound reports ErrNotFound when a write by ID matched no row.
+func expectFound(result sql.Result) error {
+	return expectRows(result, ErrNotFound)
+}
+
+func expectRows(result sql.Result, errNoRows error) error {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errNoRows
+	}
+	return nil
+}
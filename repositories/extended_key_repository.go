@@ -0,0 +1,98 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"wallet-manager/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	insertExtendedKeyQuery = `
+		INSERT INTO extended_key (cryptocurrency_id, extended_key, script_type, gap_limit, label) 
+		VALUES (:cryptocurrency_id, :extended_key, :script_type, :gap_limit, :label) 
+		RETURNING key_id, created_date;
+	`
+	getExtendedKeysQuery       = `SELECT * FROM extended_key WHERE cryptocurrency_id=$1 ORDER BY key_id;`
+	getActiveExtendedKeysQuery = `
+		SELECT k.* FROM extended_key k 
+		JOIN cryptocurrency c ON c.cryptocurrency_id = k.cryptocurrency_id 
+		WHERE c.deleted_at IS NULL 
+		ORDER BY k.key_id;
+	`
+	getExtendedKeyByIDQuery    = `SELECT * FROM extended_key WHERE cryptocurrency_id=$1 AND key_id=$2;`
+	updateExtendedKeyScanQuery = `
+		UPDATE extended_key 
+		SET receive_index=:receive_index, change_index=:change_index, scanned_at=:scanned_at 
+		WHERE key_id=:key_id;
+	`
+	deleteExtendedKeyQuery = `DELETE FROM extended_key WHERE cryptocurrency_id=$1 AND key_id=$2;`
+)
+
+type ExtendedKeyRepository interface {
+	Create(ctx context.Context, key *models.ExtendedKey) error
+	GetAll(ctx context.Context, cryptoId uint32) ([]models.ExtendedKey, error)
+	GetAllActive(ctx context.Context) ([]models.ExtendedKey, error)
+	GetByID(ctx context.Context, cryptoId uint32, id uint32) (*models.ExtendedKey, error)
+	UpdateScan(ctx context.Context, key *models.ExtendedKey) error
+	Delete(ctx context.Context, cryptoId uint32, id uint32) error
+}
+
+type extendedKeyRepository struct {
+	db *sqlx.DB
+}
+
+func NewExtendedKeyRepository(db *sqlx.DB) ExtendedKeyRepository {
+	return &extendedKeyRepository{db: db}
+}
+
+func (r *extendedKeyRepository) Create(ctx context.Context, key *models.ExtendedKey) error {
+	stmt, err := conn(ctx, r.db).PrepareNamedContext(ctx, insertExtendedKeyQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	err = stmt.QueryRowxContext(ctx, key).Scan(&key.ID, &key.CreatedDate)
+	if isUniqueViolation(err) {
+		return ErrExtendedKeyTaken
+	}
+	return err
+}
+
+func (r *extendedKeyRepository) GetAll(ctx context.Context, cryptoId uint32) ([]models.ExtendedKey, error) {
+	keys := []models.ExtendedKey{}
+	err := conn(ctx, r.db).SelectContext(ctx, &keys, getExtendedKeysQuery, cryptoId)
+	return keys, err
+}
+
+// GetAllActive returns the keys of the holdings not in the trash.
+func (r *extendedKeyRepository) GetAllActive(ctx context.Context) ([]models.ExtendedKey, error) {
+	var keys []models.ExtendedKey
+	err := conn(ctx, r.db).SelectContext(ctx, &keys, getActiveExtendedKeysQuery)
+	return keys, err
+}
+
+func (r *extendedKeyRepository) GetByID(ctx context.Context, cryptoId uint32, id uint32) (*models.ExtendedKey, error) {
+	var key models.ExtendedKey
+	err := conn(ctx, r.db).GetContext(ctx, &key, getExtendedKeyByIDQuery, cryptoId, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return &key, err
+}
+
+func (r *extendedKeyRepository) UpdateScan(ctx context.Context, key *models.ExtendedKey) error {
+	_, err := conn(ctx, r.db).NamedExecContext(ctx, updateExtendedKeyScanQuery, key)
+	return err
+}
+
+// Delete removes the key along with the addresses derived from it.
+func (r *extendedKeyRepository) Delete(ctx context.Context, cryptoId uint32, id uint32) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx, deleteExtendedKeyQuery, cryptoId, id)
+	if err != nil {
+		return err
+	}
+	return expectFound(result)
+}
@@ -0,0 +1,95 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"wallet-manager/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	// an expired key is reclaimed by the new request instead of conflicting
+	reserveIdempotencyKeyQuery = `
+		INSERT INTO idempotency_key (idempotency_key, method, path, request_hash, expires_at)
+		VALUES (:idempotency_key, :method, :path, :request_hash, :expires_at)
+		ON CONFLICT (idempotency_key, method, path) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash, expires_at = EXCLUDED.expires_at, created_date = NOW(),
+			status_code = NULL, response_headers = NULL, response_body = NULL
+		WHERE idempotency_key.expires_at < NOW()
+		RETURNING created_date;
+	`
+	getIdempotencyKeyQuery = `
+		SELECT * FROM idempotency_key
+		WHERE idempotency_key=$1 AND method=$2 AND path=$3;
+	`
+	saveIdempotencyResponseQuery = `
+		UPDATE idempotency_key
+		SET status_code=:status_code, response_headers=:response_headers, response_body=:response_body
+		WHERE idempotency_key=:idempotency_key AND method=:method AND path=:path;
+	`
+	deleteIdempotencyKeyQuery = `
+		DELETE FROM idempotency_key
+		WHERE idempotency_key=$1 AND method=$2 AND path=$3;
+	`
+	deleteExpiredIdempotencyKeysQuery = `DELETE FROM idempotency_key WHERE expires_at < NOW();`
+)
+
+type IdempotencyKeyRepository interface {
+	Reserve(ctx context.Context, key *models.IdempotencyKey) (bool, error)
+	Get(ctx context.Context, key string, method string, path string) (*models.IdempotencyKey, error)
+	SaveResponse(ctx context.Context, key *models.IdempotencyKey) error
+	Delete(ctx context.Context, key string, method string, path string) error
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+type idempotencyKeyRepository struct {
+	db *sqlx.DB
+}
+
+func NewIdempotencyKeyRepository(db *sqlx.DB) IdempotencyKeyRepository {
+	return &idempotencyKeyRepository{db: db}
+}
+
+// Reserve stores the key for an in-flight request. It returns false when a
+// live record for the same key already exists.
+func (r *idempotencyKeyRepository) Reserve(ctx context.Context, key *models.IdempotencyKey) (bool, error) {
+	stmt, err := conn(ctx, r.db).PrepareNamedContext(ctx, reserveIdempotencyKeyQuery)
+	if err != nil {
+		return false, err
+	}
+	defer stmt.Close()
+	err = stmt.GetContext(ctx, &key.CreatedDate, key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *idempotencyKeyRepository) Get(ctx context.Context, key string, method string, path string) (*models.IdempotencyKey, error) {
+	var record models.IdempotencyKey
+	err := conn(ctx, r.db).GetContext(ctx, &record, getIdempotencyKeyQuery, key, method, path)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return &record, err
+}
+
+func (r *idempotencyKeyRepository) SaveResponse(ctx context.Context, key *models.IdempotencyKey) error {
+	_, err := conn(ctx, r.db).NamedExecContext(ctx, saveIdempotencyResponseQuery, key)
+	return err
+}
+
+func (r *idempotencyKeyRepository) Delete(ctx context.Context, key string, method string, path string) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, deleteIdempotencyKeyQuery, key, method, path)
+	return err
+}
+
+func (r *idempotencyKeyRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	result, err := conn(ctx, r.db).ExecContext(ctx, deleteExpiredIdempotencyKeysQuery)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,92 @@
+package repositories
+
+import (
+	"context"
+	"time"
+	"wallet-manager/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	insertOutboxEventQuery = `
+		INSERT INTO outbox_event (event_type, payload) 
+		VALUES (:event_type, :payload) 
+		RETURNING event_id, next_attempt_at, created_date;
+	`
+	// same lease as webhook deliveries: claimed rows are skipped by other
+	// dispatchers until leaseUntil, then picked up again if still pending;
+	// RETURNING has no order of its own, so the claimed rows are sorted again
+	claimOutboxEventsQuery = `
+		WITH claimed AS (
+			UPDATE outbox_event SET next_attempt_at = $2 
+			WHERE event_id IN (
+				SELECT event_id FROM outbox_event 
+				WHERE dispatched_at IS NULL AND next_attempt_at <= NOW() 
+				ORDER BY event_id 
+				LIMIT $1 
+				FOR UPDATE SKIP LOCKED
+			) 
+			RETURNING *
+		)
+		SELECT * FROM claimed ORDER BY event_id;
+	`
+	markOutboxEventHandledQuery = `UPDATE outbox_event SET handled_by = array_append(handled_by, $2) WHERE event_id=$1;`
+	updateOutboxEventQuery      = `
+		UPDATE outbox_event 
+		SET attempts=:attempts, next_attempt_at=:next_attempt_at, last_error=:last_error, dispatched_at=:dispatched_at 
+		WHERE event_id=:event_id;
+	`
+	deleteDispatchedOutboxEventsQuery = `DELETE FROM outbox_event WHERE dispatched_at < $1;`
+)
+
+type OutboxRepository interface {
+	Create(ctx context.Context, event *models.OutboxEvent) error
+	ClaimDue(ctx context.Context, limit int, leaseUntil time.Time) ([]models.OutboxEvent, error)
+	MarkHandled(ctx context.Context, id uint64, subscriber string) error
+	Update(ctx context.Context, event *models.OutboxEvent) error
+	DeleteDispatched(ctx context.Context, before time.Time) (int64, error)
+}
+
+type outboxRepository struct {
+	db *sqlx.DB
+}
+
+func NewOutboxRepository(db *sqlx.DB) OutboxRepository {
+	return &outboxRepository{db: db}
+}
+
+func (r *outboxRepository) Create(ctx context.Context, event *models.OutboxEvent) error {
+	stmt, err := conn(ctx, r.db).PrepareNamedContext(ctx, insertOutboxEventQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	return stmt.QueryRowxContext(ctx, event).Scan(&event.ID, &event.NextAttemptAt, &event.CreatedDate)
+}
+
+// ClaimDue returns up to limit undispatched events that are due, oldest first,
+// and holds them until leaseUntil.
+func (r *outboxRepository) ClaimDue(ctx context.Context, limit int, leaseUntil time.Time) ([]models.OutboxEvent, error) {
+	var events []models.OutboxEvent
+	err := conn(ctx, r.db).SelectContext(ctx, &events, claimOutboxEventsQuery, limit, leaseUntil)
+	return events, err
+}
+
+func (r *outboxRepository) MarkHandled(ctx context.Context, id uint64, subscriber string) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, markOutboxEventHandledQuery, id, subscriber)
+	return err
+}
+
+func (r *outboxRepository) Update(ctx context.Context, event *models.OutboxEvent) error {
+	_, err := conn(ctx, r.db).NamedExecContext(ctx, updateOutboxEventQuery, event)
+	return err
+}
+
+func (r *outboxRepository) DeleteDispatched(ctx context.Context, before time.Time) (int64, error) {
+	result, err := conn(ctx, r.db).ExecContext(ctx, deleteDispatchedOutboxEventsQuery, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
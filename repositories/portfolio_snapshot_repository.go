@@ -0,0 +1,49 @@
+package repositories
+
+import (
+	"context"
+	"time"
+	"wallet-manager/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	// holdings without a price count as worth nothing
+	recordPortfolioSnapshotQuery = `
+		INSERT INTO portfolio_snapshot (snapshot_date, value, net_invested, recorded_at)
+		SELECT $1::DATE, COALESCE(SUM(c.balance * cp.price_usd), 0), COALESCE(SUM(c.fiat_balance), 0), NOW()
+		FROM cryptocurrency c
+		LEFT JOIN crypto_price cp ON c.asset_id = LOWER(cp.name)
+		WHERE c.deleted_at IS NULL
+		ON CONFLICT (snapshot_date) DO UPDATE
+		SET value = EXCLUDED.value, net_invested = EXCLUDED.net_invested, recorded_at = EXCLUDED.recorded_at;
+	`
+	getPortfolioSnapshotsSinceQuery = `SELECT * FROM portfolio_snapshot WHERE snapshot_date >= $1 ORDER BY snapshot_date;`
+)
+
+type PortfolioSnapshotRepository interface {
+	Record(ctx context.Context, date time.Time) error
+	GetSince(ctx context.Context, date time.Time) ([]models.PortfolioSnapshot, error)
+}
+
+type portfolioSnapshotRepository struct {
+	db *sqlx.DB
+}
+
+func NewPortfolioSnapshotRepository(db *sqlx.DB) PortfolioSnapshotRepository {
+	return &portfolioSnapshotRepository{db: db}
+}
+
+// Record values the holdings at the current prices as the snapshot of date,
+// replacing one taken earlier that day.
+func (r *portfolioSnapshotRepository) Record(ctx context.Context, date time.Time) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, recordPortfolioSnapshotQuery, date)
+	return err
+}
+
+func (r *portfolioSnapshotRepository) GetSince(ctx context.Context, date time.Time) ([]models.PortfolioSnapshot, error) {
+	snapshots := []models.PortfolioSnapshot{}
+	err := conn(ctx, r.db).SelectContext(ctx, &snapshots, getPortfolioSnapshotsSinceQuery, date)
+	return snapshots, err
+}
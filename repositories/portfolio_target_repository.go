@@ -0,0 +1,47 @@
+package repositories
+
+import (
+	"context"
+	"wallet-manager/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	getAllPortfolioTargetsQuery    = `SELECT * FROM portfolio_target ORDER BY percentage DESC, asset_id;`
+	deleteAllPortfolioTargetsQuery = `DELETE FROM portfolio_target;`
+	insertPortfolioTargetQuery     = `INSERT INTO portfolio_target (asset_id, percentage) VALUES (:asset_id, :percentage);`
+)
+
+type PortfolioTargetRepository interface {
+	GetAll(ctx context.Context) ([]models.PortfolioTarget, error)
+	Replace(ctx context.Context, targets []models.PortfolioTarget) error
+}
+
+type portfolioTargetRepository struct {
+	db *sqlx.DB
+}
+
+func NewPortfolioTargetRepository(db *sqlx.DB) PortfolioTargetRepository {
+	return &portfolioTargetRepository{db: db}
+}
+
+func (r *portfolioTargetRepository) GetAll(ctx context.Context) ([]models.PortfolioTarget, error) {
+	targets := []models.PortfolioTarget{}
+	err := conn(ctx, r.db).SelectContext(ctx, &targets, getAllPortfolioTargetsQuery)
+	return targets, err
+}
+
+// Replace swaps the target allocation; run it within a transaction so readers
+// never see it half written.
+func (r *portfolioTargetRepository) Replace(ctx context.Context, targets []models.PortfolioTarget) error {
+	if _, err := conn(ctx, r.db).ExecContext(ctx, deleteAllPortfolioTargetsQuery); err != nil {
+		return err
+	}
+	for _, target := range targets {
+		if _, err := conn(ctx, r.db).NamedExecContext(ctx, insertPortfolioTargetQuery, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"wallet-manager/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	getPriceQuoteQuery  = `SELECT * FROM price_cache WHERE asset=$1 AND currency=$2;`
+	savePriceQuoteQuery = `
+		INSERT INTO price_cache (asset, currency, price, fetched_at) 
+		VALUES (:asset, :currency, :price, :fetched_at) 
+		ON CONFLICT (asset, currency) DO UPDATE SET price = EXCLUDED.price, fetched_at = EXCLUDED.fetched_at 
+		WHERE price_cache.fetched_at < EXCLUDED.fetched_at;
+	`
+)
+
+type PriceCacheRepository interface {
+	Get(ctx context.Context, asset string, currency string) (*models.PriceQuote, error)
+	Save(ctx context.Context, quote *models.PriceQuote) error
+}
+
+type priceCacheRepository struct {
+	db *sqlx.DB
+}
+
+func NewPriceCacheRepository(db *sqlx.DB) PriceCacheRepository {
+	return &priceCacheRepository{db: db}
+}
+
+func (r *priceCacheRepository) Get(ctx context.Context, asset string, currency string) (*models.PriceQuote, error) {
+	var quote models.PriceQuote
+	err := conn(ctx, r.db).GetContext(ctx, &quote, getPriceQuoteQuery, asset, currency)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return &quote, err
+}
+
+// Save keeps the newest quote when instances race to store the same asset.
+func (r *priceCacheRepository) Save(ctx context.Context, quote *models.PriceQuote) error {
+	_, err := conn(ctx, r.db).NamedExecContext(ctx, savePriceQuoteQuery, quote)
+	return err
+}
@@ -0,0 +1,50 @@
+package repositories
+
+import (
+	"context"
+	"wallet-manager/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	saveReconciliationQuery = `
+		INSERT INTO reconciliation (cryptocurrency_id, balance, ledger_balance, fiat_balance, ledger_fiat_balance, onchain_balance, checked_at) 
+		VALUES (:cryptocurrency_id, :balance, :ledger_balance, :fiat_balance, :ledger_fiat_balance, :onchain_balance, :checked_at) 
+		ON CONFLICT (cryptocurrency_id) DO UPDATE 
+		SET balance = EXCLUDED.balance, ledger_balance = EXCLUDED.ledger_balance, fiat_balance = EXCLUDED.fiat_balance, 
+		ledger_fiat_balance = EXCLUDED.ledger_fiat_balance, onchain_balance = EXCLUDED.onchain_balance, checked_at = EXCLUDED.checked_at;
+	`
+	getReconciliationsQuery = `
+		SELECT r.*, c.asset_id 
+		FROM reconciliation r 
+		JOIN cryptocurrency c ON c.cryptocurrency_id = r.cryptocurrency_id 
+		WHERE c.deleted_at IS NULL ORDER BY r.cryptocurrency_id;
+	`
+)
+
+type ReconciliationRepository interface {
+	Save(ctx context.Context, reconciliation *models.Reconciliation) error
+	GetAll(ctx context.Context) ([]models.Reconciliation, error)
+}
+
+type reconciliationRepository struct {
+	db *sqlx.DB
+}
+
+func NewReconciliationRepository(db *sqlx.DB) ReconciliationRepository {
+	return &reconciliationRepository{db: db}
+}
+
+// Save replaces the last reconciliation of the holding.
+func (r *reconciliationRepository) Save(ctx context.Context, reconciliation *models.Reconciliation) error {
+	_, err := conn(ctx, r.db).NamedExecContext(ctx, saveReconciliationQuery, reconciliation)
+	return err
+}
+
+// GetAll returns the last reconciliation of every holding outside the trash.
+func (r *reconciliationRepository) GetAll(ctx context.Context) ([]models.Reconciliation, error) {
+	reconciliations := []models.Reconciliation{}
+	err := conn(ctx, r.db).SelectContext(ctx, &reconciliations, getReconciliationsQuery)
+	return reconciliations, err
+}
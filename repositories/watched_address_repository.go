@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"context"
+	"wallet-manager/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	insertWatchedAddressQuery = `
+		INSERT INTO watched_address (cryptocurrency_id, address, label, key_id, derivation_path) 
+		VALUES (:cryptocurrency_id, :address, :label, :key_id, :derivation_path) 
+		RETURNING address_id, created_date;
+	`
+	insertDerivedAddressQuery = `
+		INSERT INTO watched_address (cryptocurrency_id, address, label, key_id, derivation_path) 
+		VALUES (:cryptocurrency_id, :address, :label, :key_id, :derivation_path) 
+		ON CONFLICT (cryptocurrency_id, address) DO NOTHING;
+	`
+	getWatchedAddressesQuery  = `SELECT * FROM watched_address WHERE cryptocurrency_id=$1 ORDER BY address_id;`
+	deleteWatchedAddressQuery = `DELETE FROM watched_address WHERE cryptocurrency_id=$1 AND address_id=$2;`
+)
+
+type WatchedAddressRepository interface {
+	Create(ctx context.Context, address *models.WatchedAddress) error
+	AddDerived(ctx context.Context, addresses []models.WatchedAddress) error
+	GetAll(ctx context.Context, cryptoId uint32) ([]models.WatchedAddress, error)
+	Delete(ctx context.Context, cryptoId uint32, id uint32) error
+}
+
+type watchedAddressRepository struct {
+	db *sqlx.DB
+}
+
+func NewWatchedAddressRepository(db *sqlx.DB) WatchedAddressRepository {
+	return &watchedAddressRepository{db: db}
+}
+
+func (r *watchedAddressRepository) Create(ctx context.Context, address *models.WatchedAddress) error {
+	stmt, err := conn(ctx, r.db).PrepareNamedContext(ctx, insertWatchedAddressQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	err = stmt.QueryRowxContext(ctx, address).Scan(&address.ID, &address.CreatedDate)
+	if isUniqueViolation(err) {
+		return ErrAddressTaken
+	}
+	return err
+}
+
+// AddDerived watches the addresses derived from a key, leaving alone the ones
+// already watched.
+func (r *watchedAddressRepository) AddDerived(ctx context.Context, addresses []models.WatchedAddress) error {
+	for i := range addresses {
+		if _, err := conn(ctx, r.db).NamedExecContext(ctx, insertDerivedAddressQuery, &addresses[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *watchedAddressRepository) GetAll(ctx context.Context, cryptoId uint32) ([]models.WatchedAddress, error) {
+	addresses := []models.WatchedAddress{}
+	err := conn(ctx, r.db).SelectContext(ctx, &addresses, getWatchedAddressesQuery, cryptoId)
+	return addresses, err
+}
+
+func (r *watchedAddressRepository) Delete(ctx context.Context, cryptoId uint32, id uint32) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx, deleteWatchedAddressQuery, cryptoId, id)
+	if err != nil {
+		return err
+	}
+	return expectFound(result)
+}
@@ -0,0 +1,139 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+	"wallet-manager/models"
+
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	insertWebhookSubscriptionQuery = `
+		INSERT INTO webhook_subscription (url, secret, event_types) 
+		VALUES (:url, :secret, :event_types) 
+		RETURNING webhook_subscription_id, created_date;
+	`
+	getWebhookSubscriptionsQuery    = `SELECT * FROM webhook_subscription ORDER BY webhook_subscription_id;`
+	getWebhookSubscriptionByIDQuery = `SELECT * FROM webhook_subscription WHERE webhook_subscription_id=$1;`
+	getWebhookSubscribersQuery      = `SELECT * FROM webhook_subscription WHERE $1 = ANY(event_types);`
+	deleteWebhookSubscriptionQuery  = `DELETE FROM webhook_subscription WHERE webhook_subscription_id=$1;`
+	insertWebhookDeliveryQuery      = `
+		INSERT INTO webhook_delivery (webhook_subscription_id, event_type, payload) 
+		VALUES (:webhook_subscription_id, :event_type, :payload) 
+		RETURNING delivery_id, status, next_attempt_at, created_date;
+	`
+	// pushing next_attempt_at forward leases the claimed rows, so concurrent
+	// workers skip them and a crashed worker's rows are picked up again later
+	claimWebhookDeliveriesQuery = `
+		UPDATE webhook_delivery SET next_attempt_at = $2 
+		WHERE delivery_id IN (
+			SELECT delivery_id FROM webhook_delivery 
+			WHERE status = 'pending' AND next_attempt_at <= NOW() 
+			ORDER BY next_attempt_at 
+			LIMIT $1 
+			FOR UPDATE SKIP LOCKED
+		) 
+		RETURNING *;
+	`
+	updateWebhookDeliveryQuery = `
+		UPDATE webhook_delivery 
+		SET status=:status, attempts=:attempts, next_attempt_at=:next_attempt_at, last_status_code=:last_status_code, 
+		last_error=:last_error, delivered_at=:delivered_at 
+		WHERE delivery_id=:delivery_id;
+	`
+	getWebhookDeliveriesQuery = `
+		SELECT * FROM webhook_delivery 
+		WHERE webhook_subscription_id=$1 
+		ORDER BY created_date DESC, delivery_id DESC 
+		LIMIT $2;
+	`
+)
+
+type WebhookRepository interface {
+	CreateSubscription(ctx context.Context, subscription *models.WebhookSubscription) error
+	GetSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error)
+	GetSubscriptionByID(ctx context.Context, id uint32) (*models.WebhookSubscription, error)
+	GetSubscribers(ctx context.Context, eventType string) ([]models.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, id uint32) error
+	CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	ClaimDueDeliveries(ctx context.Context, limit int, leaseUntil time.Time) ([]models.WebhookDelivery, error)
+	UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	GetDeliveries(ctx context.Context, subscriptionID uint32, limit int) ([]models.WebhookDelivery, error)
+}
+
+type webhookRepository struct {
+	db *sqlx.DB
+}
+
+func NewWebhookRepository(db *sqlx.DB) WebhookRepository {
+	return &webhookRepository{db: db}
+}
+
+func (r *webhookRepository) CreateSubscription(ctx context.Context, subscription *models.WebhookSubscription) error {
+	stmt, err := conn(ctx, r.db).PrepareNamedContext(ctx, insertWebhookSubscriptionQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	return stmt.QueryRowxContext(ctx, subscription).Scan(&subscription.ID, &subscription.CreatedDate)
+}
+
+func (r *webhookRepository) GetSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	subscriptions := []models.WebhookSubscription{}
+	err := conn(ctx, r.db).SelectContext(ctx, &subscriptions, getWebhookSubscriptionsQuery)
+	return subscriptions, err
+}
+
+func (r *webhookRepository) GetSubscriptionByID(ctx context.Context, id uint32) (*models.WebhookSubscription, error) {
+	var subscription models.WebhookSubscription
+	err := conn(ctx, r.db).GetContext(ctx, &subscription, getWebhookSubscriptionByIDQuery, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return &subscription, err
+}
+
+func (r *webhookRepository) GetSubscribers(ctx context.Context, eventType string) ([]models.WebhookSubscription, error) {
+	var subscriptions []models.WebhookSubscription
+	err := conn(ctx, r.db).SelectContext(ctx, &subscriptions, getWebhookSubscribersQuery, eventType)
+	return subscriptions, err
+}
+
+func (r *webhookRepository) DeleteSubscription(ctx context.Context, id uint32) error {
+	result, err := conn(ctx, r.db).ExecContext(ctx, deleteWebhookSubscriptionQuery, id)
+	if err != nil {
+		return err
+	}
+	return expectFound(result)
+}
+
+func (r *webhookRepository) CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	stmt, err := conn(ctx, r.db).PrepareNamedContext(ctx, insertWebhookDeliveryQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	return stmt.QueryRowxContext(ctx, delivery).Scan(&delivery.ID, &delivery.Status, &delivery.NextAttemptAt, &delivery.CreatedDate)
+}
+
+// ClaimDueDeliveries returns up to limit pending deliveries that are due and
+// holds them until leaseUntil.
+func (r *webhookRepository) ClaimDueDeliveries(ctx context.Context, limit int, leaseUntil time.Time) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	err := conn(ctx, r.db).SelectContext(ctx, &deliveries, claimWebhookDeliveriesQuery, limit, leaseUntil)
+	return deliveries, err
+}
+
+func (r *webhookRepository) UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	_, err := conn(ctx, r.db).NamedExecContext(ctx, updateWebhookDeliveryQuery, delivery)
+	return err
+}
+
+func (r *webhookRepository) GetDeliveries(ctx context.Context, subscriptionID uint32, limit int) ([]models.WebhookDelivery, error) {
+	deliveries := []models.WebhookDelivery{}
+	err := conn(ctx, r.db).SelectContext(ctx, &deliveries, getWebhookDeliveriesQuery, subscriptionID, limit)
+	return deliveries, err
+}
@@ -0,0 +1,398 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+	"wallet-manager/chain"
+	"wallet-manager/models"
+	"wallet-manager/repositories"
+	"wallet-manager/utils"
+
+	"github.com/shopspring/decimal"
+)
+
+type AddressService interface {
+	Create(ctx context.Context, address *models.WatchedAddress) error
+	GetAll(ctx context.Context, cryptoId uint32) ([]models.WatchedAddress, error)
+	Delete(ctx context.Context, cryptoId uint32, id uint32) error
+	Status(ctx context.Context, cryptoId uint32) (*models.OnChainStatus, error)
+	OnChainBalance(ctx context.Context, cryptoId uint32) (*decimal.Decimal, error)
+	CreateKey(ctx context.Context, key *models.ExtendedKey) error
+	GetKeys(ctx context.Context, cryptoId uint32) ([]models.ExtendedKey, error)
+	DeleteKey(ctx context.Context, cryptoId uint32, id uint32) error
+	Scan(ctx context.Context, cryptoId uint32, id uint32) (*models.ExtendedKey, error)
+	ScanAll(ctx context.Context) error
+}
+
+// extended keys derive Bitcoin addresses only
+const (
+	keyChain        = "bitcoin"
+	defaultGapLimit = 20
+)
+
+type addressService struct {
+	repo            repositories.WatchedAddressRepository
+	keyRepo         repositories.ExtendedKeyRepository
+	cryptoRepo      repositories.CryptocurrencyRepository
+	transactionRepo repositories.CryptoTransactionRepository
+	priceRepo       repositories.CryptoPriceRepository
+	assets          AssetService
+	indexers        map[string]chain.Indexer
+	transactor      repositories.Transactor
+}
+
+// NewAddressService takes the indexer of each supported chain, keyed by the
+// chain of the assets; addresses of holdings on other chains are rejected.
+func NewAddressService(repo repositories.WatchedAddressRepository, keyRepo repositories.ExtendedKeyRepository, cryptoRepo repositories.CryptocurrencyRepository, transactionRepo repositories.CryptoTransactionRepository, priceRepo repositories.CryptoPriceRepository, assets AssetService, indexers map[string]chain.Indexer, transactor repositories.Transactor) AddressService {
+	return &addressService{
+		repo:            repo,
+		keyRepo:         keyRepo,
+		cryptoRepo:      cryptoRepo,
+		transactionRepo: transactionRepo,
+		priceRepo:       priceRepo,
+		assets:          assets,
+		indexers:        indexers,
+		transactor:      transactor,
+	}
+}
+
+func (s *addressService) Create(ctx context.Context, address *models.WatchedAddress) error {
+	crypto, chainName, err := s.holding(ctx, address.CryptocurrencyId)
+	if err != nil {
+		return err
+	}
+	if _, err := s.indexerOf(crypto, chainName); err != nil {
+		return err
+	}
+	if err := chain.ValidateAddress(chainName, address.Address); err != nil {
+		return err
+	}
+	address.KeyID, address.DerivationPath = nil, nil
+	return s.repo.Create(ctx, address)
+}
+
+func (s *addressService) GetAll(ctx context.Context, cryptoId uint32) ([]models.WatchedAddress, error) {
+	crypto, err := s.cryptoRepo.GetByID(ctx, cryptoId)
+	if err != nil {
+		return nil, err
+	}
+	if crypto == nil {
+		return nil, ErrCryptocurrencyNotFound
+	}
+	return s.repo.GetAll(ctx, cryptoId)
+}
+
+// Delete unwatches an address; the ones derived from a key go with the key.
+func (s *addressService) Delete(ctx context.Context, cryptoId uint32, id uint32) error {
+	addresses, err := s.repo.GetAll(ctx, cryptoId)
+	if err != nil {
+		return err
+	}
+	for _, address := range addresses {
+		if address.ID == id && address.KeyID != nil {
+			return ErrAddressDerived
+		}
+	}
+
+	err = s.repo.Delete(ctx, cryptoId, id)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return ErrAddressNotFound
+	}
+	return err
+}
+
+// Status reads the watched addresses of the holding. Transfers between two of
+// them net out, and the ones already recorded, by their transaction ID, are
+// left out of the proposals.
+func (s *addressService) Status(ctx context.Context, cryptoId uint32) (*models.OnChainStatus, error) {
+	crypto, indexer, err := s.indexer(ctx, cryptoId)
+	if err != nil {
+		return nil, err
+	}
+	addresses, err := s.repo.GetAll(ctx, cryptoId)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &models.OnChainStatus{
+		CryptocurrencyID: cryptoId,
+		LedgerBalance:    crypto.Balance,
+		OnChainBalance:   decimal.Zero,
+		Addresses:        make([]models.AddressBalance, 0, len(addresses)),
+		Proposals:        []models.CryptoTransaction{},
+	}
+	movements := map[string]*chain.Transfer{}
+	for _, address := range addresses {
+		balance, err := indexer.Balance(ctx, address.Address)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", address.Address, err)
+		}
+		status.OnChainBalance = status.OnChainBalance.Add(balance)
+		status.Addresses = append(status.Addresses, models.AddressBalance{Address: address.Address, Label: address.Label, Balance: balance})
+
+		transfers, err := indexer.Transfers(ctx, address.Address)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", address.Address, err)
+		}
+		for _, transfer := range transfers {
+			if movement, ok := movements[transfer.TxID]; ok {
+				movement.Amount = movement.Amount.Add(transfer.Amount)
+				continue
+			}
+			transfer := transfer
+			movements[transfer.TxID] = &transfer
+		}
+	}
+	status.Difference = status.OnChainBalance.Sub(status.LedgerBalance)
+
+	transactions, err := s.transactionRepo.GetAll(ctx, cryptoId)
+	if err != nil {
+		return nil, err
+	}
+	for _, transaction := range transactions {
+		if transaction.ExternalID != nil {
+			delete(movements, *transaction.ExternalID)
+		}
+	}
+
+	unrecorded := make([]*chain.Transfer, 0, len(movements))
+	for _, movement := range movements {
+		if !movement.Amount.IsZero() {
+			unrecorded = append(unrecorded, movement)
+		}
+	}
+	sort.Slice(unrecorded, func(i, j int) bool { return unrecorded[i].Time.Before(unrecorded[j].Time) })
+	for _, movement := range unrecorded {
+		proposal, err := s.propose(ctx, crypto, movement)
+		if err != nil {
+			return nil, err
+		}
+		status.Proposals = append(status.Proposals, proposal)
+	}
+	return status, nil
+}
+
+// OnChainBalance adds up the balances of the watched addresses of the holding,
+// nil when it has none or no indexer reads its chain.
+func (s *addressService) OnChainBalance(ctx context.Context, cryptoId uint32) (*decimal.Decimal, error) {
+	crypto, chainName, err := s.holding(ctx, cryptoId)
+	if err != nil {
+		return nil, err
+	}
+	indexer, err := s.indexerOf(crypto, chainName)
+	if errors.Is(err, ErrUnsupportedChain) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	addresses, err := s.repo.GetAll(ctx, cryptoId)
+	if err != nil || len(addresses) == 0 {
+		return nil, err
+	}
+
+	total := decimal.Zero
+	for _, address := range addresses {
+		balance, err := indexer.Balance(ctx, address.Address)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", address.Address, err)
+		}
+		total = total.Add(balance)
+	}
+	return &total, nil
+}
+
+// propose records a movement as a buy when coins came in and a sell when they
+// went out, valued at the price of the asset back then. FiatAmount is zero
+// when no price is known, for the user to fill in.
+func (s *addressService) propose(ctx context.Context, crypto *models.Cryptocurrency, movement *chain.Transfer) (models.CryptoTransaction, error) {
+	txId := movement.TxID
+	proposal := models.CryptoTransaction{
+		CryptocurrencyId:     crypto.ID,
+		Type:                 models.TransactionTypeBuy,
+		Status:               models.TransactionStatusConfirmed,
+		ExternalID:           &txId,
+		CryptocurrencyAmount: movement.Amount.Abs(),
+		PurchaseDate:         movement.Time.UTC().Format(utils.TimeFormat),
+	}
+	if movement.Amount.IsNegative() {
+		proposal.Type = models.TransactionTypeSell
+	}
+
+	price, err := s.priceAt(ctx, crypto.AssetID, movement.Time)
+	if err != nil {
+		return proposal, err
+	}
+	proposal.FiatAmount = proposal.CryptocurrencyAmount.Mul(price).Round(2)
+	return proposal, nil
+}
+
+func (s *addressService) priceAt(ctx context.Context, asset string, at time.Time) (decimal.Decimal, error) {
+	price, err := s.priceRepo.GetAt(ctx, asset, at)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	if price == nil {
+		if price, err = s.priceRepo.GetByName(ctx, asset); err != nil || price == nil {
+			return decimal.Zero, err
+		}
+	}
+	return price.PriceUSD, nil
+}
+
+// CreateKey registers the key of a Bitcoin holding. Its addresses are watched
+// once it is scanned.
+func (s *addressService) CreateKey(ctx context.Context, key *models.ExtendedKey) error {
+	crypto, chainName, err := s.holding(ctx, key.CryptocurrencyId)
+	if err != nil {
+		return err
+	}
+	if chainName != keyChain {
+		return fmt.Errorf("%w: extended keys are only supported for %s, not %s", ErrUnsupportedChain, keyChain, crypto.AssetID)
+	}
+	if _, ok := s.indexers[chainName]; !ok {
+		return fmt.Errorf("%w: %s", ErrUnsupportedChain, chainName)
+	}
+
+	extended, err := chain.ParseExtendedKey(key.Key, key.ScriptType)
+	if err != nil {
+		return err
+	}
+	key.ScriptType = extended.ScriptType
+	if key.GapLimit == 0 {
+		key.GapLimit = defaultGapLimit
+	}
+	key.ReceiveIndex, key.ChangeIndex, key.ScannedAt = 0, 0, nil
+	return s.keyRepo.Create(ctx, key)
+}
+
+func (s *addressService) GetKeys(ctx context.Context, cryptoId uint32) ([]models.ExtendedKey, error) {
+	if _, _, err := s.holding(ctx, cryptoId); err != nil {
+		return nil, err
+	}
+	return s.keyRepo.GetAll(ctx, cryptoId)
+}
+
+func (s *addressService) DeleteKey(ctx context.Context, cryptoId uint32, id uint32) error {
+	err := s.keyRepo.Delete(ctx, cryptoId, id)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return ErrExtendedKeyNotFound
+	}
+	return err
+}
+
+func (s *addressService) Scan(ctx context.Context, cryptoId uint32, id uint32) (*models.ExtendedKey, error) {
+	key, err := s.keyRepo.GetByID(ctx, cryptoId, id)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, ErrExtendedKeyNotFound
+	}
+	_, indexer, err := s.indexer(ctx, cryptoId)
+	if err != nil {
+		return nil, err
+	}
+	return key, s.scan(ctx, indexer, key)
+}
+
+// ScanAll scans the keys of every holding, so addresses a wallet starts using
+// get watched.
+func (s *addressService) ScanAll(ctx context.Context) error {
+	keys, err := s.keyRepo.GetAllActive(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for i := range keys {
+		_, indexer, err := s.indexer(ctx, keys[i].CryptocurrencyId)
+		if err == nil {
+			err = s.scan(ctx, indexer, &keys[i])
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("extended key %d: %w", keys[i].ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// scan goes on from the first unused address of each branch and watches the
+// ones used since.
+func (s *addressService) scan(ctx context.Context, indexer chain.Indexer, key *models.ExtendedKey) error {
+	extended, err := chain.ParseExtendedKey(key.Key, key.ScriptType)
+	if err != nil {
+		return err
+	}
+
+	var derived []models.WatchedAddress
+	for _, branch := range []struct {
+		number uint32
+		next   *uint32
+	}{{chain.BranchReceive, &key.ReceiveIndex}, {chain.BranchChange, &key.ChangeIndex}} {
+		used, next, err := chain.Discover(ctx, indexer, extended, branch.number, *branch.next, key.GapLimit)
+		if err != nil {
+			return err
+		}
+		for _, address := range used {
+			path := address.Path()
+			derived = append(derived, models.WatchedAddress{
+				CryptocurrencyId: key.CryptocurrencyId,
+				Address:          address.Address,
+				Label:            key.Label,
+				KeyID:            &key.ID,
+				DerivationPath:   &path,
+			})
+		}
+		*branch.next = next
+	}
+
+	scannedAt := time.Now().UTC()
+	key.ScannedAt = &scannedAt
+	return s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.repo.AddDerived(ctx, derived); err != nil {
+			return err
+		}
+		return s.keyRepo.UpdateScan(ctx, key)
+	})
+}
+
+// holding finds the holding and the chain of its asset, empty for tokens.
+func (s *addressService) holding(ctx context.Context, cryptoId uint32) (*models.Cryptocurrency, string, error) {
+	crypto, err := s.cryptoRepo.GetByID(ctx, cryptoId)
+	if err != nil {
+		return nil, "", err
+	}
+	if crypto == nil {
+		return nil, "", ErrCryptocurrencyNotFound
+	}
+	asset, err := s.assets.GetByID(ctx, crypto.AssetID)
+	if err != nil || asset == nil {
+		return crypto, "", err
+	}
+	return crypto, asset.Chain, nil
+}
+
+// indexer finds the holding and the indexer of the chain of its asset.
+func (s *addressService) indexer(ctx context.Context, cryptoId uint32) (*models.Cryptocurrency, chain.Indexer, error) {
+	crypto, chainName, err := s.holding(ctx, cryptoId)
+	if err != nil {
+		return nil, nil, err
+	}
+	indexer, err := s.indexerOf(crypto, chainName)
+	return crypto, indexer, err
+}
+
+func (s *addressService) indexerOf(crypto *models.Cryptocurrency, chainName string) (chain.Indexer, error) {
+	if chainName == "" {
+		return nil, fmt.Errorf("%w: %s is not a native coin", ErrUnsupportedChain, crypto.AssetID)
+	}
+	indexer, ok := s.indexers[chainName]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedChain, chainName)
+	}
+	return indexer, nil
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"net/url"
+	"time"
+	"wallet-manager/models"
+	"wallet-manager/notifiers"
+	"wallet-manager/repositories"
+	"wallet-manager/utils"
+
+	"github.com/shopspring/decimal"
+)
+
+type AlertService interface {
+	Create(ctx context.Context, rule *models.AlertRule) error
+	GetAll(ctx context.Context) ([]models.AlertRule, error)
+	GetByID(ctx context.Context, id uint32) (*models.AlertRule, error)
+	Update(ctx context.Context, rule *models.AlertRule) error
+	Delete(ctx context.Context, id uint32) error
+	Evaluate(ctx context.Context) error
+}
+
+type alertService struct {
+	repo       repositories.AlertRuleRepository
+	priceRepo  repositories.CryptoPriceRepository
+	cryptoRepo repositories.CryptocurrencyRepository
+	assets     AssetService
+	notifiers  map[string]notifiers.Notifier
+}
+
+// NewAlertService takes the notifier of each enabled channel; rules on other
+// channels are rejected.
+func NewAlertService(repo repositories.AlertRuleRepository, priceRepo repositories.CryptoPriceRepository, cryptoRepo repositories.CryptocurrencyRepository, assets AssetService, channels map[string]notifiers.Notifier) AlertService {
+	return &alertService{repo: repo, priceRepo: priceRepo, cryptoRepo: cryptoRepo, assets: assets, notifiers: channels}
+}
+
+func (s *alertService) Create(ctx context.Context, rule *models.AlertRule) error {
+	if err := s.validate(ctx, rule); err != nil {
+		return err
+	}
+	return s.repo.Create(ctx, rule)
+}
+
+func (s *alertService) GetAll(ctx context.Context) ([]models.AlertRule, error) {
+	return s.repo.GetAll(ctx)
+}
+
+func (s *alertService) GetByID(ctx context.Context, id uint32) (*models.AlertRule, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+func (s *alertService) Update(ctx context.Context, rule *models.AlertRule) error {
+	if err := s.validate(ctx, rule); err != nil {
+		return err
+	}
+	err := s.repo.Update(ctx, rule)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return ErrAlertRuleNotFound
+	}
+	return err
+}
+
+func (s *alertService) Delete(ctx context.Context, id uint32) error {
+	err := s.repo.Delete(ctx, id)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return ErrAlertRuleNotFound
+	}
+	return err
+}
+
+// validate checks what binding tags cannot: the fields each rule type needs and
+// a target matching the channel. The asset, which may be a symbol or a display
+// name, is replaced with the canonical asset id prices are stored under.
+func (s *alertService) validate(ctx context.Context, rule *models.AlertRule) error {
+	if rule.Type != models.AlertRuleProfitBelow {
+		asset, err := s.assets.Resolve(ctx, rule.Asset)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidAlertRule, rule.Asset, err)
+		}
+		rule.Asset = asset.ID
+	}
+
+	switch rule.Type {
+	case models.AlertRulePriceAbove, models.AlertRulePriceBelow:
+		if !rule.Threshold.IsPositive() {
+			return fmt.Errorf("%w: threshold must be a price greater than zero", ErrInvalidAlertRule)
+		}
+	case models.AlertRuleProfitBelow:
+		crypto, err := s.cryptoRepo.GetByID(ctx, *rule.CryptocurrencyId)
+		if err != nil {
+			return err
+		}
+		if crypto == nil {
+			return fmt.Errorf("%w: %v", ErrInvalidAlertRule, ErrCryptocurrencyNotFound)
+		}
+	}
+
+	if _, ok := s.notifiers[rule.Channel]; !ok {
+		return fmt.Errorf("%w: %s notifications are not configured", ErrInvalidAlertRule, rule.Channel)
+	}
+
+	switch rule.Channel {
+	case models.AlertChannelWebhook:
+		target, err := url.ParseRequestURI(rule.Target)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") {
+			return fmt.Errorf("%w: target must be an http(s) URL", ErrInvalidAlertRule)
+		}
+	case models.AlertChannelEmail:
+		if _, err := mail.ParseAddress(rule.Target); err != nil {
+			return fmt.Errorf("%w: target must be an email address", ErrInvalidAlertRule)
+		}
+	}
+	return nil
+}
+
+// Evaluate checks every active rule against the stored prices. A rule notifies
+// once when its condition starts to hold and is re-armed when it stops holding;
+// the cooldown additionally limits how often a flapping condition notifies.
+func (s *alertService) Evaluate(ctx context.Context) error {
+	rules, err := s.repo.GetActive(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	now := time.Now().UTC()
+	for i := range rules {
+		if err := s.evaluate(ctx, &rules[i], now); err != nil {
+			errs = append(errs, fmt.Errorf("alert rule %d: %w", rules[i].ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *alertService) evaluate(ctx context.Context, rule *models.AlertRule, now time.Time) error {
+	value, met, err := s.check(ctx, rule, now)
+	if err != nil {
+		return err
+	}
+
+	if !met {
+		if !rule.Triggered {
+			return nil
+		}
+		rule.Triggered = false
+		return s.repo.UpdateState(ctx, rule)
+	}
+	if rule.Triggered {
+		return nil
+	}
+
+	rule.Triggered = true
+	cooldown := time.Duration(rule.CooldownMinutes) * time.Minute
+	if rule.LastNotifiedAt == nil || now.Sub(*rule.LastNotifiedAt) >= cooldown {
+		if err := s.notify(ctx, rule, value, now); err != nil {
+			// stay armed so the next evaluation tries again
+			return err
+		}
+		rule.LastNotifiedAt = &now
+	} else {
+		log.Printf("alert rule %d triggered during its cooldown, not notifying", rule.ID)
+	}
+	return s.repo.UpdateState(ctx, rule)
+}
+
+// check reports the value the rule looks at and whether its condition holds.
+// Missing prices or holdings never trigger a rule.
+func (s *alertService) check(ctx context.Context, rule *models.AlertRule, now time.Time) (decimal.Decimal, bool, error) {
+	switch rule.Type {
+	case models.AlertRulePriceAbove, models.AlertRulePriceBelow:
+		price, err := s.priceRepo.GetByName(ctx, rule.Asset)
+		if err != nil || price == nil {
+			return decimal.Zero, false, err
+		}
+		if rule.Type == models.AlertRulePriceAbove {
+			return price.PriceUSD, price.PriceUSD.GreaterThan(rule.Threshold), nil
+		}
+		return price.PriceUSD, price.PriceUSD.LessThan(rule.Threshold), nil
+
+	case models.AlertRulePercentChange:
+		current, err := s.priceRepo.GetByName(ctx, rule.Asset)
+		if err != nil || current == nil {
+			return decimal.Zero, false, err
+		}
+		window := time.Duration(rule.WindowMinutes) * time.Minute
+		past, err := s.priceRepo.GetAt(ctx, rule.Asset, now.Add(-window))
+		if err != nil || past == nil || past.PriceUSD.IsZero() {
+			return decimal.Zero, false, err
+		}
+		change := current.PriceUSD.Sub(past.PriceUSD).Div(past.PriceUSD).Mul(decimal.NewFromInt(100))
+		// a negative threshold watches for drops, a positive one for rises
+		if rule.Threshold.IsNegative() {
+			return change, change.LessThanOrEqual(rule.Threshold), nil
+		}
+		return change, change.GreaterThanOrEqual(rule.Threshold), nil
+
+	case models.AlertRuleProfitBelow:
+		crypto, err := s.cryptoRepo.GetByID(ctx, *rule.CryptocurrencyId)
+		if err != nil || crypto == nil || !crypto.CostInFiat.IsPositive() {
+			return decimal.Zero, false, err
+		}
+		// read the price itself: ProfitPercentage counts a missing one as zero
+		price, err := s.priceRepo.GetByName(ctx, crypto.AssetID)
+		if err != nil || price == nil {
+			return decimal.Zero, false, err
+		}
+		value := crypto.Balance.Mul(price.PriceUSD)
+		profit := value.Sub(crypto.CostInFiat).Div(crypto.CostInFiat).Mul(decimal.NewFromInt(100))
+		return profit, profit.LessThan(rule.Threshold), nil
+	}
+	return decimal.Zero, false, fmt.Errorf("unknown rule type %q", rule.Type)
+}
+
+func (s *alertService) notify(ctx context.Context, rule *models.AlertRule, value decimal.Decimal, now time.Time) error {
+	notifier, ok := s.notifiers[rule.Channel]
+	if !ok {
+		return fmt.Errorf("%s notifications are not configured", rule.Channel)
+	}
+	return notifier.Notify(ctx, rule.Target, notifiers.Notification{
+		RuleID:      rule.ID,
+		Type:        rule.Type,
+		Asset:       rule.Asset,
+		Threshold:   rule.Threshold,
+		Value:       value,
+		Message:     alertMessage(rule, value),
+		TriggeredAt: now.Format(utils.TimeFormat),
+	})
+}
+
+func alertMessage(rule *models.AlertRule, value decimal.Decimal) string {
+	switch rule.Type {
+	case models.AlertRulePriceAbove:
+		return fmt.Sprintf("%s is above %s USD at %s USD", rule.Asset, rule.Threshold, value)
+	case models.AlertRulePriceBelow:
+		return fmt.Sprintf("%s is below %s USD at %s USD", rule.Asset, rule.Threshold, value)
+	case models.AlertRulePercentChange:
+		return fmt.Sprintf("%s changed %s%% in the last %d minutes", rule.Asset, value.StringFixed(2), rule.WindowMinutes)
+	default:
+		return fmt.Sprintf("profit of cryptocurrency %d is below %s%% at %s%%", *rule.CryptocurrencyId, rule.Threshold, value.StringFixed(2))
+	}
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"wallet-manager/models"
+	"wallet-manager/repositories"
+)
+
+const defaultAssetSearchLimit = 20
+
+type AssetService interface {
+	Seed(ctx context.Context, seed []byte) error
+	Search(ctx context.Context, query string, limit int) ([]models.Asset, error)
+	GetByID(ctx context.Context, id string) (*models.Asset, error)
+	Resolve(ctx context.Context, ref string) (*models.Asset, error)
+	IsKnown(ref string) (bool, error)
+	ProviderIDs(ctx context.Context, provider string, ids []string) (map[string]string, error)
+}
+
+type assetService struct {
+	repo repositories.AssetRepository
+}
+
+func NewAssetService(repo repositories.AssetRepository) AssetService {
+	return &assetService{repo: repo}
+}
+
+// Seed upserts the assets of a JSON list like database/seeds/assets.json, so
+// entries edited there are updated on the next start.
+func (s *assetService) Seed(ctx context.Context, seed []byte) error {
+	var assets []models.Asset
+	if err := json.Unmarshal(seed, &assets); err != nil {
+		return err
+	}
+	for i := range assets {
+		if err := s.repo.Upsert(ctx, &assets[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *assetService) Search(ctx context.Context, query string, limit int) ([]models.Asset, error) {
+	if limit <= 0 {
+		limit = defaultAssetSearchLimit
+	}
+	return s.repo.Search(ctx, query, limit)
+}
+
+func (s *assetService) GetByID(ctx context.Context, id string) (*models.Asset, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+// Resolve finds an asset by id, symbol or display name, so "BTC" and
+// "Bitcoin" both lead to bitcoin.
+func (s *assetService) Resolve(ctx context.Context, ref string) (*models.Asset, error) {
+	asset, err := s.repo.Resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if asset == nil {
+		return nil, ErrUnknownAsset
+	}
+	return asset, nil
+}
+
+// IsKnown backs the known_asset validator.
+func (s *assetService) IsKnown(ref string) (bool, error) {
+	asset, err := s.repo.Resolve(context.Background(), ref)
+	return asset != nil, err
+}
+
+// ProviderIDs lets the price aggregator ask each provider for its own ids.
+func (s *assetService) ProviderIDs(ctx context.Context, provider string, ids []string) (map[string]string, error) {
+	return s.repo.GetProviderIDs(ctx, provider, ids)
+}
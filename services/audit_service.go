@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"wallet-manager/models"
+	"wallet-manager/repositories"
+)
+
+type AuditService interface {
+	Record(ctx context.Context, action string, entity string, entityID uint32, before interface{}, after interface{}) error
+	Find(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error)
+}
+
+type auditService struct {
+	repo repositories.AuditRepository
+}
+
+func NewAuditService(repo repositories.AuditRepository) AuditService {
+	return &auditService{repo: repo}
+}
+
+// Record appends an entry for the actor in ctx. Call it with the context of the
+// transaction making the change so both are committed together.
+func (s *auditService) Record(ctx context.Context, action string, entity string, entityID uint32, before interface{}, after interface{}) error {
+	beforeJSON, err := snapshot(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := snapshot(after)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.Create(ctx, &models.AuditEntry{
+		Actor:    ActorFromContext(ctx),
+		Action:   action,
+		Entity:   entity,
+		EntityID: entityID,
+		Before:   beforeJSON,
+		After:    afterJSON,
+	})
+}
+
+func (s *auditService) Find(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error) {
+	return s.repo.Find(ctx, filter)
+}
+
+func snapshot(value interface{}) (*json.RawMessage, error) {
+	if value == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	raw := json.RawMessage(data)
+	return &raw, nil
+}
@@ -0,0 +1,39 @@
+package services
+
+import "wallet-manager/models"
+
+// balanceEffect is what the transactions add to the balance of their cryptocurrency.
+// A sell takes its amount out and its proceeds off the fiat balance, which makes
+// the fiat balance the net amount invested in the holding. Planned and skipped
+// transactions add nothing. The database derives the stored balances the same
+// way, see derive_balances.
+func balanceEffect(cryptoId uint32, transactions ...models.CryptoTransaction) models.Cryptocurrency {
+	effect := models.Cryptocurrency{ID: cryptoId}
+	for _, transaction := range transactions {
+		if transaction.Status != "" && transaction.Status != models.TransactionStatusConfirmed {
+			continue
+		}
+		if transaction.Type == models.TransactionTypeSell {
+			transaction = reversedTransaction(transaction)
+		}
+		effect.Balance = effect.Balance.Add(transaction.CryptocurrencyAmount)
+		effect.CostInFiat = effect.CostInFiat.Add(transaction.FiatAmount)
+	}
+	return effect
+}
+
+func reversed(effect models.Cryptocurrency) models.Cryptocurrency {
+	effect.Balance = effect.Balance.Neg()
+	effect.CostInFiat = effect.CostInFiat.Neg()
+	return effect
+}
+
+func isNoop(effect models.Cryptocurrency) bool {
+	return effect.Balance.IsZero() && effect.CostInFiat.IsZero()
+}
+
+func reversedTransaction(transaction models.CryptoTransaction) models.CryptoTransaction {
+	transaction.CryptocurrencyAmount = transaction.CryptocurrencyAmount.Neg()
+	transaction.FiatAmount = transaction.FiatAmount.Neg()
+	return transaction
+}
@@ -1,52 +1,254 @@
 package services
 
 import (
-	"fmt"
+	"context"
+	"wallet-manager/chain"
+	"wallet-manager/events"
 	"wallet-manager/models"
 	"wallet-manager/repositories"
 )
 
 type CryptoTransactionService interface {
-	Create(crypto *models.CryptoTransaction) error
-	GetAll(cryptoId uint32) ([]models.CryptoTransaction, error)
-	GetByID(id uint32) (*models.CryptoTransaction, error)
-	Update(crypto *models.CryptoTransaction) error
-	Delete(id uint32) error
+	Create(ctx context.Context, crypto *models.CryptoTransaction) error
+	GetAll(ctx context.Context, cryptoId uint32) ([]models.CryptoTransaction, error)
+	GetByID(ctx context.Context, cryptoId uint32, id uint32) (*models.CryptoTransaction, error)
+	Update(ctx context.Context, crypto *models.CryptoTransaction) error
+	Delete(ctx context.Context, cryptoId uint32, id uint32, version uint32) error
+	Restore(ctx context.Context, cryptoId uint32, id uint32) (*models.CryptoTransaction, error)
+	Confirm(ctx context.Context, cryptoId uint32, id uint32) (*models.CryptoTransaction, error)
+	Skip(ctx context.Context, cryptoId uint32, id uint32) (*models.CryptoTransaction, error)
 }
 
 type cryptoTransactionService struct {
 	repo          repositories.CryptoTransactionRepository
 	cryptoService CryptocurrencyService
+	assets        AssetService
+	transactor    repositories.Transactor
+	audit         AuditService
+	publisher     events.Publisher
 }
 
-func NewCryptoTransactionService(repo repositories.CryptoTransactionRepository, cryptoService CryptocurrencyService) CryptoTransactionService {
-	return &cryptoTransactionService{repo: repo, cryptoService: cryptoService}
+func NewCryptoTransactionService(repo repositories.CryptoTransactionRepository, cryptoService CryptocurrencyService, assets AssetService, transactor repositories.Transactor, audit AuditService, publisher events.Publisher) CryptoTransactionService {
+	return &cryptoTransactionService{repo: repo, cryptoService: cryptoService, assets: assets, transactor: transactor, audit: audit, publisher: publisher}
 }
 
-func (s *cryptoTransactionService) Create(crypto *models.CryptoTransaction) error {
-	err := s.repo.Create(crypto)
-	if err == nil {
-		cryptocurrency := models.Cryptocurrency{ID: crypto.CryptocurrencyId, Balance: crypto.CryptocurrencyAmount, CostInFiat: crypto.FiatAmount}
-		err = s.cryptoService.UpdateBalance(&cryptocurrency)
-		if err != nil {
-			fmt.Println("Erro ao atualizar balance")
+func (s *cryptoTransactionService) Create(ctx context.Context, crypto *models.CryptoTransaction) error {
+	setDefaults(crypto)
+	if err := s.validateAddress(ctx, crypto); err != nil {
+		return err
+	}
+	return s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.repo.Create(ctx, crypto); err != nil {
+			return err
+		}
+		if err := s.audit.Record(ctx, models.AuditActionCreate, models.AuditEntityCryptoTransaction, crypto.ID, nil, crypto); err != nil {
+			return err
 		}
+		if err := s.publisher.Publish(ctx, events.TransactionCreated{CryptoTransaction: *crypto}); err != nil {
+			return err
+		}
+
+		effect := balanceEffect(crypto.CryptocurrencyId, *crypto)
+		if isNoop(effect) {
+			return nil
+		}
+		return s.cryptoService.RecordBalance(ctx, &effect)
+	})
+}
+
+func (s *cryptoTransactionService) GetAll(ctx context.Context, cryptoId uint32) ([]models.CryptoTransaction, error) {
+	return s.repo.GetAll(ctx, cryptoId)
+}
+
+// GetByID only returns the transaction when it belongs to the given cryptocurrency.
+func (s *cryptoTransactionService) GetByID(ctx context.Context, cryptoId uint32, id uint32) (*models.CryptoTransaction, error) {
+	transaction, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if transaction == nil || transaction.CryptocurrencyId != cryptoId {
+		return nil, nil
 	}
-	return err
+	return transaction, nil
 }
 
-func (s *cryptoTransactionService) GetAll(cryptoId uint32) ([]models.CryptoTransaction, error) {
-	return s.repo.GetAll(cryptoId)
+func (s *cryptoTransactionService) Update(ctx context.Context, crypto *models.CryptoTransaction) error {
+	setDefaults(crypto)
+	if err := s.validateAddress(ctx, crypto); err != nil {
+		return err
+	}
+	return s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		existing, err := s.GetByID(ctx, crypto.CryptocurrencyId, crypto.ID)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			return ErrTransactionNotFound
+		}
+		if existing.Version != crypto.Version {
+			return repositories.ErrVersionMismatch
+		}
+		if existing.Type == models.TransactionTypeAdjustment || existing.Type == models.TransactionTypeOpeningBalance {
+			return ErrTransactionReadOnly
+		}
+		crypto.Status, crypto.PlanID, crypto.ExternalID = existing.Status, existing.PlanID, existing.ExternalID
+
+		if err := s.repo.Update(ctx, crypto); err != nil {
+			return err
+		}
+
+		updated, err := s.repo.GetByID(ctx, crypto.ID)
+		if err != nil {
+			return err
+		}
+		if err := s.audit.Record(ctx, models.AuditActionUpdate, models.AuditEntityCryptoTransaction, crypto.ID, existing, updated); err != nil {
+			return err
+		}
+		if err := s.publisher.Publish(ctx, events.TransactionUpdated{CryptoTransaction: *updated}); err != nil {
+			return err
+		}
+
+		// keep the holding balance in line with the edited amounts
+		delta := balanceEffect(crypto.CryptocurrencyId, *updated, reversedTransaction(*existing))
+		if isNoop(delta) {
+			return nil
+		}
+		return s.cryptoService.RecordBalance(ctx, &delta)
+	})
 }
 
-func (s *cryptoTransactionService) GetByID(id uint32) (*models.CryptoTransaction, error) {
-	return s.repo.GetByID(id)
+func (s *cryptoTransactionService) Delete(ctx context.Context, cryptoId uint32, id uint32, version uint32) error {
+	return s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		existing, err := s.GetByID(ctx, cryptoId, id)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			return ErrTransactionNotFound
+		}
+
+		if err := s.repo.Delete(ctx, id, version); err != nil {
+			return err
+		}
+		if err := s.audit.Record(ctx, models.AuditActionDelete, models.AuditEntityCryptoTransaction, id, existing, nil); err != nil {
+			return err
+		}
+		if err := s.publisher.Publish(ctx, events.TransactionDeleted{CryptoTransaction: *existing}); err != nil {
+			return err
+		}
+
+		effect := reversed(balanceEffect(cryptoId, *existing))
+		if isNoop(effect) {
+			return nil
+		}
+		return s.cryptoService.RecordBalance(ctx, &effect)
+	})
 }
 
-func (s *cryptoTransactionService) Update(crypto *models.CryptoTransaction) error {
-	return s.repo.Update(crypto)
+// Restore brings back a trashed transaction and applies it to the balance again.
+// Its cryptocurrency has to be restored first when it is in the trash too.
+func (s *cryptoTransactionService) Restore(ctx context.Context, cryptoId uint32, id uint32) (*models.CryptoTransaction, error) {
+	var restored *models.CryptoTransaction
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		trashed, err := s.repo.GetDeletedByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if trashed == nil || trashed.CryptocurrencyId != cryptoId {
+			return ErrTransactionNotFound
+		}
+
+		if err := s.repo.Restore(ctx, id); err != nil {
+			return err
+		}
+
+		if effect := balanceEffect(cryptoId, *trashed); !isNoop(effect) {
+			if err := s.cryptoService.RecordBalance(ctx, &effect); err != nil {
+				return err
+			}
+		}
+
+		restored, err = s.repo.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := s.audit.Record(ctx, models.AuditActionRestore, models.AuditEntityCryptoTransaction, id, trashed, restored); err != nil {
+			return err
+		}
+		return s.publisher.Publish(ctx, events.TransactionRestored{CryptoTransaction: *restored})
+	})
+	return restored, err
+}
+
+// Confirm counts a planned transaction towards the balance.
+func (s *cryptoTransactionService) Confirm(ctx context.Context, cryptoId uint32, id uint32) (*models.CryptoTransaction, error) {
+	return s.settle(ctx, cryptoId, id, models.TransactionStatusConfirmed)
+}
+
+// Skip keeps a planned transaction out of the balance for good.
+func (s *cryptoTransactionService) Skip(ctx context.Context, cryptoId uint32, id uint32) (*models.CryptoTransaction, error) {
+	return s.settle(ctx, cryptoId, id, models.TransactionStatusSkipped)
 }
 
-func (s *cryptoTransactionService) Delete(id uint32) error {
-	return s.repo.Delete(id)
+func (s *cryptoTransactionService) settle(ctx context.Context, cryptoId uint32, id uint32, status string) (*models.CryptoTransaction, error) {
+	var settled *models.CryptoTransaction
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		existing, err := s.GetByID(ctx, cryptoId, id)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			return ErrTransactionNotFound
+		}
+		if existing.Status != models.TransactionStatusPlanned {
+			return ErrTransactionNotPlanned
+		}
+
+		if err := s.repo.SetStatus(ctx, id, status); err != nil {
+			return err
+		}
+		settled, err = s.repo.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := s.audit.Record(ctx, models.AuditActionUpdate, models.AuditEntityCryptoTransaction, id, existing, settled); err != nil {
+			return err
+		}
+		if err := s.publisher.Publish(ctx, events.TransactionUpdated{CryptoTransaction: *settled}); err != nil {
+			return err
+		}
+
+		effect := balanceEffect(cryptoId, *settled)
+		if isNoop(effect) {
+			return nil
+		}
+		return s.cryptoService.RecordBalance(ctx, &effect)
+	})
+	return settled, err
+}
+
+// validateAddress checks the address against the chain of the asset of the
+// holding, if there is one.
+func (s *cryptoTransactionService) validateAddress(ctx context.Context, transaction *models.CryptoTransaction) error {
+	if transaction.Address == nil {
+		return nil
+	}
+	crypto, err := s.cryptoService.GetByID(ctx, transaction.CryptocurrencyId)
+	if err != nil || crypto == nil {
+		return err
+	}
+	asset, err := s.assets.GetByID(ctx, crypto.AssetID)
+	if err != nil || asset == nil {
+		return err
+	}
+	return chain.ValidateAddress(asset.Chain, *transaction.Address)
+}
+
+func setDefaults(transaction *models.CryptoTransaction) {
+	if transaction.Type == "" {
+		transaction.Type = models.TransactionTypeBuy
+	}
+	if transaction.Status == "" {
+		transaction.Status = models.TransactionStatusConfirmed
+	}
 }
@@ -1,51 +1,260 @@
 package services
 
 import (
+	"context"
+	"wallet-manager/events"
 	"wallet-manager/models"
 	"wallet-manager/repositories"
 )
 
 type CryptocurrencyService interface {
-	Create(crypto *models.Cryptocurrency) error
-	GetAll() ([]models.Cryptocurrency, error)
-	GetByID(id uint32) (*models.Cryptocurrency, error)
-	Update(crypto *models.Cryptocurrency) error
-	UpdateBalance(crypto *models.Cryptocurrency) error
-	Delete(id uint32) error
+	Create(ctx context.Context, crypto *models.Cryptocurrency) error
+	GetAll(ctx context.Context) ([]models.Cryptocurrency, error)
+	GetByID(ctx context.Context, id uint32) (*models.Cryptocurrency, error)
+	Update(ctx context.Context, crypto *models.Cryptocurrency) error
+	RecordBalance(ctx context.Context, effect *models.Cryptocurrency) error
+	Delete(ctx context.Context, id uint32, version uint32) error
+	Restore(ctx context.Context, id uint32) (*models.Cryptocurrency, error)
 }
 
 type cryptocurrencyService struct {
-	repo repositories.CryptocurrencyRepository
+	repo            repositories.CryptocurrencyRepository
+	transactionRepo repositories.CryptoTransactionRepository
+	assets          AssetService
+	transactor      repositories.Transactor
+	audit           AuditService
+	publisher       events.Publisher
 }
 
-func NewCryptocurrencyService(repo repositories.CryptocurrencyRepository) CryptocurrencyService {
-	return &cryptocurrencyService{repo: repo}
+func NewCryptocurrencyService(repo repositories.CryptocurrencyRepository, transactionRepo repositories.CryptoTransactionRepository, assets AssetService, transactor repositories.Transactor, audit AuditService, publisher events.Publisher) CryptocurrencyService {
+	return &cryptocurrencyService{repo: repo, transactionRepo: transactionRepo, assets: assets, transactor: transactor, audit: audit, publisher: publisher}
 }
 
-func (s *cryptocurrencyService) Create(crypto *models.Cryptocurrency) error {
-	return s.repo.Create(crypto)
+// Create records the balances the holding is given as its opening balance.
+func (s *cryptocurrencyService) Create(ctx context.Context, crypto *models.Cryptocurrency) error {
+	if err := s.resolveAsset(ctx, crypto); err != nil {
+		return err
+	}
+
+	return s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := s.repo.Create(ctx, crypto); err != nil {
+			return err
+		}
+		if err := s.audit.Record(ctx, models.AuditActionCreate, models.AuditEntityCryptocurrency, crypto.ID, nil, crypto); err != nil {
+			return err
+		}
+		if err := s.publisher.Publish(ctx, events.HoldingCreated{Cryptocurrency: *crypto}); err != nil {
+			return err
+		}
+
+		// the only transaction of a new holding is its opening balance, if it was given one
+		opening, err := s.transactionRepo.GetAll(ctx, crypto.ID)
+		if err != nil {
+			return err
+		}
+		for i := range opening {
+			if err := s.audit.Record(ctx, models.AuditActionCreate, models.AuditEntityCryptoTransaction, opening[i].ID, nil, opening[i]); err != nil {
+				return err
+			}
+			if err := s.publisher.Publish(ctx, events.TransactionCreated{CryptoTransaction: opening[i]}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
-func (s *cryptocurrencyService) GetAll() ([]models.Cryptocurrency, error) {
-	cryptos, err := s.repo.GetAll()
+func (s *cryptocurrencyService) GetAll(ctx context.Context) ([]models.Cryptocurrency, error) {
+	cryptos, err := s.repo.GetAll(ctx)
 	if err != nil {
 		return nil, err
 	}
 	return cryptos, nil
 }
 
-func (s *cryptocurrencyService) GetByID(id uint32) (*models.Cryptocurrency, error) {
-	return s.repo.GetByID(id)
+func (s *cryptocurrencyService) GetByID(ctx context.Context, id uint32) (*models.Cryptocurrency, error) {
+	return s.repo.GetByID(ctx, id)
 }
 
-func (s *cryptocurrencyService) Update(crypto *models.Cryptocurrency) error {
-	return s.repo.Update(crypto)
+// Update ignores the balances of crypto, which only transactions change.
+func (s *cryptocurrencyService) Update(ctx context.Context, crypto *models.Cryptocurrency) error {
+	if err := s.resolveAsset(ctx, crypto); err != nil {
+		return err
+	}
+
+	return s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		existing, err := s.repo.GetByID(ctx, crypto.ID)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			return ErrCryptocurrencyNotFound
+		}
+		if existing.Version != crypto.Version {
+			return repositories.ErrVersionMismatch
+		}
+
+		if err := s.repo.Update(ctx, crypto); err != nil {
+			return err
+		}
+
+		updated, err := s.repo.GetByID(ctx, crypto.ID)
+		if err != nil {
+			return err
+		}
+		if err := s.audit.Record(ctx, models.AuditActionUpdate, models.AuditEntityCryptocurrency, crypto.ID, existing, updated); err != nil {
+			return err
+		}
+		return s.publisher.Publish(ctx, events.HoldingUpdated{Cryptocurrency: *updated})
+	})
 }
 
-func (s *cryptocurrencyService) UpdateBalance(crypto *models.Cryptocurrency) error {
-	return s.repo.UpdateBalance(crypto)
+// RecordBalance audits and announces the change the transactions just written
+// made to the balances of the holding, the Balance and CostInFiat of effect;
+// the database derives the balances from them. It fails with
+// ErrInsufficientBalance when that left the balance below zero.
+func (s *cryptocurrencyService) RecordBalance(ctx context.Context, effect *models.Cryptocurrency) error {
+	return s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		after, err := s.repo.GetByID(ctx, effect.ID)
+		if err != nil {
+			return err
+		}
+		if after == nil {
+			return ErrCryptocurrencyNotFound
+		}
+		if after.Balance.IsNegative() {
+			return ErrInsufficientBalance
+		}
+
+		before := *after
+		before.Balance = after.Balance.Sub(effect.Balance)
+		before.CostInFiat = after.CostInFiat.Sub(effect.CostInFiat)
+		if err := s.audit.Record(ctx, models.AuditActionUpdateBalance, models.AuditEntityCryptocurrency, effect.ID, &before, after); err != nil {
+			return err
+		}
+		return s.publisher.Publish(ctx, balanceChanged(&before, after))
+	})
+}
+
+// Delete moves the cryptocurrency and its transactions to the trash, taking the
+// transactions out of its balance so Restore can apply them again.
+func (s *cryptocurrencyService) Delete(ctx context.Context, id uint32, version uint32) error {
+	return s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		existing, err := s.repo.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			return ErrCryptocurrencyNotFound
+		}
+		if existing.Version != version {
+			return repositories.ErrVersionMismatch
+		}
+
+		trashed, err := s.transactionRepo.DeleteByCryptocurrency(ctx, id)
+		if err != nil {
+			return err
+		}
+		for i := range trashed {
+			if err := s.audit.Record(ctx, models.AuditActionDelete, models.AuditEntityCryptoTransaction, trashed[i].ID, trashed[i], nil); err != nil {
+				return err
+			}
+			if err := s.publisher.Publish(ctx, events.TransactionDeleted{CryptoTransaction: trashed[i]}); err != nil {
+				return err
+			}
+		}
+
+		if effect := reversed(balanceEffect(id, trashed...)); !isNoop(effect) {
+			if err := s.RecordBalance(ctx, &effect); err != nil {
+				return err
+			}
+		}
+
+		// deriving the balances of the trashed transactions may have bumped the version
+		current, err := s.repo.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if current == nil {
+			return ErrCryptocurrencyNotFound
+		}
+		if err := s.repo.Delete(ctx, id, current.Version); err != nil {
+			return err
+		}
+		if err := s.audit.Record(ctx, models.AuditActionDelete, models.AuditEntityCryptocurrency, id, existing, nil); err != nil {
+			return err
+		}
+		return s.publisher.Publish(ctx, events.HoldingDeleted{Cryptocurrency: *existing})
+	})
 }
 
-func (s *cryptocurrencyService) Delete(id uint32) error {
-	return s.repo.Delete(id)
+// Restore brings the cryptocurrency back together with the transactions trashed along with it.
+func (s *cryptocurrencyService) Restore(ctx context.Context, id uint32) (*models.Cryptocurrency, error) {
+	var restored *models.Cryptocurrency
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		trashed, err := s.repo.GetDeletedByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if trashed == nil {
+			return ErrCryptocurrencyNotFound
+		}
+
+		if err := s.repo.Restore(ctx, id); err != nil {
+			return err
+		}
+		transactions, err := s.transactionRepo.RestoreByCryptocurrency(ctx, id, *trashed.DeletedAt)
+		if err != nil {
+			return err
+		}
+
+		if effect := balanceEffect(id, transactions...); !isNoop(effect) {
+			if err := s.RecordBalance(ctx, &effect); err != nil {
+				return err
+			}
+		}
+
+		for i := range transactions {
+			if err := s.audit.Record(ctx, models.AuditActionRestore, models.AuditEntityCryptoTransaction, transactions[i].ID, nil, transactions[i]); err != nil {
+				return err
+			}
+			if err := s.publisher.Publish(ctx, events.TransactionRestored{CryptoTransaction: transactions[i]}); err != nil {
+				return err
+			}
+		}
+
+		restored, err = s.repo.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := s.audit.Record(ctx, models.AuditActionRestore, models.AuditEntityCryptocurrency, id, trashed, restored); err != nil {
+			return err
+		}
+		return s.publisher.Publish(ctx, events.HoldingRestored{Cryptocurrency: *restored})
+	})
+	return restored, err
+}
+
+func balanceChanged(before *models.Cryptocurrency, after *models.Cryptocurrency) events.HoldingBalanceChanged {
+	return events.HoldingBalanceChanged{
+		CryptocurrencyId:    after.ID,
+		Balance:             after.Balance,
+		FiatBalance:         after.CostInFiat,
+		PreviousBalance:     before.Balance,
+		PreviousFiatBalance: before.CostInFiat,
+	}
+}
+
+// resolveAsset replaces the asset reference of crypto, which may be a symbol or
+// a display name, with the canonical asset id.
+func (s *cryptocurrencyService) resolveAsset(ctx context.Context, crypto *models.Cryptocurrency) error {
+	asset, err := s.assets.Resolve(ctx, crypto.AssetID)
+	if err != nil {
+		return err
+	}
+	crypto.AssetID = asset.ID
+	if crypto.Name == "" {
+		crypto.Name = asset.Name
+	}
+	return nil
 }
@@ -0,0 +1,325 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"wallet-manager/models"
+	"wallet-manager/repositories"
+	"wallet-manager/utils"
+
+	"github.com/robfig/cron/v3"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	defaultDCAExecutions = 5
+	// runs caught up with per plan and tick; a start date far in the past is
+	// caught up with over the following ticks
+	maxCatchUpRuns = 31
+	// amounts are stored as NUMERIC(30, 18)
+	amountPlaces   = 18
+	schedulerActor = "scheduler"
+)
+
+type DCAService interface {
+	Create(ctx context.Context, plan *models.DCAPlan) error
+	GetAll(ctx context.Context) ([]models.DCAPlan, error)
+	GetByID(ctx context.Context, id uint32) (*models.DCAPlan, error)
+	Update(ctx context.Context, plan *models.DCAPlan) error
+	Delete(ctx context.Context, id uint32) error
+	Executions(ctx context.Context, id uint32, limit int) ([]models.DCAExecution, error)
+	SkipNext(ctx context.Context, id uint32) (*models.DCAPlan, error)
+	Confirm(ctx context.Context, id uint32, transactionId uint32) (*models.CryptoTransaction, error)
+	Skip(ctx context.Context, id uint32, transactionId uint32) (*models.CryptoTransaction, error)
+	Run(ctx context.Context) error
+}
+
+type dcaService struct {
+	repo               repositories.DCAPlanRepository
+	cryptoRepo         repositories.CryptocurrencyRepository
+	transactionRepo    repositories.CryptoTransactionRepository
+	priceRepo          repositories.CryptoPriceRepository
+	transactionService CryptoTransactionService
+	transactor         repositories.Transactor
+	now                func() time.Time
+}
+
+func NewDCAService(repo repositories.DCAPlanRepository, cryptoRepo repositories.CryptocurrencyRepository, transactionRepo repositories.CryptoTransactionRepository, priceRepo repositories.CryptoPriceRepository, transactionService CryptoTransactionService, transactor repositories.Transactor) DCAService {
+	return &dcaService{
+		repo:               repo,
+		cryptoRepo:         cryptoRepo,
+		transactionRepo:    transactionRepo,
+		priceRepo:          priceRepo,
+		transactionService: transactionService,
+		transactor:         transactor,
+		now:                func() time.Time { return time.Now().UTC() },
+	}
+}
+
+// Create schedules the first run at or after the start date, which may be in
+// the past: the scheduler then catches up at the prices of back then.
+func (s *dcaService) Create(ctx context.Context, plan *models.DCAPlan) error {
+	schedule, err := s.validate(ctx, plan)
+	if err != nil {
+		return err
+	}
+	plan.NextRun = nextRun(schedule, plan, plan.StartDate.Add(-time.Second))
+	return s.repo.Create(ctx, plan)
+}
+
+func (s *dcaService) GetAll(ctx context.Context) ([]models.DCAPlan, error) {
+	return s.repo.GetAll(ctx)
+}
+
+func (s *dcaService) GetByID(ctx context.Context, id uint32) (*models.DCAPlan, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+// Update reschedules the plan from now on; runs missed before the update are
+// not caught up.
+func (s *dcaService) Update(ctx context.Context, plan *models.DCAPlan) error {
+	schedule, err := s.validate(ctx, plan)
+	if err != nil {
+		return err
+	}
+	plan.NextRun = nextRun(schedule, plan, maxTime(plan.StartDate.Add(-time.Second), s.now()))
+	err = s.repo.Update(ctx, plan)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return ErrDCAPlanNotFound
+	}
+	return err
+}
+
+// Delete stops the plan. Its transactions stay, planned ones included.
+func (s *dcaService) Delete(ctx context.Context, id uint32) error {
+	err := s.repo.Delete(ctx, id)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return ErrDCAPlanNotFound
+	}
+	return err
+}
+
+// Executions lists the runs awaiting confirmation followed by the next limit
+// scheduled ones.
+func (s *dcaService) Executions(ctx context.Context, id uint32, limit int) ([]models.DCAExecution, error) {
+	if limit <= 0 {
+		limit = defaultDCAExecutions
+	}
+
+	plan, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if plan == nil {
+		return nil, ErrDCAPlanNotFound
+	}
+
+	planned, err := s.transactionRepo.GetPlanned(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	executions := []models.DCAExecution{}
+	for i := range planned {
+		scheduledAt, err := time.Parse(utils.TimeFormat, planned[i].PurchaseDate)
+		if err != nil {
+			return nil, err
+		}
+		executions = append(executions, models.DCAExecution{ScheduledAt: scheduledAt, Status: models.DCAExecutionPlanned, Transaction: &planned[i]})
+	}
+
+	schedule, err := cron.ParseStandard(plan.Schedule)
+	if err != nil {
+		return nil, err
+	}
+	for next := plan.NextRun; next != nil && limit > 0; limit-- {
+		executions = append(executions, models.DCAExecution{ScheduledAt: *next, Status: models.DCAExecutionScheduled})
+		next = nextRun(schedule, plan, *next)
+	}
+	return executions, nil
+}
+
+// SkipNext moves the plan past its next scheduled run.
+func (s *dcaService) SkipNext(ctx context.Context, id uint32) (*models.DCAPlan, error) {
+	var plan *models.DCAPlan
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		plan, err = s.repo.GetByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		if plan == nil {
+			return ErrDCAPlanNotFound
+		}
+		if plan.NextRun == nil {
+			return fmt.Errorf("%w: the plan has no runs left", ErrInvalidDCAPlan)
+		}
+
+		schedule, err := cron.ParseStandard(plan.Schedule)
+		if err != nil {
+			return err
+		}
+		plan.NextRun = nextRun(schedule, plan, *plan.NextRun)
+		return s.repo.SetNextRun(ctx, id, plan.NextRun)
+	})
+	return plan, err
+}
+
+func (s *dcaService) Confirm(ctx context.Context, id uint32, transactionId uint32) (*models.CryptoTransaction, error) {
+	cryptoId, err := s.plannedBy(ctx, id, transactionId)
+	if err != nil {
+		return nil, err
+	}
+	return s.transactionService.Confirm(ctx, cryptoId, transactionId)
+}
+
+func (s *dcaService) Skip(ctx context.Context, id uint32, transactionId uint32) (*models.CryptoTransaction, error) {
+	cryptoId, err := s.plannedBy(ctx, id, transactionId)
+	if err != nil {
+		return nil, err
+	}
+	return s.transactionService.Skip(ctx, cryptoId, transactionId)
+}
+
+// plannedBy returns the holding of a transaction when the plan created it.
+func (s *dcaService) plannedBy(ctx context.Context, id uint32, transactionId uint32) (uint32, error) {
+	transaction, err := s.transactionRepo.GetByID(ctx, transactionId)
+	if err != nil {
+		return 0, err
+	}
+	if transaction == nil || transaction.PlanID == nil || *transaction.PlanID != id {
+		return 0, ErrTransactionNotFound
+	}
+	return transaction.CryptocurrencyId, nil
+}
+
+// Run adds a planned transaction for every run due, each plan in its own
+// database transaction so a plan without a price does not hold back the others.
+func (s *dcaService) Run(ctx context.Context) error {
+	ctx = WithActor(ctx, schedulerActor)
+	now := s.now()
+
+	plans, err := s.repo.GetDue(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, plan := range plans {
+		if err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+			return s.execute(ctx, plan.ID, now)
+		}); err != nil {
+			errs = append(errs, fmt.Errorf("dca plan %d: %w", plan.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// execute catches up with the runs of the plan up to now, at most
+// maxCatchUpRuns of them. The plan is read again under lock, so a run already
+// done by another scheduler is not repeated.
+func (s *dcaService) execute(ctx context.Context, id uint32, now time.Time) error {
+	plan, err := s.repo.LockDue(ctx, id, now)
+	if err != nil || plan == nil {
+		return err
+	}
+
+	holding, err := s.cryptoRepo.GetByID(ctx, plan.CryptocurrencyId)
+	if err != nil {
+		return err
+	}
+	if holding == nil {
+		return ErrCryptocurrencyNotFound
+	}
+	schedule, err := cron.ParseStandard(plan.Schedule)
+	if err != nil {
+		return err
+	}
+
+	next := plan.NextRun
+	for runs := 0; next != nil && !next.After(now) && runs < maxCatchUpRuns; runs++ {
+		price, err := s.priceAt(ctx, holding.AssetID, *next)
+		if err != nil {
+			return err
+		}
+
+		transaction := models.CryptoTransaction{
+			CryptocurrencyId:     plan.CryptocurrencyId,
+			Type:                 models.TransactionTypeBuy,
+			Status:               models.TransactionStatusPlanned,
+			PlanID:               &plan.ID,
+			CryptocurrencyAmount: plan.FiatAmount.DivRound(price, amountPlaces),
+			FiatAmount:           plan.FiatAmount,
+			PurchaseDate:         next.Format(utils.TimeFormat),
+			CreatedDate:          utils.NowFormatted(),
+		}
+		if err := s.transactionService.Create(ctx, &transaction); err != nil {
+			return err
+		}
+		next = nextRun(schedule, plan, *next)
+	}
+	return s.repo.SetNextRun(ctx, plan.ID, next)
+}
+
+// priceAt is the last price recorded at or before the run, or the current one
+// when none was recorded back then.
+func (s *dcaService) priceAt(ctx context.Context, asset string, at time.Time) (decimal.Decimal, error) {
+	price, err := s.priceRepo.GetAt(ctx, asset, at)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	if price == nil {
+		if price, err = s.priceRepo.GetByName(ctx, asset); err != nil {
+			return decimal.Zero, err
+		}
+	}
+	if price == nil || !price.PriceUSD.IsPositive() {
+		return decimal.Zero, fmt.Errorf("no price for %s", asset)
+	}
+	return price.PriceUSD, nil
+}
+
+// validate checks what binding tags cannot: the cron expression, the holding
+// and the dates.
+func (s *dcaService) validate(ctx context.Context, plan *models.DCAPlan) (cron.Schedule, error) {
+	schedule, err := cron.ParseStandard(plan.Schedule)
+	if err != nil {
+		return nil, fmt.Errorf("%w: schedule: %v", ErrInvalidDCAPlan, err)
+	}
+	if plan.EndDate != nil && !plan.EndDate.After(plan.StartDate) {
+		return nil, fmt.Errorf("%w: endDate must be after startDate", ErrInvalidDCAPlan)
+	}
+
+	crypto, err := s.cryptoRepo.GetByID(ctx, plan.CryptocurrencyId)
+	if err != nil {
+		return nil, err
+	}
+	if crypto == nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDCAPlan, ErrCryptocurrencyNotFound)
+	}
+
+	plan.StartDate = plan.StartDate.UTC()
+	if plan.EndDate != nil {
+		end := plan.EndDate.UTC()
+		plan.EndDate = &end
+	}
+	return schedule, nil
+}
+
+// nextRun is the first run of the plan after t, or nil past its end date.
+func nextRun(schedule cron.Schedule, plan *models.DCAPlan, t time.Time) *time.Time {
+	next := schedule.Next(t.UTC())
+	if next.IsZero() || (plan.EndDate != nil && next.After(*plan.EndDate)) {
+		return nil
+	}
+	return &next
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,25 @@
+package services
+
+import "errors"
+
+var (
+	ErrCryptocurrencyNotFound  = errors.New("cryptocurrency not found")
+	ErrTransactionNotFound     = errors.New("cryptoTransaction not found")
+	ErrAlertRuleNotFound       = errors.New("alert rule not found")
+	ErrInvalidAlertRule        = errors.New("invalid alert rule")
+	ErrWebhookNotFound         = errors.New("webhook subscription not found")
+	ErrUnknownAsset            = errors.New("unknown asset")
+	ErrInsufficientBalance     = errors.New("insufficient balance")
+	ErrTransactionNotPlanned   = errors.New("cryptoTransaction is not planned")
+	ErrDCAPlanNotFound         = errors.New("dca plan not found")
+	ErrInvalidDCAPlan          = errors.New("invalid dca plan")
+	ErrInvalidPortfolioTargets = errors.New("invalid portfolio targets")
+	ErrNoPortfolioTargets      = errors.New("no portfolio targets set")
+	ErrAddressNotFound         = errors.New("watched address not found")
+	ErrAddressDerived          = errors.New("the address is derived from an extended key, delete the key instead")
+	ErrExtendedKeyNotFound     = errors.New("extended key not found")
+	ErrUnsupportedChain        = errors.New("no indexer for the chain")
+	ErrTransactionReadOnly     = errors.New("adjustments and opening balances cannot be edited, delete them instead")
+	ErrAlreadyReconciled       = errors.New("the holding is already reconciled")
+	ErrNoOnChainBalance        = errors.New("no on-chain balance, watch an address of the holding first")
+)
@@ -0,0 +1,394 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"wallet-manager/models"
+	"wallet-manager/portfolio"
+	"wallet-manager/prices"
+	"wallet-manager/repositories"
+	"wallet-manager/tax"
+	"wallet-manager/utils"
+
+	"github.com/shopspring/decimal"
+)
+
+var hundredPercent = decimal.NewFromInt(100)
+
+type PortfolioService interface {
+	GetTargets(ctx context.Context) (*models.PortfolioTargets, error)
+	SetTargets(ctx context.Context, targets *models.PortfolioTargets) error
+	Rebalance(ctx context.Context, tolerance decimal.Decimal, minimizeTaxes bool) (*portfolio.Rebalance, error)
+	Benchmark(ctx context.Context, against []portfolio.Target, interval string) (*portfolio.Benchmark, error)
+	Risk(ctx context.Context, window portfolio.Window) (*portfolio.Risk, error)
+	Snapshot(ctx context.Context) error
+}
+
+type portfolioService struct {
+	targetRepo      repositories.PortfolioTargetRepository
+	snapshotRepo    repositories.PortfolioSnapshotRepository
+	cryptoRepo      repositories.CryptocurrencyRepository
+	transactionRepo repositories.CryptoTransactionRepository
+	priceRepo       repositories.CryptoPriceRepository
+	assets          AssetService
+	tax             TaxService
+	history         prices.HistoryProvider
+	transactor      repositories.Transactor
+	riskFreeRate    float64
+	now             func() time.Time
+}
+
+func NewPortfolioService(targetRepo repositories.PortfolioTargetRepository, snapshotRepo repositories.PortfolioSnapshotRepository, cryptoRepo repositories.CryptocurrencyRepository, transactionRepo repositories.CryptoTransactionRepository, priceRepo repositories.CryptoPriceRepository, assets AssetService, tax TaxService, history prices.HistoryProvider, transactor repositories.Transactor, riskFreeRate float64) PortfolioService {
+	return &portfolioService{
+		targetRepo:      targetRepo,
+		snapshotRepo:    snapshotRepo,
+		cryptoRepo:      cryptoRepo,
+		transactionRepo: transactionRepo,
+		priceRepo:       priceRepo,
+		assets:          assets,
+		tax:             tax,
+		history:         history,
+		transactor:      transactor,
+		riskFreeRate:    riskFreeRate,
+		now:             func() time.Time { return time.Now().UTC() },
+	}
+}
+
+func (s *portfolioService) GetTargets(ctx context.Context) (*models.PortfolioTargets, error) {
+	targets, err := s.targetRepo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &models.PortfolioTargets{Targets: targets}, nil
+}
+
+// SetTargets replaces the target allocation. Assets may be given by id, symbol
+// or name; the percentages must add up to 100.
+func (s *portfolioService) SetTargets(ctx context.Context, targets *models.PortfolioTargets) error {
+	seen := map[string]bool{}
+	total := decimal.Zero
+	for i := range targets.Targets {
+		target := &targets.Targets[i]
+		if strings.EqualFold(target.AssetID, portfolio.Others) {
+			target.AssetID = portfolio.Others
+		} else {
+			asset, err := s.assets.Resolve(ctx, target.AssetID)
+			if err != nil {
+				return fmt.Errorf("%w: %s: %v", ErrInvalidPortfolioTargets, target.AssetID, err)
+			}
+			target.AssetID = asset.ID
+		}
+
+		if seen[target.AssetID] {
+			return fmt.Errorf("%w: %s is listed twice", ErrInvalidPortfolioTargets, target.AssetID)
+		}
+		if target.Percentage.Exponent() < -2 {
+			return fmt.Errorf("%w: percentages take up to 2 decimal places", ErrInvalidPortfolioTargets)
+		}
+		seen[target.AssetID] = true
+		total = total.Add(target.Percentage)
+	}
+	if !total.Equal(hundredPercent) {
+		return fmt.Errorf("%w: percentages add up to %s, expected 100", ErrInvalidPortfolioTargets, total)
+	}
+
+	return s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		return s.targetRepo.Replace(ctx, targets.Targets)
+	})
+}
+
+// Rebalance values the holdings at the current prices and suggests the trades
+// back to the targets once a drift exceeds tolerance percentage points. Sells
+// come with the gain they would realize, matched against the lots by HIFO
+// when minimizing taxes, as it sells the lots that cost the most first, and by
+// FIFO otherwise.
+func (s *portfolioService) Rebalance(ctx context.Context, tolerance decimal.Decimal, minimizeTaxes bool) (*portfolio.Rebalance, error) {
+	targets, err := s.targetRepo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return nil, ErrNoPortfolioTargets
+	}
+
+	positions, err := s.positions(ctx, targets)
+	if err != nil {
+		return nil, err
+	}
+
+	planTargets := make([]portfolio.Target, 0, len(targets))
+	for _, target := range targets {
+		planTargets = append(planTargets, portfolio.Target{AssetID: target.AssetID, Percentage: target.Percentage})
+	}
+	rebalance := portfolio.Plan(positions, planTargets, tolerance)
+
+	method := tax.MethodFIFO
+	if minimizeTaxes {
+		method = tax.MethodHIFO
+	}
+	return rebalance, s.estimateGains(ctx, rebalance, method)
+}
+
+// positions are the holdings with a price and the assets with a target not
+// held yet. A holding without a price cannot be weighed and is left out.
+func (s *portfolioService) positions(ctx context.Context, targets []models.PortfolioTarget) ([]portfolio.Position, error) {
+	holdings, err := s.cryptoRepo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	priceList, err := s.priceRepo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	prices := make(map[string]decimal.Decimal, len(priceList))
+	for _, price := range priceList {
+		prices[strings.ToLower(price.Name)] = price.PriceUSD
+	}
+
+	held := map[string]bool{}
+	var positions []portfolio.Position
+	for _, holding := range holdings {
+		held[holding.AssetID] = true
+		price := prices[holding.AssetID]
+		if !price.IsPositive() {
+			continue
+		}
+		position, err := s.position(ctx, holding.AssetID, price)
+		if err != nil {
+			return nil, err
+		}
+		position.CryptocurrencyID = holding.ID
+		position.Amount = holding.Balance
+		positions = append(positions, position)
+	}
+
+	for _, target := range targets {
+		if target.AssetID == portfolio.Others || held[target.AssetID] {
+			continue
+		}
+		position, err := s.position(ctx, target.AssetID, prices[target.AssetID])
+		if err != nil {
+			return nil, err
+		}
+		positions = append(positions, position)
+	}
+	return positions, nil
+}
+
+func (s *portfolioService) position(ctx context.Context, assetId string, price decimal.Decimal) (portfolio.Position, error) {
+	position := portfolio.Position{AssetID: assetId, Price: price, Decimals: amountPlaces}
+	asset, err := s.assets.GetByID(ctx, assetId)
+	if err != nil {
+		return position, err
+	}
+	if asset != nil {
+		position.Decimals = int32(min(asset.Decimals, amountPlaces))
+	}
+	return position, nil
+}
+
+func (s *portfolioService) estimateGains(ctx context.Context, rebalance *portfolio.Rebalance, method tax.Method) error {
+	var sales []Sale
+	var trades []*portfolio.Trade
+	for i := range rebalance.Trades {
+		trade := &rebalance.Trades[i]
+		if trade.Action != portfolio.ActionSell || trade.Quantity == nil || trade.Quantity.IsZero() {
+			continue
+		}
+		sales = append(sales, Sale{CryptocurrencyID: trade.CryptocurrencyID, Amount: *trade.Quantity, Proceeds: trade.Value})
+		trades = append(trades, trade)
+	}
+	if len(sales) == 0 {
+		return nil
+	}
+
+	estimates, err := s.tax.EstimateSales(ctx, sales, method)
+	if err != nil {
+		return err
+	}
+	for i, disposals := range estimates {
+		if disposals == nil {
+			continue
+		}
+		gain := decimal.Zero
+		for _, disposal := range disposals {
+			gain = gain.Add(disposal.Gain)
+		}
+		trades[i].EstimatedGain = &gain
+	}
+	return nil
+}
+
+// Benchmark compares the portfolio to having put the same cash flows into the
+// assets against, which may be given by id, symbol or name.
+func (s *portfolioService) Benchmark(ctx context.Context, against []portfolio.Target, interval string) (*portfolio.Benchmark, error) {
+	for i := range against {
+		asset, err := s.assets.Resolve(ctx, against[i].AssetID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", portfolio.ErrInvalidBasket, against[i].AssetID, err)
+		}
+		against[i].AssetID = asset.ID
+	}
+
+	flows, err := s.cashFlows(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	history := map[string]prices.Series{}
+	if len(flows) > 0 {
+		assetIds := map[string]bool{}
+		for _, flow := range flows {
+			assetIds[flow.AssetID] = true
+		}
+		for _, target := range against {
+			assetIds[target.AssetID] = true
+		}
+
+		// flows come in date order; a day earlier gives the first one a price
+		// from before it
+		if history, err = s.priceHistory(ctx, assetIds, flows[0].Date.AddDate(0, 0, -1), now); err != nil {
+			return nil, err
+		}
+	}
+	return portfolio.Compare(flows, history, against, interval, now)
+}
+
+// Risk measures the risk of the portfolio and of the assets held over the
+// window, from daily values: the snapshots, and before the first one the
+// value the transactions add up to at the prices of each day.
+func (s *portfolioService) Risk(ctx context.Context, window portfolio.Window) (*portfolio.Risk, error) {
+	now := s.now()
+	start := window.Start(now)
+	dates := portfolio.Days(start, now)
+
+	snapshots, err := s.snapshotRepo.GetSince(ctx, start)
+	if err != nil {
+		return nil, err
+	}
+	holdings, err := s.cryptoRepo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	assetIds := map[string]bool{}
+	held := map[string]bool{}
+	for _, holding := range holdings {
+		if holding.Balance.IsPositive() {
+			assetIds[holding.AssetID], held[holding.AssetID] = true, true
+		}
+	}
+
+	replayed := dates
+	if len(snapshots) > 0 {
+		replayed = nil
+		for _, date := range dates {
+			if date.Before(snapshots[0].Date) {
+				replayed = append(replayed, date)
+			}
+		}
+	}
+	var flows []portfolio.CashFlow
+	if len(replayed) > 0 {
+		if flows, err = s.cashFlows(ctx); err != nil {
+			return nil, err
+		}
+		for _, flow := range flows {
+			assetIds[flow.AssetID] = true
+		}
+	}
+
+	history, err := s.priceHistory(ctx, assetIds, start.AddDate(0, 0, -1), now)
+	if err != nil {
+		return nil, err
+	}
+
+	points, err := portfolio.Values(flows, history, replayed)
+	if err != nil {
+		return nil, err
+	}
+	for _, snapshot := range snapshots {
+		points = append(points, portfolio.ValuePoint{Date: snapshot.Date, Value: snapshot.Value, NetInvested: snapshot.NetInvested})
+	}
+
+	for asset := range history {
+		if !held[asset] {
+			delete(history, asset)
+		}
+	}
+	risk := portfolio.Analyze(points, history, dates, s.riskFreeRate)
+	risk.Window = window.String()
+	return risk, nil
+}
+
+// Snapshot records the value of the portfolio today.
+func (s *portfolioService) Snapshot(ctx context.Context) error {
+	return s.snapshotRepo.Record(ctx, s.now())
+}
+
+// cashFlows are the confirmed transactions of the holdings in the wallet, in
+// date order.
+func (s *portfolioService) cashFlows(ctx context.Context) ([]portfolio.CashFlow, error) {
+	holdings, err := s.cryptoRepo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	assetIds := make(map[uint32]string, len(holdings))
+	for _, holding := range holdings {
+		assetIds[holding.ID] = holding.AssetID
+	}
+
+	transactions, err := s.transactionRepo.GetAllActive(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	flows := make([]portfolio.CashFlow, 0, len(transactions))
+	for _, transaction := range transactions {
+		date, err := time.Parse(utils.TimeFormat, transaction.PurchaseDate)
+		if err != nil {
+			return nil, err
+		}
+		flow := portfolio.CashFlow{
+			Date:    date,
+			AssetID: assetIds[transaction.CryptocurrencyId],
+			Amount:  transaction.CryptocurrencyAmount,
+			Fiat:    transaction.FiatAmount,
+		}
+		if transaction.Type == models.TransactionTypeSell {
+			flow.Amount, flow.Fiat = flow.Amount.Neg(), flow.Fiat.Neg()
+		}
+		flows = append(flows, flow)
+	}
+	return flows, nil
+}
+
+// priceHistory reads the USD prices of the assets from the history provider,
+// by the ids the provider knows them by.
+func (s *portfolioService) priceHistory(ctx context.Context, assetIds map[string]bool, from, to time.Time) (map[string]prices.Series, error) {
+	ids := make([]string, 0, len(assetIds))
+	for id := range assetIds {
+		ids = append(ids, id)
+	}
+	providerIds, err := s.assets.ProviderIDs(ctx, s.history.Name(), ids)
+	if err != nil {
+		return nil, err
+	}
+
+	history := make(map[string]prices.Series, len(ids))
+	for _, id := range ids {
+		providerId, ok := providerIds[id]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s is unknown to %s", portfolio.ErrNoPriceHistory, id, s.history.Name())
+		}
+		series, err := s.history.GetHistory(ctx, providerId, "usd", from, to)
+		if err != nil {
+			return nil, err
+		}
+		history[id] = series
+	}
+	return history, nil
+}
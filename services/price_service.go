@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+	"wallet-manager/events"
+	"wallet-manager/prices"
+	"wallet-manager/repositories"
+)
+
+// PriceFetcher returns the USD price of each known name and its source, like
+// prices.Aggregator.Get with "usd".
+type PriceFetcher func(ctx context.Context, names []string) (map[string]prices.Price, error)
+
+type PriceService interface {
+	Refresh(ctx context.Context) error
+}
+
+type priceService struct {
+	repo       repositories.CryptoPriceRepository
+	fetch      PriceFetcher
+	transactor repositories.Transactor
+	publisher  events.Publisher
+}
+
+func NewPriceService(repo repositories.CryptoPriceRepository, fetch PriceFetcher, transactor repositories.Transactor, publisher events.Publisher) PriceService {
+	return &priceService{repo: repo, fetch: fetch, transactor: transactor, publisher: publisher}
+}
+
+// Refresh fetches the price of every held or watched asset and stores it.
+func (s *priceService) Refresh(ctx context.Context) error {
+	names, err := s.repo.GetTrackedNames(ctx)
+	if err != nil || len(names) == 0 {
+		return err
+	}
+
+	quotes, err := s.fetch(ctx, names)
+	if err != nil {
+		return err
+	}
+
+	return s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		refreshed := make([]string, 0, len(quotes))
+		for name, quote := range quotes {
+			if err := s.repo.Save(ctx, name, quote.Value, quote.Source); err != nil {
+				return err
+			}
+			if err := s.publisher.Publish(ctx, events.PriceUpdated{Name: name, PriceUSD: quote.Value, Source: quote.Source}); err != nil {
+				return err
+			}
+			refreshed = append(refreshed, name)
+		}
+		return s.publisher.Publish(ctx, events.PricesRefreshed{Names: refreshed})
+	})
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"wallet-manager/events"
+	"wallet-manager/models"
+	"wallet-manager/repositories"
+	"wallet-manager/utils"
+
+	"github.com/shopspring/decimal"
+)
+
+type ReconciliationService interface {
+	Run(ctx context.Context) error
+	Report(ctx context.Context, refresh bool) (*models.ReconciliationReport, error)
+	Adjust(ctx context.Context, cryptoId uint32, target string) (*models.Adjustment, error)
+}
+
+type reconciliationService struct {
+	repo            repositories.ReconciliationRepository
+	cryptoRepo      repositories.CryptocurrencyRepository
+	transactionRepo repositories.CryptoTransactionRepository
+	cryptoService   CryptocurrencyService
+	addresses       AddressService
+	transactor      repositories.Transactor
+	audit           AuditService
+	publisher       events.Publisher
+}
+
+func NewReconciliationService(repo repositories.ReconciliationRepository, cryptoRepo repositories.CryptocurrencyRepository, transactionRepo repositories.CryptoTransactionRepository, cryptoService CryptocurrencyService, addresses AddressService, transactor repositories.Transactor, audit AuditService, publisher events.Publisher) ReconciliationService {
+	return &reconciliationService{
+		repo:            repo,
+		cryptoRepo:      cryptoRepo,
+		transactionRepo: transactionRepo,
+		cryptoService:   cryptoService,
+		addresses:       addresses,
+		transactor:      transactor,
+		audit:           audit,
+		publisher:       publisher,
+	}
+}
+
+// Run checks every holding against its transactions and its watched
+// addresses. A holding whose addresses cannot be read is still checked
+// against its transactions.
+func (s *reconciliationService) Run(ctx context.Context) error {
+	cryptos, err := s.cryptoRepo.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+	transactions, err := s.transactionRepo.GetAllActive(ctx)
+	if err != nil {
+		return err
+	}
+	ledger := map[uint32][]models.CryptoTransaction{}
+	for _, transaction := range transactions {
+		ledger[transaction.CryptocurrencyId] = append(ledger[transaction.CryptocurrencyId], transaction)
+	}
+
+	var errs []error
+	now := time.Now().UTC()
+	for _, crypto := range cryptos {
+		onChain, err := s.addresses.OnChainBalance(ctx, crypto.ID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cryptocurrency %d: %w", crypto.ID, err))
+		}
+		reconciliation := reconcile(crypto, ledger[crypto.ID], onChain, now)
+		if err := s.repo.Save(ctx, &reconciliation); err != nil {
+			return err
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Report lists the holdings out of line with their transactions or their
+// addresses as of the last run, or as of now when refresh is set.
+func (s *reconciliationService) Report(ctx context.Context, refresh bool) (*models.ReconciliationReport, error) {
+	if refresh {
+		if err := s.Run(ctx); err != nil {
+			return nil, err
+		}
+	}
+	reconciliations, err := s.repo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.ReconciliationReport{Holdings: len(reconciliations), Discrepancies: []models.Reconciliation{}}
+	for _, reconciliation := range reconciliations {
+		compare(&reconciliation)
+		if !balanced(reconciliation) {
+			report.Discrepancies = append(report.Discrepancies, reconciliation)
+		}
+	}
+	return report, nil
+}
+
+// Adjust posts the adjustment that brings the transactions of the holding in
+// line with its stored totals, or with its on-chain balance, which the stored
+// balance is then derived to as well. The fiat balance is always kept as stored.
+func (s *reconciliationService) Adjust(ctx context.Context, cryptoId uint32, target string) (*models.Adjustment, error) {
+	var onChain *decimal.Decimal
+	if target == models.ReconcileTargetOnChain {
+		var err error
+		if onChain, err = s.addresses.OnChainBalance(ctx, cryptoId); err != nil {
+			return nil, err
+		}
+		if onChain == nil {
+			return nil, ErrNoOnChainBalance
+		}
+	}
+
+	adjustment := &models.Adjustment{}
+	err := s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		crypto, err := s.cryptoRepo.GetByID(ctx, cryptoId)
+		if err != nil {
+			return err
+		}
+		if crypto == nil {
+			return ErrCryptocurrencyNotFound
+		}
+		transactions, err := s.transactionRepo.GetAll(ctx, cryptoId)
+		if err != nil {
+			return err
+		}
+
+		ledger := balanceEffect(cryptoId, transactions...)
+		balance := crypto.Balance
+		if onChain != nil {
+			balance = *onChain
+		}
+		transaction := models.CryptoTransaction{
+			CryptocurrencyId:     cryptoId,
+			Type:                 models.TransactionTypeAdjustment,
+			Status:               models.TransactionStatusConfirmed,
+			CryptocurrencyAmount: balance.Sub(ledger.Balance),
+			FiatAmount:           crypto.CostInFiat.Sub(ledger.CostInFiat),
+			PurchaseDate:         utils.NowFormatted(),
+			CreatedDate:          utils.NowFormatted(),
+		}
+		if isNoop(balanceEffect(cryptoId, transaction)) {
+			return ErrAlreadyReconciled
+		}
+
+		if err := s.transactionRepo.Create(ctx, &transaction); err != nil {
+			return err
+		}
+		if err := s.audit.Record(ctx, models.AuditActionCreate, models.AuditEntityCryptoTransaction, transaction.ID, nil, transaction); err != nil {
+			return err
+		}
+		if err := s.publisher.Publish(ctx, events.TransactionCreated{CryptoTransaction: transaction}); err != nil {
+			return err
+		}
+		adjustment.Transaction = transaction
+		transactions = append(transactions, transaction)
+
+		// a stored balance that drifted from the transactions is derived
+		// again, so it only changes by what it was off the target
+		if stored := (models.Cryptocurrency{ID: cryptoId, Balance: balance.Sub(crypto.Balance)}); !isNoop(stored) {
+			if err := s.cryptoService.RecordBalance(ctx, &stored); err != nil {
+				return err
+			}
+			if crypto, err = s.cryptoRepo.GetByID(ctx, cryptoId); err != nil {
+				return err
+			}
+		}
+
+		adjustment.Reconciliation = reconcile(*crypto, transactions, onChain, time.Now().UTC())
+		return s.repo.Save(ctx, &adjustment.Reconciliation)
+	})
+	if err != nil {
+		return nil, err
+	}
+	compare(&adjustment.Reconciliation)
+	return adjustment, nil
+}
+
+// reconcile checks the stored totals of the holding against its transactions,
+// of which only the confirmed ones count.
+func reconcile(crypto models.Cryptocurrency, transactions []models.CryptoTransaction, onChain *decimal.Decimal, now time.Time) models.Reconciliation {
+	ledger := balanceEffect(crypto.ID, transactions...)
+	reconciliation := models.Reconciliation{
+		CryptocurrencyID:  crypto.ID,
+		AssetID:           crypto.AssetID,
+		Balance:           crypto.Balance,
+		LedgerBalance:     ledger.Balance,
+		FiatBalance:       crypto.CostInFiat,
+		LedgerFiatBalance: ledger.CostInFiat,
+		OnChainBalance:    onChain,
+		CheckedAt:         now,
+	}
+	compare(&reconciliation)
+	return reconciliation
+}
+
+func compare(reconciliation *models.Reconciliation) {
+	reconciliation.BalanceDifference = reconciliation.Balance.Sub(reconciliation.LedgerBalance)
+	reconciliation.FiatDifference = reconciliation.FiatBalance.Sub(reconciliation.LedgerFiatBalance)
+	reconciliation.OnChainDifference = nil
+	if reconciliation.OnChainBalance != nil {
+		difference := reconciliation.OnChainBalance.Sub(reconciliation.Balance)
+		reconciliation.OnChainDifference = &difference
+	}
+}
+
+func balanced(reconciliation models.Reconciliation) bool {
+	return reconciliation.BalanceDifference.IsZero() && reconciliation.FiatDifference.IsZero() &&
+		(reconciliation.OnChainDifference == nil || reconciliation.OnChainDifference.IsZero())
+}
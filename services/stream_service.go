@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"wallet-manager/events"
+	"wallet-manager/models"
+	"wallet-manager/repositories"
+	"wallet-manager/stream"
+)
+
+type StreamService interface {
+	Handle(ctx context.Context, event events.Event) error
+	Snapshot(ctx context.Context, filter stream.Filter) ([]stream.Message, error)
+}
+
+type streamService struct {
+	hub        *stream.Hub
+	cryptoRepo repositories.CryptocurrencyRepository
+	priceRepo  repositories.CryptoPriceRepository
+}
+
+func NewStreamService(hub *stream.Hub, cryptoRepo repositories.CryptocurrencyRepository, priceRepo repositories.CryptoPriceRepository) StreamService {
+	return &streamService{hub: hub, cryptoRepo: cryptoRepo, priceRepo: priceRepo}
+}
+
+// Handle turns domain events into stream messages: a price tick for every
+// updated price, and the recomputed holding whenever its price or balance
+// changes.
+func (s *streamService) Handle(ctx context.Context, event events.Event) error {
+	switch e := event.(type) {
+	case *events.PriceUpdated:
+		s.hub.Publish(priceMessage(*e))
+	case *events.PricesRefreshed:
+		refreshed := make(map[string]bool, len(e.Names))
+		for _, name := range e.Names {
+			refreshed[name] = true
+		}
+		cryptos, err := s.cryptoRepo.GetAll(ctx)
+		if err != nil {
+			return err
+		}
+		for _, crypto := range cryptos {
+			if refreshed[crypto.AssetID] {
+				s.hub.Publish(holdingMessage(crypto))
+			}
+		}
+	case *events.HoldingBalanceChanged:
+		crypto, err := s.cryptoRepo.GetByID(ctx, e.CryptocurrencyId)
+		if err != nil || crypto == nil {
+			return err
+		}
+		s.hub.Publish(holdingMessage(*crypto))
+	}
+	return nil
+}
+
+// Snapshot is the current state matching filter, sent to clients as they
+// connect so they do not have to wait for the next change.
+func (s *streamService) Snapshot(ctx context.Context, filter stream.Filter) ([]stream.Message, error) {
+	var messages []stream.Message
+
+	prices, err := s.priceRepo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, price := range prices {
+		message := priceMessage(events.PriceUpdated{Name: price.Name, PriceUSD: price.PriceUSD, Source: price.Source})
+		if filter.Match(message) {
+			messages = append(messages, message)
+		}
+	}
+
+	cryptos, err := s.cryptoRepo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, crypto := range cryptos {
+		if message := holdingMessage(crypto); filter.Match(message) {
+			messages = append(messages, message)
+		}
+	}
+	return messages, nil
+}
+
+func priceMessage(price events.PriceUpdated) stream.Message {
+	return stream.Message{Type: stream.MessagePrice, Asset: price.Name, Data: price}
+}
+
+func holdingMessage(crypto models.Cryptocurrency) stream.Message {
+	return stream.Message{Type: stream.MessageHolding, Asset: crypto.AssetID, Data: crypto}
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"wallet-manager/models"
+	"wallet-manager/prices"
+	"wallet-manager/repositories"
+	"wallet-manager/tax"
+	"wallet-manager/utils"
+
+	"github.com/shopspring/decimal"
+)
+
+type TaxService interface {
+	Report(ctx context.Context, year int, method tax.Method) (*tax.Report, error)
+	Brazil(ctx context.Context, year int, rules tax.RuleSet) (*tax.BrazilReport, error)
+	EstimateSales(ctx context.Context, sales []Sale, method tax.Method) ([][]tax.Disposal, error)
+}
+
+// Sale is a sell of a holding being considered, valued in USD.
+type Sale struct {
+	CryptocurrencyID uint32
+	Amount           decimal.Decimal
+	Proceeds         decimal.Decimal
+}
+
+// ExchangeRates gives the USD/BRL rate of a day, see prices.PTAX.
+type ExchangeRates interface {
+	USDBRL(ctx context.Context, date time.Time) (prices.ExchangeRate, error)
+}
+
+type taxService struct {
+	cryptoRepo      repositories.CryptocurrencyRepository
+	transactionRepo repositories.CryptoTransactionRepository
+	assets          AssetService
+	rates           ExchangeRates
+}
+
+func NewTaxService(cryptoRepo repositories.CryptocurrencyRepository, transactionRepo repositories.CryptoTransactionRepository, assets AssetService, rates ExchangeRates) TaxService {
+	return &taxService{cryptoRepo: cryptoRepo, transactionRepo: transactionRepo, assets: assets, rates: rates}
+}
+
+// Report matches the transactions of every holding in the wallet, so lots
+// bought in earlier years are used up before the year reported on.
+func (s *taxService) Report(ctx context.Context, year int, method tax.Method) (*tax.Report, error) {
+	trades, err := s.trades(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return tax.Generate(trades, year, method)
+}
+
+// Brazil converts every trade to BRL at the PTAX of its day: proceeds at the
+// bid and costs at the ask, as Receita Federal has foreign currency converted.
+func (s *taxService) Brazil(ctx context.Context, year int, rules tax.RuleSet) (*tax.BrazilReport, error) {
+	trades, err := s.trades(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	end := time.Date(year+1, time.January, 1, 0, 0, 0, 0, time.UTC)
+	inBRL := make([]tax.Trade, 0, len(trades))
+	for _, trade := range trades {
+		if !trade.Date.Before(end) {
+			continue
+		}
+		rate, err := s.rates.USDBRL(ctx, trade.Date)
+		if err != nil {
+			return nil, err
+		}
+		if trade.Sell {
+			trade.Fiat = trade.Fiat.Mul(rate.Buy).Round(2)
+		} else {
+			trade.Fiat = trade.Fiat.Mul(rate.Sell).Round(2)
+		}
+		inBRL = append(inBRL, trade)
+	}
+	return tax.Brazil(inBRL, year, rules)
+}
+
+// EstimateSales matches each sale, as if made now, against the lots the
+// transactions leave open. A sale the lots do not cover, as when the balance
+// was set without transactions, gets no disposals.
+func (s *taxService) EstimateSales(ctx context.Context, sales []Sale, method tax.Method) ([][]tax.Disposal, error) {
+	symbols, err := s.symbols(ctx)
+	if err != nil {
+		return nil, err
+	}
+	trades, err := s.tradesOf(ctx, symbols)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	estimates := make([][]tax.Disposal, len(sales))
+	for i, sale := range sales {
+		disposals, err := tax.Simulate(trades, tax.Trade{
+			Asset:  symbols[sale.CryptocurrencyID],
+			Amount: sale.Amount,
+			Fiat:   sale.Proceeds,
+			Date:   now,
+		}, method)
+		if errors.Is(err, tax.ErrInsufficientLots) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		estimates[i] = disposals
+	}
+	return estimates, nil
+}
+
+// trades are the transactions of the wallet, valued in USD.
+func (s *taxService) trades(ctx context.Context) ([]tax.Trade, error) {
+	symbols, err := s.symbols(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.tradesOf(ctx, symbols)
+}
+
+func (s *taxService) tradesOf(ctx context.Context, symbols map[uint32]string) ([]tax.Trade, error) {
+	transactions, err := s.transactionRepo.GetAllActive(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	trades := make([]tax.Trade, 0, len(transactions))
+	for _, transaction := range transactions {
+		date, err := time.Parse(utils.TimeFormat, transaction.PurchaseDate)
+		if err != nil {
+			return nil, err
+		}
+		// moving no coins, as an opening balance or an adjustment of fiat alone, is no trade
+		if transaction.CryptocurrencyAmount.IsZero() {
+			continue
+		}
+		trade := tax.Trade{
+			ID:     transaction.ID,
+			Asset:  symbols[transaction.CryptocurrencyId],
+			Sell:   transaction.Type == models.TransactionTypeSell,
+			Amount: transaction.CryptocurrencyAmount,
+			Fiat:   transaction.FiatAmount,
+			Date:   date,
+		}
+		if transaction.Type == models.TransactionTypeAdjustment {
+			// an adjustment acquires what it adds and disposes of what it takes
+			// out, at the fiat it moves the same way
+			trade.Sell = transaction.CryptocurrencyAmount.IsNegative()
+			trade.Amount = transaction.CryptocurrencyAmount.Abs()
+			trade.Fiat = decimal.Zero
+			if transaction.FiatAmount.IsNegative() == trade.Sell {
+				trade.Fiat = transaction.FiatAmount.Abs()
+			}
+		}
+		trades = append(trades, trade)
+	}
+	return trades, nil
+}
+
+// symbols maps each holding to the ticker of its asset, which is how disposals
+// are described on the forms.
+func (s *taxService) symbols(ctx context.Context) (map[uint32]string, error) {
+	holdings, err := s.cryptoRepo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	symbols := make(map[uint32]string, len(holdings))
+	for _, holding := range holdings {
+		symbols[holding.ID] = strings.ToUpper(holding.AssetID)
+		asset, err := s.assets.GetByID(ctx, holding.AssetID)
+		if err != nil {
+			return nil, err
+		}
+		if asset != nil {
+			symbols[holding.ID] = asset.Symbol
+		}
+	}
+	return symbols, nil
+}
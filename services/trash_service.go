@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"time"
+	"wallet-manager/models"
+	"wallet-manager/repositories"
+)
+
+type Trash struct {
+	Cryptocurrencies []models.Cryptocurrency    `json:"cryptocurrencies"`
+	Transactions     []models.CryptoTransaction `json:"transactions"`
+}
+
+type TrashService interface {
+	GetAll(ctx context.Context) (*Trash, error)
+	Purge(ctx context.Context, retention time.Duration) error
+}
+
+type trashService struct {
+	cryptoRepo      repositories.CryptocurrencyRepository
+	transactionRepo repositories.CryptoTransactionRepository
+	transactor      repositories.Transactor
+	audit           AuditService
+}
+
+func NewTrashService(cryptoRepo repositories.CryptocurrencyRepository, transactionRepo repositories.CryptoTransactionRepository, transactor repositories.Transactor, audit AuditService) TrashService {
+	return &trashService{cryptoRepo: cryptoRepo, transactionRepo: transactionRepo, transactor: transactor, audit: audit}
+}
+
+func (s *trashService) GetAll(ctx context.Context) (*Trash, error) {
+	cryptos, err := s.cryptoRepo.GetDeleted(ctx)
+	if err != nil {
+		return nil, err
+	}
+	transactions, err := s.transactionRepo.GetDeleted(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Trash{Cryptocurrencies: cryptos, Transactions: transactions}, nil
+}
+
+// Purge permanently removes everything that has been in the trash for longer than retention.
+func (s *trashService) Purge(ctx context.Context, retention time.Duration) error {
+	deletedBefore := time.Now().Add(-retention).UTC()
+
+	return s.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		// transactions first, so the ones trashed along with a cryptocurrency are
+		// audited instead of silently removed by the cascade
+		transactions, err := s.transactionRepo.Purge(ctx, deletedBefore)
+		if err != nil {
+			return err
+		}
+		for i := range transactions {
+			if err := s.audit.Record(ctx, models.AuditActionPurge, models.AuditEntityCryptoTransaction, transactions[i].ID, transactions[i], nil); err != nil {
+				return err
+			}
+		}
+
+		cryptos, err := s.cryptoRepo.Purge(ctx, deletedBefore)
+		if err != nil {
+			return err
+		}
+		for i := range cryptos {
+			if err := s.audit.Record(ctx, models.AuditActionPurge, models.AuditEntityCryptocurrency, cryptos[i].ID, cryptos[i], nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+	"wallet-manager/events"
+	"wallet-manager/models"
+	"wallet-manager/repositories"
+	"wallet-manager/utils"
+	"wallet-manager/webhooks"
+)
+
+const (
+	webhookBatchSize   = 50
+	webhookLease       = 5 * time.Minute
+	webhookMaxAttempts = 10
+	webhookRetryBase   = 30 * time.Second
+	webhookRetryMax    = 6 * time.Hour
+	webhookLogLimit    = 100
+)
+
+type WebhookService interface {
+	Enqueue(ctx context.Context, event events.Event) error
+	CreateSubscription(ctx context.Context, subscription *models.WebhookSubscription) error
+	GetSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error)
+	GetSubscriptionByID(ctx context.Context, id uint32) (*models.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, id uint32) error
+	GetDeliveries(ctx context.Context, subscriptionID uint32) ([]models.WebhookDelivery, error)
+	Deliver(ctx context.Context) error
+}
+
+type webhookPayload struct {
+	Type       string      `json:"type"`
+	OccurredAt string      `json:"occurredAt"`
+	Data       interface{} `json:"data"`
+}
+
+type webhookService struct {
+	repo   repositories.WebhookRepository
+	sender *webhooks.Sender
+}
+
+func NewWebhookService(repo repositories.WebhookRepository, sender *webhooks.Sender) WebhookService {
+	return &webhookService{repo: repo, sender: sender}
+}
+
+// Enqueue queues a delivery of the event for each subscription asking for it.
+// It is subscribed to the event bus for models.WebhookEventTypes.
+func (s *webhookService) Enqueue(ctx context.Context, event events.Event) error {
+	eventType := event.EventType()
+	subscriptions, err := s.repo.GetSubscribers(ctx, eventType)
+	if err != nil || len(subscriptions) == 0 {
+		return err
+	}
+
+	payload, err := json.Marshal(webhookPayload{Type: eventType, OccurredAt: time.Now().UTC().Format(utils.TimeFormat), Data: event})
+	if err != nil {
+		return err
+	}
+
+	for _, subscription := range subscriptions {
+		delivery := models.WebhookDelivery{SubscriptionID: subscription.ID, EventType: eventType, Payload: payload}
+		if err := s.repo.CreateDelivery(ctx, &delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateSubscription generates a secret when none is given. The secret is only
+// returned here; reads leave it out.
+func (s *webhookService) CreateSubscription(ctx context.Context, subscription *models.WebhookSubscription) error {
+	if subscription.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return err
+		}
+		subscription.Secret = hex.EncodeToString(secret)
+	}
+	return s.repo.CreateSubscription(ctx, subscription)
+}
+
+func (s *webhookService) GetSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	subscriptions, err := s.repo.GetSubscriptions(ctx)
+	for i := range subscriptions {
+		subscriptions[i].Secret = ""
+	}
+	return subscriptions, err
+}
+
+func (s *webhookService) GetSubscriptionByID(ctx context.Context, id uint32) (*models.WebhookSubscription, error) {
+	subscription, err := s.repo.GetSubscriptionByID(ctx, id)
+	if subscription != nil {
+		subscription.Secret = ""
+	}
+	return subscription, err
+}
+
+func (s *webhookService) DeleteSubscription(ctx context.Context, id uint32) error {
+	err := s.repo.DeleteSubscription(ctx, id)
+	if errors.Is(err, repositories.ErrNotFound) {
+		return ErrWebhookNotFound
+	}
+	return err
+}
+
+// GetDeliveries returns the latest deliveries of the subscription, newest first.
+func (s *webhookService) GetDeliveries(ctx context.Context, subscriptionID uint32) ([]models.WebhookDelivery, error) {
+	subscription, err := s.repo.GetSubscriptionByID(ctx, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+	if subscription == nil {
+		return nil, ErrWebhookNotFound
+	}
+	return s.repo.GetDeliveries(ctx, subscriptionID, webhookLogLimit)
+}
+
+// Deliver sends the deliveries that are due. Failed attempts are retried with
+// exponential backoff until webhookMaxAttempts, then the delivery is marked failed.
+func (s *webhookService) Deliver(ctx context.Context) error {
+	deliveries, err := s.repo.ClaimDueDeliveries(ctx, webhookBatchSize, time.Now().Add(webhookLease).UTC())
+	if err != nil {
+		return err
+	}
+
+	subscriptions := map[uint32]*models.WebhookSubscription{}
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		subscription, ok := subscriptions[delivery.SubscriptionID]
+		if !ok {
+			if subscription, err = s.repo.GetSubscriptionByID(ctx, delivery.SubscriptionID); err != nil {
+				return err
+			}
+			subscriptions[delivery.SubscriptionID] = subscription
+		}
+		if subscription == nil {
+			// deleted since the delivery was claimed, the cascade removed it
+			continue
+		}
+
+		s.attempt(ctx, subscription, delivery)
+		if err := s.repo.UpdateDelivery(ctx, delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *webhookService) attempt(ctx context.Context, subscription *models.WebhookSubscription, delivery *models.WebhookDelivery) {
+	now := time.Now().UTC()
+	statusCode, err := s.sender.Send(ctx, subscription.URL, subscription.Secret, delivery.EventType, delivery.ID, now.Unix(), delivery.Payload)
+
+	delivery.Attempts++
+	delivery.LastStatusCode = nil
+	if statusCode != 0 {
+		delivery.LastStatusCode = &statusCode
+	}
+
+	if err == nil {
+		delivery.Status = models.WebhookDeliveryDelivered
+		delivery.DeliveredAt = &now
+		delivery.LastError = nil
+		return
+	}
+
+	message := err.Error()
+	delivery.LastError = &message
+	if delivery.Attempts >= webhookMaxAttempts {
+		delivery.Status = models.WebhookDeliveryFailed
+		return
+	}
+	delivery.NextAttemptAt = now.Add(webhookBackoff(delivery.Attempts))
+}
+
+func webhookBackoff(attempts int) time.Duration {
+	backoff := webhookRetryBase
+	for i := 1; i < attempts && backoff < webhookRetryMax; i++ {
+		backoff *= 2
+	}
+	if backoff > webhookRetryMax {
+		return webhookRetryMax
+	}
+	return backoff
+}
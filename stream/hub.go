@@ -0,0 +1,120 @@
+package stream
+
+import (
+	"strings"
+	"sync"
+)
+
+const (
+	MessagePrice   = "price"
+	MessageHolding = "holding"
+)
+
+// Message is pushed to every client whose filter matches it.
+type Message struct {
+	Type  string `json:"type"`
+	Asset string `json:"asset"`
+	Data  any    `json:"data"`
+}
+
+// Filter selects messages by asset and type; an empty set matches everything.
+type Filter struct {
+	Assets map[string]bool
+	Types  map[string]bool
+}
+
+func NewFilter(assets []string, types []string) Filter {
+	return Filter{Assets: set(assets), Types: set(types)}
+}
+
+func (f Filter) Match(message Message) bool {
+	return (len(f.Assets) == 0 || f.Assets[message.Asset]) && (len(f.Types) == 0 || f.Types[message.Type])
+}
+
+func set(values []string) map[string]bool {
+	result := map[string]bool{}
+	for _, value := range values {
+		if value = strings.ToLower(strings.TrimSpace(value)); value != "" {
+			result[value] = true
+		}
+	}
+	return result
+}
+
+type Client struct {
+	messages chan Message
+	dropped  chan struct{}
+
+	mu     sync.Mutex
+	filter Filter
+}
+
+func (c *Client) Messages() <-chan Message {
+	return c.messages
+}
+
+// Dropped is closed when the hub gives up on a client that does not keep up.
+func (c *Client) Dropped() <-chan struct{} {
+	return c.dropped
+}
+
+func (c *Client) SetFilter(filter Filter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.filter = filter
+}
+
+func (c *Client) match(message Message) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.filter.Match(message)
+}
+
+// Hub fans messages out to the connected clients of this instance. Each client
+// has a buffer of pending messages; a client whose buffer is full is dropped
+// rather than slowing down everyone else, and is expected to reconnect.
+type Hub struct {
+	mu      sync.Mutex
+	clients map[*Client]struct{}
+	buffer  int
+}
+
+func NewHub(buffer int) *Hub {
+	return &Hub{clients: map[*Client]struct{}{}, buffer: buffer}
+}
+
+func (h *Hub) Subscribe(filter Filter) *Client {
+	client := &Client{messages: make(chan Message, h.buffer), dropped: make(chan struct{}), filter: filter}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.clients[client] = struct{}{}
+	return client
+}
+
+func (h *Hub) Unsubscribe(client *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.clients, client)
+}
+
+func (h *Hub) Publish(message Message) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for client := range h.clients {
+		if !client.match(message) {
+			continue
+		}
+		select {
+		case client.messages <- message:
+		default:
+			delete(h.clients, client)
+			close(client.dropped)
+		}
+	}
+}
+
+func (h *Hub) Clients() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients)
+}
@@ -0,0 +1,246 @@
+package tax
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var ErrUnknownRuleSet = errors.New("unknown rule set")
+
+// Bracket taxes the part of the monthly gain up to UpTo at Rate. The last
+// bracket has no UpTo.
+type Bracket struct {
+	UpTo decimal.Decimal `json:"upTo"`
+	Rate decimal.Decimal `json:"rate"`
+}
+
+// RuleSet holds the Brazilian capital gains rules (GCAP) in force for a period.
+// Months whose disposals add up to no more than ExemptionLimit owe nothing.
+type RuleSet struct {
+	Name           string          `json:"name"`
+	ExemptionLimit decimal.Decimal `json:"exemptionLimit"`
+	Brackets       []Bracket       `json:"brackets"`
+}
+
+var RuleSets = map[string]RuleSet{
+	// Lei 13.259/2016, progressive rates from 2017 on
+	"gcap": {
+		Name:           "gcap",
+		ExemptionLimit: decimal.NewFromInt(35_000),
+		Brackets: []Bracket{
+			{UpTo: decimal.NewFromInt(5_000_000), Rate: decimal.RequireFromString("0.15")},
+			{UpTo: decimal.NewFromInt(10_000_000), Rate: decimal.RequireFromString("0.175")},
+			{UpTo: decimal.NewFromInt(30_000_000), Rate: decimal.RequireFromString("0.20")},
+			{Rate: decimal.RequireFromString("0.225")},
+		},
+	},
+	// flat rate up to 2016
+	"gcap-2016": {
+		Name:           "gcap-2016",
+		ExemptionLimit: decimal.NewFromInt(35_000),
+		Brackets:       []Bracket{{Rate: decimal.RequireFromString("0.15")}},
+	},
+}
+
+func ParseRuleSet(name string) (RuleSet, error) {
+	rules, ok := RuleSets[name]
+	if !ok {
+		return RuleSet{}, fmt.Errorf("%w: %q", ErrUnknownRuleSet, name)
+	}
+	return rules, nil
+}
+
+// Tax applies the brackets to a taxable gain.
+func (r RuleSet) Tax(gain decimal.Decimal) decimal.Decimal {
+	tax, floor := decimal.Zero, decimal.Zero
+	for _, bracket := range r.Brackets {
+		if !gain.GreaterThan(floor) {
+			break
+		}
+		portion := gain.Sub(floor)
+		if !bracket.UpTo.IsZero() {
+			portion = decimal.Min(portion, bracket.UpTo.Sub(floor))
+		}
+		tax = tax.Add(portion.Mul(bracket.Rate))
+		floor = bracket.UpTo
+		if floor.IsZero() {
+			break
+		}
+	}
+	return tax.Round(2)
+}
+
+// Month sums up the disposals of a month, in BRL. Gains and losses of the
+// month offset each other; a loss is not carried to later months.
+type Month struct {
+	Month       int             `json:"month"`
+	Sales       decimal.Decimal `json:"sales"`
+	CostBasis   decimal.Decimal `json:"costBasis"`
+	Gain        decimal.Decimal `json:"gain"`
+	Exempt      bool            `json:"exempt"`
+	TaxableGain decimal.Decimal `json:"taxableGain"`
+	Tax         decimal.Decimal `json:"tax"`
+	// DarfDue is the last day of the following month, when the DARF is paid.
+	DarfDue *time.Time `json:"darfDue,omitempty"`
+}
+
+// Position is an asset as declared under Bens e Direitos, group 08
+// (criptoativos): the amount held and its acquisition cost at the end of the
+// previous and of the reported year.
+type Position struct {
+	Asset        string          `json:"asset"`
+	Group        string          `json:"group"`
+	Code         string          `json:"code"`
+	Description  string          `json:"description"`
+	Amount       decimal.Decimal `json:"amount"`
+	PreviousCost decimal.Decimal `json:"previousCost"`
+	Cost         decimal.Decimal `json:"cost"`
+}
+
+type BrazilTotals struct {
+	Sales       decimal.Decimal `json:"sales"`
+	Gain        decimal.Decimal `json:"gain"`
+	TaxableGain decimal.Decimal `json:"taxableGain"`
+	Tax         decimal.Decimal `json:"tax"`
+}
+
+type BrazilReport struct {
+	Year   int          `json:"year"`
+	Rules  RuleSet      `json:"rules"`
+	Months []Month      `json:"months"`
+	Totals BrazilTotals `json:"totals"`
+	Assets []Position   `json:"assets"`
+}
+
+type holding struct {
+	amount decimal.Decimal
+	cost   decimal.Decimal
+}
+
+// Brazil reports a year of trades valued in BRL. Receita Federal wants the
+// average acquisition cost (custo médio), so lots are not matched one by one.
+func Brazil(trades []Trade, year int, rules RuleSet) (*BrazilReport, error) {
+	trades = append([]Trade(nil), trades...)
+	sort.SliceStable(trades, func(i, j int) bool { return trades[i].Date.Before(trades[j].Date) })
+
+	report := &BrazilReport{Year: year, Rules: rules, Months: make([]Month, 12)}
+	for i := range report.Months {
+		report.Months[i].Month = i + 1
+	}
+
+	holdings := map[string]*holding{}
+	var previous map[string]holding
+	for _, trade := range trades {
+		if trade.Date.Year() > year {
+			break
+		}
+		if previous == nil && trade.Date.Year() == year {
+			previous = snapshot(holdings)
+		}
+		if !trade.Amount.IsPositive() {
+			return nil, fmt.Errorf("%w: trade %d", errNonPositiveAmount, trade.ID)
+		}
+
+		h := holdings[trade.Asset]
+		if h == nil {
+			h = &holding{}
+			holdings[trade.Asset] = h
+		}
+		if !trade.Sell {
+			h.amount = h.amount.Add(trade.Amount)
+			h.cost = h.cost.Add(trade.Fiat)
+			continue
+		}
+
+		if trade.Amount.GreaterThan(h.amount) {
+			return nil, fmt.Errorf("%w: %s %s sold on %s", ErrInsufficientLots, trade.Amount.Sub(h.amount), trade.Asset, trade.Date.Format(time.DateOnly))
+		}
+		cost := h.cost
+		if trade.Amount.LessThan(h.amount) {
+			cost = h.cost.Mul(trade.Amount).Div(h.amount).Round(2)
+		}
+		h.amount = h.amount.Sub(trade.Amount)
+		h.cost = h.cost.Sub(cost)
+
+		if trade.Date.Year() == year {
+			month := &report.Months[trade.Date.Month()-1]
+			month.Sales = month.Sales.Add(trade.Fiat)
+			month.CostBasis = month.CostBasis.Add(cost)
+			month.Gain = month.Gain.Add(trade.Fiat.Sub(cost))
+		}
+	}
+	if previous == nil {
+		previous = snapshot(holdings)
+	}
+
+	for i := range report.Months {
+		month := &report.Months[i]
+		month.Exempt = !month.Sales.GreaterThan(rules.ExemptionLimit)
+		if !month.Exempt && month.Gain.IsPositive() {
+			month.TaxableGain = month.Gain
+			month.Tax = rules.Tax(month.Gain)
+		}
+		if month.Tax.IsPositive() {
+			due := time.Date(year, time.Month(month.Month)+2, 0, 0, 0, 0, 0, time.UTC)
+			month.DarfDue = &due
+		}
+
+		report.Totals.Sales = report.Totals.Sales.Add(month.Sales)
+		report.Totals.Gain = report.Totals.Gain.Add(month.Gain)
+		report.Totals.TaxableGain = report.Totals.TaxableGain.Add(month.TaxableGain)
+		report.Totals.Tax = report.Totals.Tax.Add(month.Tax)
+	}
+
+	report.Assets = positions(previous, holdings)
+	return report, nil
+}
+
+func snapshot(holdings map[string]*holding) map[string]holding {
+	copied := make(map[string]holding, len(holdings))
+	for asset, h := range holdings {
+		copied[asset] = *h
+	}
+	return copied
+}
+
+// positions lists the assets held at the end of either year, as both columns
+// of Bens e Direitos are filled in.
+func positions(previous map[string]holding, current map[string]*holding) []Position {
+	result := []Position{}
+	for asset, h := range current {
+		before := previous[asset]
+		if h.amount.IsZero() && before.amount.IsZero() {
+			continue
+		}
+		result = append(result, Position{
+			Asset:        asset,
+			Group:        "08",
+			Code:         cryptoAssetCode(asset),
+			Description:  fmt.Sprintf("%s %s", h.amount, asset),
+			Amount:       h.amount,
+			PreviousCost: before.cost,
+			Cost:         h.cost,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Asset < result[j].Asset })
+	return result
+}
+
+var stablecoins = map[string]bool{"USDT": true, "USDC": true, "DAI": true, "BUSD": true, "TUSD": true, "USDP": true, "FDUSD": true}
+
+// cryptoAssetCode is the Bens e Direitos code within group 08: 01 for Bitcoin,
+// 03 for stablecoins and 02 for other coins.
+func cryptoAssetCode(symbol string) string {
+	switch symbol = strings.ToUpper(symbol); {
+	case symbol == "BTC":
+		return "01"
+	case stablecoins[symbol]:
+		return "03"
+	}
+	return "02"
+}
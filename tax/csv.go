@@ -0,0 +1,84 @@
+package tax
+
+import (
+	"encoding/csv"
+	"io"
+	"time"
+)
+
+const form8949DateFormat = "01/02/2006"
+
+// WriteCSV writes one row per disposal followed by the totals.
+func WriteCSV(w io.Writer, report *Report) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"asset", "amount", "acquired", "disposed", "proceeds", "cost_basis", "gain", "term"})
+	for _, d := range report.Disposals {
+		out.Write([]string{
+			d.Asset,
+			d.Amount.String(),
+			d.Acquired.Format(time.DateOnly),
+			d.Disposed.Format(time.DateOnly),
+			d.Proceeds.StringFixed(2),
+			d.CostBasis.StringFixed(2),
+			d.Gain.StringFixed(2),
+			d.Term,
+		})
+	}
+
+	for _, total := range []struct {
+		name    string
+		summary Summary
+	}{
+		{"total short-term", report.Totals.ShortTerm},
+		{"total long-term", report.Totals.LongTerm},
+		{"total", report.Totals.Total},
+	} {
+		out.Write([]string{total.name, "", "", "", total.summary.Proceeds.StringFixed(2), total.summary.CostBasis.StringFixed(2), total.summary.Gain.StringFixed(2), ""})
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// WriteForm8949 lays the disposals out in the columns (a) to (h) of IRS Form
+// 8949: short-term ones in Part I, long-term ones in Part II. No adjustments
+// are made, so columns (f) and (g) stay empty.
+func WriteForm8949(w io.Writer, report *Report) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{
+		"Part",
+		"(a) Description of property",
+		"(b) Date acquired",
+		"(c) Date sold or disposed of",
+		"(d) Proceeds",
+		"(e) Cost or other basis",
+		"(f) Code",
+		"(g) Amount of adjustment",
+		"(h) Gain or (loss)",
+	})
+
+	for _, part := range []struct {
+		name string
+		term string
+	}{{"I", TermShort}, {"II", TermLong}} {
+		for _, d := range report.Disposals {
+			if d.Term != part.term {
+				continue
+			}
+			out.Write([]string{
+				part.name,
+				d.Amount.String() + " " + d.Asset,
+				d.Acquired.Format(form8949DateFormat),
+				d.Disposed.Format(form8949DateFormat),
+				d.Proceeds.StringFixed(2),
+				d.CostBasis.StringFixed(2),
+				"",
+				"",
+				d.Gain.StringFixed(2),
+			})
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
@@ -0,0 +1,205 @@
+package tax
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Method picks which open lots a disposal is matched against.
+type Method string
+
+const (
+	MethodFIFO Method = "fifo"
+	MethodLIFO Method = "lifo"
+	// MethodHIFO matches the lots with the highest unit cost first.
+	MethodHIFO Method = "hifo"
+)
+
+const (
+	TermShort = "short"
+	TermLong  = "long"
+)
+
+var (
+	ErrUnknownMethod     = errors.New("unknown lot matching method")
+	ErrInsufficientLots  = errors.New("disposal exceeds the acquired lots")
+	errNonPositiveAmount = errors.New("trade amount must be positive")
+)
+
+func ParseMethod(method string) (Method, error) {
+	switch m := Method(method); m {
+	case MethodFIFO, MethodLIFO, MethodHIFO:
+		return m, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownMethod, method)
+}
+
+// Trade is an acquisition or a disposal of an asset, valued in fiat: the cost
+// of a buy or the proceeds of a sell.
+type Trade struct {
+	ID     uint32
+	Asset  string
+	Sell   bool
+	Amount decimal.Decimal
+	Fiat   decimal.Decimal
+	Date   time.Time
+}
+
+// Disposal is the part of a sell matched against a single lot, which is how
+// Form 8949 wants it listed.
+type Disposal struct {
+	Asset         string          `json:"asset"`
+	TransactionID uint32          `json:"transactionId"`
+	LotID         uint32          `json:"lotId"`
+	Amount        decimal.Decimal `json:"amount"`
+	Acquired      time.Time       `json:"acquired"`
+	Disposed      time.Time       `json:"disposed"`
+	Proceeds      decimal.Decimal `json:"proceeds"`
+	CostBasis     decimal.Decimal `json:"costBasis"`
+	Gain          decimal.Decimal `json:"gain"`
+	Term          string          `json:"term"`
+}
+
+type lot struct {
+	id     uint32
+	date   time.Time
+	amount decimal.Decimal
+	cost   decimal.Decimal
+	unit   decimal.Decimal
+}
+
+// Match walks the trades in date order and matches every sell against the lots
+// of the same asset still open at that point.
+func Match(trades []Trade, method Method) ([]Disposal, error) {
+	disposals, _, err := replay(trades, method)
+	return disposals, err
+}
+
+// Simulate matches a sale not made yet against the lots the trades leave open,
+// without adding it to them.
+func Simulate(trades []Trade, sale Trade, method Method) ([]Disposal, error) {
+	_, lots, err := replay(trades, method)
+	if err != nil {
+		return nil, err
+	}
+	if !sale.Amount.IsPositive() {
+		return nil, fmt.Errorf("%w: sale of %s", errNonPositiveAmount, sale.Asset)
+	}
+	sale.Sell = true
+	return dispose(sale, lots[sale.Asset], method)
+}
+
+// replay returns the disposals of the trades and the lots still open after them.
+func replay(trades []Trade, method Method) ([]Disposal, map[string][]*lot, error) {
+	if _, err := ParseMethod(string(method)); err != nil {
+		return nil, nil, err
+	}
+
+	trades = append([]Trade(nil), trades...)
+	sort.SliceStable(trades, func(i, j int) bool { return trades[i].Date.Before(trades[j].Date) })
+
+	lots := map[string][]*lot{}
+	var disposals []Disposal
+	for _, trade := range trades {
+		if !trade.Amount.IsPositive() {
+			return nil, nil, fmt.Errorf("%w: trade %d", errNonPositiveAmount, trade.ID)
+		}
+		if !trade.Sell {
+			lots[trade.Asset] = append(lots[trade.Asset], &lot{
+				id:     trade.ID,
+				date:   trade.Date,
+				amount: trade.Amount,
+				cost:   trade.Fiat,
+				unit:   trade.Fiat.Div(trade.Amount),
+			})
+			continue
+		}
+
+		matched, err := dispose(trade, lots[trade.Asset], method)
+		if err != nil {
+			return nil, nil, err
+		}
+		disposals = append(disposals, matched...)
+		lots[trade.Asset] = open(lots[trade.Asset])
+	}
+	return disposals, lots, nil
+}
+
+func dispose(sell Trade, lots []*lot, method Method) ([]Disposal, error) {
+	ordered := append([]*lot(nil), lots...)
+	switch method {
+	case MethodLIFO:
+		sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].date.After(ordered[j].date) })
+	case MethodHIFO:
+		sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].unit.GreaterThan(ordered[j].unit) })
+	}
+
+	var disposals []Disposal
+	remaining, proceeds := sell.Amount, sell.Fiat
+	for _, lot := range ordered {
+		if remaining.IsZero() {
+			break
+		}
+
+		amount := decimal.Min(remaining, lot.amount)
+		cost := lot.cost
+		if amount.LessThan(lot.amount) {
+			cost = lot.cost.Mul(amount).Div(lot.amount).Round(2)
+		}
+		share := proceeds
+		if amount.LessThan(remaining) {
+			share = sell.Fiat.Mul(amount).Div(sell.Amount).Round(2)
+		}
+
+		lot.amount = lot.amount.Sub(amount)
+		lot.cost = lot.cost.Sub(cost)
+		remaining = remaining.Sub(amount)
+		proceeds = proceeds.Sub(share)
+
+		disposals = append(disposals, Disposal{
+			Asset:         sell.Asset,
+			TransactionID: sell.ID,
+			LotID:         lot.id,
+			Amount:        amount,
+			Acquired:      lot.date,
+			Disposed:      sell.Date,
+			Proceeds:      share,
+			CostBasis:     cost,
+			Gain:          share.Sub(cost),
+			Term:          term(lot.date, sell.Date),
+		})
+	}
+
+	if remaining.IsPositive() {
+		return nil, fmt.Errorf("%w: %s %s sold on %s", ErrInsufficientLots, remaining, sell.Asset, sell.Date.Format(time.DateOnly))
+	}
+	return disposals, nil
+}
+
+func open(lots []*lot) []*lot {
+	kept := lots[:0]
+	for _, lot := range lots {
+		if lot.amount.IsPositive() {
+			kept = append(kept, lot)
+		}
+	}
+	return kept
+}
+
+// term is long when the asset was held for more than a year, counting from
+// the day after it was acquired.
+func term(acquired, disposed time.Time) string {
+	if day(disposed).After(day(acquired).AddDate(1, 0, 0)) {
+		return TermLong
+	}
+	return TermShort
+}
+
+func day(t time.Time) time.Time {
+	year, month, d := t.Date()
+	return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,69 @@
+package tax
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type Summary struct {
+	Proceeds  decimal.Decimal `json:"proceeds"`
+	CostBasis decimal.Decimal `json:"costBasis"`
+	Gain      decimal.Decimal `json:"gain"`
+}
+
+func (s *Summary) add(disposal Disposal) {
+	s.Proceeds = s.Proceeds.Add(disposal.Proceeds)
+	s.CostBasis = s.CostBasis.Add(disposal.CostBasis)
+	s.Gain = s.Gain.Add(disposal.Gain)
+}
+
+type Totals struct {
+	ShortTerm Summary `json:"shortTerm"`
+	LongTerm  Summary `json:"longTerm"`
+	Total     Summary `json:"total"`
+}
+
+type Report struct {
+	Year      int        `json:"year"`
+	Method    Method     `json:"method"`
+	Disposals []Disposal `json:"disposals"`
+	Totals    Totals     `json:"totals"`
+}
+
+// NewReport keeps the disposals made in year and adds them up by term.
+func NewReport(year int, method Method, disposals []Disposal) *Report {
+	report := &Report{Year: year, Method: method, Disposals: []Disposal{}}
+	for _, disposal := range disposals {
+		if disposal.Disposed.Year() != year {
+			continue
+		}
+
+		report.Disposals = append(report.Disposals, disposal)
+		if disposal.Term == TermLong {
+			report.Totals.LongTerm.add(disposal)
+		} else {
+			report.Totals.ShortTerm.add(disposal)
+		}
+		report.Totals.Total.add(disposal)
+	}
+	return report
+}
+
+// Generate matches all trades and reports the disposals of year. Trades after
+// the end of the year cannot change it, so they are left out.
+func Generate(trades []Trade, year int, method Method) (*Report, error) {
+	end := time.Date(year+1, time.January, 1, 0, 0, 0, 0, time.UTC)
+	var until []Trade
+	for _, trade := range trades {
+		if trade.Date.Before(end) {
+			until = append(until, trade)
+		}
+	}
+
+	disposals, err := Match(until, method)
+	if err != nil {
+		return nil, err
+	}
+	return NewReport(year, method, disposals), nil
+}
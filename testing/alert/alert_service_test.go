@@ -0,0 +1,242 @@
+package testing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	db "wallet-manager/database"
+	"wallet-manager/events"
+	"wallet-manager/handlers"
+	"wallet-manager/models"
+	"wallet-manager/notifiers"
+	"wallet-manager/repositories"
+	"wallet-manager/services"
+	helper "wallet-manager/testing"
+	"wallet-manager/validators"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testDbInstance *sqlx.DB
+var tc testContext
+
+func TestMain(m *testing.M) {
+	testDB := helper.SetupTestDatabase()
+	testDbInstance = testDB.DbInstance
+	defer testDB.TearDown()
+	beforeAll()
+	os.Exit(m.Run())
+}
+
+type testContext struct {
+	repo       repositories.AlertRuleRepository
+	priceRepo  repositories.CryptoPriceRepository
+	transactor repositories.Transactor
+	bus        *events.Bus
+	service    services.AlertService
+	handle     *handlers.AlertHandler
+	engine     *gin.Engine
+}
+
+func beforeEach() {
+	deleteAll()
+}
+
+func beforeAll() {
+	validators.Register(knownAsset)
+	assets := services.NewAssetService(repositories.NewAssetRepository(testDbInstance))
+	assets.Seed(ctx, db.AssetSeed)
+	tc.repo = repositories.NewAlertRuleRepository(testDbInstance)
+	tc.priceRepo = repositories.NewCryptoPriceRepository(testDbInstance)
+	tc.transactor = repositories.NewTransactor(testDbInstance)
+	tc.bus = events.NewBus(repositories.NewOutboxRepository(testDbInstance), tc.transactor)
+	channels := map[string]notifiers.Notifier{models.AlertChannelWebhook: notifiers.NewWebhookNotifier(http.DefaultClient)}
+	tc.service = services.NewAlertService(tc.repo, tc.priceRepo, repositories.NewCryptocurrencyRepository(testDbInstance), assets, channels)
+	tc.handle = handlers.NewAlertHandler(tc.service)
+	tc.engine = gin.Default()
+	tc.engine.POST("/alerts", tc.handle.Create)
+}
+
+func knownAsset(name string) (bool, error) {
+	return true, nil
+}
+
+func testCase(test func(t *testing.T)) func(*testing.T) {
+	return func(t *testing.T) {
+		beforeEach()
+		test(t)
+	}
+}
+
+func deleteAll() {
+	testDbInstance.Exec("DELETE FROM alert_rule;")
+	testDbInstance.Exec("DELETE FROM cryptocurrency;")
+	testDbInstance.Exec("DELETE FROM crypto_price;")
+	testDbInstance.Exec("DELETE FROM crypto_price_history;")
+}
+
+func TestAlertService(t *testing.T) {
+	t.Run("Should create alert rule", testCase(testCreateAlertRule))
+	t.Run("Should reject alert rule without asset", testCase(testCreateAlertRuleWithoutAsset))
+	t.Run("Should store alert rule asset by its id", testCase(testCreateAlertRuleBySymbol))
+	t.Run("Should notify once when price crosses threshold", testCase(testNotifyOnceWhenPriceCrossesThreshold))
+	t.Run("Should not notify while price is under threshold", testCase(testNotNotifyUnderThreshold))
+	t.Run("Should notify when holding loses more than threshold", testCase(testNotifyProfitBelowThreshold))
+	t.Run("Should not notify profit below threshold without a price", testCase(testNotNotifyProfitBelowWithoutPrice))
+}
+
+func testCreateAlertRule(t *testing.T) {
+	var calls int32
+	webhook := newWebhookServer(&calls)
+	defer webhook.Close()
+
+	request, err := http.NewRequest(http.MethodPost, "/alerts", createAlertRuleJson(createPriceAboveRule(webhook.URL, decimal.NewFromInt(100))))
+	require.NoError(t, err)
+
+	responseRecorder := httptest.NewRecorder()
+	tc.engine.ServeHTTP(responseRecorder, request)
+
+	var rule models.AlertRule
+	err = json.NewDecoder(responseRecorder.Body).Decode(&rule)
+	require.NoError(t, err)
+	saved, err := tc.repo.GetByID(ctx, rule.ID)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, responseRecorder.Result().StatusCode)
+	require.NotNil(t, saved)
+	assert.Equal(t, "bitcoin", saved.Asset)
+	assert.False(t, saved.Triggered)
+}
+
+func testCreateAlertRuleWithoutAsset(t *testing.T) {
+	rule := createPriceAboveRule("http://localhost/hook", decimal.NewFromInt(100))
+	rule.Asset = ""
+	request, err := http.NewRequest(http.MethodPost, "/alerts", createAlertRuleJson(rule))
+	require.NoError(t, err)
+
+	responseRecorder := httptest.NewRecorder()
+	tc.engine.ServeHTTP(responseRecorder, request)
+
+	var body struct {
+		Fields map[string]string `json:"fields"`
+	}
+	err = json.NewDecoder(responseRecorder.Body).Decode(&body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, responseRecorder.Result().StatusCode)
+	assert.Contains(t, body.Fields, "asset")
+}
+
+func testCreateAlertRuleBySymbol(t *testing.T) {
+	rule := createPriceAboveRule("http://localhost/hook", decimal.NewFromInt(100))
+	rule.Asset = "BTC"
+	request, err := http.NewRequest(http.MethodPost, "/alerts", createAlertRuleJson(rule))
+	require.NoError(t, err)
+
+	responseRecorder := httptest.NewRecorder()
+	tc.engine.ServeHTTP(responseRecorder, request)
+
+	var created models.AlertRule
+	err = json.NewDecoder(responseRecorder.Body).Decode(&created)
+	require.NoError(t, err)
+	saved, err := tc.repo.GetByID(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, responseRecorder.Result().StatusCode)
+	require.NotNil(t, saved)
+	assert.Equal(t, "bitcoin", saved.Asset)
+}
+
+func testNotifyOnceWhenPriceCrossesThreshold(t *testing.T) {
+	var calls int32
+	webhook := newWebhookServer(&calls)
+	defer webhook.Close()
+
+	rule := createPriceAboveRule(webhook.URL, decimal.NewFromInt(100))
+	require.NoError(t, tc.service.Create(ctx, &rule))
+
+	prices := services.NewPriceService(tc.priceRepo, fixedPrices(map[string]decimal.Decimal{"bitcoin": decimal.NewFromInt(150)}), tc.transactor, tc.bus)
+	require.NoError(t, prices.Refresh(ctx))
+	require.NoError(t, tc.service.Evaluate(ctx))
+	require.NoError(t, tc.service.Evaluate(ctx))
+
+	saved, err := tc.repo.GetByID(ctx, rule.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.True(t, saved.Triggered)
+	assert.NotNil(t, saved.LastNotifiedAt)
+}
+
+func testNotNotifyUnderThreshold(t *testing.T) {
+	var calls int32
+	webhook := newWebhookServer(&calls)
+	defer webhook.Close()
+
+	rule := createPriceAboveRule(webhook.URL, decimal.NewFromInt(200))
+	require.NoError(t, tc.service.Create(ctx, &rule))
+
+	prices := services.NewPriceService(tc.priceRepo, fixedPrices(map[string]decimal.Decimal{"bitcoin": decimal.NewFromInt(150)}), tc.transactor, tc.bus)
+	require.NoError(t, prices.Refresh(ctx))
+	require.NoError(t, tc.service.Evaluate(ctx))
+
+	price, err := tc.priceRepo.GetByName(ctx, "bitcoin")
+	require.NoError(t, err)
+	require.NotNil(t, price)
+	assert.True(t, decimal.NewFromInt(150).Equal(price.PriceUSD))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&calls))
+}
+
+func testNotifyProfitBelowThreshold(t *testing.T) {
+	var calls int32
+	webhook := newWebhookServer(&calls)
+	defer webhook.Close()
+
+	crypto := createCryptocurrency(t, repositories.NewCryptocurrencyRepository(testDbInstance))
+	cryptoId := crypto.ID
+	rule := models.AlertRule{
+		Type:             models.AlertRuleProfitBelow,
+		CryptocurrencyId: &cryptoId,
+		Threshold:        decimal.NewFromInt(-10),
+		Channel:          models.AlertChannelWebhook,
+		Target:           webhook.URL,
+	}
+	require.NoError(t, tc.service.Create(ctx, &rule))
+
+	// bought 1 bitcoin for 60000, now worth 50000: a loss of 16.67%
+	prices := services.NewPriceService(tc.priceRepo, fixedPrices(map[string]decimal.Decimal{"bitcoin": decimal.NewFromInt(50000)}), tc.transactor, tc.bus)
+	require.NoError(t, prices.Refresh(ctx))
+	require.NoError(t, tc.service.Evaluate(ctx))
+
+	saved, err := tc.repo.GetByID(ctx, rule.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.True(t, saved.Triggered)
+}
+
+func testNotNotifyProfitBelowWithoutPrice(t *testing.T) {
+	var calls int32
+	webhook := newWebhookServer(&calls)
+	defer webhook.Close()
+
+	crypto := createCryptocurrency(t, repositories.NewCryptocurrencyRepository(testDbInstance))
+	cryptoId := crypto.ID
+	rule := models.AlertRule{
+		Type:             models.AlertRuleProfitBelow,
+		CryptocurrencyId: &cryptoId,
+		Threshold:        decimal.NewFromInt(-10),
+		Channel:          models.AlertChannelWebhook,
+		Target:           webhook.URL,
+	}
+	require.NoError(t, tc.service.Create(ctx, &rule))
+
+	require.NoError(t, tc.service.Evaluate(ctx))
+
+	saved, err := tc.repo.GetByID(ctx, rule.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&calls))
+	assert.False(t, saved.Triggered)
+}
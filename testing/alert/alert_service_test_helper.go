@@ -0,0 +1,66 @@
+package testing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"wallet-manager/models"
+	"wallet-manager/prices"
+	"wallet-manager/repositories"
+	"wallet-manager/services"
+	"wallet-manager/utils"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+var ctx = context.Background()
+
+func createAlertRuleJson(rule models.AlertRule) *bytes.Reader {
+	jsonBody, _ := json.Marshal(rule)
+	return bytes.NewReader(jsonBody)
+}
+
+func createPriceAboveRule(target string, threshold decimal.Decimal) models.AlertRule {
+	return models.AlertRule{
+		Type:      models.AlertRulePriceAbove,
+		Asset:     "bitcoin",
+		Threshold: threshold,
+		Channel:   models.AlertChannelWebhook,
+		Target:    target,
+	}
+}
+
+func createCryptocurrency(t *testing.T, cryptoRepo repositories.CryptocurrencyRepository) models.Cryptocurrency {
+	crypto := models.Cryptocurrency{
+		AssetID:     "bitcoin",
+		Name:        "Bitcoin",
+		Balance:     decimal.NewFromInt(1),
+		CostInFiat:  decimal.NewFromInt(60000),
+		CreatedDate: utils.NowFormatted(),
+	}
+	require.NoError(t, cryptoRepo.Create(ctx, &crypto))
+	return crypto
+}
+
+func fixedPrices(fixed map[string]decimal.Decimal) services.PriceFetcher {
+	return func(ctx context.Context, names []string) (map[string]prices.Price, error) {
+		quotes := make(map[string]prices.Price, len(fixed))
+		for name, price := range fixed {
+			quotes[name] = prices.Price{Value: price, Source: "test"}
+		}
+		return quotes, nil
+	}
+}
+
+// newWebhookServer counts the notifications it receives.
+func newWebhookServer(calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+}
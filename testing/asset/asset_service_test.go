@@ -0,0 +1,104 @@
+package testing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	db "wallet-manager/database"
+	"wallet-manager/handlers"
+	"wallet-manager/models"
+	"wallet-manager/repositories"
+	"wallet-manager/services"
+	helper "wallet-manager/testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testDbInstance *sqlx.DB
+var tc testContext
+
+func TestMain(m *testing.M) {
+	testDB := helper.SetupTestDatabase()
+	testDbInstance = testDB.DbInstance
+	defer testDB.TearDown()
+	beforeAll()
+	os.Exit(m.Run())
+}
+
+type testContext struct {
+	service services.AssetService
+	handle  *handlers.AssetHandler
+	engine  *gin.Engine
+}
+
+func beforeAll() {
+	tc.service = services.NewAssetService(repositories.NewAssetRepository(testDbInstance))
+	if err := tc.service.Seed(ctx, db.AssetSeed); err != nil {
+		panic(err)
+	}
+	tc.handle = handlers.NewAssetHandler(tc.service)
+	tc.engine = gin.Default()
+	tc.engine.GET("/assets", tc.handle.Search)
+	tc.engine.GET("/assets/:assetId", tc.handle.GetByID)
+}
+
+func TestAssetService(t *testing.T) {
+	t.Run("Should seed assets again without duplicating them", testSeedTwice)
+	t.Run("Should resolve asset by ID, symbol or name", testResolveAsset)
+	t.Run("Should search assets by symbol", testSearchAssets)
+	t.Run("Should map asset IDs to provider IDs", testProviderIDs)
+	t.Run("Should return 404 for unknown asset", testGetUnknownAsset)
+}
+
+func testSeedTwice(t *testing.T) {
+	var before, after int
+	require.NoError(t, testDbInstance.Get(&before, "SELECT COUNT(*) FROM asset"))
+	require.NoError(t, tc.service.Seed(ctx, db.AssetSeed))
+	require.NoError(t, testDbInstance.Get(&after, "SELECT COUNT(*) FROM asset"))
+
+	assert.Positive(t, before)
+	assert.Equal(t, before, after)
+}
+
+func testResolveAsset(t *testing.T) {
+	for _, ref := range []string{"bitcoin", "BTC", "btc", "Bitcoin"} {
+		asset, err := tc.service.Resolve(ctx, ref)
+		require.NoError(t, err)
+		assert.Equal(t, "bitcoin", asset.ID, ref)
+	}
+
+	_, err := tc.service.Resolve(ctx, "not-a-coin")
+	assert.ErrorIs(t, err, services.ErrUnknownAsset)
+}
+
+func testSearchAssets(t *testing.T) {
+	responseRecorder := httptest.NewRecorder()
+	tc.engine.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, "/assets?q=eth&limit=5", nil))
+
+	var assets []models.Asset
+	err := json.NewDecoder(responseRecorder.Body).Decode(&assets)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, responseRecorder.Result().StatusCode)
+	require.NotEmpty(t, assets)
+	assert.Equal(t, "ethereum", assets[0].ID)
+	assert.LessOrEqual(t, len(assets), 5)
+}
+
+func testProviderIDs(t *testing.T) {
+	ids, err := tc.service.ProviderIDs(ctx, "coingecko", []string{"polygon", "bitcoin", "not-a-coin"})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{"polygon": "polygon-ecosystem-token", "bitcoin": "bitcoin"}, ids)
+}
+
+func testGetUnknownAsset(t *testing.T) {
+	responseRecorder := httptest.NewRecorder()
+	tc.engine.ServeHTTP(responseRecorder, httptest.NewRequest(http.MethodGet, "/assets/not-a-coin", nil))
+
+	assert.Equal(t, http.StatusNotFound, responseRecorder.Result().StatusCode)
+}
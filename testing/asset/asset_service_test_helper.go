@@ -0,0 +1,5 @@
+package testing
+
+import "context"
+
+var ctx = context.Background()
@@ -0,0 +1,121 @@
+package testing
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"wallet-manager/chain"
+
+	"github.com/btcsuite/btcd/btcutil/base58"
+	"github.com/btcsuite/btcd/btcutil/bech32"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddressValidation(t *testing.T) {
+	t.Run("Should accept valid addresses", testValidAddresses)
+	t.Run("Should explain why addresses are invalid", testInvalidAddresses)
+	t.Run("Should take addresses of chains without a validator", testUnvalidatedChain)
+}
+
+// segwit encodes a witness program of size bytes, with Bech32m when m is set.
+func segwit(t *testing.T, hrp string, version byte, size int, m bool) string {
+	data, err := bech32.ConvertBits(make([]byte, size), 8, 5, true)
+	require.NoError(t, err)
+	data = append([]byte{version}, data...)
+	encode := bech32.Encode
+	if m {
+		encode = bech32.EncodeM
+	}
+	address, err := encode(hrp, data)
+	require.NoError(t, err)
+	return address
+}
+
+func cosmos(t *testing.T, hrp string, size int) string {
+	data, err := bech32.ConvertBits(make([]byte, size), 8, 5, true)
+	require.NoError(t, err)
+	address, err := bech32.Encode(hrp, data)
+	require.NoError(t, err)
+	return address
+}
+
+func testValidAddresses(t *testing.T) {
+	valid := map[string][]string{
+		"bitcoin": {
+			"1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN2",
+			"3J98t1WpEZ73CNmQviecrnyiWrnqRhWNLy",
+			// BIP173 and BIP350 test vectors
+			"BC1QW508D6QEJXTDG4Y5R3ZARVARY0C5XW7KV8F3T4",
+			"bc1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3qccfmv3",
+			"bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0",
+		},
+		"litecoin": {base58.CheckEncode(make([]byte, 20), 0x30), segwit(t, "ltc", 0, 20, false)},
+		"dogecoin": {base58.CheckEncode(make([]byte, 20), 0x1e)},
+		// EIP-55 test vectors, and addresses without a checksum
+		"ethereum": {
+			"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+			"0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359",
+			"0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB",
+			"0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb",
+			"0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed",
+		},
+		"polygon": {"0x52908400098527886E0F7030069857D2E4169EE7"},
+		"solana":  {"11111111111111111111111111111111", "So11111111111111111111111111111111111111112"},
+		"tron":    {"TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6t"},
+		"xrp":     {"rHb9CJAWyB4rj91VRWn96DkukG4bwdtyTh", "rrrrrrrrrrrrrrrrrrrrrhoLvTp"},
+		"cosmos":  {cosmos(t, "cosmos", 20)},
+		"aptos":   {"0x1", "0x" + strings.Repeat("a", 64)},
+		"sui":     {"0x" + strings.Repeat("0", 63) + "2"},
+	}
+	for chainName, addresses := range valid {
+		for _, address := range addresses {
+			assert.NoError(t, chain.ValidateAddress(chainName, address), "%s %s", chainName, address)
+		}
+	}
+}
+
+func testInvalidAddresses(t *testing.T) {
+	invalid := []struct {
+		chain   string
+		address string
+		reason  string
+	}{
+		{"bitcoin", "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN3", "Base58Check checksum does not match"},
+		{"bitcoin", "1BvBMSEYstWetqTFn5Au4m4GFg7xJaNVN0", `character '0' at position 34 is not in the Base58 alphabet`},
+		{"bitcoin", base58.CheckEncode(make([]byte, 20), 0x30), "unexpected version byte 0x30"},
+		{"bitcoin", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t5", "Bech32 checksum does not match"},
+		{"bitcoin", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kV8f3t4", "mixes upper and lower case"},
+		{"bitcoin", segwit(t, "bc", 1, 32, false), "version 1 addresses are encoded with Bech32m, not Bech32"},
+		{"bitcoin", segwit(t, "bc", 0, 20, true), "version 0 addresses are encoded with Bech32, not Bech32m"},
+		{"bitcoin", segwit(t, "bc", 0, 24, false), "version 0 witness program of 24 bytes, expected 20 or 32"},
+		{"litecoin", segwit(t, "bc", 0, 20, false), "expected the ltc prefix, got bc"},
+		{"dogecoin", segwit(t, "bc", 0, 20, false), "expected a Base58Check address"},
+		{"ethereum", "0x5aaeb6053F3E94C9b9A09f33669435E7Ef1BeAed", "EIP-55 checksum does not match"},
+		{"ethereum", "5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", "must start with 0x"},
+		{"ethereum", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAe", "expected 40 hex digits after 0x, got 39"},
+		{"ethereum", "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeg", `character 'g' at position 42 is not a hex digit`},
+		{"solana", "1111111111111111111111111111111", "expected a 32-byte public key, got 31 bytes"},
+		{"solana", "So1111111111111111111111111111111111111111O", `character 'O' at position 43 is not in the Base58 alphabet`},
+		{"tron", "TR7NHqjeKQxGTCi8q8ZY4pL8otSzgjLj6u", "Base58Check checksum does not match"},
+		{"xrp", "rHb9CJAWyB4rj91VRWn96DkukG4bwdtyTi", "Base58Check checksum does not match"},
+		{"xrp", "rHb9CJAWyB4rj91VRWn96DkukG4bwdtyT0", `character '0' at position 34 is not in the XRP Ledger alphabet`},
+		{"cosmos", cosmos(t, "osmo", 20), "expected the cosmos prefix"},
+		{"sui", "0x2", "expected 64 hex digits after 0x, got 1"},
+	}
+	for _, test := range invalid {
+		err := chain.ValidateAddress(test.chain, test.address)
+		require.Error(t, err, "%s %s", test.chain, test.address)
+		assert.True(t, errors.Is(err, chain.ErrInvalidAddress))
+
+		var addressErr *chain.AddressError
+		require.True(t, errors.As(err, &addressErr))
+		assert.Equal(t, test.chain, addressErr.Chain)
+		assert.Contains(t, addressErr.Reason, test.reason, "%s %s", test.chain, test.address)
+	}
+}
+
+func testUnvalidatedChain(t *testing.T) {
+	assert.NoError(t, chain.ValidateAddress("monero", "anything"))
+	assert.NoError(t, chain.ValidateAddress("", "anything"))
+}
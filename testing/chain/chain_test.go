@@ -0,0 +1,183 @@
+package testing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"wallet-manager/chain"
+	"wallet-manager/config"
+	"wallet-manager/upstream"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	btcAddress = "bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq"
+	ethAddress = "0xde709f2102306220921060314715629080e2fb77"
+)
+
+func newClient(name string) *upstream.Client {
+	return upstream.NewClient(name, &config.UpstreamConfig{Timeout: time.Second})
+}
+
+func TestIndexers(t *testing.T) {
+	t.Run("Should read an Esplora address balance", testEsploraBalance)
+	t.Run("Should net Esplora transfers across pages", testEsploraTransfers)
+	t.Run("Should read an EVM address balance", testEVMBalance)
+	t.Run("Should scan EVM blocks for transfers", testEVMTransfers)
+}
+
+func testEsploraBalance(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/address/"+btcAddress, r.URL.Path)
+		w.Write([]byte(`{"address":"` + btcAddress + `","chain_stats":{"funded_txo_sum":150000000,"spent_txo_sum":25000000},"mempool_stats":{"funded_txo_sum":1000,"spent_txo_sum":0}}`))
+	}))
+	defer server.Close()
+
+	balance, err := chain.NewEsplora(newClient("esplora"), server.URL).Balance(context.Background(), btcAddress)
+	require.NoError(t, err)
+	assert.True(t, decimal.RequireFromString("1.25").Equal(balance), balance.String())
+}
+
+// esploraTx pays received satoshis to the address and spends spent of its own.
+func esploraTx(id string, received int64, spent int64, confirmed bool) map[string]any {
+	tx := map[string]any{
+		"txid":   id,
+		"status": map[string]any{"confirmed": confirmed, "block_time": 1704067200},
+		"vin":    []any{},
+		"vout":   []any{map[string]any{"scriptpubkey_address": "bc1qother", "value": 5000}},
+	}
+	if received > 0 {
+		tx["vout"] = append(tx["vout"].([]any), map[string]any{"scriptpubkey_address": btcAddress, "value": received})
+	}
+	if spent > 0 {
+		tx["vin"] = []any{map[string]any{"prevout": map[string]any{"scriptpubkey_address": btcAddress, "value": spent}}}
+	}
+	return tx
+}
+
+func testEsploraTransfers(t *testing.T) {
+	first := []any{esploraTx("change", 30000, 100000, true), esploraTx("pending", 1000, 0, false)}
+	for i := len(first); i < 25; i++ {
+		first = append(first, esploraTx(fmt.Sprintf("unrelated%d", i), 0, 0, true))
+	}
+	second := []any{esploraTx("deposit", 100000, 0, true)}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/address/" + btcAddress + "/txs/chain":
+			json.NewEncoder(w).Encode(first)
+		case "/address/" + btcAddress + "/txs/chain/unrelated24":
+			json.NewEncoder(w).Encode(second)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	transfers, err := chain.NewEsplora(newClient("esplora"), server.URL).Transfers(context.Background(), btcAddress)
+	require.NoError(t, err)
+	require.Len(t, transfers, 2)
+	assert.Equal(t, "change", transfers[0].TxID)
+	assert.True(t, decimal.RequireFromString("-0.0007").Equal(transfers[0].Amount), transfers[0].Amount.String())
+	assert.Equal(t, "deposit", transfers[1].TxID)
+	assert.True(t, decimal.RequireFromString("0.001").Equal(transfers[1].Amount), transfers[1].Amount.String())
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), transfers[1].Time)
+}
+
+// newRPCServer stands in for a node: it answers each request of a batch with
+// the result of method, given its params.
+func newRPCServer(t *testing.T, methods map[string]func(params []json.RawMessage) any) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var requests []struct {
+			ID     int               `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&requests))
+		responses := make([]map[string]any, 0, len(requests))
+		for _, request := range requests {
+			method, ok := methods[request.Method]
+			if !ok {
+				responses = append(responses, map[string]any{"jsonrpc": "2.0", "id": request.ID, "error": map[string]any{"code": -32601, "message": "method not found"}})
+				continue
+			}
+			responses = append(responses, map[string]any{"jsonrpc": "2.0", "id": request.ID, "result": method(request.Params)})
+		}
+		json.NewEncoder(w).Encode(responses)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func testEVMBalance(t *testing.T) {
+	server := newRPCServer(t, map[string]func([]json.RawMessage) any{
+		"eth_getBalance": func(params []json.RawMessage) any {
+			assert.JSONEq(t, `"`+ethAddress+`"`, string(params[0]))
+			return "0x1bc16d674ec80000" // 2 ether
+		},
+	})
+
+	balance, err := chain.NewEVM(newClient("evm"), server.URL, 10).Balance(context.Background(), ethAddress)
+	require.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(2).Equal(balance), balance.String())
+}
+
+func testEVMTransfers(t *testing.T) {
+	upper := "0xDE709F2102306220921060314715629080E2FB77"
+	blocks := map[string]any{
+		"0x95": map[string]any{"timestamp": "0x65920080", "transactions": []any{
+			map[string]any{"hash": "0xin", "from": "0xabc", "to": upper, "value": "0xde0b6b3a7640000"},
+			map[string]any{"hash": "0xother", "from": "0xabc", "to": "0xdef", "value": "0x1"},
+		}},
+		"0x96": map[string]any{"timestamp": "0x65920090", "transactions": []any{
+			map[string]any{"hash": "0xout", "from": ethAddress, "to": "0xabc", "value": "0x6f05b59d3b20000"},
+			map[string]any{"hash": "0xfailed", "from": ethAddress, "to": "0xabc", "value": "0xde0b6b3a7640000"},
+		}},
+	}
+	receipts := map[string]any{
+		"0xin":     map[string]any{"status": "0x1", "gasUsed": "0x5208", "effectiveGasPrice": "0x3b9aca00"},
+		"0xout":    map[string]any{"status": "0x1", "gasUsed": "0x5208", "effectiveGasPrice": "0x3b9aca00"},
+		"0xfailed": map[string]any{"status": "0x0", "gasUsed": "0x5208", "effectiveGasPrice": "0x3b9aca00"},
+	}
+	var scanned []string
+	server := newRPCServer(t, map[string]func([]json.RawMessage) any{
+		"eth_blockNumber": func([]json.RawMessage) any { return "0x96" },
+		"eth_getBlockByNumber": func(params []json.RawMessage) any {
+			var number string
+			json.Unmarshal(params[0], &number)
+			scanned = append(scanned, number)
+			if block, ok := blocks[number]; ok {
+				return block
+			}
+			return map[string]any{"timestamp": "0x0", "transactions": []any{}}
+		},
+		"eth_getTransactionReceipt": func(params []json.RawMessage) any {
+			var hash string
+			json.Unmarshal(params[0], &hash)
+			return receipts[hash]
+		},
+	})
+
+	transfers, err := chain.NewEVM(newClient("evm"), server.URL, 3).Transfers(context.Background(), ethAddress)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0x94", "0x95", "0x96"}, scanned)
+
+	// 21000 gas at 1 gwei
+	fee := decimal.RequireFromString("0.000021")
+	require.Len(t, transfers, 3)
+	assert.Equal(t, "0xin", transfers[0].TxID)
+	assert.True(t, decimal.NewFromInt(1).Equal(transfers[0].Amount), transfers[0].Amount.String())
+	assert.Equal(t, time.Unix(0x65920080, 0).UTC(), transfers[0].Time)
+	assert.Equal(t, "0xout", transfers[1].TxID)
+	assert.True(t, decimal.RequireFromString("-0.5").Sub(fee).Equal(transfers[1].Amount), transfers[1].Amount.String())
+	assert.Equal(t, "0xfailed", transfers[2].TxID)
+	assert.True(t, fee.Neg().Equal(transfers[2].Amount), transfers[2].Amount.String())
+	assert.Equal(t, ethAddress, transfers[0].Address)
+}
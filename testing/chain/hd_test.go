@@ -0,0 +1,131 @@
+package testing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"wallet-manager/chain"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// account keys of the BIP39 mnemonic "abandon abandon ... about", as published
+// with BIP84 and by the common derivation tools for BIP44 and BIP49
+const (
+	bip44Key = "xpub6BosfCnifzxcFwrSzQiqu2DBVTshkCXacvNsWGYJVVhhawA7d4R5WSWGFNbi8Aw6ZRc1brxMyWMzG3DSSSSoekkudhUd9yLb6qx39T9nMdj"
+	bip49Key = "ypub6Ww3ibxVfGzLrAH1PNcjyAWenMTbbAosGNB6VvmSEgytSER9azLDWCxoJwW7Ke7icmizBMXrzBx9979FfaHxHcrArf3zbeJJJUZPf663zsP"
+	bip84Key = "zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs"
+)
+
+// usedAddresses has transfers on the addresses it holds only.
+type usedAddresses map[string]bool
+
+func (u usedAddresses) Name() string {
+	return "fixed"
+}
+
+func (u usedAddresses) Balance(ctx context.Context, address string) (decimal.Decimal, error) {
+	return decimal.Zero, nil
+}
+
+func (u usedAddresses) Transfers(ctx context.Context, address string) ([]chain.Transfer, error) {
+	if !u[address] {
+		return nil, nil
+	}
+	return []chain.Transfer{{TxID: "tx", Address: address, Amount: decimal.NewFromInt(1)}}, nil
+}
+
+func TestExtendedKeys(t *testing.T) {
+	t.Run("Should derive the published test vectors", testDeriveVectors)
+	t.Run("Should override the script type of the prefix", testScriptTypeOverride)
+	t.Run("Should reject private and unknown keys", testInvalidExtendedKeys)
+	t.Run("Should discover used addresses up to the gap limit", testDiscover)
+}
+
+func testDeriveVectors(t *testing.T) {
+	vectors := []struct {
+		key        string
+		scriptType string
+		receive    []string
+		change     string
+	}{
+		{bip44Key, chain.ScriptP2PKH, []string{"1LqBGSKuX5yYUonjxT5qGfpUsXKYYWeabA", "1Ak8PffB2meyfYnbXZR9EGfLfFZVpzJvQP"}, "1J3J6EvPrv8q6AC3VCjWV45Uf3nssNMRtH"},
+		{bip49Key, chain.ScriptP2SHP2WPKH, []string{"37VucYSaXLCAsxYyAPfbSi9eh4iEcbShgf", "3LtMnn87fqUeHBUG414p9CWwnoV6E2pNKS"}, "34K56kSjgUCUSD8GTtuF7c9Zzwokbs6uZ7"},
+		{bip84Key, chain.ScriptP2WPKH, []string{"bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu", "bc1qnjg0jd8228aq7egyzacy8cys3knf9xvrerkf9g"}, "bc1q8c6fshw2dlwun7ekn9qwf37cu2rn755upcp6el"},
+	}
+	for _, vector := range vectors {
+		key, err := chain.ParseExtendedKey(vector.key, "")
+		require.NoError(t, err)
+		assert.Equal(t, vector.scriptType, key.ScriptType)
+
+		for index, expected := range vector.receive {
+			address, err := key.Address(chain.BranchReceive, uint32(index))
+			require.NoError(t, err)
+			assert.Equal(t, expected, address)
+		}
+		change, err := key.Address(chain.BranchChange, 0)
+		require.NoError(t, err)
+		assert.Equal(t, vector.change, change)
+	}
+}
+
+func testScriptTypeOverride(t *testing.T) {
+	key, err := chain.ParseExtendedKey(bip84Key, chain.ScriptP2PKH)
+	require.NoError(t, err)
+	assert.Equal(t, chain.ScriptP2PKH, key.ScriptType)
+
+	address, err := key.Address(chain.BranchReceive, 0)
+	require.NoError(t, err)
+	assert.Equal(t, byte('1'), address[0])
+
+	_, err = chain.ParseExtendedKey(bip84Key, "p2tr")
+	assert.True(t, errors.Is(err, chain.ErrInvalidExtendedKey))
+}
+
+func testInvalidExtendedKeys(t *testing.T) {
+	for _, key := range []string{
+		// BIP32 test vector 1 master private key
+		"xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi",
+		// BIP84 account key on testnet
+		"vpub5Y6cjg78GGuNLsaPhmYsiw4gYX3HoQiRBiSwDaBXKUafCt9bNwWQiitDk5VZ5BVxYnQdwoTyXSs2JHRPAgjAvtbBrf8ZhDYe2jWAqvZVnsc",
+		"zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYt",
+		"not a key",
+	} {
+		_, err := chain.ParseExtendedKey(key, "")
+		assert.True(t, errors.Is(err, chain.ErrInvalidExtendedKey), key)
+	}
+}
+
+func testDiscover(t *testing.T) {
+	key, err := chain.ParseExtendedKey(bip84Key, "")
+	require.NoError(t, err)
+	address := func(index uint32) string {
+		address, err := key.Address(chain.BranchReceive, index)
+		require.NoError(t, err)
+		return address
+	}
+
+	indexer := usedAddresses{address(0): true, address(1): true, address(4): true, address(8): true}
+	used, next, err := chain.Discover(context.Background(), indexer, key, chain.BranchReceive, 0, 3)
+	require.NoError(t, err)
+	require.Len(t, used, 3)
+	assert.Equal(t, "0/0", used[0].Path())
+	assert.Equal(t, "0/1", used[1].Path())
+	assert.Equal(t, "0/4", used[2].Path())
+	assert.Equal(t, address(4), used[2].Address)
+	// 5, 6 and 7 are unused, so 8 is past the gap
+	assert.Equal(t, uint32(5), next)
+
+	used, next, err = chain.Discover(context.Background(), indexer, key, chain.BranchReceive, 5, 5)
+	require.NoError(t, err)
+	require.Len(t, used, 1)
+	assert.Equal(t, "0/8", used[0].Path())
+	assert.Equal(t, uint32(9), next)
+
+	used, next, err = chain.Discover(context.Background(), indexer, key, chain.BranchChange, 0, 3)
+	require.NoError(t, err)
+	assert.Empty(t, used)
+	assert.Equal(t, uint32(0), next)
+}
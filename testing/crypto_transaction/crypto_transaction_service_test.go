@@ -2,20 +2,28 @@ package testing
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
+	db "wallet-manager/database"
+	"wallet-manager/events"
 	"wallet-manager/handlers"
+	"wallet-manager/middlewares"
 	"wallet-manager/models"
 	"wallet-manager/repositories"
 	"wallet-manager/services"
 	helper "wallet-manager/testing"
+	"wallet-manager/validators"
+	"wallet-manager/webhooks"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -36,6 +44,9 @@ type testContext struct {
 	serviceCrypto services.CryptocurrencyService
 	repo          repositories.CryptoTransactionRepository
 	service       services.CryptoTransactionService
+	audit         services.AuditService
+	webhooks      services.WebhookService
+	bus           *events.Bus
 	handle        *handlers.CryptoTransactionHandler
 	engine        *gin.Engine
 }
@@ -45,18 +56,32 @@ func beforeEach() {
 }
 
 func beforeAll() {
+	validators.Register(knownAsset)
+	transactor := repositories.NewTransactor(testDbInstance)
+	tc.audit = services.NewAuditService(repositories.NewAuditRepository(testDbInstance))
+	tc.webhooks = services.NewWebhookService(repositories.NewWebhookRepository(testDbInstance), webhooks.NewSender(http.DefaultClient))
+	tc.bus = events.NewBus(repositories.NewOutboxRepository(testDbInstance), transactor)
+	tc.bus.Subscribe("webhooks", tc.webhooks.Enqueue, models.WebhookEventTypes...)
 	tc.repoCrypto = repositories.NewCryptocurrencyRepository(testDbInstance)
-	tc.serviceCrypto = services.NewCryptocurrencyService(tc.repoCrypto)
 	tc.repo = repositories.NewCryptoTransactionRepository(testDbInstance)
-	tc.service = services.NewCryptoTransactionService(tc.repo, tc.repoCrypto)
+	assets := services.NewAssetService(repositories.NewAssetRepository(testDbInstance))
+	assets.Seed(ctx, db.AssetSeed)
+	tc.serviceCrypto = services.NewCryptocurrencyService(tc.repoCrypto, tc.repo, assets, transactor, tc.audit, tc.bus)
+	tc.service = services.NewCryptoTransactionService(tc.repo, tc.serviceCrypto, assets, transactor, tc.audit, tc.bus)
 	tc.handle = handlers.NewCryptoTransactionHandler(tc.service)
 	tc.engine = gin.Default()
+	tc.engine.Use(middlewares.Actor())
+	tc.engine.Use(middlewares.Idempotency(repositories.NewIdempotencyKeyRepository(testDbInstance), time.Hour))
 	insertCryptoPrice()
 }
 
 func after() {
 }
 
+func knownAsset(name string) (bool, error) {
+	return true, nil
+}
+
 func testCase(test func(t *testing.T)) func(*testing.T) {
 	return func(t *testing.T) {
 		beforeEach()
@@ -82,7 +107,18 @@ func TestCryptocurrencyService(t *testing.T) {
 	t.Run("Should get all cryptoTransaction", testCase(testGetAllCryptoTransaction))
 	t.Run("Should find cryptoTransaction by ID", testCase(testFindCryptoTransactionById))
 	t.Run("Should delete cryptoTransaction", testCase(testDeleteCryptoTransaction))
-	// t.Run("Should update cryptoTransaction", testCase(testUpdatecryptoTransaction))
+	t.Run("Should restore deleted cryptoTransaction and its balance effect", testCase(testRestoreCryptoTransaction))
+	t.Run("Should not record a balance change for planned cryptoTransaction", testCase(testDeleteAndRestorePlannedCryptoTransaction))
+	t.Run("Should reject invalid cryptoTransaction", testCase(testCreateInvalidCryptoTransaction))
+	t.Run("Should reject address invalid on the chain of the asset", testCase(testCreateTransactionWithInvalidAddress))
+	t.Run("Should take sell out of the balance", testCase(testCreateSellTransaction))
+	t.Run("Should reject sell above the balance", testCase(testCreateSellAboveBalance))
+	t.Run("Should replay cryptoTransaction created with the same Idempotency-Key", testCase(testCreateCryptoTransactionIdempotently))
+	t.Run("Should release Idempotency-Key when the handler panics", testCase(testReleaseIdempotencyKeyOnPanic))
+	t.Run("Should audit cryptoTransaction creation and balance update", testCase(testAuditCryptoTransactionCreation))
+	t.Run("Should send signed webhook for cryptoTransaction creation", testCase(testWebhookForCryptoTransactionCreation))
+	t.Run("Should update cryptoTransaction", testCase(testUpdateCryptoTransaction))
+	t.Run("Should not update cryptoTransaction of another cryptocurrency", testCase(testUpdateCryptoTransactionOfAnotherCryptocurrency))
 }
 
 func testCreateCryptoTransaction(t *testing.T) {
@@ -102,7 +138,7 @@ func testCreateCryptoTransaction(t *testing.T) {
 
 	var transaction models.CryptoTransaction
 	err = json.NewDecoder(responseRecorder.Body).Decode(&transaction)
-	insertedTransaction, errGetById := tc.repo.GetByID(transaction.ID)
+	insertedTransaction, errGetById := tc.repo.GetByID(ctx, transaction.ID)
 
 	require.NoError(t, err)
 	require.NoError(t, errGetById)
@@ -113,42 +149,269 @@ func testCreateCryptoTransaction(t *testing.T) {
 	assert.Equal(t, expectedCreatedDate, insertedTransaction.CreatedDate)
 }
 
-// func testUpdatecryptoTransaction(t *testing.T) {
-// 	tc.engine.PUT("/cryptocurrencies/:cryptoId/transactions/:transactionId", tc.handle.Update)
-// 	server := httptest.NewServer(tc.engine)
-// 	defer server.Close()
-
-// 	toSave := createTransaction(testDbInstance)
-// 	toSave.CryptocurrencyId = createCryptocurrency(testDbInstance).ID
-// 	err := tc.repo.Create(&toSave)
-// 	require.NoError(t, err)
-
-// 	transactionToUpdate := models.CryptoTransaction{
-// 		CryptocurrencyId:     createCryptocurrency(testDbInstance).ID,
-// 		CryptocurrencyAmount: decimal.NewFromInt(130),
-// 		FiatAmount:           decimal.NewFromInt(150),
-// 		PurchaseDate:         utils.NowFormatted(),
-// 		CreatedDate:          utils.NowFormatted(),
-// 	}
-// 	transactionToUpdate.ID = toSave.ID
-
-// 	request, err := http.NewRequest(http.MethodPut, server.URL+"/cryptocurrencies"+strconv.FormatUint(uint64(toSave.CryptocurrencyId), 10)+"/transactions/"+strconv.FormatUint(uint64(toSave.ID), 10), createCryptoTransactionJson(transactionToUpdate))
-// 	require.NoError(t, err)
-
-// 	responseRecorder := httptest.NewRecorder()
-// 	tc.engine.ServeHTTP(responseRecorder, request)
-
-// 	var transaction models.CryptoTransaction
-// 	err = json.NewDecoder(responseRecorder.Body).Decode(&transaction)
-// 	updatedtransaction, errGetById := tc.repo.GetByID(toSave.ID)
-// 	require.NoError(t, err)
-// 	require.NoError(t, errGetById)
-// 	assert.Equal(t, http.StatusOK, responseRecorder.Result().StatusCode)
-// 	assert.Equal(t, transactionToUpdate.CryptocurrencyId, updatedtransaction.CryptocurrencyId)
-// 	assert.Equal(t, transactionToUpdate.CryptocurrencyAmount, updatedtransaction.CryptocurrencyAmount)
-// 	assert.Equal(t, transactionToUpdate, updatedtransaction.PurchaseDate)
-// 	assert.Equal(t, transactionToUpdate, updatedtransaction.CreatedDate)
-// }
+func testCreateInvalidCryptoTransaction(t *testing.T) {
+	server := httptest.NewServer(tc.engine)
+	defer server.Close()
+
+	cryptocurrency := createCryptocurrency(testDbInstance)
+	transactionToInsert := createTransactionWithoutCryptocurrencyId()
+	transactionToInsert.CryptocurrencyAmount = decimal.NewFromInt(-10)
+	transactionToInsert.FiatAmount = decimal.Zero
+	transactionToInsert.PurchaseDate = "yesterday"
+	request, err := http.NewRequest(http.MethodPost, server.URL+"/cryptocurrencies/"+strconv.FormatUint(uint64(cryptocurrency.ID), 10)+"/transactions", createCryptoTransactionJson(transactionToInsert))
+	require.NoError(t, err)
+
+	responseRecorder := httptest.NewRecorder()
+	tc.engine.ServeHTTP(responseRecorder, request)
+
+	var body struct {
+		Fields map[string]string `json:"fields"`
+	}
+	err = json.NewDecoder(responseRecorder.Body).Decode(&body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, responseRecorder.Result().StatusCode)
+	assert.Contains(t, body.Fields, "cryptocurrencyAmount")
+	assert.Contains(t, body.Fields, "fiatAmount")
+	assert.Contains(t, body.Fields, "purchaseDate")
+}
+
+func testCreateTransactionWithInvalidAddress(t *testing.T) {
+	server := httptest.NewServer(tc.engine)
+	defer server.Close()
+
+	cryptocurrency := createCryptocurrency(testDbInstance)
+	sell := createTransactionWithoutCryptocurrencyId()
+	sell.Type = models.TransactionTypeSell
+	sell.CryptocurrencyAmount = decimal.NewFromInt(1)
+	address := "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t5"
+	sell.Address = &address
+	request, err := http.NewRequest(http.MethodPost, server.URL+"/cryptocurrencies/"+strconv.FormatUint(uint64(cryptocurrency.ID), 10)+"/transactions", createCryptoTransactionJson(sell))
+	require.NoError(t, err)
+
+	responseRecorder := httptest.NewRecorder()
+	tc.engine.ServeHTTP(responseRecorder, request)
+
+	var body struct {
+		Fields map[string]string `json:"fields"`
+	}
+	err = json.NewDecoder(responseRecorder.Body).Decode(&body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, responseRecorder.Result().StatusCode)
+	assert.Equal(t, "invalid bitcoin address: Bech32 checksum does not match", body.Fields["address"])
+
+	transactions, err := tc.repo.GetAll(ctx, cryptocurrency.ID)
+	require.NoError(t, err)
+	require.Len(t, transactions, 1)
+	assert.Equal(t, models.TransactionTypeOpeningBalance, transactions[0].Type)
+}
+
+func testCreateSellTransaction(t *testing.T) {
+	server := httptest.NewServer(tc.engine)
+	defer server.Close()
+
+	cryptocurrency := createCryptocurrency(testDbInstance)
+	sell := createTransactionWithoutCryptocurrencyId()
+	sell.Type = models.TransactionTypeSell
+	sell.CryptocurrencyAmount = decimal.NewFromInt(4)
+	sell.FiatAmount = decimal.NewFromInt(6)
+	request, err := http.NewRequest(http.MethodPost, server.URL+"/cryptocurrencies/"+strconv.FormatUint(uint64(cryptocurrency.ID), 10)+"/transactions", createCryptoTransactionJson(sell))
+	require.NoError(t, err)
+
+	responseRecorder := httptest.NewRecorder()
+	tc.engine.ServeHTTP(responseRecorder, request)
+
+	updatedCryptocurrency, err := tc.repoCrypto.GetByID(ctx, cryptocurrency.ID)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, responseRecorder.Result().StatusCode)
+	assert.True(t, decimal.NewFromInt(6).Equal(updatedCryptocurrency.Balance))
+	assert.True(t, decimal.NewFromInt(4).Equal(updatedCryptocurrency.CostInFiat))
+}
+
+func testCreateSellAboveBalance(t *testing.T) {
+	server := httptest.NewServer(tc.engine)
+	defer server.Close()
+
+	cryptocurrency := createCryptocurrency(testDbInstance)
+	sell := createTransactionWithoutCryptocurrencyId()
+	sell.Type = models.TransactionTypeSell
+	sell.CryptocurrencyAmount = decimal.NewFromInt(11)
+	request, err := http.NewRequest(http.MethodPost, server.URL+"/cryptocurrencies/"+strconv.FormatUint(uint64(cryptocurrency.ID), 10)+"/transactions", createCryptoTransactionJson(sell))
+	require.NoError(t, err)
+
+	responseRecorder := httptest.NewRecorder()
+	tc.engine.ServeHTTP(responseRecorder, request)
+
+	transactions, err := tc.repo.GetAll(ctx, cryptocurrency.ID)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnprocessableEntity, responseRecorder.Result().StatusCode)
+	require.Len(t, transactions, 1)
+	assert.Equal(t, models.TransactionTypeOpeningBalance, transactions[0].Type)
+}
+
+func testCreateCryptoTransactionIdempotently(t *testing.T) {
+	server := httptest.NewServer(tc.engine)
+	defer server.Close()
+
+	cryptocurrency := createCryptocurrency(testDbInstance)
+	transactionToInsert := createTransactionWithoutCryptocurrencyId()
+	url := server.URL + "/cryptocurrencies/" + strconv.FormatUint(uint64(cryptocurrency.ID), 10) + "/transactions"
+
+	var responses [2]models.CryptoTransaction
+	for i := range responses {
+		request, err := http.NewRequest(http.MethodPost, url, createCryptoTransactionJson(transactionToInsert))
+		require.NoError(t, err)
+		request.Header.Set(middlewares.IdempotencyKeyHeader, "purchase-1")
+
+		responseRecorder := httptest.NewRecorder()
+		tc.engine.ServeHTTP(responseRecorder, request)
+		require.Equal(t, http.StatusCreated, responseRecorder.Result().StatusCode)
+		require.NoError(t, json.NewDecoder(responseRecorder.Body).Decode(&responses[i]))
+	}
+
+	transactions, err := tc.repo.GetAll(ctx, cryptocurrency.ID)
+	require.NoError(t, err)
+	updatedCryptocurrency, err := tc.repoCrypto.GetByID(ctx, cryptocurrency.ID)
+	require.NoError(t, err)
+	assert.Equal(t, responses[0].ID, responses[1].ID)
+	// the opening balance and the purchase
+	assert.Equal(t, 2, len(transactions))
+	assert.True(t, cryptocurrency.Balance.Add(transactionToInsert.CryptocurrencyAmount).Equal(updatedCryptocurrency.Balance))
+}
+
+func testReleaseIdempotencyKeyOnPanic(t *testing.T) {
+	tc.engine.POST("/panic", func(c *gin.Context) { panic("boom") })
+	server := httptest.NewServer(tc.engine)
+	defer server.Close()
+
+	request, err := http.NewRequest(http.MethodPost, server.URL+"/panic", strings.NewReader("{}"))
+	require.NoError(t, err)
+	request.Header.Set(middlewares.IdempotencyKeyHeader, "panic-1")
+
+	responseRecorder := httptest.NewRecorder()
+	tc.engine.ServeHTTP(responseRecorder, request)
+
+	stored, err := repositories.NewIdempotencyKeyRepository(testDbInstance).Get(ctx, "panic-1", http.MethodPost, "/panic")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, responseRecorder.Result().StatusCode)
+	assert.Nil(t, stored)
+}
+
+func testAuditCryptoTransactionCreation(t *testing.T) {
+	server := httptest.NewServer(tc.engine)
+	defer server.Close()
+
+	cryptocurrency := createCryptocurrency(testDbInstance)
+	request, err := http.NewRequest(http.MethodPost, server.URL+"/cryptocurrencies/"+strconv.FormatUint(uint64(cryptocurrency.ID), 10)+"/transactions", createCryptoTransactionJson(createTransactionWithoutCryptocurrencyId()))
+	require.NoError(t, err)
+	request.Header.Set(middlewares.ActorHeader, "alice")
+
+	responseRecorder := httptest.NewRecorder()
+	tc.engine.ServeHTTP(responseRecorder, request)
+
+	var transaction models.CryptoTransaction
+	err = json.NewDecoder(responseRecorder.Body).Decode(&transaction)
+	require.NoError(t, err)
+
+	transactionEntries, err := tc.audit.Find(ctx, models.AuditFilter{Entity: models.AuditEntityCryptoTransaction, EntityID: &transaction.ID})
+	require.NoError(t, err)
+	cryptocurrencyEntries, err := tc.audit.Find(ctx, models.AuditFilter{Entity: models.AuditEntityCryptocurrency, EntityID: &cryptocurrency.ID})
+	require.NoError(t, err)
+
+	require.Equal(t, 1, len(transactionEntries))
+	assert.Equal(t, models.AuditActionCreate, transactionEntries[0].Action)
+	assert.Equal(t, "alice", transactionEntries[0].Actor)
+	assert.Nil(t, transactionEntries[0].Before)
+	assert.NotNil(t, transactionEntries[0].After)
+	require.Equal(t, 1, len(cryptocurrencyEntries))
+	assert.Equal(t, models.AuditActionUpdateBalance, cryptocurrencyEntries[0].Action)
+	assert.NotNil(t, cryptocurrencyEntries[0].Before)
+	assert.NotNil(t, cryptocurrencyEntries[0].After)
+}
+
+func testWebhookForCryptoTransactionCreation(t *testing.T) {
+	var event string
+	var verified bool
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		event = r.Header.Get(webhooks.EventHeader)
+		verified = webhooks.Verify("secret", r.Header.Get(webhooks.TimestampHeader), body, r.Header.Get(webhooks.SignatureHeader))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	subscription := models.WebhookSubscription{URL: receiver.URL, Secret: "secret", EventTypes: []string{models.EventTransactionCreated}}
+	err := tc.webhooks.CreateSubscription(ctx, &subscription)
+	require.NoError(t, err)
+	defer tc.webhooks.DeleteSubscription(ctx, subscription.ID)
+
+	transaction := createTransaction(testDbInstance)
+	err = tc.service.Create(ctx, &transaction)
+	require.NoError(t, err)
+	err = tc.bus.Dispatch(ctx)
+	require.NoError(t, err)
+	err = tc.webhooks.Deliver(ctx)
+	require.NoError(t, err)
+
+	deliveries, err := tc.webhooks.GetDeliveries(ctx, subscription.ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(deliveries))
+	assert.Equal(t, models.WebhookDeliveryDelivered, deliveries[0].Status)
+	assert.Equal(t, models.EventTransactionCreated, event)
+	assert.True(t, verified)
+}
+
+func testUpdateCryptoTransaction(t *testing.T) {
+	tc.engine.PUT("/cryptocurrencies/:cryptoId/transactions/:transactionId", tc.handle.Update)
+	server := httptest.NewServer(tc.engine)
+	defer server.Close()
+
+	toSave := createTransaction(testDbInstance)
+	err := tc.repo.Create(ctx, &toSave)
+	require.NoError(t, err)
+
+	transactionToUpdate := createTransactionWithoutCryptocurrencyId()
+	transactionToUpdate.CryptocurrencyAmount = decimal.NewFromInt(130)
+	transactionToUpdate.FiatAmount = decimal.NewFromInt(150)
+
+	request, err := http.NewRequest(http.MethodPut, server.URL+"/cryptocurrencies/"+strconv.FormatUint(uint64(toSave.CryptocurrencyId), 10)+"/transactions/"+strconv.FormatUint(uint64(toSave.ID), 10), createCryptoTransactionJson(transactionToUpdate))
+	require.NoError(t, err)
+	request.Header.Set("If-Match", ifMatch(toSave.Version))
+
+	responseRecorder := httptest.NewRecorder()
+	tc.engine.ServeHTTP(responseRecorder, request)
+
+	updatedTransaction, err := tc.repo.GetByID(ctx, toSave.ID)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, responseRecorder.Result().StatusCode)
+	assert.Equal(t, toSave.CryptocurrencyId, updatedTransaction.CryptocurrencyId)
+	assert.True(t, transactionToUpdate.CryptocurrencyAmount.Equal(updatedTransaction.CryptocurrencyAmount))
+	assert.True(t, transactionToUpdate.FiatAmount.Equal(updatedTransaction.FiatAmount))
+}
+
+func testUpdateCryptoTransactionOfAnotherCryptocurrency(t *testing.T) {
+	server := httptest.NewServer(tc.engine)
+	defer server.Close()
+
+	toSave := createTransaction(testDbInstance)
+	err := tc.repo.Create(ctx, &toSave)
+	require.NoError(t, err)
+
+	transactionToUpdate := createTransactionWithoutCryptocurrencyId()
+	transactionToUpdate.CryptocurrencyAmount = decimal.NewFromInt(130)
+
+	otherCryptoId := strconv.FormatUint(uint64(toSave.CryptocurrencyId+1), 10)
+	request, err := http.NewRequest(http.MethodPut, server.URL+"/cryptocurrencies/"+otherCryptoId+"/transactions/"+strconv.FormatUint(uint64(toSave.ID), 10), createCryptoTransactionJson(transactionToUpdate))
+	require.NoError(t, err)
+	request.Header.Set("If-Match", ifMatch(toSave.Version))
+
+	responseRecorder := httptest.NewRecorder()
+	tc.engine.ServeHTTP(responseRecorder, request)
+
+	unchangedTransaction, err := tc.repo.GetByID(ctx, toSave.ID)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, responseRecorder.Result().StatusCode)
+	assert.True(t, toSave.CryptocurrencyAmount.Equal(unchangedTransaction.CryptocurrencyAmount))
+}
 
 func testDeleteCryptoTransaction(t *testing.T) {
 	tc.engine.DELETE("/cryptocurrencies/:cryptoId/transactions/:transactionId", tc.handle.Delete)
@@ -157,27 +420,67 @@ func testDeleteCryptoTransaction(t *testing.T) {
 	defer server.Close()
 
 	toDelete := createTransaction(testDbInstance)
-	err := tc.repo.Create(&toDelete)
+	err := tc.repo.Create(ctx, &toDelete)
 	require.NoError(t, err)
 
 	idToDelete := strconv.FormatUint(uint64(toDelete.ID), 10)
 	request, err := http.NewRequest(http.MethodDelete, server.URL+"/cryptocurrencies/"+strconv.FormatUint(uint64(toDelete.CryptocurrencyId), 10)+"/transactions/"+idToDelete, nil)
 	require.NoError(t, err)
+	request.Header.Set("If-Match", ifMatch(toDelete.Version))
 
 	responseRecorder := httptest.NewRecorder()
 	tc.engine.ServeHTTP(responseRecorder, request)
 
 	assert.Equal(t, http.StatusNoContent, responseRecorder.Result().StatusCode)
 	exist := true
-	testDbInstance.Get(&exist, "select exists(select * from cryptocurrency where cryptocurrency_id = $1)", idToDelete)
+	testDbInstance.Get(&exist, "select exists(select * from crypto_transaction where transaction_id = $1 and deleted_at is null)", idToDelete)
 	assert.False(t, exist)
 }
 
+func testDeleteAndRestorePlannedCryptoTransaction(t *testing.T) {
+	planned := createTransaction(testDbInstance)
+	planned.Status = models.TransactionStatusPlanned
+	err := tc.repo.Create(ctx, &planned)
+	require.NoError(t, err)
+
+	require.NoError(t, tc.service.Delete(ctx, planned.CryptocurrencyId, planned.ID, planned.Version))
+	_, err = tc.service.Restore(ctx, planned.CryptocurrencyId, planned.ID)
+	require.NoError(t, err)
+
+	cryptocurrencyEntries, err := tc.audit.Find(ctx, models.AuditFilter{Entity: models.AuditEntityCryptocurrency, EntityID: &planned.CryptocurrencyId})
+	require.NoError(t, err)
+	assert.Empty(t, cryptocurrencyEntries)
+}
+
+func testRestoreCryptoTransaction(t *testing.T) {
+	tc.engine.POST("/cryptocurrencies/:cryptoId/transactions/:transactionId/restore", tc.handle.Restore)
+
+	server := httptest.NewServer(tc.engine)
+	defer server.Close()
+
+	toRestore := createTransaction(testDbInstance)
+	err := tc.service.Create(ctx, &toRestore)
+	require.NoError(t, err)
+	err = tc.service.Delete(ctx, toRestore.CryptocurrencyId, toRestore.ID, toRestore.Version)
+	require.NoError(t, err)
+
+	request, err := http.NewRequest(http.MethodPost, server.URL+"/cryptocurrencies/"+strconv.FormatUint(uint64(toRestore.CryptocurrencyId), 10)+"/transactions/"+strconv.FormatUint(uint64(toRestore.ID), 10)+"/restore", nil)
+	require.NoError(t, err)
+
+	responseRecorder := httptest.NewRecorder()
+	tc.engine.ServeHTTP(responseRecorder, request)
+
+	crypto, err := tc.repoCrypto.GetByID(ctx, toRestore.CryptocurrencyId)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, responseRecorder.Result().StatusCode)
+	assert.True(t, decimal.NewFromInt(20).Equal(crypto.Balance))
+}
+
 func testGetAllCryptoTransaction(t *testing.T) {
 	transaction := createTransaction(testDbInstance)
-	tc.repo.Create(&transaction)
-	tc.repo.Create(&transaction)
-	tc.repo.Create(&transaction)
+	tc.repo.Create(ctx, &transaction)
+	tc.repo.Create(ctx, &transaction)
+	tc.repo.Create(ctx, &transaction)
 
 	tc.engine.GET("cryptocurrencies/:cryptoId/transactions", tc.handle.GetAll)
 
@@ -194,7 +497,8 @@ func testGetAllCryptoTransaction(t *testing.T) {
 	err = json.NewDecoder(responseRecorder.Body).Decode(&cryptos)
 	require.NoError(t, err)
 	assert.Equal(t, http.StatusOK, responseRecorder.Result().StatusCode)
-	assert.Equal(t, 3, len(cryptos))
+	// the opening balance and the three purchases
+	assert.Equal(t, 4, len(cryptos))
 }
 
 func testFindCryptoTransactionById(t *testing.T) {
@@ -204,7 +508,7 @@ func testFindCryptoTransactionById(t *testing.T) {
 	defer server.Close()
 
 	toFind := createTransaction(testDbInstance)
-	err := tc.repo.Create(&toFind)
+	err := tc.repo.Create(ctx, &toFind)
 	require.NoError(t, err)
 	expectedPurchaseDate := toFind.PurchaseDate[:strings.LastIndex(toFind.PurchaseDate, "-")] + "Z"
 	expectedCreatedDate := toFind.CreatedDate[:strings.LastIndex(toFind.CreatedDate, "-")] + "Z"
@@ -2,7 +2,9 @@ package testing
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"strconv"
 	"wallet-manager/models"
 	"wallet-manager/repositories"
 	"wallet-manager/utils"
@@ -11,6 +13,8 @@ import (
 	"github.com/shopspring/decimal"
 )
 
+var ctx = context.Background()
+
 func createCryptoTransactionJson(transaction models.CryptoTransaction) *bytes.Reader {
 	jsonBody, _ := json.Marshal(transaction)
 	return bytes.NewReader(jsonBody)
@@ -19,17 +23,19 @@ func createCryptoTransactionJson(transaction models.CryptoTransaction) *bytes.Re
 func createCryptocurrency(testDbInstance *sqlx.DB) models.Cryptocurrency {
 	cryptocurrencyRepository := repositories.NewCryptocurrencyRepository(testDbInstance)
 	crypto := models.Cryptocurrency{
-		Name:        "bitcoin",
+		AssetID:     "bitcoin",
 		Balance:     decimal.NewFromInt(10),
 		CostInFiat:  decimal.NewFromInt(10),
 		CreatedDate: utils.NowFormatted(),
 	}
-	cryptocurrencyRepository.Create(&crypto)
+	cryptocurrencyRepository.Create(ctx, &crypto)
 	return crypto
 }
 
 func createTransactionWithoutCryptocurrencyId() models.CryptoTransaction {
 	return models.CryptoTransaction{
+		Type:                 models.TransactionTypeBuy,
+		Status:               models.TransactionStatusConfirmed,
 		CryptocurrencyAmount: decimal.NewFromInt(10),
 		FiatAmount:           decimal.NewFromInt(10),
 		PurchaseDate:         utils.NowFormatted(),
@@ -40,9 +46,15 @@ func createTransactionWithoutCryptocurrencyId() models.CryptoTransaction {
 func createTransaction(testDbInstance *sqlx.DB) models.CryptoTransaction {
 	return models.CryptoTransaction{
 		CryptocurrencyId:     createCryptocurrency(testDbInstance).ID,
+		Type:                 models.TransactionTypeBuy,
+		Status:               models.TransactionStatusConfirmed,
 		CryptocurrencyAmount: decimal.NewFromInt(10),
 		FiatAmount:           decimal.NewFromInt(10),
 		PurchaseDate:         utils.NowFormatted(),
 		CreatedDate:          utils.NowFormatted(),
 	}
 }
+
+func ifMatch(version uint32) string {
+	return strconv.Quote(strconv.FormatUint(uint64(version), 10))
+}
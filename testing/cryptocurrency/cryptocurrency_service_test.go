@@ -8,12 +8,15 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	db "wallet-manager/database"
+	"wallet-manager/events"
 	"wallet-manager/handlers"
 	"wallet-manager/models"
 	"wallet-manager/repositories"
 	"wallet-manager/services"
 	helper "wallet-manager/testing"
 	"wallet-manager/utils"
+	"wallet-manager/validators"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
@@ -34,10 +37,11 @@ func TestMain(m *testing.M) {
 }
 
 type testContext struct {
-	repo    repositories.CryptocurrencyRepository
-	service services.CryptocurrencyService
-	handle  *handlers.CryptocurrencyHandler
-	engine  *gin.Engine
+	repo            repositories.CryptocurrencyRepository
+	transactionRepo repositories.CryptoTransactionRepository
+	service         services.CryptocurrencyService
+	handle          *handlers.CryptocurrencyHandler
+	engine          *gin.Engine
 }
 
 func beforeEach() {
@@ -45,8 +49,12 @@ func beforeEach() {
 }
 
 func beforeAll() {
+	validators.Register(knownAsset)
 	tc.repo = repositories.NewCryptocurrencyRepository(testDbInstance)
-	tc.service = services.NewCryptocurrencyService(tc.repo)
+	tc.transactionRepo = repositories.NewCryptoTransactionRepository(testDbInstance)
+	assets := services.NewAssetService(repositories.NewAssetRepository(testDbInstance))
+	assets.Seed(ctx, db.AssetSeed)
+	tc.service = services.NewCryptocurrencyService(tc.repo, tc.transactionRepo, assets, repositories.NewTransactor(testDbInstance), services.NewAuditService(repositories.NewAuditRepository(testDbInstance)), events.NewBus(repositories.NewOutboxRepository(testDbInstance), repositories.NewTransactor(testDbInstance)))
 	tc.handle = handlers.NewCryptocurrencyHandler(tc.service)
 	tc.engine = gin.Default()
 	insertCryptoPrice()
@@ -55,6 +63,10 @@ func beforeAll() {
 func after() {
 }
 
+func knownAsset(name string) (bool, error) {
+	return true, nil
+}
+
 func testCase(test func(t *testing.T)) func(*testing.T) {
 	return func(t *testing.T) {
 		beforeEach()
@@ -77,11 +89,21 @@ func deleteAllCryptoPrice() {
 
 func TestCryptocurrencyService(t *testing.T) {
 	t.Run("Should create cryptocurrency", testCase(testCreateCryptocurrency))
+	t.Run("Should record initial balance as opening balance transaction", testCase(testCreateCryptocurrencyWithOpeningBalance))
 	t.Run("Should get all cryptocurrency", testCase(testGetAllCryptocurrencies))
 	t.Run("Should find cryptocurrency by ID", testCase(testFindCryptocurrencyById))
 	t.Run("Should delete cryptocurrency", testCase(testDeleteCryptocurrency))
+	t.Run("Should restore deleted cryptocurrency", testCase(testRestoreCryptocurrency))
 	t.Run("Should update cryptocurrency", testCase(testUpdateCryptocurrency))
 	t.Run("Should find cryptocurrency when there is no crypto price for crypto name", testCase(testFindCryptocurrencyWithoutCryptoPrice))
+	t.Run("Should reject invalid cryptocurrency", testCase(testCreateInvalidCryptocurrency))
+	t.Run("Should reject duplicated cryptocurrency name", testCase(testCreateDuplicatedCryptocurrency))
+	t.Run("Should create several cryptocurrencies of the same asset", testCase(testCreateCryptocurrenciesOfSameAsset))
+	t.Run("Should resolve asset symbol to asset ID", testCase(testCreateCryptocurrencyBySymbol))
+	t.Run("Should reject unknown asset", testCase(testCreateCryptocurrencyWithUnknownAsset))
+	t.Run("Should patch only supplied cryptocurrency fields", testCase(testPatchCryptocurrency))
+	t.Run("Should reject update with stale version", testCase(testUpdateCryptocurrencyWithStaleVersion))
+	t.Run("Should require If-Match to update cryptocurrency", testCase(testUpdateCryptocurrencyWithoutIfMatch))
 	// t.Run("Should return cryptocurrency with profitPercentage", testCase(testFindCryptocurrencyWhihoutCryptoPrice))
 }
 
@@ -100,41 +122,68 @@ func testCreateCryptocurrency(t *testing.T) {
 
 	var crypto models.Cryptocurrency
 	err = json.NewDecoder(responseRecorder.Body).Decode(&crypto)
-	savedCrypto, errGetById := tc.repo.GetByID(crypto.ID)
+	savedCrypto, errGetById := tc.repo.GetByID(ctx, crypto.ID)
 	require.NoError(t, err)
 	require.NoError(t, errGetById)
 	assert.Equal(t, http.StatusCreated, responseRecorder.Result().StatusCode)
-	assert.Equal(t, strings.ToLower(cryptoToSave.Name), savedCrypto.Name)
+	assert.Equal(t, cryptoToSave.AssetID, savedCrypto.AssetID)
+	assert.Equal(t, "Bitcoin", savedCrypto.Name)
 	// assert.Equal(t, cryptoToSave.Balance, savedCrypto.Balance)
 	// assert.Equal(t, cryptoToSave.CostInFiat, savedCrypto.CostInFiat)
 }
 
+func testCreateCryptocurrencyWithOpeningBalance(t *testing.T) {
+	server := httptest.NewServer(tc.engine)
+	defer server.Close()
+
+	request, err := http.NewRequest(http.MethodPost, server.URL+"/cryptocurrencies", createCryptocurrencyJson(createCryptocurrency()))
+	require.NoError(t, err)
+
+	responseRecorder := httptest.NewRecorder()
+	tc.engine.ServeHTTP(responseRecorder, request)
+
+	var crypto models.Cryptocurrency
+	require.NoError(t, json.NewDecoder(responseRecorder.Body).Decode(&crypto))
+	transactions, err := tc.transactionRepo.GetAll(ctx, crypto.ID)
+	require.NoError(t, err)
+	savedCrypto, err := tc.repo.GetByID(ctx, crypto.ID)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, responseRecorder.Result().StatusCode)
+	require.Len(t, transactions, 1)
+	assert.Equal(t, models.TransactionTypeOpeningBalance, transactions[0].Type)
+	assert.True(t, decimal.NewFromInt(1).Equal(transactions[0].CryptocurrencyAmount))
+	assert.True(t, decimal.NewFromInt(60000).Equal(transactions[0].FiatAmount))
+	assert.True(t, decimal.NewFromInt(1).Equal(savedCrypto.Balance))
+	assert.True(t, decimal.NewFromInt(60000).Equal(savedCrypto.CostInFiat))
+}
+
 func testUpdateCryptocurrency(t *testing.T) {
-	tc.engine.PUT("/cryptocurrencies", tc.handle.Update)
+	tc.engine.PUT("/cryptocurrencies/:cryptoId", tc.handle.Update)
 	server := httptest.NewServer(tc.engine)
 	defer server.Close()
 
 	toSave := createCryptocurrency()
-	err := tc.repo.Create(&toSave)
+	err := tc.repo.Create(ctx, &toSave)
 	require.NoError(t, err)
 
-	cryptoToUpdate := createCryptoWithParamaters("Litecoin", decimal.NewFromInt(10), decimal.NewFromInt(70000))
+	cryptoToUpdate := createCryptoWithParamaters("litecoin", decimal.NewFromInt(10), decimal.NewFromInt(70000))
 	cryptoToUpdate.ID = toSave.ID
 
-	request, err := http.NewRequest(http.MethodPut, server.URL+"/cryptocurrencies", createCryptocurrencyJson(cryptoToUpdate))
+	request, err := http.NewRequest(http.MethodPut, server.URL+"/cryptocurrencies/"+strconv.FormatUint(uint64(toSave.ID), 10), createCryptocurrencyJson(cryptoToUpdate))
 	require.NoError(t, err)
+	request.Header.Set("If-Match", ifMatch(toSave.Version))
 
 	responseRecorder := httptest.NewRecorder()
 	tc.engine.ServeHTTP(responseRecorder, request)
 
 	var crypto models.Cryptocurrency
 	err = json.NewDecoder(responseRecorder.Body).Decode(&crypto)
-	updatedCrypto, errGetById := tc.repo.GetByID(crypto.ID)
+	updatedCrypto, errGetById := tc.repo.GetByID(ctx, crypto.ID)
 	require.NoError(t, err)
 	require.NoError(t, errGetById)
 	assert.Equal(t, http.StatusOK, responseRecorder.Result().StatusCode)
-	assert.Equal(t, strings.ToLower(cryptoToUpdate.Name), updatedCrypto.Name)
-	assert.NotEqual(t, toSave.Name, updatedCrypto.Name)
+	assert.Equal(t, cryptoToUpdate.AssetID, updatedCrypto.AssetID)
+	assert.NotEqual(t, toSave.AssetID, updatedCrypto.AssetID)
 	// assert.Equal(t, toSave.Balance, updatedCrypto.Balance)
 	// assert.Equal(t, toSave.CostInFiat, updatedCrypto.CostInFiat)
 }
@@ -146,27 +195,53 @@ func testDeleteCryptocurrency(t *testing.T) {
 	defer server.Close()
 
 	toDelete := createCryptocurrency()
-	err := tc.repo.Create(&toDelete)
+	err := tc.repo.Create(ctx, &toDelete)
 	require.NoError(t, err)
 
 	idToDelete := strconv.FormatUint(uint64(toDelete.ID), 10)
 	request, err := http.NewRequest(http.MethodDelete, server.URL+"/cryptocurrencies/"+idToDelete, nil)
 	require.NoError(t, err)
+	request.Header.Set("If-Match", ifMatch(toDelete.Version))
 
 	responseRecorder := httptest.NewRecorder()
 	tc.engine.ServeHTTP(responseRecorder, request)
 
 	assert.Equal(t, http.StatusNoContent, responseRecorder.Result().StatusCode)
 	exist := true
-	testDbInstance.Get(&exist, "select exists(select * from cryptocurrency where cryptocurrency_id = $1)", idToDelete)
+	testDbInstance.Get(&exist, "select exists(select * from cryptocurrency where cryptocurrency_id = $1 and deleted_at is null)", idToDelete)
 	assert.False(t, exist)
 }
 
+func testRestoreCryptocurrency(t *testing.T) {
+	tc.engine.POST("/cryptocurrencies/:cryptoId/restore", tc.handle.Restore)
+
+	server := httptest.NewServer(tc.engine)
+	defer server.Close()
+
+	toRestore := createCryptocurrency()
+	err := tc.repo.Create(ctx, &toRestore)
+	require.NoError(t, err)
+	err = tc.service.Delete(ctx, toRestore.ID, toRestore.Version)
+	require.NoError(t, err)
+
+	request, err := http.NewRequest(http.MethodPost, server.URL+"/cryptocurrencies/"+strconv.FormatUint(uint64(toRestore.ID), 10)+"/restore", nil)
+	require.NoError(t, err)
+
+	responseRecorder := httptest.NewRecorder()
+	tc.engine.ServeHTTP(responseRecorder, request)
+
+	restoredCrypto, err := tc.repo.GetByID(ctx, toRestore.ID)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, responseRecorder.Result().StatusCode)
+	require.NotNil(t, restoredCrypto)
+	assert.True(t, toRestore.Balance.Equal(restoredCrypto.Balance))
+}
+
 func testGetAllCryptocurrencies(t *testing.T) {
-	crypto := models.Cryptocurrency{Name: "Bitcoin", Balance: decimal.NewFromInt(1), CostInFiat: decimal.NewFromInt(60000), CreatedDate: utils.NowFormatted()}
-	tc.repo.Create(&crypto)
-	tc.repo.Create(&crypto)
-	tc.repo.Create(&crypto)
+	for _, name := range []string{"Exchange", "Cold wallet", "Hot wallet"} {
+		crypto := models.Cryptocurrency{AssetID: "bitcoin", Name: name, Balance: decimal.NewFromInt(1), CostInFiat: decimal.NewFromInt(60000), CreatedDate: utils.NowFormatted()}
+		tc.repo.Create(ctx, &crypto)
+	}
 
 	tc.engine.GET("/cryptocurrencies", tc.handle.GetAll)
 
@@ -193,7 +268,7 @@ func testFindCryptocurrencyById(t *testing.T) {
 	defer server.Close()
 
 	toFind := createCryptocurrency()
-	err := tc.repo.Create(&toFind)
+	err := tc.repo.Create(ctx, &toFind)
 	require.NoError(t, err)
 
 	idToFind := strconv.FormatUint(uint64(toFind.ID), 10)
@@ -208,7 +283,7 @@ func testFindCryptocurrencyById(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, http.StatusOK, responseRecorder.Result().StatusCode)
 	assert.Equal(t, toFind.ID, crypto.ID)
-	assert.Equal(t, strings.ToLower(toFind.Name), crypto.Name, crypto.Name)
+	assert.Equal(t, toFind.AssetID, crypto.AssetID)
 }
 
 func testFindCryptocurrencyWithoutCryptoPrice(t *testing.T) {
@@ -217,8 +292,8 @@ func testFindCryptocurrencyWithoutCryptoPrice(t *testing.T) {
 
 	deleteAllCryptoPrice()
 	toFind := createCryptocurrency()
-	toFind.Name = "CryptoWithoutPrice"
-	err := tc.repo.Create(&toFind)
+	toFind.AssetID = "ethereum"
+	err := tc.repo.Create(ctx, &toFind)
 	require.NoError(t, err)
 
 	idToFind := strconv.FormatUint(uint64(toFind.ID), 10)
@@ -238,5 +313,175 @@ func testFindCryptocurrencyWithoutCryptoPrice(t *testing.T) {
 	assert.False(t, existCryptoPrice)
 	assert.Equal(t, http.StatusOK, responseRecorder.Result().StatusCode)
 	assert.Equal(t, toFind.ID, crypto.ID)
-	assert.Equal(t, strings.ToLower(toFind.Name), crypto.Name, crypto.Name)
+	assert.Equal(t, toFind.AssetID, crypto.AssetID)
+}
+
+func testCreateInvalidCryptocurrency(t *testing.T) {
+	server := httptest.NewServer(tc.engine)
+	defer server.Close()
+
+	cryptoToSave := createCryptoWithParamaters("", decimal.NewFromInt(-1), decimal.NewFromInt(60000))
+
+	request, err := http.NewRequest(http.MethodPost, server.URL+"/cryptocurrencies", createCryptocurrencyJson(cryptoToSave))
+	require.NoError(t, err)
+
+	responseRecorder := httptest.NewRecorder()
+	tc.engine.ServeHTTP(responseRecorder, request)
+
+	var body struct {
+		Fields map[string]string `json:"fields"`
+	}
+	err = json.NewDecoder(responseRecorder.Body).Decode(&body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, responseRecorder.Result().StatusCode)
+	assert.Contains(t, body.Fields, "assetId")
+	assert.Contains(t, body.Fields, "balance")
+}
+
+func testCreateDuplicatedCryptocurrency(t *testing.T) {
+	server := httptest.NewServer(tc.engine)
+	defer server.Close()
+
+	existing := createCryptocurrency()
+	existing.Name = "Cold wallet"
+	err := tc.repo.Create(ctx, &existing)
+	require.NoError(t, err)
+
+	duplicated := createCryptoWithParamaters("ethereum", decimal.NewFromInt(1), decimal.NewFromInt(3000))
+	duplicated.Name = "Cold wallet"
+	request, err := http.NewRequest(http.MethodPost, server.URL+"/cryptocurrencies", createCryptocurrencyJson(duplicated))
+	require.NoError(t, err)
+
+	responseRecorder := httptest.NewRecorder()
+	tc.engine.ServeHTTP(responseRecorder, request)
+
+	assert.Equal(t, http.StatusConflict, responseRecorder.Result().StatusCode)
+}
+
+func testCreateCryptocurrenciesOfSameAsset(t *testing.T) {
+	server := httptest.NewServer(tc.engine)
+	defer server.Close()
+
+	existing := createCryptocurrency()
+	existing.Name = "Exchange"
+	err := tc.repo.Create(ctx, &existing)
+	require.NoError(t, err)
+
+	coldWallet := createCryptocurrency()
+	coldWallet.Name = "Cold wallet"
+	request, err := http.NewRequest(http.MethodPost, server.URL+"/cryptocurrencies", createCryptocurrencyJson(coldWallet))
+	require.NoError(t, err)
+
+	responseRecorder := httptest.NewRecorder()
+	tc.engine.ServeHTTP(responseRecorder, request)
+
+	cryptos, err := tc.repo.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, responseRecorder.Result().StatusCode)
+	assert.Equal(t, 2, len(cryptos))
+}
+
+func testCreateCryptocurrencyBySymbol(t *testing.T) {
+	server := httptest.NewServer(tc.engine)
+	defer server.Close()
+
+	cryptoToSave := createCryptoWithParamaters("POL", decimal.NewFromInt(1), decimal.NewFromInt(1))
+	cryptoToSave.Name = "Cold wallet"
+
+	request, err := http.NewRequest(http.MethodPost, server.URL+"/cryptocurrencies", createCryptocurrencyJson(cryptoToSave))
+	require.NoError(t, err)
+
+	responseRecorder := httptest.NewRecorder()
+	tc.engine.ServeHTTP(responseRecorder, request)
+
+	var crypto models.Cryptocurrency
+	err = json.NewDecoder(responseRecorder.Body).Decode(&crypto)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, responseRecorder.Result().StatusCode)
+	assert.Equal(t, "polygon", crypto.AssetID)
+	assert.Equal(t, "Cold wallet", crypto.Name)
+}
+
+func testCreateCryptocurrencyWithUnknownAsset(t *testing.T) {
+	server := httptest.NewServer(tc.engine)
+	defer server.Close()
+
+	request, err := http.NewRequest(http.MethodPost, server.URL+"/cryptocurrencies", createCryptocurrencyJson(createCryptoWithParamaters("not-a-coin", decimal.NewFromInt(1), decimal.NewFromInt(1))))
+	require.NoError(t, err)
+
+	responseRecorder := httptest.NewRecorder()
+	tc.engine.ServeHTTP(responseRecorder, request)
+
+	assert.Equal(t, http.StatusBadRequest, responseRecorder.Result().StatusCode)
+}
+
+func testPatchCryptocurrency(t *testing.T) {
+	tc.engine.PATCH("/cryptocurrencies/:cryptoId", tc.handle.Patch)
+	server := httptest.NewServer(tc.engine)
+	defer server.Close()
+
+	toSave := createCryptocurrency()
+	err := tc.repo.Create(ctx, &toSave)
+	require.NoError(t, err)
+	saved, err := tc.repo.GetByID(ctx, toSave.ID)
+	require.NoError(t, err)
+
+	request, err := http.NewRequest(http.MethodPatch, server.URL+"/cryptocurrencies/"+strconv.FormatUint(uint64(toSave.ID), 10), strings.NewReader(`{"name": "Cold wallet", "balance": 2}`))
+	require.NoError(t, err)
+	request.Header.Set("If-Match", ifMatch(toSave.Version))
+	request.Header.Set("Content-Type", "application/merge-patch+json")
+
+	responseRecorder := httptest.NewRecorder()
+	tc.engine.ServeHTTP(responseRecorder, request)
+
+	patched, err := tc.repo.GetByID(ctx, toSave.ID)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, responseRecorder.Result().StatusCode)
+	assert.Equal(t, "Cold wallet", patched.Name)
+	// balances only change through transactions
+	assert.True(t, saved.Balance.Equal(patched.Balance))
+	assert.True(t, saved.CostInFiat.Equal(patched.CostInFiat))
+	assert.Equal(t, saved.CreatedDate, patched.CreatedDate)
+}
+
+func testUpdateCryptocurrencyWithStaleVersion(t *testing.T) {
+	server := httptest.NewServer(tc.engine)
+	defer server.Close()
+
+	toSave := createCryptocurrency()
+	err := tc.repo.Create(ctx, &toSave)
+	require.NoError(t, err)
+	staleVersion := toSave.Version
+	err = tc.repo.Update(ctx, &toSave)
+	require.NoError(t, err)
+
+	cryptoToUpdate := createCryptoWithParamaters("litecoin", decimal.NewFromInt(10), decimal.NewFromInt(70000))
+	request, err := http.NewRequest(http.MethodPut, server.URL+"/cryptocurrencies/"+strconv.FormatUint(uint64(toSave.ID), 10), createCryptocurrencyJson(cryptoToUpdate))
+	require.NoError(t, err)
+	request.Header.Set("If-Match", ifMatch(staleVersion))
+
+	responseRecorder := httptest.NewRecorder()
+	tc.engine.ServeHTTP(responseRecorder, request)
+
+	unchangedCrypto, err := tc.repo.GetByID(ctx, toSave.ID)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusPreconditionFailed, responseRecorder.Result().StatusCode)
+	assert.Equal(t, toSave.AssetID, unchangedCrypto.AssetID)
+}
+
+func testUpdateCryptocurrencyWithoutIfMatch(t *testing.T) {
+	server := httptest.NewServer(tc.engine)
+	defer server.Close()
+
+	toSave := createCryptocurrency()
+	err := tc.repo.Create(ctx, &toSave)
+	require.NoError(t, err)
+
+	request, err := http.NewRequest(http.MethodPut, server.URL+"/cryptocurrencies/"+strconv.FormatUint(uint64(toSave.ID), 10), createCryptocurrencyJson(toSave))
+	require.NoError(t, err)
+
+	responseRecorder := httptest.NewRecorder()
+	tc.engine.ServeHTTP(responseRecorder, request)
+
+	assert.Equal(t, http.StatusPreconditionRequired, responseRecorder.Result().StatusCode)
 }
@@ -2,27 +2,35 @@ package testing
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"strconv"
 	"wallet-manager/models"
 	"wallet-manager/utils"
 
 	"github.com/shopspring/decimal"
 )
 
+var ctx = context.Background()
+
 func createCryptocurrencyJson(crypto models.Cryptocurrency) *bytes.Reader {
 	jsonBody, _ := json.Marshal(crypto)
 	return bytes.NewReader(jsonBody)
 }
 
 func createCryptocurrency() models.Cryptocurrency {
-	return createCryptoWithParamaters("Bitcoin", decimal.NewFromInt(1), decimal.NewFromInt(60000))
+	return createCryptoWithParamaters("bitcoin", decimal.NewFromInt(1), decimal.NewFromInt(60000))
 }
 
-func createCryptoWithParamaters(name string, balance decimal.Decimal, costInFiat decimal.Decimal) models.Cryptocurrency {
+func createCryptoWithParamaters(asset string, balance decimal.Decimal, costInFiat decimal.Decimal) models.Cryptocurrency {
 	return models.Cryptocurrency{
-		Name:        name,
+		AssetID:     asset,
 		Balance:     balance,
 		CostInFiat:  costInFiat,
 		CreatedDate: utils.NowFormatted(),
 	}
 }
+
+func ifMatch(version uint32) string {
+	return strconv.Quote(strconv.FormatUint(uint64(version), 10))
+}
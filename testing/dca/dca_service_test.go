@@ -0,0 +1,246 @@
+package testing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+	db "wallet-manager/database"
+	"wallet-manager/events"
+	"wallet-manager/handlers"
+	"wallet-manager/models"
+	"wallet-manager/repositories"
+	"wallet-manager/services"
+	helper "wallet-manager/testing"
+	"wallet-manager/validators"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testDbInstance *sqlx.DB
+var tc testContext
+
+func TestMain(m *testing.M) {
+	testDB := helper.SetupTestDatabase()
+	testDbInstance = testDB.DbInstance
+	defer testDB.TearDown()
+	beforeAll()
+	os.Exit(m.Run())
+}
+
+type testContext struct {
+	cryptoRepo      repositories.CryptocurrencyRepository
+	transactionRepo repositories.CryptoTransactionRepository
+	service         services.DCAService
+	handle          *handlers.DCAHandler
+	engine          *gin.Engine
+}
+
+func beforeEach() {
+	deleteAll()
+}
+
+func beforeAll() {
+	validators.Register(knownAsset)
+	transactor := repositories.NewTransactor(testDbInstance)
+	audit := services.NewAuditService(repositories.NewAuditRepository(testDbInstance))
+	bus := events.NewBus(repositories.NewOutboxRepository(testDbInstance), transactor)
+	assets := services.NewAssetService(repositories.NewAssetRepository(testDbInstance))
+	assets.Seed(ctx, db.AssetSeed)
+	tc.cryptoRepo = repositories.NewCryptocurrencyRepository(testDbInstance)
+	tc.transactionRepo = repositories.NewCryptoTransactionRepository(testDbInstance)
+	cryptoService := services.NewCryptocurrencyService(tc.cryptoRepo, tc.transactionRepo, assets, transactor, audit, bus)
+	transactionService := services.NewCryptoTransactionService(tc.transactionRepo, cryptoService, assets, transactor, audit, bus)
+	tc.service = services.NewDCAService(repositories.NewDCAPlanRepository(testDbInstance), tc.cryptoRepo, tc.transactionRepo,
+		repositories.NewCryptoPriceRepository(testDbInstance), transactionService, transactor)
+	tc.handle = handlers.NewDCAHandler(tc.service)
+	tc.engine = gin.Default()
+	tc.engine.POST("/dca-plans", tc.handle.Create)
+	tc.engine.GET("/dca-plans/:planId/executions", tc.handle.Executions)
+	tc.engine.POST("/dca-plans/:planId/skip-next", tc.handle.SkipNext)
+	tc.engine.POST("/dca-plans/:planId/executions/:transactionId/confirm", tc.handle.Confirm)
+	tc.engine.POST("/dca-plans/:planId/executions/:transactionId/skip", tc.handle.Skip)
+}
+
+func knownAsset(name string) (bool, error) {
+	return true, nil
+}
+
+func testCase(test func(t *testing.T)) func(*testing.T) {
+	return func(t *testing.T) {
+		beforeEach()
+		test(t)
+	}
+}
+
+func deleteAll() {
+	testDbInstance.Exec("DELETE FROM cryptocurrency;")
+	testDbInstance.Exec("DELETE FROM crypto_price_history;")
+}
+
+func TestDCAService(t *testing.T) {
+	t.Run("Should create plan with its first run", testCase(testCreatePlan))
+	t.Run("Should reject plan with invalid schedule", testCase(testCreatePlanWithInvalidSchedule))
+	t.Run("Should add planned transactions for due runs", testCase(testRunCreatesPlannedTransactions))
+	t.Run("Should catch up with a past start date over several ticks", testCase(testRunCatchesUpOverTicks))
+	t.Run("Should apply confirmed execution to the balance", testCase(testConfirmExecution))
+	t.Run("Should leave skipped execution out of the balance", testCase(testSkipExecution))
+	t.Run("Should list planned and upcoming executions", testCase(testListExecutions))
+	t.Run("Should skip the next scheduled run", testCase(testSkipNextRun))
+}
+
+func testCreatePlan(t *testing.T) {
+	crypto := createCryptocurrency(tc.cryptoRepo)
+	start := time.Date(2030, time.January, 1, 12, 0, 0, 0, time.UTC)
+	plan := models.DCAPlan{CryptocurrencyId: crypto.ID, FiatAmount: decimal.NewFromInt(50), Schedule: "0 9 * * MON", StartDate: start}
+	request, err := http.NewRequest(http.MethodPost, "/dca-plans", createPlanJson(plan))
+	require.NoError(t, err)
+
+	responseRecorder := httptest.NewRecorder()
+	tc.engine.ServeHTTP(responseRecorder, request)
+
+	var created models.DCAPlan
+	require.NoError(t, json.NewDecoder(responseRecorder.Body).Decode(&created))
+	assert.Equal(t, http.StatusCreated, responseRecorder.Result().StatusCode)
+	require.NotNil(t, created.NextRun)
+	assert.Equal(t, time.Date(2030, time.January, 7, 9, 0, 0, 0, time.UTC), created.NextRun.UTC())
+}
+
+func testCreatePlanWithInvalidSchedule(t *testing.T) {
+	crypto := createCryptocurrency(tc.cryptoRepo)
+	plan := models.DCAPlan{CryptocurrencyId: crypto.ID, FiatAmount: decimal.NewFromInt(50), Schedule: "every monday", StartDate: time.Now()}
+	request, err := http.NewRequest(http.MethodPost, "/dca-plans", createPlanJson(plan))
+	require.NoError(t, err)
+
+	responseRecorder := httptest.NewRecorder()
+	tc.engine.ServeHTTP(responseRecorder, request)
+
+	assert.Equal(t, http.StatusBadRequest, responseRecorder.Result().StatusCode)
+}
+
+func testRunCreatesPlannedTransactions(t *testing.T) {
+	crypto := createCryptocurrency(tc.cryptoRepo)
+	insertPriceHistory(testDbInstance, 50, time.Now().UTC().AddDate(0, 0, -10))
+	plan := createDailyPlan(tc.service, crypto.ID, 2)
+
+	require.NoError(t, tc.service.Run(ctx))
+	require.NoError(t, tc.service.Run(ctx))
+
+	transactions, err := tc.transactionRepo.GetPlanned(ctx, plan.ID)
+	require.NoError(t, err)
+	holding, err := tc.cryptoRepo.GetByID(ctx, crypto.ID)
+	require.NoError(t, err)
+	require.Len(t, transactions, 3)
+	assert.True(t, decimal.NewFromInt(2).Equal(transactions[0].CryptocurrencyAmount))
+	assert.Equal(t, models.TransactionStatusPlanned, transactions[0].Status)
+	assert.True(t, holding.Balance.IsZero())
+}
+
+func testRunCatchesUpOverTicks(t *testing.T) {
+	crypto := createCryptocurrency(tc.cryptoRepo)
+	insertPriceHistory(testDbInstance, 50, time.Now().UTC().AddDate(0, 0, -60))
+	plan := createDailyPlan(tc.service, crypto.ID, 40)
+
+	require.NoError(t, tc.service.Run(ctx))
+	firstTick, err := tc.transactionRepo.GetPlanned(ctx, plan.ID)
+	require.NoError(t, err)
+	require.NoError(t, tc.service.Run(ctx))
+	secondTick, err := tc.transactionRepo.GetPlanned(ctx, plan.ID)
+	require.NoError(t, err)
+
+	assert.Len(t, firstTick, 31)
+	assert.Len(t, secondTick, 41)
+}
+
+func testConfirmExecution(t *testing.T) {
+	crypto := createCryptocurrency(tc.cryptoRepo)
+	insertPriceHistory(testDbInstance, 50, time.Now().UTC().AddDate(0, 0, -10))
+	plan := createDailyPlan(tc.service, crypto.ID, 0)
+	require.NoError(t, tc.service.Run(ctx))
+	transactions, err := tc.transactionRepo.GetPlanned(ctx, plan.ID)
+	require.NoError(t, err)
+	require.Len(t, transactions, 1)
+	url := planURL(plan, "/executions/"+strconv.FormatUint(uint64(transactions[0].ID), 10)+"/confirm")
+
+	var statuses [2]int
+	for i := range statuses {
+		request, err := http.NewRequest(http.MethodPost, url, nil)
+		require.NoError(t, err)
+		responseRecorder := httptest.NewRecorder()
+		tc.engine.ServeHTTP(responseRecorder, request)
+		statuses[i] = responseRecorder.Result().StatusCode
+	}
+
+	holding, err := tc.cryptoRepo.GetByID(ctx, crypto.ID)
+	require.NoError(t, err)
+	assert.Equal(t, [2]int{http.StatusOK, http.StatusConflict}, statuses)
+	assert.True(t, decimal.NewFromInt(2).Equal(holding.Balance))
+	assert.True(t, decimal.NewFromInt(100).Equal(holding.CostInFiat))
+}
+
+func testSkipExecution(t *testing.T) {
+	crypto := createCryptocurrency(tc.cryptoRepo)
+	insertPriceHistory(testDbInstance, 50, time.Now().UTC().AddDate(0, 0, -10))
+	plan := createDailyPlan(tc.service, crypto.ID, 0)
+	require.NoError(t, tc.service.Run(ctx))
+	transactions, err := tc.transactionRepo.GetPlanned(ctx, plan.ID)
+	require.NoError(t, err)
+	require.Len(t, transactions, 1)
+	request, err := http.NewRequest(http.MethodPost, planURL(plan, "/executions/"+strconv.FormatUint(uint64(transactions[0].ID), 10)+"/skip"), nil)
+	require.NoError(t, err)
+
+	responseRecorder := httptest.NewRecorder()
+	tc.engine.ServeHTTP(responseRecorder, request)
+
+	var skipped models.CryptoTransaction
+	require.NoError(t, json.NewDecoder(responseRecorder.Body).Decode(&skipped))
+	holding, err := tc.cryptoRepo.GetByID(ctx, crypto.ID)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, responseRecorder.Result().StatusCode)
+	assert.Equal(t, models.TransactionStatusSkipped, skipped.Status)
+	assert.True(t, holding.Balance.IsZero())
+}
+
+func testListExecutions(t *testing.T) {
+	crypto := createCryptocurrency(tc.cryptoRepo)
+	insertPriceHistory(testDbInstance, 50, time.Now().UTC().AddDate(0, 0, -10))
+	plan := createDailyPlan(tc.service, crypto.ID, 1)
+	require.NoError(t, tc.service.Run(ctx))
+	request, err := http.NewRequest(http.MethodGet, planURL(plan, "/executions?limit=3"), nil)
+	require.NoError(t, err)
+
+	responseRecorder := httptest.NewRecorder()
+	tc.engine.ServeHTTP(responseRecorder, request)
+
+	var executions []models.DCAExecution
+	require.NoError(t, json.NewDecoder(responseRecorder.Body).Decode(&executions))
+	assert.Equal(t, http.StatusOK, responseRecorder.Result().StatusCode)
+	require.Len(t, executions, 5)
+	assert.Equal(t, models.DCAExecutionPlanned, executions[0].Status)
+	assert.NotNil(t, executions[0].Transaction)
+	assert.Equal(t, models.DCAExecutionScheduled, executions[2].Status)
+	assert.Equal(t, 24*time.Hour, executions[3].ScheduledAt.Sub(executions[2].ScheduledAt))
+}
+
+func testSkipNextRun(t *testing.T) {
+	crypto := createCryptocurrency(tc.cryptoRepo)
+	plan := createDailyPlan(tc.service, crypto.ID, -1)
+	request, err := http.NewRequest(http.MethodPost, planURL(plan, "/skip-next"), nil)
+	require.NoError(t, err)
+
+	responseRecorder := httptest.NewRecorder()
+	tc.engine.ServeHTTP(responseRecorder, request)
+
+	var updated models.DCAPlan
+	require.NoError(t, json.NewDecoder(responseRecorder.Body).Decode(&updated))
+	assert.Equal(t, http.StatusOK, responseRecorder.Result().StatusCode)
+	require.NotNil(t, updated.NextRun)
+	assert.Equal(t, plan.NextRun.AddDate(0, 0, 1), updated.NextRun.UTC())
+}
@@ -0,0 +1,57 @@
+package testing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+	"wallet-manager/models"
+	"wallet-manager/repositories"
+	"wallet-manager/services"
+	"wallet-manager/utils"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/shopspring/decimal"
+)
+
+var ctx = context.Background()
+
+func createPlanJson(plan models.DCAPlan) *bytes.Reader {
+	jsonBody, _ := json.Marshal(plan)
+	return bytes.NewReader(jsonBody)
+}
+
+func createCryptocurrency(cryptoRepo repositories.CryptocurrencyRepository) models.Cryptocurrency {
+	crypto := models.Cryptocurrency{
+		AssetID:     "bitcoin",
+		Balance:     decimal.Zero,
+		CostInFiat:  decimal.Zero,
+		CreatedDate: utils.NowFormatted(),
+	}
+	cryptoRepo.Create(ctx, &crypto)
+	return crypto
+}
+
+// createDailyPlan buys 100 of the holding every day at midnight, starting
+// days ago, so the scheduler has days+1 runs to catch up with.
+func createDailyPlan(service services.DCAService, cryptoId uint32, days int) models.DCAPlan {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	plan := models.DCAPlan{
+		CryptocurrencyId: cryptoId,
+		FiatAmount:       decimal.NewFromInt(100),
+		Schedule:         "0 0 * * *",
+		StartDate:        today.AddDate(0, 0, -days),
+	}
+	service.Create(ctx, &plan)
+	return plan
+}
+
+// insertPriceHistory records the price of bitcoin at the given time.
+func insertPriceHistory(testDbInstance *sqlx.DB, price int64, at time.Time) {
+	testDbInstance.Exec("INSERT INTO crypto_price_history (name, price_usd, recorded_at) VALUES ('bitcoin', $1, $2);", price, at)
+}
+
+func planURL(plan models.DCAPlan, path string) string {
+	return "/dca-plans/" + strconv.FormatUint(uint64(plan.ID), 10) + path
+}
@@ -0,0 +1,126 @@
+package testing
+
+import (
+	"context"
+	"os"
+	"testing"
+	"wallet-manager/events"
+	"wallet-manager/models"
+	"wallet-manager/repositories"
+	helper "wallet-manager/testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testDbInstance *sqlx.DB
+var tc testContext
+
+func TestMain(m *testing.M) {
+	testDB := helper.SetupTestDatabase()
+	testDbInstance = testDB.DbInstance
+	defer testDB.TearDown()
+	beforeAll()
+	os.Exit(m.Run())
+}
+
+type testContext struct {
+	repo       repositories.OutboxRepository
+	transactor repositories.Transactor
+}
+
+func beforeEach() {
+	deleteAll()
+}
+
+func beforeAll() {
+	tc.repo = repositories.NewOutboxRepository(testDbInstance)
+	tc.transactor = repositories.NewTransactor(testDbInstance)
+}
+
+func testCase(test func(t *testing.T)) func(*testing.T) {
+	return func(t *testing.T) {
+		beforeEach()
+		test(t)
+	}
+}
+
+func deleteAll() {
+	testDbInstance.Exec("DELETE FROM outbox_event;")
+}
+
+func TestEventBus(t *testing.T) {
+	t.Run("Should dispatch published event to subscriber", testCase(testDispatchPublishedEvent))
+	t.Run("Should not dispatch event of rolled back transaction", testCase(testNotDispatchRolledBackEvent))
+	t.Run("Should retry only the failed subscriber", testCase(testRetryFailedSubscriber))
+}
+
+func testDispatchPublishedEvent(t *testing.T) {
+	bus := events.NewBus(tc.repo, tc.transactor)
+	subscriber := &recorder{}
+	bus.Subscribe("recorder", subscriber.handle, models.EventPriceUpdated)
+
+	published := createPriceUpdated()
+	err := bus.Publish(ctx, published)
+	require.NoError(t, err)
+	err = bus.Dispatch(ctx)
+	require.NoError(t, err)
+	err = bus.Dispatch(ctx)
+	require.NoError(t, err)
+
+	require.Equal(t, 1, len(subscriber.received))
+	received, ok := subscriber.received[0].(*events.PriceUpdated)
+	require.True(t, ok)
+	assert.Equal(t, published.Name, received.Name)
+	assert.True(t, published.PriceUSD.Equal(received.PriceUSD))
+}
+
+func testNotDispatchRolledBackEvent(t *testing.T) {
+	bus := events.NewBus(tc.repo, tc.transactor)
+	subscriber := &recorder{}
+	bus.Subscribe("recorder", subscriber.handle, models.EventPriceUpdated)
+
+	err := tc.transactor.WithinTransaction(ctx, func(ctx context.Context) error {
+		if err := bus.Publish(ctx, createPriceUpdated()); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	require.ErrorIs(t, err, errRollback)
+	err = bus.Dispatch(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, 0, len(subscriber.received))
+}
+
+func testRetryFailedSubscriber(t *testing.T) {
+	bus := events.NewBus(tc.repo, tc.transactor)
+	healthy := &recorder{}
+	flaky := &recorder{failures: 1}
+	bus.Subscribe("healthy", healthy.handle, models.EventPriceUpdated)
+	bus.Subscribe("flaky", flaky.handle, models.EventPriceUpdated)
+
+	err := bus.Publish(ctx, createPriceUpdated())
+	require.NoError(t, err)
+	err = bus.Dispatch(ctx)
+	require.NoError(t, err)
+
+	var pending models.OutboxEvent
+	err = testDbInstance.Get(&pending, "SELECT * FROM outbox_event")
+	require.NoError(t, err)
+	assert.Nil(t, pending.DispatchedAt)
+	assert.Equal(t, []string{"healthy"}, []string(pending.HandledBy))
+
+	// skip the backoff
+	testDbInstance.Exec("UPDATE outbox_event SET next_attempt_at = NOW() - INTERVAL '1 second'")
+	err = bus.Dispatch(ctx)
+	require.NoError(t, err)
+
+	var dispatched models.OutboxEvent
+	err = testDbInstance.Get(&dispatched, "SELECT * FROM outbox_event")
+	require.NoError(t, err)
+	assert.NotNil(t, dispatched.DispatchedAt)
+	assert.Equal(t, 1, len(healthy.received))
+	assert.Equal(t, 2, len(flaky.received))
+}
@@ -0,0 +1,32 @@
+package testing
+
+import (
+	"context"
+	"errors"
+	"wallet-manager/events"
+
+	"github.com/shopspring/decimal"
+)
+
+var ctx = context.Background()
+
+var errRollback = errors.New("rollback")
+
+func createPriceUpdated() events.PriceUpdated {
+	return events.PriceUpdated{Name: "bitcoin", PriceUSD: decimal.NewFromInt(60000)}
+}
+
+// recorder is a subscriber that keeps what it receives and fails the first
+// failures calls.
+type recorder struct {
+	received []events.Event
+	failures int
+}
+
+func (r *recorder) handle(ctx context.Context, event events.Event) error {
+	r.received = append(r.received, event)
+	if len(r.received) <= r.failures {
+		return errors.New("subscriber unavailable")
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package testing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"wallet-manager/config"
+	"wallet-manager/notifiers"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ctx = context.Background()
+
+func createNotification() notifiers.Notification {
+	return notifiers.Notification{
+		RuleID:      1,
+		Type:        "price_above",
+		Asset:       "bitcoin",
+		Threshold:   decimal.NewFromInt(100000),
+		Value:       decimal.NewFromInt(100500),
+		Message:     "bitcoin is above 100000 USD at 100500 USD",
+		TriggeredAt: "2024-01-02T15:04:05Z",
+	}
+}
+
+func TestNotifiers(t *testing.T) {
+	t.Run("Should post notification to webhook", testWebhookNotifier)
+	t.Run("Should fail when webhook does not accept notification", testWebhookNotifierRejected)
+	t.Run("Should send notification by email", testEmailNotifier)
+}
+
+func testWebhookNotifier(t *testing.T) {
+	var received notifiers.Notification
+	var contentType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	notification := createNotification()
+	err := notifiers.NewWebhookNotifier(server.Client()).Notify(ctx, server.URL, notification)
+
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", contentType)
+	assert.Equal(t, notification.RuleID, received.RuleID)
+	assert.Equal(t, notification.Message, received.Message)
+	assert.True(t, notification.Value.Equal(received.Value))
+}
+
+func testWebhookNotifierRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	err := notifiers.NewWebhookNotifier(server.Client()).Notify(ctx, server.URL, createNotification())
+
+	assert.Error(t, err)
+}
+
+func testEmailNotifier(t *testing.T) {
+	server, err := newFakeSMTPServer()
+	require.NoError(t, err)
+	defer server.Close()
+
+	notification := createNotification()
+	notifier := notifiers.NewEmailNotifier(&config.SMTPConfig{Addr: server.Addr(), From: "alerts@example.com"})
+	err = notifier.Notify(ctx, "me@example.com", notification)
+	require.NoError(t, err)
+
+	select {
+	case mail := <-server.mails:
+		assert.Contains(t, mail.from, "alerts@example.com")
+		require.Len(t, mail.to, 1)
+		assert.Contains(t, mail.to[0], "me@example.com")
+		assert.Contains(t, mail.data, "Subject: Wallet alert: "+notification.Message)
+		assert.Contains(t, mail.data, "Value: 100500")
+	case <-time.After(5 * time.Second):
+		t.Fatal("no mail received")
+	}
+}
@@ -0,0 +1,83 @@
+package testing
+
+import (
+	"io"
+	"net"
+	"net/textproto"
+	"strings"
+)
+
+// fakeSMTPServer accepts a single mail and hands it over on mails.
+type fakeSMTPServer struct {
+	listener net.Listener
+	mails    chan fakeMail
+}
+
+type fakeMail struct {
+	from string
+	to   []string
+	data string
+}
+
+func newFakeSMTPServer() (*fakeSMTPServer, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	server := &fakeSMTPServer{listener: listener, mails: make(chan fakeMail, 1)}
+	go server.serve()
+	return server, nil
+}
+
+func (s *fakeSMTPServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *fakeSMTPServer) Close() {
+	s.listener.Close()
+}
+
+func (s *fakeSMTPServer) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	text := textproto.NewConn(conn)
+	reply := func(line string) { text.PrintfLine("%s", line) }
+
+	var mail fakeMail
+	reply("220 localhost fake SMTP")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch command {
+		case "EHLO", "HELO":
+			reply("250 localhost")
+		case "MAIL":
+			mail.from = line
+			reply("250 OK")
+		case "RCPT":
+			mail.to = append(mail.to, line)
+			reply("250 OK")
+		case "DATA":
+			reply("354 go ahead")
+			data, err := io.ReadAll(text.DotReader())
+			if err != nil {
+				return
+			}
+			mail.data = string(data)
+			reply("250 OK")
+			s.mails <- mail
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package testing
+
+import (
+	"testing"
+	"time"
+	"wallet-manager/portfolio"
+	"wallet-manager/prices"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBenchmark(t *testing.T) {
+	t.Run("Should put every buy into the benchmark", testBenchmarkBuys)
+	t.Run("Should take sell proceeds out of the benchmark", testBenchmarkSells)
+	t.Run("Should parse benchmark baskets", testParseBasket)
+	t.Run("Should reject unknown interval", testBenchmarkUnknownInterval)
+}
+
+func date(value string) time.Time {
+	d, _ := time.Parse(time.DateOnly, value)
+	return d
+}
+
+func series(points ...string) prices.Series {
+	var s prices.Series
+	for i := 0; i < len(points); i += 2 {
+		s = append(s, prices.Point{Time: date(points[i]), Price: decimal.RequireFromString(points[i+1])})
+	}
+	return s
+}
+
+func history() map[string]prices.Series {
+	return map[string]prices.Series{
+		"bitcoin":  series("2024-01-01", "40000", "2024-02-01", "50000", "2024-03-01", "60000"),
+		"ethereum": series("2024-01-01", "2000", "2024-02-01", "2000", "2024-03-01", "4000"),
+	}
+}
+
+func flow(on string, asset string, amount, fiat string) portfolio.CashFlow {
+	return portfolio.CashFlow{Date: date(on), AssetID: asset, Amount: decimal.RequireFromString(amount), Fiat: decimal.RequireFromString(fiat)}
+}
+
+func testBenchmarkBuys(t *testing.T) {
+	flows := []portfolio.CashFlow{
+		flow("2024-01-01", "ethereum", "1", "2000"),
+		flow("2024-02-01", "ethereum", "1", "2000"),
+	}
+	against := []portfolio.Target{target("bitcoin", 100)}
+
+	benchmark, err := portfolio.Compare(flows, history(), against, portfolio.IntervalMonth, date("2024-03-01"))
+
+	require.NoError(t, err)
+	require.Len(t, benchmark.Series, 3)
+	assert.True(t, decimal.NewFromInt(4000).Equal(benchmark.Series[1].Portfolio))
+	assert.True(t, decimal.NewFromInt(4500).Equal(benchmark.Series[1].Benchmark))
+	assert.True(t, decimal.NewFromInt(4000).Equal(benchmark.Series[1].NetInvested))
+	assert.True(t, decimal.NewFromInt(8000).Equal(benchmark.Portfolio.Value))
+	assert.True(t, decimal.NewFromInt(100).Equal(benchmark.Portfolio.Return))
+	assert.True(t, decimal.NewFromInt(5400).Equal(benchmark.Benchmark.Value))
+	assert.True(t, decimal.NewFromInt(35).Equal(benchmark.Benchmark.Return))
+	assert.True(t, decimal.NewFromInt(65).Equal(benchmark.RelativePerformance))
+}
+
+func testBenchmarkSells(t *testing.T) {
+	flows := []portfolio.CashFlow{
+		flow("2024-01-01", "bitcoin", "1", "40000"),
+		flow("2024-02-01", "bitcoin", "-0.5", "-25000"),
+	}
+	against := []portfolio.Target{target("ethereum", 100)}
+
+	benchmark, err := portfolio.Compare(flows, history(), against, portfolio.IntervalMonth, date("2024-03-01"))
+
+	require.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(25000).Equal(benchmark.Withdrawn))
+	assert.True(t, decimal.NewFromInt(30000).Equal(benchmark.Portfolio.Value))
+	assert.True(t, decimal.NewFromInt(15000).Equal(benchmark.Portfolio.Gain))
+	assert.True(t, decimal.NewFromInt(30000).Equal(benchmark.Benchmark.Value))
+	assert.True(t, decimal.RequireFromString("37.5").Equal(benchmark.Benchmark.Return))
+}
+
+func testParseBasket(t *testing.T) {
+	basket, err := portfolio.ParseBasket("bitcoin:60, ethereum:40")
+	require.NoError(t, err)
+	assert.Equal(t, []portfolio.Target{target("bitcoin", 60), target("ethereum", 40)}, basket)
+
+	basket, err = portfolio.ParseBasket("bitcoin,ethereum")
+	require.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(50).Equal(basket[1].Percentage))
+
+	_, err = portfolio.ParseBasket("bitcoin:60,ethereum")
+	assert.ErrorIs(t, err, portfolio.ErrInvalidBasket)
+	_, err = portfolio.ParseBasket("bitcoin:60,ethereum:30")
+	assert.ErrorIs(t, err, portfolio.ErrInvalidBasket)
+}
+
+func testBenchmarkUnknownInterval(t *testing.T) {
+	_, err := portfolio.Compare(nil, history(), []portfolio.Target{target("bitcoin", 100)}, "hour", date("2024-03-01"))
+
+	assert.ErrorIs(t, err, portfolio.ErrUnknownInterval)
+}
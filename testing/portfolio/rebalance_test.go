@@ -0,0 +1,113 @@
+package testing
+
+import (
+	"testing"
+	"wallet-manager/portfolio"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRebalance(t *testing.T) {
+	t.Run("Should leave a portfolio within the band alone", testRebalanceWithinBand)
+	t.Run("Should trade back to the targets once out of the band", testRebalanceOutOfBand)
+	t.Run("Should sell assets without a target when others has none", testRebalanceUntargeted)
+	t.Run("Should buy targets not held yet", testRebalanceNotHeld)
+}
+
+func position(id uint32, asset string, amount, price string) portfolio.Position {
+	return portfolio.Position{
+		AssetID:          asset,
+		CryptocurrencyID: id,
+		Amount:           decimal.RequireFromString(amount),
+		Price:            decimal.RequireFromString(price),
+		Decimals:         8,
+	}
+}
+
+func target(asset string, percentage int64) portfolio.Target {
+	return portfolio.Target{AssetID: asset, Percentage: decimal.NewFromInt(percentage)}
+}
+
+func sixtyThirtyTen() []portfolio.Target {
+	return []portfolio.Target{target("bitcoin", 60), target("ethereum", 30), target(portfolio.Others, 10)}
+}
+
+func testRebalanceWithinBand(t *testing.T) {
+	positions := []portfolio.Position{
+		position(1, "bitcoin", "1", "60000"),
+		position(2, "ethereum", "10", "2900"),
+		position(3, "solana", "100", "110"),
+	}
+
+	rebalance := portfolio.Plan(positions, sixtyThirtyTen(), decimal.NewFromInt(5))
+
+	assert.True(t, rebalance.Balanced)
+	assert.Empty(t, rebalance.Trades)
+	require.Len(t, rebalance.Allocations, 3)
+	assert.Equal(t, portfolio.Others, rebalance.Allocations[2].AssetID)
+	assert.True(t, decimal.NewFromInt(11).Equal(rebalance.Allocations[2].Weight))
+	assert.True(t, decimal.NewFromInt(-1).Equal(rebalance.Allocations[1].Drift))
+}
+
+func testRebalanceOutOfBand(t *testing.T) {
+	positions := []portfolio.Position{
+		position(1, "bitcoin", "1", "70000"),
+		position(2, "ethereum", "10", "2000"),
+		position(3, "solana", "100", "100"),
+	}
+
+	rebalance := portfolio.Plan(positions, sixtyThirtyTen(), decimal.NewFromInt(5))
+
+	assert.False(t, rebalance.Balanced)
+	assert.True(t, rebalance.Allocations[0].OutOfBand)
+	assert.True(t, rebalance.Allocations[1].OutOfBand)
+	require.Len(t, rebalance.Trades, 2)
+	sell, buy := rebalance.Trades[0], rebalance.Trades[1]
+	assert.Equal(t, portfolio.ActionSell, sell.Action)
+	assert.Equal(t, uint32(1), sell.CryptocurrencyID)
+	assert.True(t, decimal.NewFromInt(10000).Equal(sell.Value))
+	assert.Equal(t, "0.14285714", sell.Quantity.String())
+	assert.Equal(t, portfolio.ActionBuy, buy.Action)
+	assert.Equal(t, "ethereum", buy.AssetID)
+	assert.True(t, decimal.NewFromInt(5).Equal(*buy.Quantity))
+}
+
+func testRebalanceUntargeted(t *testing.T) {
+	positions := []portfolio.Position{
+		position(1, "bitcoin", "1", "50000"),
+		position(2, "ethereum", "10", "3000"),
+		position(3, "dogecoin", "1000", "20"),
+	}
+	targets := []portfolio.Target{target("bitcoin", 50), target("ethereum", 50)}
+
+	rebalance := portfolio.Plan(positions, targets, decimal.NewFromInt(5))
+
+	require.Len(t, rebalance.Trades, 2)
+	sell := rebalance.Trades[0]
+	assert.Equal(t, "dogecoin", sell.AssetID)
+	assert.Equal(t, portfolio.ActionSell, sell.Action)
+	assert.True(t, decimal.NewFromInt(1000).Equal(*sell.Quantity))
+	assert.Equal(t, "ethereum", rebalance.Trades[1].AssetID)
+	assert.True(t, decimal.NewFromInt(20000).Equal(rebalance.Trades[1].Value))
+}
+
+func testRebalanceNotHeld(t *testing.T) {
+	positions := []portfolio.Position{
+		position(1, "bitcoin", "1", "100"),
+		position(0, "ethereum", "0", "10"),
+		position(0, "solana", "0", "0"),
+	}
+	targets := []portfolio.Target{target("bitcoin", 50), target("ethereum", 30), target("solana", 20)}
+
+	rebalance := portfolio.Plan(positions, targets, decimal.NewFromInt(5))
+
+	require.Len(t, rebalance.Trades, 3)
+	eth, sol := rebalance.Trades[1], rebalance.Trades[2]
+	assert.Equal(t, "ethereum", eth.AssetID)
+	assert.True(t, decimal.NewFromInt(3).Equal(*eth.Quantity))
+	assert.Equal(t, "solana", sol.AssetID)
+	assert.True(t, decimal.NewFromInt(20).Equal(sol.Value))
+	assert.Nil(t, sol.Quantity)
+}
@@ -0,0 +1,138 @@
+package testing
+
+import (
+	"testing"
+	"time"
+	"wallet-manager/portfolio"
+	"wallet-manager/prices"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRisk(t *testing.T) {
+	t.Run("Should find the max drawdown and its recovery", testRiskMaxDrawdown)
+	t.Run("Should leave deposits out of portfolio returns", testRiskPortfolioReturns)
+	t.Run("Should correlate asset returns", testRiskCorrelation)
+	t.Run("Should correlate asset returns of the same dates", testRiskCorrelationByDate)
+	t.Run("Should value the portfolio from cash flows", testRiskValues)
+	t.Run("Should parse lookback windows", testParseWindow)
+}
+
+func days(from string, count int) []time.Time {
+	start := date(from)
+	var dates []time.Time
+	for i := 0; i < count; i++ {
+		dates = append(dates, start.AddDate(0, 0, i))
+	}
+	return dates
+}
+
+func daily(from string, values ...string) prices.Series {
+	var s prices.Series
+	for i, d := range days(from, len(values)) {
+		s = append(s, prices.Point{Time: d, Price: decimal.RequireFromString(values[i])})
+	}
+	return s
+}
+
+func testRiskMaxDrawdown(t *testing.T) {
+	history := map[string]prices.Series{"bitcoin": daily("2024-01-01", "100", "120", "60", "90", "130")}
+
+	risk := portfolio.Analyze(nil, history, days("2024-01-01", 5), 0)
+
+	require.Len(t, risk.Assets, 1)
+	drawdown := risk.Assets[0].MaxDrawdown
+	require.NotNil(t, drawdown)
+	assert.Equal(t, float64(50), drawdown.Percent)
+	assert.Equal(t, date("2024-01-02"), drawdown.Peak)
+	assert.Equal(t, date("2024-01-03"), drawdown.Trough)
+	require.NotNil(t, drawdown.Recovery)
+	assert.Equal(t, date("2024-01-05"), *drawdown.Recovery)
+	assert.Equal(t, 4, risk.Assets[0].Observations)
+	assert.NotNil(t, risk.Assets[0].Sortino)
+}
+
+func testRiskPortfolioReturns(t *testing.T) {
+	dates := days("2024-01-01", 3)
+	points := []portfolio.ValuePoint{
+		{Date: dates[0], Value: decimal.NewFromInt(100), NetInvested: decimal.NewFromInt(100)},
+		{Date: dates[1], Value: decimal.NewFromInt(300), NetInvested: decimal.NewFromInt(300)},
+		{Date: dates[2], Value: decimal.NewFromInt(330), NetInvested: decimal.NewFromInt(300)},
+	}
+
+	risk := portfolio.Analyze(points, nil, dates, 0)
+
+	assert.Equal(t, 2, risk.Portfolio.Observations)
+	require.NotNil(t, risk.Portfolio.Volatility)
+	assert.InDelta(t, 135.0926, *risk.Portfolio.Volatility, 0.001)
+	require.NotNil(t, risk.Portfolio.Sharpe)
+	assert.InDelta(t, 13.5093, *risk.Portfolio.Sharpe, 0.001)
+	assert.Equal(t, float64(0), risk.Portfolio.MaxDrawdown.Percent)
+	assert.Nil(t, risk.Portfolio.Sortino)
+}
+
+func testRiskCorrelation(t *testing.T) {
+	history := map[string]prices.Series{
+		"bitcoin":  daily("2024-01-01", "100", "110", "99", "120"),
+		"ethereum": daily("2024-01-01", "10", "11", "9.9", "12"),
+		"tether":   daily("2024-01-01", "1", "1", "1", "1"),
+	}
+
+	risk := portfolio.Analyze(nil, history, days("2024-01-01", 4), 0)
+
+	assert.Equal(t, []string{"bitcoin", "ethereum", "tether"}, risk.Correlation.Assets)
+	require.NotNil(t, risk.Correlation.Matrix[0][1])
+	assert.InDelta(t, 1, *risk.Correlation.Matrix[0][1], 0.0001)
+	assert.InDelta(t, 1, *risk.Correlation.Matrix[1][1], 0.0001)
+	assert.Nil(t, risk.Correlation.Matrix[0][2])
+}
+
+func testRiskCorrelationByDate(t *testing.T) {
+	// ethereum has no return on the 3rd, after its price was missing, so only
+	// the other dates pair up and bitcoin moved half as much on each of them
+	history := map[string]prices.Series{
+		"bitcoin":  daily("2024-01-01", "100", "50", "200", "210", "189", "212.625"),
+		"ethereum": daily("2024-01-01", "10", "0", "10", "11", "8.8", "11"),
+	}
+
+	risk := portfolio.Analyze(nil, history, days("2024-01-01", 6), 0)
+
+	require.NotNil(t, risk.Correlation.Matrix[0][1])
+	assert.InDelta(t, 1, *risk.Correlation.Matrix[0][1], 0.0001)
+}
+
+func testRiskValues(t *testing.T) {
+	flows := []portfolio.CashFlow{
+		flow("2024-01-02", "bitcoin", "2", "100"),
+		flow("2024-01-03", "bitcoin", "-1", "-70"),
+	}
+	history := map[string]prices.Series{"bitcoin": daily("2024-01-01", "40", "50", "70")}
+
+	points, err := portfolio.Values(flows, history, days("2024-01-01", 3))
+
+	require.NoError(t, err)
+	require.Len(t, points, 3)
+	assert.True(t, points[0].Value.IsZero())
+	assert.True(t, decimal.NewFromInt(100).Equal(points[1].Value))
+	assert.True(t, decimal.NewFromInt(70).Equal(points[2].Value))
+	assert.True(t, decimal.NewFromInt(30).Equal(points[2].NetInvested))
+}
+
+func testParseWindow(t *testing.T) {
+	now := time.Date(2024, time.March, 31, 15, 0, 0, 0, time.UTC)
+
+	window, err := portfolio.ParseWindow("90d")
+	require.NoError(t, err)
+	assert.Equal(t, date("2024-01-01"), window.Start(now))
+
+	window, err = portfolio.ParseWindow("12w")
+	require.NoError(t, err)
+	assert.Equal(t, date("2024-01-07"), window.Start(now))
+
+	for _, invalid := range []string{"", "d", "0d", "5h", "-1w"} {
+		_, err := portfolio.ParseWindow(invalid)
+		assert.ErrorIs(t, err, portfolio.ErrInvalidWindow, invalid)
+	}
+}
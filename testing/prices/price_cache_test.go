@@ -0,0 +1,195 @@
+package testing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"wallet-manager/handlers"
+	"wallet-manager/prices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ctx = context.Background()
+
+type fakeUpstream struct {
+	calls atomic.Int32
+	fail  atomic.Bool
+	price decimal.Decimal
+	gate  chan struct{}
+}
+
+func (u *fakeUpstream) fetch(ctx context.Context, names []string, currency string) (map[string]decimal.Decimal, error) {
+	u.calls.Add(1)
+	if u.gate != nil {
+		<-u.gate
+	}
+	if u.fail.Load() {
+		return nil, errors.New("429 Too Many Requests")
+	}
+	prices := map[string]decimal.Decimal{}
+	for _, name := range names {
+		prices[name] = u.price
+	}
+	return prices, nil
+}
+
+func TestPriceCache(t *testing.T) {
+	t.Run("Should call upstream once within TTL", testCacheHit)
+	t.Run("Should coalesce concurrent requests", testCoalescing)
+	t.Run("Should keep shared call when one caller cancels", testCancelledCaller)
+	t.Run("Should serve stale price when upstream fails", testStaleOnFailure)
+	t.Run("Should revalidate stale price in background", testRevalidate)
+	t.Run("Should fail when nothing is cached and upstream fails", testNoPrices)
+	t.Run("Should answer no prices for names unknown upstream", testUnknownNames)
+	t.Run("Should set X-Price-Age header", testPriceAgeHeader)
+}
+
+func testCacheHit(t *testing.T) {
+	upstream := &fakeUpstream{price: decimal.NewFromInt(100)}
+	cache := prices.NewCache(upstream.fetch, time.Minute, time.Minute, prices.NewMemoryStore())
+
+	_, err := cache.Get(ctx, []string{"bitcoin", "ethereum"}, "usd")
+	require.NoError(t, err)
+	result, err := cache.Get(ctx, []string{"Ethereum", "bitcoin", "bitcoin"}, "USD")
+	require.NoError(t, err)
+
+	assert.Equal(t, int32(1), upstream.calls.Load())
+	assert.True(t, decimal.NewFromInt(100).Equal(result.Prices["ethereum"]))
+	assert.Len(t, result.Prices, 2)
+}
+
+func testCoalescing(t *testing.T) {
+	upstream := &fakeUpstream{price: decimal.NewFromInt(100), gate: make(chan struct{})}
+	cache := prices.NewCache(upstream.fetch, time.Minute, time.Minute, prices.NewMemoryStore())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := cache.Get(ctx, []string{"bitcoin"}, "usd")
+			assert.NoError(t, err)
+			assert.Len(t, result.Prices, 1)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(upstream.gate)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), upstream.calls.Load())
+}
+
+func testCancelledCaller(t *testing.T) {
+	upstream := &fakeUpstream{price: decimal.NewFromInt(100), gate: make(chan struct{})}
+	cache := prices.NewCache(upstream.fetch, time.Minute, time.Minute, prices.NewMemoryStore())
+
+	cancelled, cancel := context.WithCancel(ctx)
+	first := make(chan error)
+	go func() {
+		_, err := cache.Get(cancelled, []string{"bitcoin"}, "usd")
+		first <- err
+	}()
+	second := make(chan *prices.Result)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		result, _ := cache.Get(ctx, []string{"bitcoin"}, "usd")
+		second <- result
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-first, prices.ErrNoPrices)
+	close(upstream.gate)
+
+	result := <-second
+	require.NotNil(t, result)
+	assert.True(t, decimal.NewFromInt(100).Equal(result.Prices["bitcoin"]))
+	assert.Equal(t, int32(1), upstream.calls.Load())
+}
+
+func testStaleOnFailure(t *testing.T) {
+	upstream := &fakeUpstream{price: decimal.NewFromInt(100)}
+	cache := prices.NewCache(upstream.fetch, 10*time.Millisecond, 0, prices.NewMemoryStore())
+
+	_, err := cache.Get(ctx, []string{"bitcoin"}, "usd")
+	require.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+
+	upstream.fail.Store(true)
+	result, err := cache.Get(ctx, []string{"bitcoin"}, "usd")
+	require.NoError(t, err)
+
+	assert.Equal(t, int32(2), upstream.calls.Load())
+	assert.True(t, decimal.NewFromInt(100).Equal(result.Prices["bitcoin"]))
+	assert.GreaterOrEqual(t, result.Age, 20*time.Millisecond)
+}
+
+func testRevalidate(t *testing.T) {
+	upstream := &fakeUpstream{price: decimal.NewFromInt(100)}
+	cache := prices.NewCache(upstream.fetch, 10*time.Millisecond, time.Minute, prices.NewMemoryStore())
+
+	_, err := cache.Get(ctx, []string{"bitcoin"}, "usd")
+	require.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+
+	upstream.price = decimal.NewFromInt(200)
+	result, err := cache.Get(ctx, []string{"bitcoin"}, "usd")
+	require.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(100).Equal(result.Prices["bitcoin"]))
+
+	require.Eventually(t, func() bool {
+		result, err := cache.Get(ctx, []string{"bitcoin"}, "usd")
+		return err == nil && decimal.NewFromInt(200).Equal(result.Prices["bitcoin"])
+	}, time.Second, 5*time.Millisecond)
+}
+
+func testNoPrices(t *testing.T) {
+	upstream := &fakeUpstream{}
+	upstream.fail.Store(true)
+	cache := prices.NewCache(upstream.fetch, time.Minute, time.Minute, prices.NewMemoryStore())
+
+	_, err := cache.Get(ctx, []string{"bitcoin"}, "usd")
+	assert.ErrorIs(t, err, prices.ErrNoPrices)
+}
+
+func testUnknownNames(t *testing.T) {
+	unknown := func(ctx context.Context, names []string, currency string) (map[string]decimal.Decimal, error) {
+		return map[string]decimal.Decimal{}, nil
+	}
+	cache := prices.NewCache(unknown, time.Minute, time.Minute, prices.NewMemoryStore())
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/prices", handlers.NewPriceHandler(cache).GetMultiplePrices)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/prices?names=notacoin", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{}`, w.Body.String())
+}
+
+func testPriceAgeHeader(t *testing.T) {
+	upstream := &fakeUpstream{price: decimal.NewFromInt(100)}
+	cache := prices.NewCache(upstream.fetch, time.Minute, time.Minute, prices.NewMemoryStore())
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/prices", handlers.NewPriceHandler(cache).GetMultiplePrices)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/prices?names=bitcoin", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0", w.Header().Get("X-Price-Age"))
+	assert.JSONEq(t, `{"bitcoin":"100"}`, w.Body.String())
+}
@@ -0,0 +1,273 @@
+package testing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"wallet-manager/config"
+	"wallet-manager/prices"
+	"wallet-manager/upstream"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newClient(name string) *upstream.Client {
+	return upstream.NewClient(name, &config.UpstreamConfig{Timeout: time.Second})
+}
+
+// newProviderServer answers path with body, and 404 anything else.
+func newProviderServer(t *testing.T, path string, body string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+type fixedProvider struct {
+	name   string
+	quotes map[string]decimal.Decimal
+	err    error
+}
+
+func (p *fixedProvider) Name() string {
+	return p.name
+}
+
+func (p *fixedProvider) GetQuotes(ctx context.Context, names []string, currency string) (map[string]decimal.Decimal, error) {
+	quotes := map[string]decimal.Decimal{}
+	for _, name := range names {
+		if price, ok := p.quotes[name]; ok {
+			quotes[name] = price
+		}
+	}
+	return quotes, p.err
+}
+
+type fixedRegistry map[string]map[string]string
+
+func (r fixedRegistry) ProviderIDs(ctx context.Context, provider string, ids []string) (map[string]string, error) {
+	providerIDs := map[string]string{}
+	for _, id := range ids {
+		if providerID, ok := r[provider][id]; ok {
+			providerIDs[id] = providerID
+		}
+	}
+	return providerIDs, nil
+}
+
+func price(value string) decimal.Decimal {
+	return decimal.RequireFromString(value)
+}
+
+func TestPriceProviders(t *testing.T) {
+	t.Run("Should read CoinGecko simple price", testCoinGecko)
+	t.Run("Should read CoinGecko market chart", testCoinGeckoHistory)
+	t.Run("Should read CoinCap assets", testCoinCap)
+	t.Run("Should read Binance ticker", testBinance)
+	t.Run("Should read prices file", testFile)
+	t.Run("Should read last PTAX rate up to the date", testPTAX)
+	t.Run("Should fail on upstream error", testProviderError)
+}
+
+func testCoinGecko(t *testing.T) {
+	server := newProviderServer(t, "/simple/price", `{"bitcoin":{"usd":65000.5},"ethereum":{"eur":3000}}`)
+
+	quotes, err := prices.NewCoinGecko(newClient("coingecko"), server.URL).GetQuotes(ctx, []string{"bitcoin", "ethereum"}, "usd")
+	require.NoError(t, err)
+
+	assert.Len(t, quotes, 1)
+	assert.True(t, price("65000.5").Equal(quotes["bitcoin"]))
+}
+
+func testCoinGeckoHistory(t *testing.T) {
+	server := newProviderServer(t, "/coins/bitcoin/market_chart/range", `{"prices":[[1704067200000,42280.23],[1704153600000,44187.14]],"total_volumes":[]}`)
+	from := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	history, err := prices.NewCoinGecko(newClient("coingecko"), server.URL).GetHistory(ctx, "bitcoin", "usd", from, from.AddDate(0, 0, 2))
+	require.NoError(t, err)
+
+	require.Len(t, history, 2)
+	assert.Equal(t, from, history[0].Time)
+	assert.True(t, price("44187.14").Equal(history[1].Price))
+	at, ok := history.At(from.Add(36 * time.Hour))
+	assert.True(t, ok)
+	assert.True(t, price("44187.14").Equal(at))
+	at, _ = history.At(from.Add(-time.Hour))
+	assert.True(t, price("42280.23").Equal(at))
+}
+
+func testCoinCap(t *testing.T) {
+	server := newProviderServer(t, "/assets", `{"data":[{"id":"bitcoin","priceUsd":"65010.1234"},{"id":"ethereum","priceUsd":null}]}`)
+	provider := prices.NewCoinCap(newClient("coincap"), server.URL)
+
+	quotes, err := provider.GetQuotes(ctx, []string{"bitcoin", "ethereum"}, "usd")
+	require.NoError(t, err)
+	assert.Len(t, quotes, 1)
+	assert.True(t, price("65010.1234").Equal(quotes["bitcoin"]))
+
+	quotes, err = provider.GetQuotes(ctx, []string{"bitcoin"}, "brl")
+	require.NoError(t, err)
+	assert.Empty(t, quotes)
+}
+
+func testBinance(t *testing.T) {
+	server := newProviderServer(t, "/ticker/price", `[{"symbol":"BTCUSDT","price":"64990.00"},{"symbol":"BTCBRL","price":"350000.00"},{"symbol":"ETHBTC","price":"0.05"}]`)
+	provider := prices.NewBinance(newClient("binance"), server.URL)
+
+	quotes, err := provider.GetQuotes(ctx, []string{"BTC", "ETH", "XYZ"}, "usd")
+	require.NoError(t, err)
+	assert.Len(t, quotes, 1)
+	assert.True(t, price("64990").Equal(quotes["BTC"]))
+
+	quotes, err = provider.GetQuotes(ctx, []string{"BTC"}, "brl")
+	require.NoError(t, err)
+	assert.True(t, price("350000").Equal(quotes["BTC"]))
+}
+
+func testFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prices.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"bitcoin":{"usd":"65000","brl":"350000"}}`), 0o600))
+
+	quotes, err := prices.NewFile(path).GetQuotes(ctx, []string{"bitcoin", "ethereum"}, "brl")
+	require.NoError(t, err)
+
+	assert.Len(t, quotes, 1)
+	assert.True(t, price("350000").Equal(quotes["bitcoin"]))
+}
+
+func testPTAX(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, "/CotacaoDolarPeriodo(dataInicial=@dataInicial,dataFinalCotacao=@dataFinalCotacao)", r.URL.Path)
+		assert.Equal(t, "'03-09-2024'", r.URL.Query().Get("@dataFinalCotacao"))
+		w.Write([]byte(`{"value":[
+			{"cotacaoCompra":4.9501,"cotacaoVenda":4.9507,"dataHoraCotacao":"2024-03-07 13:04:25.123"},
+			{"cotacaoCompra":4.9390,"cotacaoVenda":4.9396,"dataHoraCotacao":"2024-03-08 13:11:30.456"}
+		]}`))
+	}))
+	defer server.Close()
+	ptax := prices.NewPTAX(newClient("ptax"), server.URL)
+	saturday := time.Date(2024, time.March, 9, 0, 0, 0, 0, time.UTC)
+
+	rate, err := ptax.USDBRL(ctx, saturday)
+	require.NoError(t, err)
+	_, err = ptax.USDBRL(ctx, saturday)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, requests)
+	assert.Equal(t, "2024-03-08", rate.Date.Format(time.DateOnly))
+	assert.True(t, price("4.939").Equal(rate.Buy))
+	assert.True(t, price("4.9396").Equal(rate.Sell))
+}
+
+func testProviderError(t *testing.T) {
+	server := newProviderServer(t, "/elsewhere", `{}`)
+
+	_, err := prices.NewCoinGecko(newClient("coingecko"), server.URL).GetQuotes(ctx, []string{"bitcoin"}, "usd")
+	assert.ErrorContains(t, err, "404")
+}
+
+func TestPriceAggregator(t *testing.T) {
+	t.Run("Should fall back to next provider for missing names", testFallback)
+	t.Run("Should fail when every provider fails", testAllProvidersFail)
+	t.Run("Should take the median of providers", testMedian)
+	t.Run("Should leave outliers out of the median", testMedianOutlier)
+	t.Run("Should ask providers for their own asset IDs", testRegistryIDs)
+	t.Run("Should reject unknown aggregation", testUnknownAggregation)
+}
+
+func testFallback(t *testing.T) {
+	down := &fixedProvider{name: "down", err: errors.New("503 Service Unavailable")}
+	partial := &fixedProvider{name: "partial", quotes: map[string]decimal.Decimal{"bitcoin": price("65000")}}
+	full := &fixedProvider{name: "full", quotes: map[string]decimal.Decimal{"bitcoin": price("1"), "ethereum": price("3000")}}
+	aggregator, err := prices.NewAggregator(prices.AggregationFallback, decimal.Zero, nil, down, partial, full)
+	require.NoError(t, err)
+
+	quotes, err := aggregator.Get(ctx, []string{"bitcoin", "ethereum", "unknown-coin"}, "usd")
+	require.NoError(t, err)
+
+	assert.Len(t, quotes, 2)
+	assert.Equal(t, "partial", quotes["bitcoin"].Source)
+	assert.True(t, price("65000").Equal(quotes["bitcoin"].Value))
+	assert.Equal(t, "full", quotes["ethereum"].Source)
+}
+
+func testAllProvidersFail(t *testing.T) {
+	aggregator, err := prices.NewAggregator(prices.AggregationMedian, decimal.Zero, nil,
+		&fixedProvider{name: "a", err: errors.New("timeout")},
+		&fixedProvider{name: "b", err: errors.New("429 Too Many Requests")})
+	require.NoError(t, err)
+
+	_, err = aggregator.Get(ctx, []string{"bitcoin"}, "usd")
+	assert.ErrorContains(t, err, "timeout")
+	assert.ErrorContains(t, err, "429")
+}
+
+func testMedian(t *testing.T) {
+	aggregator, err := prices.NewAggregator(prices.AggregationMedian, price("0.05"), nil,
+		&fixedProvider{name: "a", quotes: map[string]decimal.Decimal{"bitcoin": price("100")}},
+		&fixedProvider{name: "b", err: errors.New("timeout")},
+		&fixedProvider{name: "c", quotes: map[string]decimal.Decimal{"bitcoin": price("102")}})
+	require.NoError(t, err)
+
+	quotes, err := aggregator.Get(ctx, []string{"bitcoin"}, "usd")
+	require.NoError(t, err)
+
+	assert.True(t, price("101").Equal(quotes["bitcoin"].Value))
+	assert.Equal(t, "median(a,c)", quotes["bitcoin"].Source)
+	assert.Empty(t, quotes["bitcoin"].Outliers)
+}
+
+func testMedianOutlier(t *testing.T) {
+	aggregator, err := prices.NewAggregator(prices.AggregationMedian, price("0.05"), nil,
+		&fixedProvider{name: "a", quotes: map[string]decimal.Decimal{"bitcoin": price("100")}},
+		&fixedProvider{name: "b", quotes: map[string]decimal.Decimal{"bitcoin": price("150")}},
+		&fixedProvider{name: "c", quotes: map[string]decimal.Decimal{"bitcoin": price("101")}},
+		&fixedProvider{name: "d", quotes: map[string]decimal.Decimal{"bitcoin": price("99")}})
+	require.NoError(t, err)
+
+	quotes, err := aggregator.Get(ctx, []string{"bitcoin"}, "usd")
+	require.NoError(t, err)
+
+	assert.True(t, price("100").Equal(quotes["bitcoin"].Value))
+	assert.Equal(t, "median(a,c,d)", quotes["bitcoin"].Source)
+	assert.Equal(t, []string{"b"}, quotes["bitcoin"].Outliers)
+}
+
+func testRegistryIDs(t *testing.T) {
+	registry := fixedRegistry{
+		"coingecko": {"polygon": "polygon-ecosystem-token"},
+		"binance":   {"polygon": "POL", "bitcoin": "BTC"},
+	}
+	aggregator, err := prices.NewAggregator(prices.AggregationMedian, decimal.Zero, registry,
+		&fixedProvider{name: "coingecko", quotes: map[string]decimal.Decimal{"polygon-ecosystem-token": price("0.40"), "bitcoin": price("1")}},
+		&fixedProvider{name: "binance", quotes: map[string]decimal.Decimal{"POL": price("0.42"), "BTC": price("65000")}})
+	require.NoError(t, err)
+
+	quotes, err := aggregator.Get(ctx, []string{"polygon", "bitcoin"}, "usd")
+	require.NoError(t, err)
+
+	assert.True(t, price("0.41").Equal(quotes["polygon"].Value))
+	assert.Equal(t, "median(coingecko,binance)", quotes["polygon"].Source)
+	assert.True(t, price("65000").Equal(quotes["bitcoin"].Value))
+	assert.Equal(t, "binance", quotes["bitcoin"].Source)
+}
+
+func testUnknownAggregation(t *testing.T) {
+	_, err := prices.NewAggregator("average", decimal.Zero, nil, &fixedProvider{name: "a"})
+	assert.Error(t, err)
+}
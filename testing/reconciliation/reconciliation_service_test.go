@@ -0,0 +1,189 @@
+package testing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"wallet-manager/chain"
+	db "wallet-manager/database"
+	"wallet-manager/events"
+	"wallet-manager/handlers"
+	"wallet-manager/models"
+	"wallet-manager/repositories"
+	"wallet-manager/services"
+	helper "wallet-manager/testing"
+	"wallet-manager/validators"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testDbInstance *sqlx.DB
+var tc testContext
+
+func TestMain(m *testing.M) {
+	testDB := helper.SetupTestDatabase()
+	testDbInstance = testDB.DbInstance
+	defer testDB.TearDown()
+	beforeAll()
+	os.Exit(m.Run())
+}
+
+type testContext struct {
+	cryptoRepo         repositories.CryptocurrencyRepository
+	transactionRepo    repositories.CryptoTransactionRepository
+	transactionService services.CryptoTransactionService
+	service            services.ReconciliationService
+	handle             *handlers.ReconciliationHandler
+	engine             *gin.Engine
+}
+
+func beforeEach() {
+	deleteAll()
+}
+
+func beforeAll() {
+	validators.Register(knownAsset)
+	transactor := repositories.NewTransactor(testDbInstance)
+	audit := services.NewAuditService(repositories.NewAuditRepository(testDbInstance))
+	bus := events.NewBus(repositories.NewOutboxRepository(testDbInstance), transactor)
+	assets := services.NewAssetService(repositories.NewAssetRepository(testDbInstance))
+	assets.Seed(ctx, db.AssetSeed)
+	tc.cryptoRepo = repositories.NewCryptocurrencyRepository(testDbInstance)
+	tc.transactionRepo = repositories.NewCryptoTransactionRepository(testDbInstance)
+	cryptoService := services.NewCryptocurrencyService(tc.cryptoRepo, tc.transactionRepo, assets, transactor, audit, bus)
+	tc.transactionService = services.NewCryptoTransactionService(tc.transactionRepo, cryptoService, assets, transactor, audit, bus)
+	// no indexers, so no holding has an on-chain balance
+	addresses := services.NewAddressService(repositories.NewWatchedAddressRepository(testDbInstance), repositories.NewExtendedKeyRepository(testDbInstance),
+		tc.cryptoRepo, tc.transactionRepo, repositories.NewCryptoPriceRepository(testDbInstance), assets, map[string]chain.Indexer{}, transactor)
+	tc.service = services.NewReconciliationService(repositories.NewReconciliationRepository(testDbInstance), tc.cryptoRepo, tc.transactionRepo,
+		cryptoService, addresses, transactor, audit, bus)
+	tc.handle = handlers.NewReconciliationHandler(tc.service)
+	tc.engine = gin.Default()
+	tc.engine.GET("/reconciliation", tc.handle.Get)
+	tc.engine.POST("/reconciliation/:cryptoId/adjust", tc.handle.Adjust)
+}
+
+func knownAsset(name string) (bool, error) {
+	return true, nil
+}
+
+func testCase(test func(t *testing.T)) func(*testing.T) {
+	return func(t *testing.T) {
+		beforeEach()
+		test(t)
+	}
+}
+
+func deleteAll() {
+	testDbInstance.Exec("DELETE FROM cryptocurrency;")
+}
+
+func TestReconciliationService(t *testing.T) {
+	t.Run("Should report no discrepancy for a reconciled holding", testCase(testReportReconciledHolding))
+	t.Run("Should report an overwritten balance", testCase(testReportOverwrittenBalance))
+	t.Run("Should post an adjustment up to the stored balance", testCase(testAdjustToStoredBalance))
+	t.Run("Should reject an adjustment of a reconciled holding", testCase(testAdjustReconciledHolding))
+	t.Run("Should reject an on-chain adjustment without watched addresses", testCase(testAdjustWithoutOnChainBalance))
+	t.Run("Should not edit an adjustment", testCase(testUpdateAdjustment))
+}
+
+func getReport(t *testing.T) models.ReconciliationReport {
+	request, err := http.NewRequest(http.MethodGet, "/reconciliation?refresh=true", nil)
+	require.NoError(t, err)
+
+	responseRecorder := httptest.NewRecorder()
+	tc.engine.ServeHTTP(responseRecorder, request)
+
+	var report models.ReconciliationReport
+	require.Equal(t, http.StatusOK, responseRecorder.Result().StatusCode)
+	require.NoError(t, json.NewDecoder(responseRecorder.Body).Decode(&report))
+	return report
+}
+
+func testReportReconciledHolding(t *testing.T) {
+	createHolding(tc.cryptoRepo, tc.transactionService)
+
+	report := getReport(t)
+
+	assert.Equal(t, 1, report.Holdings)
+	assert.Empty(t, report.Discrepancies)
+}
+
+func testReportOverwrittenBalance(t *testing.T) {
+	crypto := createHolding(tc.cryptoRepo, tc.transactionService)
+	overwriteBalance(testDbInstance, crypto.ID, 3)
+
+	report := getReport(t)
+
+	require.Len(t, report.Discrepancies, 1)
+	discrepancy := report.Discrepancies[0]
+	assert.Equal(t, crypto.ID, discrepancy.CryptocurrencyID)
+	assert.True(t, decimal.NewFromInt(1).Equal(discrepancy.LedgerBalance))
+	assert.True(t, decimal.NewFromInt(2).Equal(discrepancy.BalanceDifference))
+	assert.True(t, discrepancy.FiatDifference.IsZero())
+	assert.Nil(t, discrepancy.OnChainBalance)
+}
+
+func testAdjustToStoredBalance(t *testing.T) {
+	crypto := createHolding(tc.cryptoRepo, tc.transactionService)
+	overwriteBalance(testDbInstance, crypto.ID, 3)
+	request, err := http.NewRequest(http.MethodPost, adjustURL(crypto.ID, ""), nil)
+	require.NoError(t, err)
+
+	responseRecorder := httptest.NewRecorder()
+	tc.engine.ServeHTTP(responseRecorder, request)
+
+	var adjustment models.Adjustment
+	require.NoError(t, json.NewDecoder(responseRecorder.Body).Decode(&adjustment))
+	assert.Equal(t, http.StatusCreated, responseRecorder.Result().StatusCode)
+	assert.Equal(t, models.TransactionTypeAdjustment, adjustment.Transaction.Type)
+	assert.True(t, decimal.NewFromInt(2).Equal(adjustment.Transaction.CryptocurrencyAmount))
+	assert.True(t, adjustment.Reconciliation.BalanceDifference.IsZero())
+
+	holding, err := tc.cryptoRepo.GetByID(ctx, crypto.ID)
+	require.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(3).Equal(holding.Balance))
+	assert.Empty(t, getReport(t).Discrepancies)
+}
+
+func testAdjustReconciledHolding(t *testing.T) {
+	crypto := createHolding(tc.cryptoRepo, tc.transactionService)
+	request, err := http.NewRequest(http.MethodPost, adjustURL(crypto.ID, ""), nil)
+	require.NoError(t, err)
+
+	responseRecorder := httptest.NewRecorder()
+	tc.engine.ServeHTTP(responseRecorder, request)
+
+	assert.Equal(t, http.StatusConflict, responseRecorder.Result().StatusCode)
+}
+
+func testAdjustWithoutOnChainBalance(t *testing.T) {
+	crypto := createHolding(tc.cryptoRepo, tc.transactionService)
+	overwriteBalance(testDbInstance, crypto.ID, 3)
+	request, err := http.NewRequest(http.MethodPost, adjustURL(crypto.ID, models.ReconcileTargetOnChain), nil)
+	require.NoError(t, err)
+
+	responseRecorder := httptest.NewRecorder()
+	tc.engine.ServeHTTP(responseRecorder, request)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, responseRecorder.Result().StatusCode)
+}
+
+func testUpdateAdjustment(t *testing.T) {
+	crypto := createHolding(tc.cryptoRepo, tc.transactionService)
+	overwriteBalance(testDbInstance, crypto.ID, 3)
+	adjustment, err := tc.service.Adjust(ctx, crypto.ID, models.ReconcileTargetBalance)
+	require.NoError(t, err)
+
+	edited := adjustment.Transaction
+	edited.Type = models.TransactionTypeBuy
+	err = tc.transactionService.Update(ctx, &edited)
+
+	assert.ErrorIs(t, err, services.ErrTransactionReadOnly)
+}
@@ -0,0 +1,49 @@
+package testing
+
+import (
+	"context"
+	"strconv"
+	"wallet-manager/models"
+	"wallet-manager/repositories"
+	"wallet-manager/services"
+	"wallet-manager/utils"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/shopspring/decimal"
+)
+
+var ctx = context.Background()
+
+// createHolding buys 1 bitcoin for 100 through the transactions, so the
+// holding starts reconciled.
+func createHolding(cryptoRepo repositories.CryptocurrencyRepository, transactionService services.CryptoTransactionService) models.Cryptocurrency {
+	crypto := models.Cryptocurrency{
+		AssetID:     "bitcoin",
+		Balance:     decimal.Zero,
+		CostInFiat:  decimal.Zero,
+		CreatedDate: utils.NowFormatted(),
+	}
+	cryptoRepo.Create(ctx, &crypto)
+	transactionService.Create(ctx, &models.CryptoTransaction{
+		CryptocurrencyId:     crypto.ID,
+		CryptocurrencyAmount: decimal.NewFromInt(1),
+		FiatAmount:           decimal.NewFromInt(100),
+		PurchaseDate:         utils.NowFormatted(),
+		CreatedDate:          utils.NowFormatted(),
+	})
+	return crypto
+}
+
+// overwriteBalance sets the stored balance the way PUT /cryptocurrencies
+// does, leaving the transactions alone.
+func overwriteBalance(testDbInstance *sqlx.DB, cryptoId uint32, balance int64) {
+	testDbInstance.Exec("UPDATE cryptocurrency SET balance = $1 WHERE cryptocurrency_id = $2;", balance, cryptoId)
+}
+
+func adjustURL(cryptoId uint32, target string) string {
+	url := "/reconciliation/" + strconv.FormatUint(uint64(cryptoId), 10) + "/adjust"
+	if target != "" {
+		url += "?target=" + target
+	}
+	return url
+}
@@ -0,0 +1,124 @@
+package testing
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"wallet-manager/handlers"
+	"wallet-manager/stream"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStream(t *testing.T) {
+	t.Run("Should deliver only messages matching the filter", testHubFilter)
+	t.Run("Should drop clients that fall behind", testHubDropsSlowClient)
+	t.Run("Should stream snapshot and ticks over SSE", testSSE)
+	t.Run("Should change subscription over WebSocket", testWebSocket)
+}
+
+func testHubFilter(t *testing.T) {
+	hub := stream.NewHub(10)
+	client := hub.Subscribe(stream.NewFilter([]string{"Bitcoin"}, []string{"price"}))
+
+	hub.Publish(priceMessage("ethereum"))
+	hub.Publish(stream.Message{Type: stream.MessageHolding, Asset: "bitcoin"})
+	hub.Publish(priceMessage("bitcoin"))
+
+	require.Len(t, client.Messages(), 1)
+	assert.Equal(t, "bitcoin", (<-client.Messages()).Asset)
+}
+
+func testHubDropsSlowClient(t *testing.T) {
+	hub := stream.NewHub(2)
+	slow := hub.Subscribe(stream.Filter{})
+	fast := hub.Subscribe(stream.Filter{})
+
+	for i := 0; i < 3; i++ {
+		hub.Publish(priceMessage("bitcoin"))
+		<-fast.Messages()
+	}
+
+	select {
+	case <-slow.Dropped():
+	default:
+		t.Fatal("slow client was not dropped")
+	}
+	assert.Equal(t, 1, hub.Clients())
+}
+
+func newStreamServer(hub *stream.Hub, snapshot ...stream.Message) *httptest.Server {
+	gin.SetMode(gin.TestMode)
+	handler := handlers.NewStreamHandler(hub, &fakeStreamService{snapshot: snapshot}, time.Minute)
+	r := gin.New()
+	r.GET("/stream", handler.SSE)
+	r.GET("/ws", handler.WebSocket)
+	return httptest.NewServer(r)
+}
+
+func waitForClients(t *testing.T, hub *stream.Hub, count int) {
+	require.Eventually(t, func() bool { return hub.Clients() == count }, time.Second, 5*time.Millisecond)
+}
+
+func testSSE(t *testing.T) {
+	hub := stream.NewHub(10)
+	server := newStreamServer(hub, priceMessage("bitcoin"), priceMessage("ethereum"))
+	defer server.Close()
+
+	timeout, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	request, err := http.NewRequestWithContext(timeout, http.MethodGet, server.URL+"/stream?assets=bitcoin", nil)
+	require.NoError(t, err)
+	response, err := http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	defer response.Body.Close()
+	assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+
+	waitForClients(t, hub, 1)
+	hub.Publish(priceMessage("ethereum"))
+	hub.Publish(priceMessage("bitcoin"))
+
+	var events []string
+	scanner := bufio.NewScanner(response.Body)
+	for len(events) < 2 && scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "data:") {
+			events = append(events, line)
+		}
+	}
+
+	require.Len(t, events, 2)
+	for _, event := range events {
+		assert.Contains(t, event, `"asset":"bitcoin"`)
+	}
+}
+
+func testWebSocket(t *testing.T) {
+	hub := stream.NewHub(10)
+	server := newStreamServer(hub, priceMessage("bitcoin"), priceMessage("ethereum"))
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws?assets=bitcoin", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var message stream.Message
+	require.NoError(t, conn.ReadJSON(&message))
+	assert.Equal(t, "bitcoin", message.Asset)
+
+	require.NoError(t, conn.WriteJSON(map[string]any{"action": "subscribe", "assets": []string{"ethereum"}}))
+	require.NoError(t, conn.ReadJSON(&message))
+	assert.Equal(t, "ethereum", message.Asset)
+
+	hub.Publish(priceMessage("bitcoin"))
+	hub.Publish(priceMessage("ethereum"))
+	require.NoError(t, conn.ReadJSON(&message))
+	assert.Equal(t, "ethereum", message.Asset)
+}
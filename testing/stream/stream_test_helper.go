@@ -0,0 +1,32 @@
+package testing
+
+import (
+	"context"
+	"wallet-manager/events"
+	"wallet-manager/stream"
+)
+
+var ctx = context.Background()
+
+// fakeStreamService answers snapshots with a fixed list of messages.
+type fakeStreamService struct {
+	snapshot []stream.Message
+}
+
+func (s *fakeStreamService) Handle(ctx context.Context, event events.Event) error {
+	return nil
+}
+
+func (s *fakeStreamService) Snapshot(ctx context.Context, filter stream.Filter) ([]stream.Message, error) {
+	var messages []stream.Message
+	for _, message := range s.snapshot {
+		if filter.Match(message) {
+			messages = append(messages, message)
+		}
+	}
+	return messages, nil
+}
+
+func priceMessage(asset string) stream.Message {
+	return stream.Message{Type: stream.MessagePrice, Asset: asset, Data: map[string]string{"name": asset}}
+}
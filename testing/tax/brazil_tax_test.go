@@ -0,0 +1,121 @@
+package testing
+
+import (
+	"testing"
+	"wallet-manager/tax"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBrazilTax(t *testing.T) {
+	t.Run("Should exempt months with sales up to the limit", testBrazilExemptMonth)
+	t.Run("Should tax gains over average cost", testBrazilTaxableMonth)
+	t.Run("Should net gains and losses within the month", testBrazilMonthNetting)
+	t.Run("Should apply progressive brackets", testBrazilBrackets)
+	t.Run("Should list Bens e Direitos positions", testBrazilPositions)
+	t.Run("Should reject unknown rule set", testBrazilUnknownRuleSet)
+}
+
+func gcap(t *testing.T) tax.RuleSet {
+	rules, err := tax.ParseRuleSet("gcap")
+	require.NoError(t, err)
+	return rules
+}
+
+func testBrazilExemptMonth(t *testing.T) {
+	trades := []tax.Trade{
+		buy(1, "BTC", 1, 20_000, "2023-01-10"),
+		sell(2, "BTC", 1, 35_000, "2024-02-10"),
+	}
+
+	report, err := tax.Brazil(trades, 2024, gcap(t))
+
+	require.NoError(t, err)
+	february := report.Months[1]
+	assert.True(t, february.Exempt)
+	assert.True(t, decimal.NewFromInt(15_000).Equal(february.Gain))
+	assert.True(t, february.Tax.IsZero())
+	assert.Nil(t, february.DarfDue)
+	assert.True(t, report.Totals.Tax.IsZero())
+}
+
+func testBrazilTaxableMonth(t *testing.T) {
+	trades := []tax.Trade{
+		buy(1, "BTC", 1, 100_000, "2024-01-10"),
+		buy(2, "BTC", 1, 200_000, "2024-02-10"),
+		sell(3, "BTC", 1, 250_000, "2024-03-10"),
+	}
+
+	report, err := tax.Brazil(trades, 2024, gcap(t))
+
+	require.NoError(t, err)
+	march := report.Months[2]
+	assert.False(t, march.Exempt)
+	assert.True(t, decimal.NewFromInt(150_000).Equal(march.CostBasis))
+	assert.True(t, decimal.NewFromInt(100_000).Equal(march.TaxableGain))
+	assert.True(t, decimal.NewFromInt(15_000).Equal(march.Tax))
+	require.NotNil(t, march.DarfDue)
+	assert.Equal(t, "2024-04-30", march.DarfDue.Format("2006-01-02"))
+	assert.True(t, decimal.NewFromInt(15_000).Equal(report.Totals.Tax))
+}
+
+func testBrazilMonthNetting(t *testing.T) {
+	trades := []tax.Trade{
+		buy(1, "BTC", 1, 30_000, "2024-01-10"),
+		buy(2, "ETH", 10, 40_000, "2024-01-11"),
+		sell(3, "BTC", 1, 40_000, "2024-05-10"),
+		sell(4, "ETH", 10, 36_000, "2024-05-20"),
+	}
+
+	report, err := tax.Brazil(trades, 2024, gcap(t))
+
+	require.NoError(t, err)
+	may := report.Months[4]
+	assert.True(t, decimal.NewFromInt(76_000).Equal(may.Sales))
+	assert.True(t, decimal.NewFromInt(6_000).Equal(may.Gain))
+	assert.True(t, decimal.NewFromInt(900).Equal(may.Tax))
+}
+
+func testBrazilBrackets(t *testing.T) {
+	rules := gcap(t)
+
+	assert.True(t, decimal.NewFromInt(750_000).Equal(rules.Tax(decimal.NewFromInt(5_000_000))))
+	assert.True(t, decimal.NewFromInt(925_000).Equal(rules.Tax(decimal.NewFromInt(6_000_000))))
+	assert.True(t, decimal.NewFromInt(6_750_000).Equal(rules.Tax(decimal.NewFromInt(35_000_000))))
+}
+
+func testBrazilPositions(t *testing.T) {
+	trades := []tax.Trade{
+		buy(1, "BTC", 2, 200_000, "2023-01-10"),
+		buy(2, "USDT", 1000, 5_000, "2023-06-10"),
+		sell(3, "BTC", 1, 150_000, "2024-03-10"),
+		buy(4, "ETH", 1, 10_000, "2024-04-10"),
+		sell(5, "USDT", 1000, 5_100, "2024-05-10"),
+		buy(6, "ETH", 1, 99_999, "2025-01-10"),
+	}
+
+	report, err := tax.Brazil(trades, 2024, gcap(t))
+
+	require.NoError(t, err)
+	require.Len(t, report.Assets, 3)
+	btc, eth, usdt := report.Assets[0], report.Assets[1], report.Assets[2]
+	assert.Equal(t, "01", btc.Code)
+	assert.Equal(t, "08", btc.Group)
+	assert.True(t, decimal.NewFromInt(200_000).Equal(btc.PreviousCost))
+	assert.True(t, decimal.NewFromInt(100_000).Equal(btc.Cost))
+	assert.Equal(t, "1 BTC", btc.Description)
+	assert.Equal(t, "02", eth.Code)
+	assert.True(t, eth.PreviousCost.IsZero())
+	assert.True(t, decimal.NewFromInt(10_000).Equal(eth.Cost))
+	assert.Equal(t, "03", usdt.Code)
+	assert.True(t, decimal.NewFromInt(5_000).Equal(usdt.PreviousCost))
+	assert.True(t, usdt.Cost.IsZero())
+}
+
+func testBrazilUnknownRuleSet(t *testing.T) {
+	_, err := tax.ParseRuleSet("irpf")
+
+	assert.ErrorIs(t, err, tax.ErrUnknownRuleSet)
+}
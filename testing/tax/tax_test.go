@@ -0,0 +1,217 @@
+package testing
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+	"wallet-manager/tax"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTax(t *testing.T) {
+	t.Run("Should match disposals first in first out", testMatchFIFO)
+	t.Run("Should match disposals last in first out", testMatchLIFO)
+	t.Run("Should match disposals against the highest cost first", testMatchHIFO)
+	t.Run("Should split a disposal across lots", testMatchSplitsDisposal)
+	t.Run("Should classify holding period", testHoldingPeriod)
+	t.Run("Should fail when selling more than was acquired", testInsufficientLots)
+	t.Run("Should simulate a sale against the open lots", testSimulateSale)
+	t.Run("Should report only disposals of the year", testReportYear)
+	t.Run("Should write CSV with totals", testWriteCSV)
+	t.Run("Should write Form 8949 parts", testWriteForm8949)
+	t.Run("Should reject unknown method", testUnknownMethod)
+}
+
+func date(value string) time.Time {
+	d, _ := time.Parse(time.DateOnly, value)
+	return d
+}
+
+func buy(id uint32, asset string, amount, fiat int64, on string) tax.Trade {
+	return tax.Trade{ID: id, Asset: asset, Amount: decimal.NewFromInt(amount), Fiat: decimal.NewFromInt(fiat), Date: date(on)}
+}
+
+func sell(id uint32, asset string, amount, fiat int64, on string) tax.Trade {
+	trade := buy(id, asset, amount, fiat, on)
+	trade.Sell = true
+	return trade
+}
+
+// three lots of 1 BTC at 100, 300 and 200, then 1 BTC sold for 400
+func lots() []tax.Trade {
+	return []tax.Trade{
+		buy(1, "BTC", 1, 100, "2023-01-10"),
+		buy(2, "BTC", 1, 300, "2023-02-10"),
+		buy(3, "BTC", 1, 200, "2023-03-10"),
+		sell(4, "BTC", 1, 400, "2023-04-10"),
+	}
+}
+
+func testMatchFIFO(t *testing.T) {
+	disposals, err := tax.Match(lots(), tax.MethodFIFO)
+
+	require.NoError(t, err)
+	require.Len(t, disposals, 1)
+	assert.Equal(t, uint32(1), disposals[0].LotID)
+	assert.True(t, decimal.NewFromInt(300).Equal(disposals[0].Gain))
+}
+
+func testMatchLIFO(t *testing.T) {
+	disposals, err := tax.Match(lots(), tax.MethodLIFO)
+
+	require.NoError(t, err)
+	require.Len(t, disposals, 1)
+	assert.Equal(t, uint32(3), disposals[0].LotID)
+	assert.True(t, decimal.NewFromInt(200).Equal(disposals[0].Gain))
+}
+
+func testMatchHIFO(t *testing.T) {
+	disposals, err := tax.Match(lots(), tax.MethodHIFO)
+
+	require.NoError(t, err)
+	require.Len(t, disposals, 1)
+	assert.Equal(t, uint32(2), disposals[0].LotID)
+	assert.True(t, decimal.NewFromInt(100).Equal(disposals[0].Gain))
+}
+
+func testMatchSplitsDisposal(t *testing.T) {
+	trades := []tax.Trade{
+		buy(1, "BTC", 2, 100, "2023-01-10"),
+		buy(2, "ETH", 5, 50, "2023-01-11"),
+		buy(3, "BTC", 2, 300, "2023-02-10"),
+		sell(4, "BTC", 3, 900, "2023-04-10"),
+		sell(5, "BTC", 1, 100, "2023-05-10"),
+	}
+
+	disposals, err := tax.Match(trades, tax.MethodFIFO)
+
+	require.NoError(t, err)
+	require.Len(t, disposals, 3)
+	assert.Equal(t, uint32(1), disposals[0].LotID)
+	assert.True(t, decimal.NewFromInt(2).Equal(disposals[0].Amount))
+	assert.True(t, decimal.NewFromInt(600).Equal(disposals[0].Proceeds))
+	assert.True(t, decimal.NewFromInt(100).Equal(disposals[0].CostBasis))
+	assert.Equal(t, uint32(3), disposals[1].LotID)
+	assert.True(t, decimal.NewFromInt(300).Equal(disposals[1].Proceeds))
+	assert.True(t, decimal.NewFromInt(150).Equal(disposals[1].CostBasis))
+	assert.Equal(t, uint32(5), disposals[2].TransactionID)
+	assert.True(t, decimal.NewFromInt(150).Equal(disposals[2].CostBasis))
+	assert.True(t, decimal.NewFromInt(-50).Equal(disposals[2].Gain))
+}
+
+func testHoldingPeriod(t *testing.T) {
+	trades := []tax.Trade{
+		buy(1, "BTC", 1, 100, "2022-03-10"),
+		buy(2, "BTC", 1, 100, "2022-03-11"),
+		sell(3, "BTC", 2, 400, "2023-03-11"),
+	}
+
+	disposals, err := tax.Match(trades, tax.MethodFIFO)
+
+	require.NoError(t, err)
+	require.Len(t, disposals, 2)
+	assert.Equal(t, tax.TermLong, disposals[0].Term)
+	assert.Equal(t, tax.TermShort, disposals[1].Term)
+}
+
+func testInsufficientLots(t *testing.T) {
+	trades := []tax.Trade{
+		buy(1, "BTC", 1, 100, "2023-01-10"),
+		sell(2, "BTC", 2, 400, "2023-04-10"),
+	}
+
+	_, err := tax.Match(trades, tax.MethodFIFO)
+
+	assert.ErrorIs(t, err, tax.ErrInsufficientLots)
+}
+
+func testSimulateSale(t *testing.T) {
+	trades := []tax.Trade{
+		buy(1, "BTC", 1, 100, "2023-01-10"),
+		buy(2, "BTC", 1, 300, "2023-02-10"),
+	}
+	sale := tax.Trade{Asset: "BTC", Amount: decimal.NewFromInt(1), Fiat: decimal.NewFromInt(500), Date: date("2023-05-10")}
+
+	fifo, err := tax.Simulate(trades, sale, tax.MethodFIFO)
+	require.NoError(t, err)
+	hifo, err := tax.Simulate(trades, sale, tax.MethodHIFO)
+	require.NoError(t, err)
+
+	require.Len(t, fifo, 1)
+	assert.Equal(t, uint32(1), fifo[0].LotID)
+	assert.True(t, decimal.NewFromInt(400).Equal(fifo[0].Gain))
+	require.Len(t, hifo, 1)
+	assert.Equal(t, uint32(2), hifo[0].LotID)
+	assert.True(t, decimal.NewFromInt(200).Equal(hifo[0].Gain))
+
+	sale.Amount = decimal.NewFromInt(3)
+	_, err = tax.Simulate(trades, sale, tax.MethodFIFO)
+	assert.ErrorIs(t, err, tax.ErrInsufficientLots)
+}
+
+func testReportYear(t *testing.T) {
+	trades := []tax.Trade{
+		buy(1, "BTC", 3, 300, "2022-06-01"),
+		sell(2, "BTC", 1, 200, "2022-06-10"),
+		sell(3, "BTC", 1, 50, "2023-02-10"),
+		sell(4, "BTC", 1, 400, "2023-06-10"),
+		sell(5, "BTC", 5, 400, "2024-06-10"),
+	}
+
+	report, err := tax.Generate(trades, 2023, tax.MethodFIFO)
+
+	require.NoError(t, err)
+	require.Len(t, report.Disposals, 2)
+	assert.True(t, decimal.NewFromInt(-50).Equal(report.Totals.ShortTerm.Gain))
+	assert.True(t, decimal.NewFromInt(300).Equal(report.Totals.LongTerm.Gain))
+	assert.True(t, decimal.NewFromInt(450).Equal(report.Totals.Total.Proceeds))
+	assert.True(t, decimal.NewFromInt(250).Equal(report.Totals.Total.Gain))
+}
+
+func report(t *testing.T) *tax.Report {
+	trades := []tax.Trade{
+		buy(1, "BTC", 1, 100, "2022-01-10"),
+		buy(2, "BTC", 1, 200, "2023-01-10"),
+		sell(3, "BTC", 2, 500, "2023-06-10"),
+	}
+	report, err := tax.Generate(trades, 2023, tax.MethodFIFO)
+	require.NoError(t, err)
+	return report
+}
+
+func readCSV(t *testing.T, write func(*bytes.Buffer) error) [][]string {
+	var buffer bytes.Buffer
+	require.NoError(t, write(&buffer))
+	rows, err := csv.NewReader(&buffer).ReadAll()
+	require.NoError(t, err)
+	return rows
+}
+
+func testWriteCSV(t *testing.T) {
+	report := report(t)
+	rows := readCSV(t, func(b *bytes.Buffer) error { return tax.WriteCSV(b, report) })
+
+	require.Len(t, rows, 6)
+	assert.Equal(t, []string{"BTC", "1", "2022-01-10", "2023-06-10", "250.00", "100.00", "150.00", "long"}, rows[1])
+	assert.Equal(t, []string{"total", "", "", "", "500.00", "300.00", "200.00", ""}, rows[5])
+}
+
+func testWriteForm8949(t *testing.T) {
+	report := report(t)
+	rows := readCSV(t, func(b *bytes.Buffer) error { return tax.WriteForm8949(b, report) })
+
+	require.Len(t, rows, 3)
+	assert.Equal(t, "(a) Description of property", rows[0][1])
+	assert.Equal(t, []string{"I", "1 BTC", "01/10/2023", "06/10/2023", "250.00", "200.00", "", "", "50.00"}, rows[1])
+	assert.Equal(t, []string{"II", "1 BTC", "01/10/2022", "06/10/2023", "250.00", "100.00", "", "", "150.00"}, rows[2])
+}
+
+func testUnknownMethod(t *testing.T) {
+	_, err := tax.ParseMethod("average")
+
+	assert.ErrorIs(t, err, tax.ErrUnknownMethod)
+}
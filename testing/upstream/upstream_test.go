@@ -0,0 +1,162 @@
+package testing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+	"wallet-manager/config"
+	"wallet-manager/upstream"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ctx = context.Background()
+
+// newServer answers with statuses in order, then keeps repeating the last one.
+func newServer(calls *int32, retryAfter string, statuses ...int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := atomic.AddInt32(calls, 1)
+		status := statuses[min(int(call), len(statuses))-1]
+		if status != http.StatusOK && retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		w.WriteHeader(status)
+	}))
+}
+
+func newConfig() *config.UpstreamConfig {
+	return &config.UpstreamConfig{
+		Timeout:          time.Second,
+		MaxRetries:       3,
+		BreakerThreshold: 5,
+		BreakerCooldown:  time.Minute,
+	}
+}
+
+func TestUpstreamClient(t *testing.T) {
+	t.Run("Should retry server errors", testRetryServerError)
+	t.Run("Should honor Retry-After", testRetryAfter)
+	t.Run("Should stop retrying when context is cancelled", testCancelledRetry)
+	t.Run("Should open circuit after consecutive failures", testCircuitOpens)
+	t.Run("Should close circuit after successful probe", testCircuitCloses)
+	t.Run("Should limit request rate", testRateLimit)
+}
+
+func testRetryServerError(t *testing.T) {
+	var calls int32
+	server := newServer(&calls, "", http.StatusServiceUnavailable, http.StatusOK)
+	defer server.Close()
+
+	resp, err := upstream.NewClient("test", newConfig()).Get(ctx, server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int32(2), calls)
+}
+
+func testRetryAfter(t *testing.T) {
+	var calls int32
+	server := newServer(&calls, "1", http.StatusTooManyRequests, http.StatusOK)
+	defer server.Close()
+
+	start := time.Now()
+	resp, err := upstream.NewClient("test", newConfig()).Get(ctx, server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+}
+
+func testCancelledRetry(t *testing.T) {
+	var calls int32
+	server := newServer(&calls, "", http.StatusServiceUnavailable)
+	defer server.Close()
+
+	cfg := newConfig()
+	cfg.MaxRetries = 100
+	timeout, cancel := context.WithTimeout(ctx, 300*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := upstream.NewClient("test", cfg).Get(timeout, server.URL)
+
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func testCircuitOpens(t *testing.T) {
+	var calls int32
+	server := newServer(&calls, "", http.StatusInternalServerError)
+	defer server.Close()
+
+	cfg := newConfig()
+	cfg.MaxRetries = 0
+	cfg.BreakerThreshold = 2
+	client := upstream.NewClient("test", cfg)
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(ctx, server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	_, err := client.Get(ctx, server.URL)
+
+	assert.ErrorIs(t, err, upstream.ErrCircuitOpen)
+	assert.Equal(t, int32(2), calls)
+	health := client.Health()
+	assert.Equal(t, "test", health.Name)
+	assert.Equal(t, upstream.StateOpen, health.State)
+	assert.NotNil(t, health.OpenedAt)
+}
+
+func testCircuitCloses(t *testing.T) {
+	var calls int32
+	server := newServer(&calls, "", http.StatusInternalServerError, http.StatusOK)
+	defer server.Close()
+
+	cfg := newConfig()
+	cfg.MaxRetries = 0
+	cfg.BreakerThreshold = 1
+	cfg.BreakerCooldown = 50 * time.Millisecond
+	client := upstream.NewClient("test", cfg)
+
+	resp, err := client.Get(ctx, server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, upstream.StateOpen, client.Health().State)
+
+	time.Sleep(60 * time.Millisecond)
+	assert.Equal(t, upstream.StateHalfOpen, client.Health().State)
+
+	resp, err = client.Get(ctx, server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, upstream.StateClosed, client.Health().State)
+}
+
+func testRateLimit(t *testing.T) {
+	var calls int32
+	server := newServer(&calls, "", http.StatusOK)
+	defer server.Close()
+
+	cfg := newConfig()
+	cfg.RatePerMinute = 600
+	cfg.Burst = 1
+	client := upstream.NewClient("test", cfg)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(ctx, server.URL)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	// the first request uses the burst, the next two wait 100ms each
+	assert.GreaterOrEqual(t, time.Since(start), 190*time.Millisecond)
+}
@@ -0,0 +1,66 @@
+package testing
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"wallet-manager/webhooks"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ctx = context.Background()
+
+func TestWebhooks(t *testing.T) {
+	t.Run("Should sign payload with HMAC-SHA256", testSign)
+	t.Run("Should send signed delivery", testSendSignedDelivery)
+	t.Run("Should return status code of rejected delivery", testSendRejectedDelivery)
+}
+
+func testSign(t *testing.T) {
+	body := []byte(`{"type":"transaction.created"}`)
+	signature := webhooks.Sign("secret", "1700000000", body)
+
+	// echo -n '1700000000.{"type":"transaction.created"}' | openssl dgst -sha256 -hmac secret
+	assert.Equal(t, "sha256=7662e27032362c2a508627d9e7f4acb3f8f25343672e533a99dd33388b359ca4", signature)
+	assert.True(t, webhooks.Verify("secret", "1700000000", body, signature))
+	assert.False(t, webhooks.Verify("other", "1700000000", body, signature))
+	assert.False(t, webhooks.Verify("secret", "1700000001", body, signature))
+}
+
+func testSendSignedDelivery(t *testing.T) {
+	var headers http.Header
+	var body []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header.Clone()
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer receiver.Close()
+
+	payload := []byte(`{"type":"price.updated"}`)
+	statusCode, err := webhooks.NewSender(receiver.Client()).Send(ctx, receiver.URL, "secret", "price.updated", 42, 1700000000, payload)
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, statusCode)
+	assert.Equal(t, payload, body)
+	assert.Equal(t, "price.updated", headers.Get(webhooks.EventHeader))
+	assert.Equal(t, "42", headers.Get(webhooks.DeliveryHeader))
+	assert.Equal(t, "1700000000", headers.Get(webhooks.TimestampHeader))
+	assert.True(t, webhooks.Verify("secret", headers.Get(webhooks.TimestampHeader), body, headers.Get(webhooks.SignatureHeader)))
+}
+
+func testSendRejectedDelivery(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	statusCode, err := webhooks.NewSender(receiver.Client()).Send(ctx, receiver.URL, "secret", "price.updated", 1, 1700000000, []byte(`{}`))
+
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, statusCode)
+}
@@ -0,0 +1,164 @@
+package upstream
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+	"wallet-manager/config"
+)
+
+const (
+	baseRetryDelay = 500 * time.Millisecond
+	maxRetryDelay  = 30 * time.Second
+)
+
+type Health struct {
+	Name     string     `json:"name"`
+	State    string     `json:"state"`
+	Failures int        `json:"failures"`
+	OpenedAt *time.Time `json:"opened_at,omitempty"`
+}
+
+// Client is meant to be shared by every call to one provider, so its rate
+// limit and circuit breaker see all of the traffic.
+type Client struct {
+	name       string
+	http       *http.Client
+	maxRetries int
+	limiter    *limiter
+	breaker    *breaker
+}
+
+func NewClient(name string, cfg *config.UpstreamConfig) *Client {
+	return &Client{
+		name:       name,
+		http:       &http.Client{Timeout: cfg.Timeout},
+		maxRetries: cfg.MaxRetries,
+		limiter:    newLimiter(cfg.RatePerMinute, cfg.Burst),
+		breaker:    newBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown),
+	}
+}
+
+func (c *Client) Name() string {
+	return c.name
+}
+
+func (c *Client) Health() Health {
+	health := c.breaker.health()
+	health.Name = c.name
+	return health
+}
+
+// Get requests url until it gets a response that is not worth retrying:
+// network errors, 429 and 5xx are retried with exponential backoff, or after
+// the delay asked by Retry-After. The last response is returned as is, so
+// callers still check its status.
+func (c *Client) Get(ctx context.Context, url string) (*http.Response, error) {
+	return c.send(ctx, http.MethodGet, url, "", nil)
+}
+
+// Post sends body to url, retried as Get is, so it is only meant for requests
+// that are safe to repeat, such as JSON-RPC reads.
+func (c *Client) Post(ctx context.Context, url string, contentType string, body []byte) (*http.Response, error) {
+	return c.send(ctx, http.MethodPost, url, contentType, body)
+}
+
+func (c *Client) send(ctx context.Context, method string, url string, contentType string, body []byte) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := c.try(ctx, method, url, contentType, body)
+		if err == nil && !retryable(resp.StatusCode) {
+			return resp, nil
+		}
+		if ctx.Err() != nil || err == ErrCircuitOpen || attempt >= c.maxRetries {
+			return resp, err
+		}
+
+		delay := backoff(attempt)
+		if err == nil {
+			if after, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+				delay = after
+			}
+			resp.Body.Close()
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			if err == nil {
+				err = fmt.Errorf("%s: %s, retry after %s exceeds the deadline", c.name, resp.Status, delay)
+			}
+			return nil, err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (c *Client) try(ctx context.Context, method string, url string, contentType string, body []byte) (*http.Response, error) {
+	if err := c.breaker.allow(); err != nil {
+		return nil, err
+	}
+	if err := c.limiter.wait(ctx); err != nil {
+		c.breaker.release()
+		return nil, err
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		c.breaker.release()
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := c.http.Do(req)
+	switch {
+	case err != nil && ctx.Err() != nil:
+		// the caller gave up, that says nothing about the upstream
+		c.breaker.release()
+	case err != nil || retryable(resp.StatusCode):
+		c.breaker.failure()
+	default:
+		c.breaker.success()
+	}
+	return resp, err
+}
+
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+func backoff(attempt int) time.Duration {
+	delay := baseRetryDelay << attempt
+	if delay > maxRetryDelay || delay <= 0 {
+		delay = maxRetryDelay
+	}
+	// full jitter keeps clients that failed together from retrying together
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// retryAfter parses Retry-After, given either in seconds or as an HTTP date.
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(0, time.Until(at)), true
+	}
+	return 0, false
+}
@@ -0,0 +1,128 @@
+package validators
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"time"
+	"wallet-manager/utils"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/shopspring/decimal"
+)
+
+type AssetChecker func(name string) (bool, error)
+
+func Register(isKnownAsset AssetChecker) error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("unexpected validator engine")
+	}
+
+	v.RegisterTagNameFunc(jsonFieldName)
+	v.RegisterCustomTypeFunc(decimalValue, decimal.Decimal{})
+
+	validations := map[string]validator.Func{
+		"decimal_gt0":  decimalGreaterThanZero,
+		"decimal_gte0": decimalGreaterThanOrEqualZero,
+		"rfc3339":      rfc3339,
+		"known_asset":  knownAsset(isKnownAsset),
+	}
+	for tag, fn := range validations {
+		if err := v.RegisterValidation(tag, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func FieldErrors(err error) map[string]string {
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return nil
+	}
+
+	fields := make(map[string]string, len(validationErrors))
+	for _, fe := range validationErrors {
+		fields[fe.Field()] = message(fe)
+	}
+	return fields
+}
+
+func message(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required", "required_if", "required_unless":
+		return "is required"
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", strings.ReplaceAll(fe.Param(), " ", ", "))
+	case "max":
+		return fmt.Sprintf("must be at most %s characters", fe.Param())
+	case "min":
+		return fmt.Sprintf("must have at least %s item(s)", fe.Param())
+	case "url":
+		return "must be a valid URL"
+	case "decimal_gt0":
+		return "must be a number greater than zero"
+	case "decimal_gte0":
+		return "must be a number greater than or equal to zero"
+	case "rfc3339":
+		return "must be a date in RFC3339 format (e.g. 2024-01-02T15:04:05Z)"
+	case "known_asset":
+		return "is not a known asset identifier"
+	default:
+		return fmt.Sprintf("failed on the '%s' rule", fe.Tag())
+	}
+}
+
+func jsonFieldName(field reflect.StructField) string {
+	name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	if name == "-" || name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// decimalValue exposes decimal.Decimal to the validator as its string representation,
+// otherwise it would be treated as a nested struct.
+func decimalValue(field reflect.Value) interface{} {
+	if d, ok := field.Interface().(decimal.Decimal); ok {
+		return d.String()
+	}
+	return nil
+}
+
+func parseDecimal(fl validator.FieldLevel) (decimal.Decimal, bool) {
+	d, err := decimal.NewFromString(fl.Field().String())
+	return d, err == nil
+}
+
+func decimalGreaterThanZero(fl validator.FieldLevel) bool {
+	d, ok := parseDecimal(fl)
+	return ok && d.IsPositive()
+}
+
+func decimalGreaterThanOrEqualZero(fl validator.FieldLevel) bool {
+	d, ok := parseDecimal(fl)
+	return ok && !d.IsNegative()
+}
+
+func rfc3339(fl validator.FieldLevel) bool {
+	_, err := time.Parse(utils.TimeFormat, fl.Field().String())
+	return err == nil
+}
+
+// knownAsset fails open when the registry cannot be read; services resolve the
+// asset again and reject unknown ones.
+func knownAsset(isKnownAsset AssetChecker) validator.Func {
+	return func(fl validator.FieldLevel) bool {
+		known, err := isKnownAsset(strings.ToLower(fl.Field().String()))
+		if err != nil {
+			log.Printf("could not check asset %q: %v", fl.Field().String(), err)
+			return true
+		}
+		return known
+	}
+}